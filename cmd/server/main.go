@@ -49,15 +49,15 @@ func main() {
 	// 配置CORS中间件
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // 允许所有来源，生产环境中应该限制特定域名
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "X-Request-ID", "X-API-Key"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// 设置API路由
-	if err := routes.SetupRoutes(router, mongoDB); err != nil {
+	if err := routes.SetupRoutes(router, mongoDB, cfg); err != nil {
 		log.Fatalf("设置路由失败: %v", err)
 	}
 
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -23,13 +25,70 @@ type Config struct {
 		MaxPoolSize uint64        `mapstructure:"max_pool_size"` // 最大连接池大小
 	} `mapstructure:"mongodb"`
 
+	// Session 包含持久会话配置
+	Session struct {
+		SecretKey     string `mapstructure:"secret_key"`     // 旧版本会话中数据库凭据的加密密钥，只用于迁移这些会话的凭据
+		DefaultTTL    int    `mapstructure:"default_ttl"`    // 默认会话有效期(秒)
+		MinTTL        int    `mapstructure:"min_ttl"`        // 客户端可以请求的最短有效期(秒)
+		MaxTTL        int    `mapstructure:"max_ttl"`        // 客户端可以请求的最长有效期(秒)
+		ExpiryWarning int    `mapstructure:"expiry_warning"` // 过期前多久发出预警事件(秒)
+	} `mapstructure:"session"`
+
+	// Pool 包含外部数据源连接池配置
+	Pool struct {
+		MaxConnections int `mapstructure:"max_connections"` // 所有外部数据源连接池的连接数上限，0表示不限制
+		MaxPerTarget   int `mapstructure:"max_per_target"`  // 同一数据库服务器或文件的连接数上限，0表示不限制
+		PoolSize       int `mapstructure:"pool_size"`       // 单个连接池的最大连接数
+		IdleTimeout    int `mapstructure:"idle_timeout"`    // 连接池不再使用后保留的时间(秒)
+	} `mapstructure:"pool"`
+
+	// Secrets 包含数据源凭据的加密配置
+	Secrets struct {
+		ActiveKey string            `mapstructure:"active_key"` // 新凭据使用的密钥ID，只有一个密钥时可以不设置
+		Keys      map[string]string `mapstructure:"keys"`       // 密钥ID -> 密钥，轮换后保留旧密钥直到重新加密完成
+	} `mapstructure:"secrets"`
+
 	// JWT 包含JWT认证配置
 	JWT struct {
-		Secret     string        `mapstructure:"secret"`     // JWT签名密钥
-		Expiration time.Duration `mapstructure:"expiration"` // 令牌过期时间(小时)
+		Secret            string        `mapstructure:"secret"`             // JWT签名密钥
+		Expiration        time.Duration `mapstructure:"expiration"`         // 令牌过期时间(小时)
+		RefreshExpiration int           `mapstructure:"refresh_expiration"` // 刷新令牌过期时间(小时)
 	} `mapstructure:"jwt"`
+
+	// Auth 包含用户账号和接口认证配置
+	Auth struct {
+		InitialUsername string   `mapstructure:"initial_username"` // 还没有任何用户时创建的初始用户
+		InitialPassword string   `mapstructure:"initial_password"` // 初始用户的密码，为空时不创建
+		PublicRoutes    []string `mapstructure:"public_routes"`    // 不需要登录的路由，格式为"方法 路径"
+	} `mapstructure:"auth"`
+
+	// Limits 包含请求频率和配额的默认值，用户和API密钥可以单独设置
+	Limits struct {
+		User   LimitsConfig `mapstructure:"user"`    // 用户的默认限额，也按客户端IP用于未登录的请求
+		APIKey LimitsConfig `mapstructure:"api_key"` // API密钥的默认限额，通过密钥的请求同时计入用户的用量
+	} `mapstructure:"limits"`
+}
+
+// LimitsConfig 一组限额，0表示不限制
+type LimitsConfig struct {
+	RequestsPerMinute int   `mapstructure:"requests_per_minute"`  // 每分钟请求数
+	ConcurrentImports int   `mapstructure:"concurrent_imports"`   // 同时进行的导入和复制数
+	UploadBytesPerDay int64 `mapstructure:"upload_bytes_per_day"` // 每天(UTC)上传的请求体字节数
 }
 
+// 通过环境变量提供凭据加密密钥，避免将密钥写入配置文件
+// 设置后该密钥加入密钥列表并作为当前密钥，ID未设置时为"env"
+const (
+	SecretsKeyEnv   = "MINDS_SECRETS_KEY"
+	SecretsKeyIDEnv = "MINDS_SECRETS_KEY_ID"
+)
+
+// 通过环境变量提供JWT签名密钥和初始用户的密码，设置后覆盖配置文件中的值
+const (
+	JWTSecretEnv       = "MINDS_JWT_SECRET"
+	InitialPasswordEnv = "MINDS_INITIAL_PASSWORD"
+)
+
 // Load 从配置文件加载配置
 func Load() (*Config, error) {
 	// 设置默认值
@@ -56,10 +115,41 @@ func Load() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("解析配置失败: %w", err)
 	}
+	applySecretsEnv(&config)
+	applyAuthEnv(&config)
 
 	return &config, nil
 }
 
+// applySecretsEnv 将环境变量中的凭据加密密钥设为当前密钥
+// viper将keys中的密钥ID转为小写，密钥ID因此不区分大小写
+func applySecretsEnv(config *Config) {
+	config.Secrets.ActiveKey = strings.ToLower(config.Secrets.ActiveKey)
+	key := os.Getenv(SecretsKeyEnv)
+	if key == "" {
+		return
+	}
+	id := strings.ToLower(os.Getenv(SecretsKeyIDEnv))
+	if id == "" {
+		id = "env"
+	}
+	if config.Secrets.Keys == nil {
+		config.Secrets.Keys = make(map[string]string)
+	}
+	config.Secrets.Keys[id] = key
+	config.Secrets.ActiveKey = id
+}
+
+// applyAuthEnv 用环境变量覆盖JWT签名密钥和初始用户的密码
+func applyAuthEnv(config *Config) {
+	if secret := os.Getenv(JWTSecretEnv); secret != "" {
+		config.JWT.Secret = secret
+	}
+	if password := os.Getenv(InitialPasswordEnv); password != "" {
+		config.Auth.InitialPassword = password
+	}
+}
+
 // setDefaults 设置配置默认值
 func setDefaults() {
 	// 服务器默认设置
@@ -72,6 +162,30 @@ func setDefaults() {
 	viper.SetDefault("mongodb.timeout", 20) // 20秒
 	viper.SetDefault("mongodb.max_pool_size", 100)
 
+	// 会话默认设置
+	viper.SetDefault("session.default_ttl", 1800)  // 30分钟
+	viper.SetDefault("session.min_ttl", 60)        // 1分钟
+	viper.SetDefault("session.max_ttl", 28800)     // 8小时
+	viper.SetDefault("session.expiry_warning", 60) // 过期前1分钟
+
+	// 连接池默认设置
+	viper.SetDefault("pool.max_connections", 200)
+	viper.SetDefault("pool.max_per_target", 50)
+	viper.SetDefault("pool.pool_size", 10)
+	viper.SetDefault("pool.idle_timeout", 300) // 5分钟
+
 	// JWT默认设置
-	viper.SetDefault("jwt.expiration", 24) // 24小时
+	viper.SetDefault("jwt.expiration", 24)          // 24小时
+	viper.SetDefault("jwt.refresh_expiration", 168) // 7天
+
+	// 认证默认设置
+	viper.SetDefault("auth.initial_username", "admin")
+
+	// 限额默认设置，API密钥默认只受所属用户的限额约束
+	viper.SetDefault("limits.user.requests_per_minute", 600)
+	viper.SetDefault("limits.user.concurrent_imports", 2)
+	viper.SetDefault("limits.user.upload_bytes_per_day", 1<<30) // 1GB
+	viper.SetDefault("limits.api_key.requests_per_minute", 0)
+	viper.SetDefault("limits.api_key.concurrent_imports", 0)
+	viper.SetDefault("limits.api_key.upload_bytes_per_day", 0)
 }
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"minds_iolite_backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 检查当前用户能否连接各类数据源的权限服务
+var accessControl *rbac.Service
+
+// InitAccessControl 设置检查数据源权限的服务
+func InitAccessControl(access *rbac.Service) {
+	accessControl = access
+}
+
+// checkDataSource 检查当前用户是否有权连接该类型的数据源，没有权限时已写入响应
+func checkDataSource(c *gin.Context, dataSourceType string) bool {
+	return accessControl.Check(c, rbac.DataSourcePermission(dataSourceType))
+}
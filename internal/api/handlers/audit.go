@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"minds_iolite_backend/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 记录导入和复制数据的审计日志
+var changeLog audit.Log
+
+// InitChangeLog 设置记录导入和复制数据的审计日志
+func InitChangeLog(l audit.Log) {
+	changeLog = l
+}
+
+// recordImport 记录一次导入或复制，target为写入的集合或表名，未设置审计日志时不记录
+func recordImport(c *gin.Context, target string, details map[string]interface{}) {
+	if changeLog == nil {
+		return
+	}
+	audit.Record(c, changeLog, &audit.Entry{
+		Action:  audit.ActionImport,
+		Model:   target,
+		Details: details,
+	})
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers/mongodb"
+	"minds_iolite_backend/internal/datasource/providers/mysql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 浏览接口只读取元信息，不写入config.json，供前端按需逐级展开数据库树
+
+// browseTimeout 单次浏览请求的超时时间
+const browseTimeout = 30 * time.Second
+
+// ListMongoDatabases 列出MongoDB服务器上可访问的数据库
+func (h *DataSourceHandler) ListMongoDatabases(c *gin.Context) {
+	_, connector, ok := h.openMongoConnector(c)
+	if !ok {
+		return
+	}
+	defer connector.Close()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), browseTimeout)
+	defer cancel()
+
+	databases, err := connector.ListDatabases(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"databases": databases,
+	})
+}
+
+// ListMongoCollections 列出MongoDB数据库中的集合，只返回类型和估算文档数
+func (h *DataSourceHandler) ListMongoCollections(c *gin.Context) {
+	request, connector, ok := h.openMongoConnector(c)
+	if !ok {
+		return
+	}
+	defer connector.Close()
+
+	dbName := request.database()
+	if dbName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "缺少必要参数: 数据库名",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), browseTimeout)
+	defer cancel()
+
+	collections, err := connector.ListCollections(ctx, dbName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"database":    dbName,
+		"collections": collections,
+	})
+}
+
+// DescribeMongoCollection 采样推断单个集合的结构并返回样本数据
+func (h *DataSourceHandler) DescribeMongoCollection(c *gin.Context) {
+	request, connector, ok := h.openMongoConnector(c)
+	if !ok {
+		return
+	}
+	defer connector.Close()
+
+	dbName := request.database()
+	if dbName == "" || request.Collection == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "缺少必要参数: 数据库名和集合名",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), browseTimeout)
+	defer cancel()
+
+	collInfo, err := connector.ExtractCollectionInfo(ctx, dbName, request.Collection, request.extractOptions())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"database":   dbName,
+		"collection": request.Collection,
+		"info":       collInfo,
+	})
+}
+
+// openMongoConnector 解析请求并连接MongoDB，失败时已写入响应
+func (h *DataSourceHandler) openMongoConnector(c *gin.Context) (*mongoConnectRequest, *mongodb.MongoDBConnector, bool) {
+	request, err := bindMongoConnectRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的请求格式: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	connector, err := mongodb.NewMongoDBConnector(request.uri())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "连接MongoDB失败: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+	return request, connector, true
+}
+
+// ListMySQLDatabases 列出MySQL服务器上可访问的数据库
+func (h *DataSourceHandler) ListMySQLDatabases(c *gin.Context) {
+	_, connector, ok := h.openMySQLConnector(c)
+	if !ok {
+		return
+	}
+	defer connector.Close()
+
+	databases, err := connector.ListDatabases()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"databases": databases,
+	})
+}
+
+// ListMySQLTables 列出MySQL数据库中的表和视图，只读取information_schema
+func (h *DataSourceHandler) ListMySQLTables(c *gin.Context) {
+	request, connector, ok := h.openMySQLConnector(c)
+	if !ok {
+		return
+	}
+	defer connector.Close()
+
+	if request.Database == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "缺少必要参数: 数据库名",
+		})
+		return
+	}
+
+	tables, err := connector.ListTables(request.Database)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"database": request.Database,
+		"tables":   tables,
+	})
+}
+
+// DescribeMySQLTable 获取单个表的字段类型和样本数据
+func (h *DataSourceHandler) DescribeMySQLTable(c *gin.Context) {
+	request, connector, ok := h.openMySQLConnector(c)
+	if !ok {
+		return
+	}
+	defer connector.Close()
+
+	if request.Database == "" || request.Table == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "缺少必要参数: 数据库名和表名",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), browseTimeout)
+	defer cancel()
+
+	tableInfo, err := connector.ExtractTableInfo(ctx, request.Database, request.Table)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"database": request.Database,
+		"table":    request.Table,
+		"info":     tableInfo,
+	})
+}
+
+// openMySQLConnector 解析请求并连接MySQL，失败时已写入响应
+// 连接时不指定默认数据库，以便浏览服务器上的所有库
+func (h *DataSourceHandler) openMySQLConnector(c *gin.Context) (*mysqlConnectRequest, *mysql.MySQLConnector, bool) {
+	request, err := bindMySQLRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的请求参数: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	connector, err := mysql.NewReadOnlyMySQLConnector(request.Host, request.Port, request.Username, request.Password, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "连接MySQL失败: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+	return request, connector, true
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/providers/mongodb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mongoConnectRequest MongoDB连接请求
+// 支持两种请求格式：1. ConnectionURI + Database, 2. host + port + username + password + database
+type mongoConnectRequest struct {
+	// 首选格式（原有格式）
+	ConnectionURI string
+	Database      string
+
+	// 备用格式（与MySQL保持一致）
+	Host     string
+	Port     interface{} // 支持字符串或数字
+	Username string
+	Password string
+	DbName   string
+
+	// 独立字段格式的附加连接参数
+	AuthSource    string
+	AuthMechanism string
+	ReplicaSet    string
+	TLS           *bool
+	SRV           bool
+
+	// 浏览单个集合时的集合名
+	Collection string
+
+	// 结构推断采样参数
+	SampleSize int
+	UseSample  bool
+
+	// 是否返回索引、校验规则、集合类型和存储统计
+	IncludeDetails bool
+
+	// 集合名过滤、并发数和单集合超时
+	Introspect providers.IntrospectOptions
+}
+
+// bindMongoConnectRequest 解析MongoDB连接请求，字段名不区分大小写
+func bindMongoConnectRequest(c *gin.Context) (*mongoConnectRequest, error) {
+	var rawData map[string]interface{}
+	if err := c.ShouldBindJSON(&rawData); err != nil {
+		return nil, err
+	}
+
+	request := &mongoConnectRequest{}
+	for key, value := range rawData {
+		lowerKey := strings.ToLower(key)
+		switch lowerKey {
+		case "connectionuri", "connection_uri", "connection":
+			if strValue, ok := value.(string); ok {
+				request.ConnectionURI = strValue
+			}
+		case "database", "db", "dbname":
+			if strValue, ok := value.(string); ok {
+				if request.Database == "" { // 优先使用Database字段
+					request.Database = strValue
+				}
+				if request.DbName == "" { // 备用使用database字段
+					request.DbName = strValue
+				}
+			}
+		case "host":
+			if strValue, ok := value.(string); ok {
+				request.Host = strValue
+			}
+		case "port":
+			request.Port = value // 可以是字符串或数字
+		case "username", "user":
+			if strValue, ok := value.(string); ok {
+				request.Username = strValue
+			}
+		case "password", "pwd", "passwd":
+			if strValue, ok := value.(string); ok {
+				request.Password = strValue
+			}
+		case "authsource", "auth_source":
+			if strValue, ok := value.(string); ok {
+				request.AuthSource = strValue
+			}
+		case "authmechanism", "auth_mechanism":
+			if strValue, ok := value.(string); ok {
+				request.AuthMechanism = strValue
+			}
+		case "replicaset", "replica_set":
+			if strValue, ok := value.(string); ok {
+				request.ReplicaSet = strValue
+			}
+		case "tls", "ssl":
+			if boolValue, ok := value.(bool); ok {
+				request.TLS = &boolValue
+			}
+		case "srv":
+			if boolValue, ok := value.(bool); ok {
+				request.SRV = boolValue
+			}
+		case "collection", "collname", "coll_name":
+			if strValue, ok := value.(string); ok {
+				request.Collection = strValue
+			}
+		case "samplesize", "sample_size":
+			if numValue, ok := value.(float64); ok {
+				request.SampleSize = int(numValue)
+			}
+		case "usesample", "use_sample":
+			if boolValue, ok := value.(bool); ok {
+				request.UseSample = boolValue
+			}
+		case "includedetails", "include_details", "introspect":
+			if boolValue, ok := value.(bool); ok {
+				request.IncludeDetails = boolValue
+			}
+		default:
+			if err := parseIntrospectField(lowerKey, value, &request.Introspect); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return request, nil
+}
+
+// database 返回请求中的数据库名
+func (r *mongoConnectRequest) database() string {
+	if r.Database != "" {
+		return r.Database
+	}
+	return r.DbName
+}
+
+// uri 返回连接字符串，未提供URI时从独立字段构建
+func (r *mongoConnectRequest) uri() string {
+	if r.ConnectionURI != "" {
+		return r.ConnectionURI
+	}
+	// 设置默认连接URI（如果都未提供）
+	if r.Host == "" {
+		return "mongodb://localhost:27017"
+	}
+
+	// 处理端口值（可能是字符串或数字）
+	portStr := ""
+	switch v := r.Port.(type) {
+	case string:
+		portStr = v
+	case float64:
+		portStr = fmt.Sprintf("%d", int(v))
+	case int:
+		portStr = fmt.Sprintf("%d", v)
+	}
+
+	// 用户名和密码会被转义，避免特殊字符破坏连接字符串
+	return mongodb.BuildURI(mongodb.URIParams{
+		Host:          r.Host,
+		Port:          portStr,
+		Username:      r.Username,
+		Password:      r.Password,
+		AuthSource:    r.AuthSource,
+		AuthMechanism: r.AuthMechanism,
+		ReplicaSet:    r.ReplicaSet,
+		TLS:           r.TLS,
+		SRV:           r.SRV,
+	})
+}
+
+// extractOptions 返回请求中的结构推断选项
+func (r *mongoConnectRequest) extractOptions() mongodb.ExtractOptions {
+	return mongodb.ExtractOptions{
+		Schema: mongodb.SchemaOptions{
+			SampleSize: r.SampleSize,
+			UseSample:  r.UseSample,
+		},
+		IncludeDetails: r.IncludeDetails,
+		Introspect:     r.Introspect,
+	}
+}
+
+// mysqlConnectRequest MySQL连接请求，端口支持字符串或数字
+type mysqlConnectRequest struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Database string
+	Table    string
+
+	// 表名过滤、并发数和单表超时
+	Introspect providers.IntrospectOptions
+}
+
+// bindMySQLRequest 解析MySQL连接请求，字段名不区分大小写，数据库名由调用方按需检查
+func bindMySQLRequest(c *gin.Context) (*mysqlConnectRequest, error) {
+	var rawData map[string]interface{}
+	if err := c.ShouldBindJSON(&rawData); err != nil {
+		return nil, err
+	}
+
+	request := &mysqlConnectRequest{Port: 3306}
+	for key, value := range rawData {
+		strValue, _ := value.(string)
+		lowerKey := strings.ToLower(key)
+		switch lowerKey {
+		case "host":
+			request.Host = strValue
+		case "port":
+			switch v := value.(type) {
+			case float64:
+				request.Port = int(v)
+			case string:
+				port, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("无效的端口: %s", v)
+				}
+				request.Port = port
+			}
+		case "username", "user":
+			request.Username = strValue
+		case "password", "pwd", "passwd":
+			request.Password = strValue
+		case "database", "db", "dbname":
+			request.Database = strValue
+		case "table", "tablename", "table_name":
+			request.Table = strValue
+		default:
+			if err := parseIntrospectField(lowerKey, value, &request.Introspect); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if request.Host == "" {
+		return nil, fmt.Errorf("缺少必要参数: host")
+	}
+	if request.Username == "" {
+		return nil, fmt.Errorf("缺少必要参数: username")
+	}
+	return request, nil
+}
+
+// introspectRequest 批量结构推断参数，用于结构体绑定的请求
+type introspectRequest struct {
+	Include      []string `json:"include"`      // 只推断匹配任一模式的表/集合
+	Exclude      []string `json:"exclude"`      // 跳过匹配任一模式的表/集合
+	Concurrency  int      `json:"concurrency"`  // 并发数
+	TableTimeout int      `json:"tableTimeout"` // 单个表/集合的超时秒数
+}
+
+// options 转换为推断选项
+func (r introspectRequest) options() providers.IntrospectOptions {
+	return providers.IntrospectOptions{
+		Include:      r.Include,
+		Exclude:      r.Exclude,
+		Concurrency:  r.Concurrency,
+		TableTimeout: time.Duration(r.TableTimeout) * time.Second,
+	}
+}
+
+// parseIntrospectField 解析批量结构推断参数，key为小写字段名
+// include/exclude支持字符串数组或逗号分隔的字符串，tableTimeout单位为秒
+func parseIntrospectField(key string, value interface{}, opts *providers.IntrospectOptions) error {
+	switch key {
+	case "include":
+		opts.Include = stringList(value)
+	case "exclude":
+		opts.Exclude = stringList(value)
+	case "concurrency":
+		if numValue, ok := value.(float64); ok {
+			opts.Concurrency = int(numValue)
+		}
+	case "tabletimeout", "table_timeout":
+		if numValue, ok := value.(float64); ok {
+			opts.TableTimeout = time.Duration(numValue * float64(time.Second))
+		}
+	}
+	return opts.Validate()
+}
+
+// stringList 将字符串数组或逗号分隔的字符串转换为字符串切片
+func stringList(value interface{}) []string {
+	var result []string
+	switch v := value.(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok && str != "" {
+				result = append(result, str)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/services/datacopy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// copyRequest 数据复制请求
+type copyRequest struct {
+	Source struct {
+		Type   string           `json:"type"`   // 源数据源类型：csv/sqlite/mysql/mongodb
+		Config providers.Config `json:"config"` // 源连接配置
+		Entity string           `json:"entity"` // 源表、集合或文件名
+	} `json:"source"`
+	Sink struct {
+		Type   string           `json:"type"`   // 目标类型：mongodb/mysql/sqlite
+		Config providers.Config `json:"config"` // 目标连接配置
+		Target string           `json:"target"` // 目标表或集合名，为空时与源同名
+	} `json:"sink"`
+	Mode      string   `json:"mode"`      // replace/append/upsert，默认append
+	BatchSize int      `json:"batchSize"` // 每批写入的行数
+	DryRun    bool     `json:"dryRun"`    // 只预览，不写入目标
+	KeyFields []string `json:"keyFields"` // upsert使用的主键字段
+}
+
+// Copy 从任意已注册的数据源读取实体并写入MongoDB、MySQL或SQLite目标
+// 目标表按推断结构自动创建，嵌套文档写入SQL目标时展开为多列
+func (h *ProviderHandler) Copy(c *gin.Context) {
+	var request copyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的请求参数: " + err.Error(),
+		})
+		return
+	}
+	mode, err := datacopy.ParseMode(request.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if request.Source.Entity == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   providers.ErrEntityRequired.Error(),
+		})
+		return
+	}
+	if request.Source.Config == nil {
+		request.Source.Config = providers.Config{}
+	}
+	if request.Sink.Config == nil {
+		request.Sink.Config = providers.Config{}
+	}
+
+	// 读取源和写入目标都需要连接对应类型数据源的权限
+	if !checkDataSource(c, request.Source.Type) || !checkDataSource(c, request.Sink.Type) {
+		return
+	}
+
+	provider, err := providers.Get(request.Source.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 复制可能耗时较长，只受客户端连接的生命周期约束
+	ctx := c.Request.Context()
+	source, err := provider.Connect(ctx, request.Source.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "连接数据源失败: " + err.Error(),
+		})
+		return
+	}
+	defer source.Close()
+
+	sink, err := datacopy.NewSink(request.Sink.Type, request.Sink.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "连接目标失败: " + err.Error(),
+		})
+		return
+	}
+	defer sink.Close()
+
+	result, err := datacopy.Copy(ctx, source, request.Source.Entity, sink, request.Sink.Target, datacopy.Options{
+		Mode:      mode,
+		BatchSize: request.BatchSize,
+		DryRun:    request.DryRun,
+		KeyFields: request.KeyFields,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, providers.ErrEntityRequired) || errors.Is(err, datacopy.ErrNoKeyFields) ||
+			errors.Is(err, datacopy.ErrNoUniqueKey) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志，预览不写入目标
+	if !result.DryRun {
+		recordImport(c, result.Target, map[string]interface{}{
+			"sourceType":  request.Source.Type,
+			"source":      result.Source,
+			"sinkType":    result.Sink,
+			"mode":        result.Mode,
+			"rowsWritten": result.RowsWritten,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
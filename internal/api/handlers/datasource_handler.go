@@ -1,18 +1,19 @@
 package handlers
 
 import (
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 
+	"minds_iolite_backend/internal/datasource/pool"
 	"minds_iolite_backend/internal/datasource/providers/csv"
 	"minds_iolite_backend/internal/datasource/providers/mongodb"
+	"minds_iolite_backend/internal/datasource/providers/mysql"
 	"minds_iolite_backend/internal/datasource/providers/sqlite"
 	"minds_iolite_backend/internal/models/datasource"
 	"minds_iolite_backend/internal/services/datastorage"
@@ -24,29 +25,6 @@ import (
 type DataSourceHandler struct {
 }
 
-// EncryptPassword 使用XOR加密密码并返回十六进制字符串
-func EncryptPassword(password, key string) string {
-	encrypted := make([]byte, len(password))
-	for i := 0; i < len(password); i++ {
-		encrypted[i] = password[i] ^ key[i%len(key)]
-	}
-	return hex.EncodeToString(encrypted)
-}
-
-// DecryptPassword 解密密码
-func DecryptPassword(cipherHex, key string) (string, error) {
-	cipher, err := hex.DecodeString(cipherHex)
-	if err != nil {
-		return "", err
-	}
-
-	decrypted := make([]byte, len(cipher))
-	for i := 0; i < len(cipher); i++ {
-		decrypted[i] = cipher[i] ^ key[i%len(key)]
-	}
-	return string(decrypted), nil
-}
-
 // NewDataSourceHandler 创建新的数据源处理器
 func NewDataSourceHandler() *DataSourceHandler {
 	return &DataSourceHandler{}
@@ -488,6 +466,16 @@ func (h *DataSourceHandler) ImportCSVToMongoDB(c *gin.Context) {
 		return
 	}
 
+	// 记录审计日志
+	for name := range connInfo.Collections {
+		recordImport(c, name, map[string]interface{}{
+			"sourceType": "csv",
+			"filePath":   filePath,
+			"database":   connInfo.Database,
+			"rows":       model.TotalRecords,
+		})
+	}
+
 	// 获取配置信息的保存路径 - 修改为保存在可执行文件所在目录的data子目录
 	// 获取当前工作目录
 	wd, err := os.Getwd()
@@ -522,23 +510,8 @@ func (h *DataSourceHandler) ImportCSVToMongoDB(c *gin.Context) {
 
 // ConnectToMongoDB 处理MongoDB连接请求
 func (h *DataSourceHandler) ConnectToMongoDB(c *gin.Context) {
-	// 支持两种请求格式：1. ConnectionURI + Database, 2. host + port + username + password + database
-	var request struct {
-		// 首选格式（原有格式）
-		ConnectionURI string `json:"ConnectionURI" binding:"omitempty"`
-		Database      string `json:"Database" binding:"omitempty"`
-
-		// 备用格式（与MySQL保持一致）
-		Host     string      `json:"host" binding:"omitempty"`
-		Port     interface{} `json:"port" binding:"omitempty"` // 支持字符串或数字
-		Username string      `json:"username" binding:"omitempty"`
-		Password string      `json:"password" binding:"omitempty"`
-		DbName   string      `json:"database" binding:"omitempty"`
-	}
-
-	// 支持字段大小写不敏感
-	var rawData map[string]interface{}
-	if err := c.ShouldBindJSON(&rawData); err != nil {
+	request, err := bindMongoConnectRequest(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "无效的请求格式: " + err.Error(),
@@ -546,45 +519,8 @@ func (h *DataSourceHandler) ConnectToMongoDB(c *gin.Context) {
 		return
 	}
 
-	// 尝试从原始数据中提取关键字段（不区分大小写）
-	for key, value := range rawData {
-		lowerKey := strings.ToLower(key)
-		switch lowerKey {
-		case "connectionuri", "connection_uri", "connection":
-			if strValue, ok := value.(string); ok {
-				request.ConnectionURI = strValue
-			}
-		case "database", "db", "dbname":
-			if strValue, ok := value.(string); ok {
-				if request.Database == "" { // 优先使用Database字段
-					request.Database = strValue
-				}
-				if request.DbName == "" { // 备用使用database字段
-					request.DbName = strValue
-				}
-			}
-		case "host":
-			if strValue, ok := value.(string); ok {
-				request.Host = strValue
-			}
-		case "port":
-			request.Port = value // 可以是字符串或数字
-		case "username", "user":
-			if strValue, ok := value.(string); ok {
-				request.Username = strValue
-			}
-		case "password", "pwd", "passwd":
-			if strValue, ok := value.(string); ok {
-				request.Password = strValue
-			}
-		}
-	}
-
 	// 确保有数据库名
-	dbName := request.Database
-	if dbName == "" {
-		dbName = request.DbName
-	}
+	dbName := request.database()
 	if dbName == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -592,44 +528,7 @@ func (h *DataSourceHandler) ConnectToMongoDB(c *gin.Context) {
 		})
 		return
 	}
-
-	// 构建连接URI
-	uri := request.ConnectionURI
-	if uri == "" && request.Host != "" {
-		// 从独立字段构建连接字符串
-		portStr := "27017" // 默认端口
-
-		// 处理端口值（可能是字符串或数字）
-		if request.Port != nil {
-			switch v := request.Port.(type) {
-			case string:
-				portStr = v
-			case float64:
-				portStr = fmt.Sprintf("%d", int(v))
-			case int:
-				portStr = fmt.Sprintf("%d", v)
-			}
-		}
-
-		// 构建基本连接字符串
-		uri = fmt.Sprintf("mongodb://%s:%s", request.Host, portStr)
-
-		// 如果有用户名和密码，添加到URI
-		if request.Username != "" {
-			if request.Password != "" {
-				uri = fmt.Sprintf("mongodb://%s:%s@%s:%s",
-					request.Username, request.Password, request.Host, portStr)
-			} else {
-				uri = fmt.Sprintf("mongodb://%s@%s:%s",
-					request.Username, request.Host, portStr)
-			}
-		}
-	}
-
-	// 设置默认连接URI（如果都未提供）
-	if uri == "" {
-		uri = "mongodb://localhost:27017"
-	}
+	uri := request.uri()
 
 	// 创建MongoDB连接器
 	connector, err := mongodb.NewMongoDBConnector(uri)
@@ -643,7 +542,7 @@ func (h *DataSourceHandler) ConnectToMongoDB(c *gin.Context) {
 	defer connector.Close()
 
 	// 提取连接信息
-	connInfo, err := connector.ExtractConnectionInfo(dbName)
+	connInfo, err := connector.ExtractConnectionInfo(c.Request.Context(), dbName, request.extractOptions())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -687,36 +586,14 @@ func (h *DataSourceHandler) ConnectToMongoDB(c *gin.Context) {
 }
 
 // ConnectToMySQL 处理MySQL连接请求
+// 支持 include/exclude 表名模式、concurrency 并发数和 tableTimeout 单表超时秒数，
+// 单个表失败时只在该表的error中记录原因
 func (h *DataSourceHandler) ConnectToMySQL(c *gin.Context) {
-	var request struct {
-		Host     string `json:"host" binding:"required"`
-		Port     int    `json:"port" binding:"required"`
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password"`
-		Database string `json:"database" binding:"required"`
+	request, err := bindMySQLRequest(c)
+	if err == nil && request.Database == "" {
+		err = fmt.Errorf("缺少必要参数: database")
 	}
-
-	if err := c.ShouldBindJSON(&request); err != nil {
-		// 检查是否可能是端口类型错误
-		var rawData map[string]interface{}
-		if bindErr := c.ShouldBindJSON(&rawData); bindErr == nil {
-			// 尝试从原始数据中获取端口值并转换
-			if portVal, ok := rawData["port"]; ok {
-				// 如果是字符串类型，尝试转换为整数
-				if portStr, isStr := portVal.(string); isStr {
-					if portNum, err := strconv.Atoi(portStr); err == nil {
-						request.Port = portNum
-						request.Host = rawData["host"].(string)
-						request.Username = rawData["username"].(string)
-						request.Password = rawData["password"].(string)
-						request.Database = rawData["database"].(string)
-						// 修正了端口，继续处理
-						goto ProcessRequest
-					}
-				}
-			}
-		}
-
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "无效的请求参数: " + err.Error(),
@@ -724,15 +601,21 @@ func (h *DataSourceHandler) ConnectToMySQL(c *gin.Context) {
 		return
 	}
 
-ProcessRequest:
-	// 创建MySQL存储服务
-	storage, err := datastorage.NewMySQLStorage(
+	// 创建只读MySQL连接器
+	connector, err := mysql.NewReadOnlyMySQLConnector(
 		request.Host,
 		request.Port,
 		request.Username,
 		request.Password,
 		request.Database,
 	)
+	if errors.Is(err, pool.ErrLimitReached) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -740,10 +623,10 @@ ProcessRequest:
 		})
 		return
 	}
-	defer storage.Close()
+	defer connector.Close()
 
 	// 获取所有表的连接信息
-	connInfo, err := storage.GenerateConnectionInfo()
+	connInfo, err := connector.ExtractConnectionInfo(c.Request.Context(), request.Introspect)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -752,10 +635,14 @@ ProcessRequest:
 		return
 	}
 
-	// 对密码进行加密，使用固定密钥"TokugawaMatsuri"
-	encryptedPassword := ""
-	if request.Password != "" {
-		encryptedPassword = EncryptPassword(request.Password, "TokugawaMatsuri")
+	// 密码保存到凭据库，config.json和响应中只包含凭据ID
+	passwordSecretID, err := storePassword(c.Request.Context(), request.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "保存数据库密码失败: " + err.Error(),
+		})
+		return
 	}
 
 	// 将结构体转换为map以便操作和包装
@@ -767,9 +654,9 @@ ProcessRequest:
 		"tables":   connInfo.Tables,
 	}
 
-	// 添加加密后的密码
-	if encryptedPassword != "" {
-		connInfoMap["password"] = encryptedPassword
+	// 添加密码的凭据ID
+	if passwordSecretID != "" {
+		connInfoMap["passwordSecretId"] = passwordSecretID
 	}
 
 	// 将连接信息包装到mysql对象中
@@ -813,9 +700,14 @@ func (h *DataSourceHandler) ProcessSQLiteFile(c *gin.Context) {
 	var request struct {
 		FilePath string `json:"filePath" binding:"required"`
 		Table    string `json:"table"` // 可选，指定要处理的表
+		introspectRequest
 	}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
+	err := c.ShouldBindJSON(&request)
+	if err == nil {
+		err = request.options().Validate()
+	}
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "无效的请求参数: " + err.Error(),
@@ -835,8 +727,8 @@ func (h *DataSourceHandler) ProcessSQLiteFile(c *gin.Context) {
 		return
 	}
 
-	// 创建SQLite连接器
-	connector, err := sqlite.NewSQLiteConnector(request.FilePath)
+	// 以只读方式打开SQLite文件
+	connector, err := sqlite.NewReadOnlySQLiteConnector(request.FilePath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -853,7 +745,7 @@ func (h *DataSourceHandler) ProcessSQLiteFile(c *gin.Context) {
 		connInfo, err = connector.ExtractTableConnectionInfo(request.Table)
 	} else {
 		// 获取所有表的信息
-		connInfo, err = connector.ExtractConnectionInfo()
+		connInfo, err = connector.ExtractConnectionInfo(c.Request.Context(), request.options())
 	}
 
 	if err != nil {
@@ -968,6 +860,16 @@ func (h *DataSourceHandler) ImportSQLiteToMongoDB(c *gin.Context) {
 		return
 	}
 
+	// 记录审计日志
+	for name := range connInfo.Collections {
+		recordImport(c, name, map[string]interface{}{
+			"sourceType": "sqlite",
+			"filePath":   request.FilePath,
+			"table":      request.Table,
+			"database":   connInfo.Database,
+		})
+	}
+
 	// 获取当前工作目录
 	wd, err := os.Getwd()
 	if err != nil {
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProviderHandler 处理通用数据源接口 /api/datasource/:type/...
+// 数据源类型通过providers注册表查找，新增数据源类型无需修改此处理器
+type ProviderHandler struct {
+}
+
+// NewProviderHandler 创建通用数据源处理器
+func NewProviderHandler() *ProviderHandler {
+	return &ProviderHandler{}
+}
+
+// providerRequest 通用数据源请求
+type providerRequest struct {
+	Config providers.Config `json:"config"` // 连接配置，内容由数据源类型决定
+	Entity string           `json:"entity"` // 表、集合或文件名
+	Limit  int              `json:"limit"`  // 样本行数
+}
+
+// providerTimeout 除流式读取外单次请求的超时时间
+const providerTimeout = 60 * time.Second
+
+// ListProviders 列出已注册的数据源类型
+func (h *ProviderHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"providers": providers.Names(),
+	})
+}
+
+// ValidateConfig 校验连接配置，不建立连接
+func (h *ProviderHandler) ValidateConfig(c *gin.Context) {
+	provider, request, ok := h.bind(c)
+	if !ok {
+		return
+	}
+	if err := provider.ValidateConfig(request.Config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListEntities 列出数据源中的实体
+func (h *ProviderHandler) ListEntities(c *gin.Context) {
+	h.withConnection(c, func(ctx context.Context, conn providers.Connection, request *providerRequest) (interface{}, error) {
+		entities, err := conn.ListEntities(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"success": true, "entities": entities}, nil
+	})
+}
+
+// Describe 返回实体的字段结构
+func (h *ProviderHandler) Describe(c *gin.Context) {
+	h.withConnection(c, func(ctx context.Context, conn providers.Connection, request *providerRequest) (interface{}, error) {
+		schema, err := conn.Describe(ctx, request.Entity)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"success": true, "schema": schema}, nil
+	})
+}
+
+// Sample 返回实体的样本数据
+func (h *ProviderHandler) Sample(c *gin.Context) {
+	h.withConnection(c, func(ctx context.Context, conn providers.Connection, request *providerRequest) (interface{}, error) {
+		rows, err := conn.Sample(ctx, request.Entity, request.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"success": true, "entity": request.Entity, "rows": rows}, nil
+	})
+}
+
+// StreamRows 以NDJSON格式流式返回实体的全部数据，每行一个JSON对象
+// 读取过程中出错时，最后一行为 {"error": "..."}
+func (h *ProviderHandler) StreamRows(c *gin.Context) {
+	provider, request, ok := h.bind(c)
+	if !ok {
+		return
+	}
+	if request.Entity == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   providers.ErrEntityRequired.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	conn, err := provider.Connect(ctx, request.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "连接数据源失败: " + err.Error(),
+		})
+		return
+	}
+	defer conn.Close()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	err = conn.StreamRows(ctx, request.Entity, func(row providers.Row) error {
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		encoder.Encode(gin.H{"error": err.Error()})
+	}
+}
+
+// bind 查找数据源类型、检查连接权限并解析请求，失败时已写入响应
+func (h *ProviderHandler) bind(c *gin.Context) (providers.Provider, *providerRequest, bool) {
+	provider, err := providers.Get(c.Param("type"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return nil, nil, false
+	}
+	if !checkDataSource(c, c.Param("type")) {
+		return nil, nil, false
+	}
+
+	var request providerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的请求参数: " + err.Error(),
+		})
+		return nil, nil, false
+	}
+	if request.Config == nil {
+		request.Config = providers.Config{}
+	}
+	return provider, &request, true
+}
+
+// withConnection 建立连接、执行操作并写入JSON响应
+func (h *ProviderHandler) withConnection(c *gin.Context, fn func(ctx context.Context, conn providers.Connection, request *providerRequest) (interface{}, error)) {
+	provider, request, ok := h.bind(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), providerTimeout)
+	defer cancel()
+
+	conn, err := provider.Connect(ctx, request.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "连接数据源失败: " + err.Error(),
+		})
+		return
+	}
+	defer conn.Close()
+
+	result, err := fn(ctx, conn, request)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, providers.ErrEntityRequired) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
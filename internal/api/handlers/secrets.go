@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"minds_iolite_backend/internal/secrets"
+)
+
+// legacyPasswordKey 旧版本写入config.json的MySQL密码使用该固定密钥按字节异或后十六进制编码，只用于迁移
+const legacyPasswordKey = "TokugawaMatsuri"
+
+// 保存数据源凭据的凭据库，config.json中只保存凭据ID
+var secretVault *secrets.Vault
+
+// InitSecretVault 设置保存数据源凭据的凭据库
+func InitSecretVault(vault *secrets.Vault) {
+	secretVault = vault
+}
+
+// storePassword 将密码保存到凭据库并返回凭据ID，密码为空时返回空ID
+func storePassword(ctx context.Context, password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if secretVault == nil {
+		return "", errors.New("凭据库未初始化")
+	}
+	return secretVault.Put(ctx, password, time.Time{})
+}
+
+// config.json中可选的passwordEncoding字段的值，用于明确指定无法自动判断的password的编码
+const (
+	passwordEncodingLegacy = "xor-hex" // 旧版本的异或编码
+	passwordEncodingPlain  = "plain"   // 明文
+)
+
+// decodeLegacyPassword 还原旧版本异或编码的密码，返回的bool表示是否按旧版本编码还原
+// encoding为passwordEncoding字段的值；未指定时只有值为旧版本写入的小写十六进制、还原结果是不含控制字符的文本，
+// 且重新编码后与原值一致时才还原，否则原样返回，由调用方报告
+func decodeLegacyPassword(value, encoding string) (string, bool, error) {
+	switch encoding {
+	case passwordEncodingPlain:
+		return value, false, nil
+	case passwordEncodingLegacy:
+		decoded, err := xorHexDecode(value)
+		if err != nil {
+			return "", false, fmt.Errorf("password不是有效的十六进制编码: %w", err)
+		}
+		return decoded, true, nil
+	case "":
+	default:
+		return "", false, fmt.Errorf("不支持的passwordEncoding: %s", encoding)
+	}
+
+	decoded, err := xorHexDecode(value)
+	if err != nil || decoded == "" || !printable(decoded) || xorHexEncode(decoded) != value {
+		return value, false, nil
+	}
+	return decoded, true, nil
+}
+
+// xorHexDecode 按旧版本的方式解码：十六进制解码后与固定密钥按字节异或
+func xorHexDecode(value string) (string, error) {
+	encoded, err := hex.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	decoded := make([]byte, len(encoded))
+	for i := range encoded {
+		decoded[i] = encoded[i] ^ legacyPasswordKey[i%len(legacyPasswordKey)]
+	}
+	return string(decoded), nil
+}
+
+// xorHexEncode 按旧版本的方式编码，与xorHexDecode互逆
+func xorHexEncode(value string) string {
+	encoded := make([]byte, len(value))
+	for i := 0; i < len(value); i++ {
+		encoded[i] = value[i] ^ legacyPasswordKey[i%len(legacyPasswordKey)]
+	}
+	return hex.EncodeToString(encoded)
+}
+
+// printable 是否为不含控制字符的UTF-8文本
+func printable(value string) bool {
+	if !utf8.ValidString(value) {
+		return false
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// DataConfigPaths 返回各个数据源接口写入config.json的位置：当前目录和可执行文件所在目录下的data目录
+func DataConfigPaths() []string {
+	var paths []string
+	if wd, err := os.Getwd(); err == nil {
+		paths = append(paths, filepath.Join(wd, "data", "config.json"))
+	}
+	if exePath, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(exePath), "data", "config.json")
+		if len(paths) == 0 || paths[0] != path {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// MigrateLegacyPasswords 将config.json中旧版本保存的password移入凭据库，替换为passwordSecretId
+// 返回迁移的密码数；文件不存在时跳过，凭据库不能持久保存时不迁移，否则服务重启后密码会丢失
+func MigrateLegacyPasswords(ctx context.Context, paths ...string) (int, error) {
+	if secretVault == nil || !secretVault.Persistent() {
+		return 0, nil
+	}
+	migrated := 0
+	for _, path := range paths {
+		count, err := migrateConfigFile(ctx, path)
+		migrated += count
+		if err != nil {
+			return migrated, fmt.Errorf("迁移 %s 中的密码失败: %w", path, err)
+		}
+	}
+	return migrated, nil
+}
+
+// migrateConfigFile 迁移单个config.json，文件顶层为数据源类型 -> 连接信息
+func migrateConfigFile(ctx context.Context, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var sources map[string]interface{}
+	if err := json.Unmarshal(data, &sources); err != nil {
+		// 不是数据源连接信息的文件不处理
+		return 0, nil
+	}
+
+	migrated := 0
+	for name, value := range sources {
+		info, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		password, ok := info["password"].(string)
+		if !ok {
+			continue
+		}
+		encoding, _ := info["passwordEncoding"].(string)
+		decoded, legacy, err := decodeLegacyPassword(password, encoding)
+		if err != nil {
+			log.Printf("跳过 %s 中 %s 的password: %v", path, name, err)
+			continue
+		}
+		if !legacy && encoding == "" {
+			log.Printf("%s 中 %s 的password不是旧版本编码，按明文迁移；如需按旧版本编码还原，请设置\"passwordEncoding\": \"%s\"后重启",
+				path, name, passwordEncodingLegacy)
+		}
+		secretID, err := storePassword(ctx, decoded)
+		if err != nil {
+			return migrated, err
+		}
+		delete(info, "password")
+		delete(info, "passwordEncoding")
+		if secretID != "" {
+			info["passwordSecretId"] = secretID
+		}
+		migrated++
+	}
+	if migrated == 0 {
+		return 0, nil
+	}
+
+	configData, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	return migrated, os.WriteFile(path, configData, 0644)
+}
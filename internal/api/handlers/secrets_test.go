@@ -0,0 +1,38 @@
+package handlers
+
+import "testing"
+
+func TestDecodeLegacyPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		encoding string
+		want     string
+		legacy   bool
+		wantErr  bool
+	}{
+		{"旧版本编码", xorHexEncode("s3cret!"), "", "s3cret!", true, false},
+		{"非十六进制明文", "p@ssw0rd", "", "p@ssw0rd", false, false},
+		{"奇数长度的数字明文", "12345", "", "12345", false, false},
+		{"还原结果含控制字符", "546f6b", "", "546f6b", false, false},
+		{"大写十六进制不是旧版本写入的", "4F5B3D", "", "4F5B3D", false, false},
+		{"明确指定明文", xorHexEncode("s3cret!"), passwordEncodingPlain, xorHexEncode("s3cret!"), false, false},
+		{"明确指定旧版本编码", xorHexEncode("\x01pw"), passwordEncodingLegacy, "\x01pw", true, false},
+		{"指定旧版本编码但不是十六进制", "p@ss", passwordEncodingLegacy, "", false, true},
+		{"不支持的编码", "p@ss", "base64", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, legacy, err := decodeLegacyPassword(tt.value, tt.encoding)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, 期望出错: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want || legacy != tt.legacy {
+				t.Errorf("decodeLegacyPassword(%q, %q) = %q, %v, 期望 %q, %v", tt.value, tt.encoding, got, legacy, tt.want, tt.legacy)
+			}
+		})
+	}
+}
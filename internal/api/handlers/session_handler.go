@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	"minds_iolite_backend/internal/auth"
+	"minds_iolite_backend/internal/models/datasource"
 	"minds_iolite_backend/internal/session"
 
 	"github.com/gin-gonic/gin"
@@ -20,9 +23,9 @@ func NewSessionHandler() *SessionHandler {
 type SessionHandler struct {
 }
 
-// InitSessionManager 初始化全局会话管理器
-func InitSessionManager() {
-	sessionManager = session.NewManager()
+// InitSessionManager 初始化全局会话管理器，store为nil时会话只保存在本进程内存中
+func InitSessionManager(store session.Store) {
+	sessionManager = session.NewManager(store, session.DefaultOptions())
 }
 
 // CreateSessionRequest 表示创建会话的请求
@@ -39,6 +42,13 @@ type CreateSessionRequest struct {
 	MongoDbName   string                 `json:"mongoDbName,omitempty"`
 	MongoCollName string                 `json:"mongoCollName,omitempty"`
 	Collections   map[string]interface{} `json:"collections,omitempty"`
+	ReadOnly      *bool                  `json:"readOnly,omitempty"` // 默认为true，只有显式设置为false时才允许写入
+	TTL           int                    `json:"ttl,omitempty"`      // 会话有效期(秒)，默认使用配置的有效期
+}
+
+// readOnly 返回会话是否以只读方式连接，未指定时默认只读
+func (r *CreateSessionRequest) readOnly() bool {
+	return r.ReadOnly == nil || *r.ReadOnly
 }
 
 // CreateSession 创建新的持久连接会话
@@ -73,10 +83,10 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 			return
 		}
 	} else if req.Type == "csv" {
-		if req.FilePath == "" {
+		if err := datasource.NewCSVSource(req.FilePath).Validate(); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"error":   "CSV处理需要提供文件路径",
+				"error":   err.Error(),
 			})
 			return
 		}
@@ -88,6 +98,13 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		return
 	}
 
+	// 校验会话有效期
+	ttl, err := sessionManager.ResolveTTL(req.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
 	// 创建连接信息
 	info := session.ConnectionInfo{
 		Type:     req.Type,
@@ -97,10 +114,20 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		Password: req.Password,
 		Database: req.Database,
 		URI:      req.URI,
+		FilePath: req.FilePath,
+		ReadOnly: req.readOnly(),
 	}
 
-	// 创建会话
-	sessionID := sessionManager.CreateSession(info, req.Collections, nil)
+	// 创建会话并建立连接
+	ownerID := ""
+	if user, ok := auth.CurrentUser(c); ok {
+		ownerID = user.ID
+	}
+	sessionID, err := sessionManager.CreateSession(ownerID, info, ttl, req.Collections, nil)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": "连接数据源失败: " + err.Error()})
+		return
+	}
 
 	// 获取创建的会话状态
 	state, _ := sessionManager.GetSession(sessionID)
@@ -150,13 +177,19 @@ func (h *SessionHandler) RefreshSession(c *gin.Context) {
 		return
 	}
 
-	success := sessionManager.RefreshSession(sessionID)
-	if !success {
+	state, err := sessionManager.RefreshSession(sessionID)
+	if errors.Is(err, session.ErrSessionNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "会话不存在或已过期"})
 		return
 	}
-
-	state, _ := sessionManager.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"error":   "重新连接失败: " + err.Error(),
+			"state":   state,
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"state":   state,
@@ -2,6 +2,7 @@ package api
 
 import (
 	"minds_iolite_backend/internal/api/handlers"
+	"minds_iolite_backend/internal/session"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,9 +11,9 @@ import (
 var dataSourceHandler = handlers.NewDataSourceHandler()
 var sessionHandler = handlers.NewSessionHandler()
 
-func SetupRoutes(r *gin.Engine) {
+func SetupRoutes(r *gin.Engine, store session.Store) {
 	// 初始化会话管理器 - 放在最前面确保在使用前初始化
-	handlers.InitSessionManager()
+	handlers.InitSessionManager(store)
 
 	// 添加CORS中间件
 	r.Use(func(c *gin.Context) {
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 请求ID的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// ContextRequestIDKey 请求ID保存在gin.Context中的键
+const ContextRequestIDKey = "audit.requestId"
+
+// maxRequestIDLength 客户端提供的请求ID的最大长度，超出时生成新的ID
+const maxRequestIDLength = 128
+
+// ignoredFields 计算字段变化时忽略的字段：ID不会变化，更新时间每次都变化
+var ignoredFields = map[string]bool{"_id": true, "id": true, "updatedAt": true}
+
+// RequestID 返回请求ID中间件：使用客户端在X-Request-ID中提供的ID，没有时生成新的ID，并在响应头中返回
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.New().String()
+		}
+		c.Set(ContextRequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// CurrentRequestID 返回请求ID中间件设置的请求ID，未经过中间件时返回空字符串
+func CurrentRequestID(c *gin.Context) string {
+	return c.GetString(ContextRequestIDKey)
+}
+
+// Record 填充操作者、请求ID和客户端IP后保存记录
+// 记录在修改完成后保存，保存失败只写入日志，不影响已完成的修改
+func Record(c *gin.Context, l Log, entry *Entry) {
+	if user, ok := auth.CurrentUser(c); ok {
+		entry.ActorID = user.ID
+		entry.ActorName = user.Username
+	}
+	entry.RequestID = CurrentRequestID(c)
+	entry.ClientIP = c.ClientIP()
+
+	// 请求可能已被客户端取消，修改已经完成，记录仍需保存
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := l.Record(ctx, entry); err != nil {
+		log.Printf("保存审计记录失败 (操作: %s, 模型: %s, 记录: %s, 请求: %s): %v",
+			entry.Action, entry.Model, entry.RecordID, entry.RequestID, err)
+	}
+}
+
+// Diff 比较修改前后的记录或模型定义，返回发生变化的顶层字段
+// 两个值按JSON表示比较，忽略_id、id和updatedAt
+func Diff(before, after interface{}) map[string]Change {
+	beforeFields, afterFields := jsonFields(before), jsonFields(after)
+	changes := make(map[string]Change)
+	for field, value := range beforeFields {
+		if ignoredFields[field] {
+			continue
+		}
+		if newValue, ok := afterFields[field]; !ok || !reflect.DeepEqual(value, newValue) {
+			changes[field] = Change{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok && !ignoredFields[field] {
+			changes[field] = Change{Before: nil, After: value}
+		}
+	}
+	return changes
+}
+
+// jsonFields 将值转换为JSON对象的顶层字段，无法转换时返回空map
+func jsonFields(value interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
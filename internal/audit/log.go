@@ -0,0 +1,245 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/database"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName 在MongoDB中保存审计记录的集合名称
+const CollectionName = "audit_log"
+
+func init() {
+	database.ReserveCollections(CollectionName)
+}
+
+// 记录的操作
+const (
+	ActionCreate      = "create"       // 通过动态API创建记录，或通过会话插入行
+	ActionUpdate      = "update"       // 通过动态API更新记录，或通过会话更新行
+	ActionDelete      = "delete"       // 通过动态API删除记录，或通过会话删除行
+	ActionImport      = "import"       // 导入或复制数据到集合或表
+	ActionModelCreate = "model.create" // 创建模型定义
+	ActionModelUpdate = "model.update" // 修改模型定义
+	ActionModelDelete = "model.delete" // 删除模型定义及其集合
+)
+
+const (
+	// DefaultLimit 默认返回的记录数
+	DefaultLimit = 100
+	// MaxLimit 一次最多返回的记录数
+	MaxLimit = 1000
+)
+
+// maxMemoryEntries 进程内审计日志保留的最大记录数，超出后丢弃最早的记录
+const maxMemoryEntries = 10000
+
+// Change 一个字段修改前后的值，字段新增时Before为null，删除时After为null
+type Change struct {
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// Entry 一次修改数据或模型定义的记录，只记录成功的修改
+type Entry struct {
+	ID        string                 `json:"id" bson:"_id"`
+	Time      time.Time              `json:"time" bson:"time"`
+	ActorID   string                 `json:"actorId,omitempty" bson:"actorId,omitempty"` // 操作者的用户ID，未登录的请求为空
+	ActorName string                 `json:"actorName,omitempty" bson:"actorName,omitempty"`
+	Action    string                 `json:"action" bson:"action"`
+	Model     string                 `json:"model" bson:"model"` // 模型名，导入时为目标集合或表名
+	RecordID  string                 `json:"recordId,omitempty" bson:"recordId,omitempty"`
+	Before    interface{}            `json:"before,omitempty" bson:"before,omitempty"`   // 修改前的记录或模型定义
+	After     interface{}            `json:"after,omitempty" bson:"after,omitempty"`     // 修改后的记录或模型定义
+	Changes   map[string]Change      `json:"changes,omitempty" bson:"changes,omitempty"` // 更新时发生变化的顶层字段
+	Details   map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"` // 导入的来源、行数等
+	RequestID string                 `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	ClientIP  string                 `json:"clientIp,omitempty" bson:"clientIp,omitempty"`
+}
+
+// Filter 查询条件，为空的条件不参与过滤
+type Filter struct {
+	ActorID   string
+	Action    string
+	Model     string
+	RecordID  string
+	RequestID string
+	Since     time.Time // 包含
+	Until     time.Time // 不包含
+	Limit     int
+	Offset    int
+}
+
+// normalize 将未设置或超出范围的分页参数替换为默认值
+func (f Filter) normalize() Filter {
+	if f.Limit <= 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit > MaxLimit {
+		f.Limit = MaxLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f
+}
+
+// matches 记录是否满足查询条件
+func (f Filter) matches(entry *Entry) bool {
+	return (f.ActorID == "" || entry.ActorID == f.ActorID) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Model == "" || entry.Model == f.Model) &&
+		(f.RecordID == "" || entry.RecordID == f.RecordID) &&
+		(f.RequestID == "" || entry.RequestID == f.RequestID) &&
+		(f.Since.IsZero() || !entry.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || entry.Time.Before(f.Until))
+}
+
+// Log 只追加的审计日志，已保存的记录不能修改或删除
+type Log interface {
+	// Record 保存一条记录，未设置ID和时间时自动生成
+	Record(ctx context.Context, entry *Entry) error
+	// Query 按时间倒序返回满足条件的记录，以及满足条件的记录总数
+	Query(ctx context.Context, filter Filter) ([]*Entry, int64, error)
+}
+
+// prepareEntry 填充记录的ID和时间
+func prepareEntry(entry *Entry) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+}
+
+// MemoryLog 进程内的审计日志，用于未配置MongoDB时，服务重启后记录会丢失
+type MemoryLog struct {
+	entries []*Entry
+	mutex   sync.Mutex
+}
+
+// NewMemoryLog 创建进程内审计日志
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{}
+}
+
+// Record 保存一条记录
+func (l *MemoryLog) Record(ctx context.Context, entry *Entry) error {
+	prepareEntry(entry)
+	snapshot := *entry
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, &snapshot)
+	if len(l.entries) > maxMemoryEntries {
+		l.entries = append([]*Entry(nil), l.entries[len(l.entries)-maxMemoryEntries:]...)
+	}
+	return nil
+}
+
+// Query 按时间倒序返回满足条件的记录
+func (l *MemoryLog) Query(ctx context.Context, filter Filter) ([]*Entry, int64, error) {
+	filter = filter.normalize()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	result := []*Entry{}
+	var total int64
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if !filter.matches(l.entries[i]) {
+			continue
+		}
+		total++
+		if total > int64(filter.Offset) && len(result) < filter.Limit {
+			snapshot := *l.entries[i]
+			result = append(result, &snapshot)
+		}
+	}
+	return result, total, nil
+}
+
+// MongoLog 基于MongoDB的审计日志
+type MongoLog struct {
+	coll *mongo.Collection
+}
+
+// NewMongoLog 创建MongoDB审计日志并确保按时间、模型、操作者和请求查询的索引存在
+func NewMongoLog(ctx context.Context, coll *mongo.Collection) (*MongoLog, error) {
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "time", Value: -1}}, Options: options.Index().SetName("time")},
+		{Keys: bson.D{{Key: "model", Value: 1}, {Key: "recordId", Value: 1}, {Key: "time", Value: -1}}, Options: options.Index().SetName("model_recordId_time")},
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "time", Value: -1}}, Options: options.Index().SetName("actorId_time")},
+		{Keys: bson.D{{Key: "requestId", Value: 1}}, Options: options.Index().SetName("requestId")},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建审计日志索引失败: %w", err)
+	}
+	// 修改前后的记录是任意结构的文档，读取时解码为map，否则嵌套文档会序列化为键值对数组
+	coll = coll.Database().Collection(coll.Name(),
+		options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}))
+	return &MongoLog{coll: coll}, nil
+}
+
+// Record 保存一条记录
+func (l *MongoLog) Record(ctx context.Context, entry *Entry) error {
+	prepareEntry(entry)
+	_, err := l.coll.InsertOne(ctx, entry)
+	return err
+}
+
+// Query 按时间倒序返回满足条件的记录
+func (l *MongoLog) Query(ctx context.Context, filter Filter) ([]*Entry, int64, error) {
+	filter = filter.normalize()
+
+	query := bson.M{}
+	for key, value := range map[string]string{
+		"actorId":   filter.ActorID,
+		"action":    filter.Action,
+		"model":     filter.Model,
+		"recordId":  filter.RecordID,
+		"requestId": filter.RequestID,
+	} {
+		if value != "" {
+			query[key] = value
+		}
+	}
+	timeRange := bson.M{}
+	if !filter.Since.IsZero() {
+		timeRange["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		timeRange["$lt"] = filter.Until
+	}
+	if len(timeRange) > 0 {
+		query["time"] = timeRange
+	}
+
+	total, err := l.coll.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := l.coll.Find(ctx, query, options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}}).
+		SetSkip(int64(filter.Offset)).
+		SetLimit(int64(filter.Limit)))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*Entry{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
@@ -0,0 +1,421 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeysCollectionName 在MongoDB中保存API密钥的集合名称
+const APIKeysCollectionName = "api_keys"
+
+// APIKeyPrefix API密钥的固定前缀，以它开头的Bearer令牌按API密钥验证
+const APIKeyPrefix = "mik_"
+
+// APIKeyHeader 也可以通过该请求头提供API密钥
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyDisplayLength 保存和展示的密钥开头部分的长度，用于区分同一用户的多个密钥
+const apiKeyDisplayLength = 12
+
+// lastUsedPrecision 最后使用时间的精度，距上次记录不足该时间时不再写入存储
+const lastUsedPrecision = time.Minute
+
+var (
+	// ErrAPIKeyNotFound API密钥不存在
+	ErrAPIKeyNotFound = errors.New("API密钥不存在")
+	// ErrInvalidAPIKey 请求中的API密钥不存在或格式错误
+	ErrInvalidAPIKey = errors.New("无效的API密钥")
+	// ErrAPIKeyRevoked API密钥已吊销
+	ErrAPIKeyRevoked = errors.New("API密钥已吊销")
+	// ErrAPIKeyExpired API密钥已过期
+	ErrAPIKeyExpired = errors.New("API密钥已过期")
+	// ErrInvalidAPIKeySettings 密钥名称、权限范围或有效期不符合要求
+	ErrInvalidAPIKeySettings = errors.New("API密钥的设置不符合要求")
+	// ErrInvalidLimits 限额不符合要求
+	ErrInvalidLimits = errors.New("限额不符合要求")
+)
+
+// Limits 请求频率和配额，0表示使用默认值
+type Limits struct {
+	RequestsPerMinute int   `json:"requestsPerMinute,omitempty" bson:"requestsPerMinute,omitempty"` // 每分钟请求数
+	ConcurrentImports int   `json:"concurrentImports,omitempty" bson:"concurrentImports,omitempty"` // 同时进行的导入和复制数
+	UploadBytesPerDay int64 `json:"uploadBytesPerDay,omitempty" bson:"uploadBytesPerDay,omitempty"` // 每天(UTC)上传的请求体字节数
+}
+
+// Merge 用l中大于0的值覆盖defaults
+func (l Limits) Merge(defaults Limits) Limits {
+	if l.RequestsPerMinute > 0 {
+		defaults.RequestsPerMinute = l.RequestsPerMinute
+	}
+	if l.ConcurrentImports > 0 {
+		defaults.ConcurrentImports = l.ConcurrentImports
+	}
+	if l.UploadBytesPerDay > 0 {
+		defaults.UploadBytesPerDay = l.UploadBytesPerDay
+	}
+	return defaults
+}
+
+// validate 检查限额不为负数
+func (l Limits) validate() error {
+	if l.RequestsPerMinute < 0 || l.ConcurrentImports < 0 || l.UploadBytesPerDay < 0 {
+		return fmt.Errorf("%w: 限额不能为负数", ErrInvalidLimits)
+	}
+	return nil
+}
+
+// APIKey 供脚本和集成使用的API密钥，只保存密钥的SHA-256哈希
+// 请求拥有用户权限与密钥权限范围的交集
+type APIKey struct {
+	ID         string     `json:"id" bson:"_id"`
+	UserID     string     `json:"userId" bson:"userId"`
+	Name       string     `json:"name" bson:"name"`
+	Prefix     string     `json:"prefix" bson:"prefix"` // 密钥的开头部分，用于识别密钥
+	Hash       string     `json:"-" bson:"hash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"` // 权限范围，格式与角色的权限相同
+	Limits     Limits     `json:"limits" bson:"limits"` // 该密钥的限额，0表示使用API密钥的默认限额
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+}
+
+// APIKeyStore 保存API密钥，吊销的密钥保留记录
+type APIKeyStore interface {
+	// Create 保存新密钥
+	Create(ctx context.Context, key *APIKey) error
+	// Get 按ID读取密钥，不存在时返回ErrAPIKeyNotFound
+	Get(ctx context.Context, id string) (*APIKey, error)
+	// GetByHash 按密钥哈希读取密钥，不存在时返回ErrAPIKeyNotFound
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	// List 按创建时间返回用户的密钥，userID为空时返回所有密钥
+	List(ctx context.Context, userID string) ([]*APIKey, error)
+	// Revoke 记录吊销时间，已吊销的密钥保持原吊销时间，不存在时返回ErrAPIKeyNotFound
+	Revoke(ctx context.Context, id string, revokedAt time.Time) error
+	// Touch 记录密钥的最后使用时间
+	Touch(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// hashAPIKey 计算密钥的SHA-256哈希，密钥本身是高熵随机值，不需要加盐
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey 生成带固定前缀的随机密钥
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成API密钥失败: %w", err)
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// validateScopes 检查权限范围的格式：至少一项，每项由不含空白的非空段以冒号连接
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("至少需要一个权限范围，\"*\"表示用户的全部权限")
+	}
+	for _, scope := range scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\r\n") {
+			return fmt.Errorf("权限范围 %q 无效", scope)
+		}
+		for _, part := range strings.Split(scope, ":") {
+			if part == "" {
+				return fmt.Errorf("权限范围 %q 包含空段", scope)
+			}
+		}
+	}
+	return nil
+}
+
+// CreateAPIKey 为用户创建API密钥，返回保存的密钥信息和只在此时返回的完整密钥
+func (s *Service) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, limits Limits, expiresAt *time.Time) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, "", fmt.Errorf("%w: 名称不能为空且不能超过100个字符", ErrInvalidAPIKeySettings)
+	}
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidAPIKeySettings, err)
+	}
+	if err := limits.validate(); err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", fmt.Errorf("%w: 过期时间必须晚于当前时间", ErrInvalidAPIKeySettings)
+	}
+	if _, err := s.users.Get(ctx, userID); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:apiKeyDisplayLength],
+		Hash:      hashAPIKey(secret),
+		Scopes:    scopes,
+		Limits:    limits,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.apiKeys.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// GetAPIKey 按ID读取API密钥
+func (s *Service) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	return s.apiKeys.Get(ctx, id)
+}
+
+// ListAPIKeys 按创建时间返回用户的API密钥，userID为空时返回所有密钥
+func (s *Service) ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	return s.apiKeys.List(ctx, userID)
+}
+
+// RevokeAPIKey 吊销API密钥，吊销后立即失效，记录保留
+func (s *Service) RevokeAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	if err := s.apiKeys.Revoke(ctx, id, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.apiKeys.Get(ctx, id)
+}
+
+// AuthenticateAPIKey 验证API密钥，并确认密钥所属的用户仍然存在且未被禁用
+func (s *Service) AuthenticateAPIKey(ctx context.Context, secret string) (*APIKey, *User, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	key, err := s.apiKeys.GetByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, nil, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, nil, ErrAPIKeyExpired
+	}
+	user, err := s.users.Get(ctx, key.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	// 最后使用时间只用于展示，写入失败不影响请求
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedPrecision {
+		if err := s.apiKeys.Touch(ctx, key.ID, now); err != nil {
+			log.Printf("记录API密钥 %s 的最后使用时间失败: %v", key.ID, err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+	return key, user, nil
+}
+
+// MemoryAPIKeyStore 进程内的API密钥存储，服务重启后密钥会丢失
+type MemoryAPIKeyStore struct {
+	keys  map[string]*APIKey
+	mutex sync.Mutex
+}
+
+// NewMemoryAPIKeyStore 创建进程内API密钥存储
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[string]*APIKey)}
+}
+
+// Create 保存新密钥
+func (s *MemoryAPIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := *key
+	s.keys[key.ID] = &snapshot
+	return nil
+}
+
+// Get 按ID读取密钥
+func (s *MemoryAPIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	snapshot := *key
+	return &snapshot, nil
+}
+
+// GetByHash 按密钥哈希读取密钥
+func (s *MemoryAPIKeyStore) GetByHash(ctx context.Context, hash string) (*APIKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			snapshot := *key
+			return &snapshot, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+// List 按创建时间返回用户的密钥
+func (s *MemoryAPIKeyStore) List(ctx context.Context, userID string) ([]*APIKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := []*APIKey{}
+	for _, key := range s.keys {
+		if userID == "" || key.UserID == userID {
+			snapshot := *key
+			result = append(result, &snapshot)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+// Revoke 记录吊销时间
+func (s *MemoryAPIKeyStore) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &revokedAt
+	}
+	return nil
+}
+
+// Touch 记录密钥的最后使用时间
+func (s *MemoryAPIKeyStore) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if key, ok := s.keys[id]; ok {
+		key.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+// MongoAPIKeyStore 基于MongoDB的API密钥存储，多个服务实例可以共享
+type MongoAPIKeyStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoAPIKeyStore 创建MongoDB API密钥存储并确保密钥哈希唯一索引和用户索引存在
+func NewMongoAPIKeyStore(ctx context.Context, coll *mongo.Collection) (*MongoAPIKeyStore, error) {
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetName("hash").SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetName("userId_createdAt")},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建API密钥索引失败: %w", err)
+	}
+	return &MongoAPIKeyStore{coll: coll}, nil
+}
+
+// Create 保存新密钥
+func (s *MongoAPIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	_, err := s.coll.InsertOne(ctx, key)
+	return err
+}
+
+// Get 按ID读取密钥
+func (s *MongoAPIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+// GetByHash 按密钥哈希读取密钥
+func (s *MongoAPIKeyStore) GetByHash(ctx context.Context, hash string) (*APIKey, error) {
+	return s.findOne(ctx, bson.M{"hash": hash})
+}
+
+// findOne 读取满足条件的密钥
+func (s *MongoAPIKeyStore) findOne(ctx context.Context, filter bson.M) (*APIKey, error) {
+	var key APIKey
+	err := s.coll.FindOne(ctx, filter).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List 按创建时间返回用户的密钥
+func (s *MongoAPIKeyStore) List(ctx context.Context, userID string) ([]*APIKey, error) {
+	filter := bson.M{}
+	if userID != "" {
+		filter["userId"] = userID
+	}
+	cursor, err := s.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*APIKey{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Revoke 记录吊销时间
+func (s *MongoAPIKeyStore) Revoke(ctx context.Context, id string, revokedAt time.Time) error {
+	result, err := s.coll.UpdateOne(ctx, bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": revokedAt}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// 已吊销或不存在
+		if _, err := s.Get(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Touch 记录密钥的最后使用时间
+func (s *MongoAPIKeyStore) Touch(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": usedAt}})
+	return err
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	secret := APIKeyPrefix + "abc"
+	hash := hashAPIKey(secret)
+	if hash != hashAPIKey(secret) {
+		t.Error("同一密钥的哈希不一致")
+	}
+	if hash == hashAPIKey(APIKeyPrefix+"abd") {
+		t.Error("不同密钥的哈希相同")
+	}
+	if strings.Contains(hash, "abc") || len(hash) != 64 {
+		t.Errorf("hashAPIKey(%q) = %q, 期望64位十六进制SHA-256哈希", secret, hash)
+	}
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewService(nil, nil, nil, Options{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := service.CreateUser(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		userID    string
+		keyName   string
+		scopes    []string
+		limits    Limits
+		expiresAt *time.Time
+		wantErr   error
+	}{
+		{"有效的密钥", user.ID, "脚本", []string{"model:*:read"}, Limits{RequestsPerMinute: 10}, nil, nil},
+		{"全部权限", user.ID, "脚本", []string{"*"}, Limits{}, nil, nil},
+		{"名称为空", user.ID, " ", []string{"*"}, Limits{}, nil, ErrInvalidAPIKeySettings},
+		{"名称过长", user.ID, strings.Repeat("a", 101), []string{"*"}, Limits{}, nil, ErrInvalidAPIKeySettings},
+		{"没有权限范围", user.ID, "脚本", nil, Limits{}, nil, ErrInvalidAPIKeySettings},
+		{"权限范围包含空段", user.ID, "脚本", []string{"model::read"}, Limits{}, nil, ErrInvalidAPIKeySettings},
+		{"权限范围包含空白", user.ID, "脚本", []string{"model:a b:read"}, Limits{}, nil, ErrInvalidAPIKeySettings},
+		{"负数限额", user.ID, "脚本", []string{"*"}, Limits{RequestsPerMinute: -1}, nil, ErrInvalidLimits},
+		{"过期时间已过", user.ID, "脚本", []string{"*"}, Limits{}, &past, ErrInvalidAPIKeySettings},
+		{"用户不存在", "nobody", "脚本", []string{"*"}, Limits{}, nil, ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, secret, err := service.CreateAPIKey(ctx, tt.userID, tt.keyName, tt.scopes, tt.limits, tt.expiresAt)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("CreateAPIKey() 错误 = %v, 期望 %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateAPIKey() 错误 = %v", err)
+			}
+			if !strings.HasPrefix(secret, APIKeyPrefix) {
+				t.Errorf("密钥 %q 没有前缀 %q", secret, APIKeyPrefix)
+			}
+			if !strings.HasPrefix(secret, key.Prefix) || len(key.Prefix) >= len(secret) {
+				t.Errorf("Prefix = %q, 期望密钥的开头部分", key.Prefix)
+			}
+			stored, err := service.GetAPIKey(ctx, key.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Hash != hashAPIKey(secret) || strings.Contains(stored.Hash, secret) {
+				t.Errorf("保存的哈希 = %q, 期望 %q", stored.Hash, hashAPIKey(secret))
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	apiKeys := NewMemoryAPIKeyStore()
+	service, err := NewService(nil, nil, apiKeys, Options{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := service.CreateUser(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := service.CreateUser(ctx, "bob", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	_, valid, err := service.CreateAPIKey(ctx, alice.ID, "有效", []string{"*"}, Limits{}, &future)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedKey, revoked, err := service.CreateAPIKey(ctx, alice.ID, "吊销", []string{"*"}, Limits{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.RevokeAPIKey(ctx, revokedKey.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, disabled, err := service.CreateAPIKey(ctx, bob.ID, "禁用", []string{"*"}, Limits{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SetDisabled(ctx, bob.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	// CreateAPIKey不接受已过去的过期时间，直接写入存储模拟已过期的密钥
+	expired := APIKeyPrefix + "expired"
+	past := time.Now().Add(-time.Minute)
+	if err := apiKeys.Create(ctx, &APIKey{ID: "expired", UserID: alice.ID, Name: "过期", Hash: hashAPIKey(expired), Scopes: []string{"*"}, ExpiresAt: &past}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr error
+	}{
+		{"有效的密钥", valid, nil},
+		{"错误的密钥", valid + "x", ErrInvalidAPIKey},
+		{"没有前缀", strings.TrimPrefix(valid, APIKeyPrefix), ErrInvalidAPIKey},
+		{"空密钥", "", ErrInvalidAPIKey},
+		{"已吊销", revoked, ErrAPIKeyRevoked},
+		{"已过期", expired, ErrAPIKeyExpired},
+		{"用户已被禁用", disabled, ErrUserDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, user, err := service.AuthenticateAPIKey(ctx, tt.secret)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("AuthenticateAPIKey() 错误 = %v, 期望 %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AuthenticateAPIKey() 错误 = %v", err)
+			}
+			if user.ID != alice.ID || key.UserID != alice.ID {
+				t.Errorf("用户 = %s, 期望 %s", user.ID, alice.ID)
+			}
+			if key.LastUsedAt == nil {
+				t.Error("没有记录最后使用时间")
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 认证通过后保存在gin.Context中的声明、用户和API密钥
+const (
+	ContextClaimsKey = "auth.claims"
+	ContextUserKey   = "auth.user"
+	ContextAPIKeyKey = "auth.apiKey"
+)
+
+// PublicRoutes 不需要登录即可访问的路由，按方法和路由定义中的路径匹配（如"GET /api/items/:id"）
+// 同时记录可以通过access_token查询参数传递访问令牌的GET路由
+type PublicRoutes struct {
+	routes     map[string]bool
+	queryToken map[string]bool
+	mutex      sync.RWMutex
+}
+
+// NewPublicRoutes 创建路由列表，routes的每一项为"方法 路径"
+func NewPublicRoutes(routes ...string) *PublicRoutes {
+	p := &PublicRoutes{routes: make(map[string]bool), queryToken: make(map[string]bool)}
+	for _, route := range routes {
+		method, routePath, found := strings.Cut(strings.TrimSpace(route), " ")
+		if found {
+			p.Add(method, strings.TrimSpace(routePath))
+		}
+	}
+	return p
+}
+
+// Add 将路由标记为不需要登录
+func (p *PublicRoutes) Add(method, routePath string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.routes[strings.ToUpper(method)+" "+routePath] = true
+}
+
+// Handle 在路由组中注册不需要登录的路由
+func (p *PublicRoutes) Handle(group *gin.RouterGroup, method, relativePath string, handlers ...gin.HandlerFunc) {
+	fullPath := path.Join(group.BasePath(), relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(fullPath, "/") {
+		fullPath += "/"
+	}
+	p.Add(method, fullPath)
+	group.Handle(method, relativePath, handlers...)
+}
+
+// contains 路由是否不需要登录
+func (p *PublicRoutes) contains(method, routePath string) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.routes[method+" "+routePath]
+}
+
+// AllowQueryToken 允许GET路由通过access_token查询参数传递访问令牌
+// 只用于浏览器EventSource无法设置请求头的SSE接口，查询参数中的令牌会出现在访问日志和浏览器历史中
+func (p *PublicRoutes) AllowQueryToken(routePath string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.queryToken[routePath] = true
+}
+
+// acceptsQueryToken 请求是否可以通过access_token查询参数传递访问令牌
+func (p *PublicRoutes) acceptsQueryToken(c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet {
+		return false
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.queryToken[c.FullPath()]
+}
+
+// Middleware 返回认证中间件，路径以prefixes之一开头的请求必须携带有效的访问令牌或API密钥，public中的路由除外
+// 令牌通过Authorization: Bearer请求头提供；浏览器的EventSource无法设置请求头，public.AllowQueryToken允许的路由也可以使用access_token查询参数
+// API密钥通过X-API-Key请求头提供，或作为Bearer令牌提供
+func (s *Service) Middleware(public *PublicRoutes, prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !protected(c.Request.URL.Path, prefixes) || c.Request.Method == http.MethodOptions ||
+			public.contains(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		token := c.GetHeader(APIKeyHeader)
+		if token == "" {
+			token = bearerToken(c)
+		}
+		if token == "" && public.acceptsQueryToken(c) {
+			token = c.Query("access_token")
+		}
+		if token == "" {
+			abortUnauthorized(c, "未登录，请在Authorization请求头中提供访问令牌")
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		var claims *Claims
+		var key *APIKey
+		var user *User
+		var err error
+		if strings.HasPrefix(token, APIKeyPrefix) {
+			key, user, err = s.AuthenticateAPIKey(ctx, token)
+		} else {
+			claims, user, err = s.Authenticate(ctx, token)
+		}
+		cancel()
+		if !authenticated(c, err) {
+			return
+		}
+		if key != nil {
+			c.Set(ContextAPIKeyKey, key)
+		} else {
+			c.Set(ContextClaimsKey, claims)
+		}
+		c.Set(ContextUserKey, user)
+		c.Next()
+	}
+}
+
+// authenticated 验证失败时中止请求，令牌或密钥无效时返回401
+func authenticated(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenExpired) ||
+		errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrUserDisabled) ||
+		errors.Is(err, ErrInvalidAPIKey) || errors.Is(err, ErrAPIKeyRevoked) || errors.Is(err, ErrAPIKeyExpired) {
+		abortUnauthorized(c, err.Error())
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "验证令牌失败: " + err.Error()})
+	return false
+}
+
+// protected 路径是否以某个前缀开头（按路径段匹配，/apis不匹配/api）
+func protected(requestPath string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken 读取Authorization请求头中的访问令牌
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if scheme, token, found := strings.Cut(header, " "); found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// abortUnauthorized 返回401
+func abortUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="minds_iolite"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": message})
+}
+
+// CurrentClaims 返回认证中间件保存的令牌声明，请求未经认证时返回false
+func CurrentClaims(c *gin.Context) (*Claims, bool) {
+	value, ok := c.Get(ContextClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
+
+// CurrentUser 返回认证中间件读取的用户，请求未经认证时返回false
+func CurrentUser(c *gin.Context) (*User, bool) {
+	value, ok := c.Get(ContextUserKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*User)
+	return user, ok
+}
+
+// CurrentAPIKey 返回请求使用的API密钥，请求未使用API密钥时返回false
+func CurrentAPIKey(c *gin.Context) (*APIKey, bool) {
+	value, ok := c.Get(ContextAPIKeyKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := value.(*APIKey)
+	return key, ok
+}
@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength 密码的最小长度
+const MinPasswordLength = 8
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误，不区分用户不存在和密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrTokenRevoked 令牌已注销
+	ErrTokenRevoked = errors.New("令牌已注销")
+	// ErrUserDisabled 用户已被禁用
+	ErrUserDisabled = errors.New("用户已被禁用")
+	// ErrInvalidAccount 用户名或密码不符合要求
+	ErrInvalidAccount = errors.New("用户名或密码不符合要求")
+)
+
+// dummyHash 用户不存在时也比较一次密码，登录耗时不会暴露用户名是否存在
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("minds-iolite-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Options 令牌签名密钥和有效期
+type Options struct {
+	Secret     string        // HS256签名密钥
+	AccessTTL  time.Duration // 访问令牌有效期
+	RefreshTTL time.Duration // 刷新令牌有效期，每次刷新后重新计算
+}
+
+// DefaultOptions 返回默认有效期：访问令牌24小时，刷新令牌7天
+func DefaultOptions() Options {
+	return Options{AccessTTL: 24 * time.Hour, RefreshTTL: 7 * 24 * time.Hour}
+}
+
+// normalize 将未设置的有效期替换为默认值
+func (o Options) normalize() Options {
+	defaults := DefaultOptions()
+	if o.AccessTTL <= 0 {
+		o.AccessTTL = defaults.AccessTTL
+	}
+	if o.RefreshTTL <= 0 {
+		o.RefreshTTL = defaults.RefreshTTL
+	}
+	return o
+}
+
+// TokenPair 登录或刷新后签发的令牌
+type TokenPair struct {
+	AccessToken      string `json:"accessToken"`
+	RefreshToken     string `json:"refreshToken"`
+	TokenType        string `json:"tokenType"`
+	ExpiresIn        int64  `json:"expiresIn"`        // 访问令牌的有效期(秒)
+	RefreshExpiresIn int64  `json:"refreshExpiresIn"` // 刷新令牌的有效期(秒)
+}
+
+// Service 用户账号和令牌服务
+type Service struct {
+	users   UserStore
+	revoked RevocationStore
+	apiKeys APIKeyStore
+	signer  signer
+	options Options
+}
+
+// NewService 创建用户、令牌和API密钥服务，store为nil时使用进程内存储
+func NewService(users UserStore, revoked RevocationStore, apiKeys APIKeyStore, options Options) (*Service, error) {
+	if options.Secret == "" {
+		return nil, errors.New("令牌签名密钥不能为空")
+	}
+	if users == nil {
+		users = NewMemoryUserStore()
+	}
+	if revoked == nil {
+		revoked = NewMemoryRevocationStore()
+	}
+	if apiKeys == nil {
+		apiKeys = NewMemoryAPIKeyStore()
+	}
+	return &Service{
+		users:   users,
+		revoked: revoked,
+		apiKeys: apiKeys,
+		signer:  signer{key: []byte(options.Secret)},
+		options: options.normalize(),
+	}, nil
+}
+
+// Login 验证用户名和密码并签发令牌
+func (s *Service) Login(ctx context.Context, username, password string) (*TokenPair, *User, error) {
+	user, err := s.users.GetByUsername(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+	tokens, err := s.issue(user, uuid.New().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
+
+// Refresh 用刷新令牌换取新的令牌，旧的刷新令牌随即失效
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := s.verify(ctx, refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.tokenUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	// 同一刷新令牌的并发请求中只有首先吊销它的请求能换取新令牌
+	first, err := s.revoked.Revoke(ctx, claims.ID, claims.Expiry())
+	if err != nil {
+		return nil, fmt.Errorf("吊销刷新令牌失败: %w", err)
+	}
+	if !first {
+		return nil, ErrTokenRevoked
+	}
+	return s.issue(user, claims.SessionID)
+}
+
+// Logout 注销令牌所属的登录，同一次登录签发的所有访问令牌和刷新令牌都失效
+func (s *Service) Logout(ctx context.Context, claims *Claims) error {
+	// 刷新令牌会顺延登录，吊销记录保留到最后一个刷新令牌过期
+	expiresAt := time.Now().Add(s.options.RefreshTTL)
+	if s.options.AccessTTL > s.options.RefreshTTL {
+		expiresAt = time.Now().Add(s.options.AccessTTL)
+	}
+	_, err := s.revoked.Revoke(ctx, claims.SessionID, expiresAt)
+	return err
+}
+
+// Authenticate 验证访问令牌，并确认令牌所属的用户仍然存在且未被禁用
+func (s *Service) Authenticate(ctx context.Context, accessToken string) (*Claims, *User, error) {
+	claims, err := s.verify(ctx, accessToken, TokenTypeAccess)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.tokenUser(ctx, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, user, nil
+}
+
+// tokenUser 读取令牌所属的用户，用户不存在、已被禁用或修改密码后令牌已失效时返回错误
+func (s *Service) tokenUser(ctx context.Context, claims *Claims) (*User, error) {
+	user, err := s.users.Get(ctx, claims.Subject)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+	if claims.Version != user.TokenVersion {
+		return nil, ErrTokenRevoked
+	}
+	return user, nil
+}
+
+// verify 验证令牌的签名、有效期、类型，并检查令牌和所属登录是否已注销
+func (s *Service) verify(ctx context.Context, token, tokenType string) (*Claims, error) {
+	claims, err := s.signer.parse(token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+	revoked, err := s.revoked.Revoked(ctx, claims.ID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// issue 为登录签发一对新令牌
+func (s *Service) issue(user *User, sessionID string) (*TokenPair, error) {
+	now := time.Now()
+	access, err := s.signer.sign(&Claims{
+		Subject:   user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
+		Type:      TokenTypeAccess,
+		ID:        uuid.New().String(),
+		Version:   user.TokenVersion,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.options.AccessTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.signer.sign(&Claims{
+		Subject:   user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
+		Type:      TokenTypeRefresh,
+		ID:        uuid.New().String(),
+		Version:   user.TokenVersion,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.options.RefreshTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(s.options.AccessTTL / time.Second),
+		RefreshExpiresIn: int64(s.options.RefreshTTL / time.Second),
+	}, nil
+}
+
+// validateCredentials 检查用户名和密码是否符合要求
+func validateCredentials(username, password string) error {
+	if username == "" {
+		return fmt.Errorf("%w: 用户名不能为空", ErrInvalidAccount)
+	}
+	if strings.ContainsAny(username, " \t\r\n") {
+		return fmt.Errorf("%w: 用户名不能包含空白字符", ErrInvalidAccount)
+	}
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("%w: 密码至少需要%d个字符", ErrInvalidAccount, MinPasswordLength)
+	}
+	// bcrypt只使用密码的前72个字节
+	if len(password) > 72 {
+		return fmt.Errorf("%w: 密码不能超过72个字节", ErrInvalidAccount)
+	}
+	return nil
+}
+
+// hashPassword 计算密码的bcrypt哈希
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("计算密码哈希失败: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CreateUser 创建用户，用户名已存在时返回ErrDuplicateUser
+func (s *Service) CreateUser(ctx context.Context, username, password string) (*User, error) {
+	if err := validateCredentials(username, password); err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user := &User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: hash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// EnsureInitialUser 还没有任何用户时创建初始用户，返回是否创建
+func (s *Service) EnsureInitialUser(ctx context.Context, username, password string) (bool, error) {
+	count, err := s.users.Count(ctx)
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if _, err := s.CreateUser(ctx, username, password); err != nil {
+		if errors.Is(err, ErrDuplicateUser) {
+			// 其他实例同时创建了初始用户
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetUser 按ID读取用户
+func (s *Service) GetUser(ctx context.Context, id string) (*User, error) {
+	return s.users.Get(ctx, id)
+}
+
+// GetUserByUsername 按用户名读取用户
+func (s *Service) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return s.users.GetByUsername(ctx, username)
+}
+
+// ListUsers 按用户名返回所有用户
+func (s *Service) ListUsers(ctx context.Context) ([]*User, error) {
+	return s.users.List(ctx)
+}
+
+// ChangePassword 校验旧密码后修改密码，用户已签发的令牌全部失效，返回为新登录签发的令牌
+func (s *Service) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) (*TokenPair, error) {
+	user, err := s.users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := validateCredentials(user.Username, newPassword); err != nil {
+		return nil, err
+	}
+	if user.PasswordHash, err = hashPassword(newPassword); err != nil {
+		return nil, err
+	}
+	user.TokenVersion++
+	user.UpdatedAt = time.Now()
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.issue(user, uuid.New().String())
+}
+
+// SetDisabled 禁用或启用用户，禁用后用户已签发的令牌立即失效
+func (s *Service) SetDisabled(ctx context.Context, userID string, disabled bool) (*User, error) {
+	user, err := s.users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.Disabled = disabled
+	user.UpdatedAt = time.Now()
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetLimits 替换用户的限额，值为0的项使用默认限额
+func (s *Service) SetLimits(ctx context.Context, userID string, limits Limits) (*User, error) {
+	if err := limits.validate(); err != nil {
+		return nil, err
+	}
+	user, err := s.users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.Limits = limits
+	user.UpdatedAt = time.Now()
+	if err := s.users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
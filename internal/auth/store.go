@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 在MongoDB中保存用户和已吊销令牌的集合名称
+const (
+	UsersCollectionName   = "users"
+	RevokedCollectionName = "revoked_tokens"
+)
+
+func init() {
+	database.ReserveCollections(UsersCollectionName, RevokedCollectionName, APIKeysCollectionName)
+}
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("用户不存在")
+	// ErrDuplicateUser 用户名已被使用
+	ErrDuplicateUser = errors.New("用户名已存在")
+)
+
+// User 用户账号，密码只保存bcrypt哈希
+type User struct {
+	ID           string    `json:"id" bson:"_id"`
+	Username     string    `json:"username" bson:"username"`
+	PasswordHash string    `json:"-" bson:"passwordHash"`
+	Disabled     bool      `json:"disabled" bson:"disabled"`        // 禁用的用户不能登录和刷新令牌
+	Limits       Limits    `json:"limits" bson:"limits"`            // 用户的限额，0表示使用默认限额
+	TokenVersion int       `json:"-" bson:"tokenVersion,omitempty"` // 修改密码时增加，之前签发的令牌随即失效
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// UserStore 保存用户账号，用户名区分大小写且唯一
+type UserStore interface {
+	// Create 保存新用户，用户名已存在时返回ErrDuplicateUser
+	Create(ctx context.Context, user *User) error
+	// Get 按ID读取用户，不存在时返回ErrUserNotFound
+	Get(ctx context.Context, id string) (*User, error)
+	// GetByUsername 按用户名读取用户，不存在时返回ErrUserNotFound
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	// Update 覆盖已有用户，不存在时返回ErrUserNotFound
+	Update(ctx context.Context, user *User) error
+	// List 按用户名返回所有用户
+	List(ctx context.Context) ([]*User, error)
+	// Count 返回用户数
+	Count(ctx context.Context) (int64, error)
+}
+
+// RevocationStore 保存已吊销的令牌ID和登录ID，记录在令牌过期后删除
+type RevocationStore interface {
+	// Revoke 吊销ID，expiresAt之后相关令牌已过期，记录可以删除
+	// 检查和写入是原子的，返回本次调用是否首先吊销了该ID，ID已被吊销时返回false
+	Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+	// Revoked 返回ids中是否有已吊销的ID
+	Revoked(ctx context.Context, ids ...string) (bool, error)
+}
+
+// MemoryUserStore 进程内的用户存储，用于未配置MongoDB时，服务重启后用户会丢失
+type MemoryUserStore struct {
+	users map[string]*User
+	mutex sync.Mutex
+}
+
+// NewMemoryUserStore 创建进程内用户存储
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[string]*User)}
+}
+
+// Create 保存新用户
+func (s *MemoryUserStore) Create(ctx context.Context, user *User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.byUsername(user.Username) != nil {
+		return ErrDuplicateUser
+	}
+	snapshot := *user
+	s.users[user.ID] = &snapshot
+	return nil
+}
+
+// Get 按ID读取用户
+func (s *MemoryUserStore) Get(ctx context.Context, id string) (*User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	snapshot := *user
+	return &snapshot, nil
+}
+
+// GetByUsername 按用户名读取用户
+func (s *MemoryUserStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user := s.byUsername(username)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	snapshot := *user
+	return &snapshot, nil
+}
+
+// Update 覆盖已有用户
+func (s *MemoryUserStore) Update(ctx context.Context, user *User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.users[user.ID]; !ok {
+		return ErrUserNotFound
+	}
+	if existing := s.byUsername(user.Username); existing != nil && existing.ID != user.ID {
+		return ErrDuplicateUser
+	}
+	snapshot := *user
+	s.users[user.ID] = &snapshot
+	return nil
+}
+
+// List 按用户名返回所有用户
+func (s *MemoryUserStore) List(ctx context.Context) ([]*User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]*User, 0, len(s.users))
+	for _, user := range s.users {
+		snapshot := *user
+		result = append(result, &snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Username < result[j].Username })
+	return result, nil
+}
+
+// Count 返回用户数
+func (s *MemoryUserStore) Count(ctx context.Context) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return int64(len(s.users)), nil
+}
+
+// byUsername 按用户名查找用户，调用方需持有锁
+func (s *MemoryUserStore) byUsername(username string) *User {
+	for _, user := range s.users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+// MongoUserStore 基于MongoDB的用户存储，多个服务实例可以共享
+type MongoUserStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoUserStore 创建MongoDB用户存储并确保用户名唯一索引存在
+func NewMongoUserStore(ctx context.Context, coll *mongo.Collection) (*MongoUserStore, error) {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetName("username").SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建用户索引失败: %w", err)
+	}
+	return &MongoUserStore{coll: coll}, nil
+}
+
+// Create 保存新用户
+func (s *MongoUserStore) Create(ctx context.Context, user *User) error {
+	_, err := s.coll.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateUser
+	}
+	return err
+}
+
+// Get 按ID读取用户
+func (s *MongoUserStore) Get(ctx context.Context, id string) (*User, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+// GetByUsername 按用户名读取用户
+func (s *MongoUserStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return s.findOne(ctx, bson.M{"username": username})
+}
+
+// findOne 读取满足条件的用户
+func (s *MongoUserStore) findOne(ctx context.Context, filter bson.M) (*User, error) {
+	var user User
+	err := s.coll.FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Update 覆盖已有用户
+func (s *MongoUserStore) Update(ctx context.Context, user *User) error {
+	result, err := s.coll.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateUser
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// List 按用户名返回所有用户
+func (s *MongoUserStore) List(ctx context.Context) ([]*User, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "username", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*User{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Count 返回用户数
+func (s *MongoUserStore) Count(ctx context.Context) (int64, error) {
+	return s.coll.CountDocuments(ctx, bson.M{})
+}
+
+// MemoryRevocationStore 进程内的吊销记录，服务重启后已注销的令牌在过期前重新有效
+type MemoryRevocationStore struct {
+	revoked map[string]time.Time
+	mutex   sync.Mutex
+}
+
+// NewMemoryRevocationStore 创建进程内吊销记录
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time)}
+}
+
+// Revoke 吊销ID，顺带删除已过期的记录
+func (s *MemoryRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for revokedID, expiry := range s.revoked {
+		if !now.Before(expiry) {
+			delete(s.revoked, revokedID)
+		}
+	}
+	if _, ok := s.revoked[id]; ok {
+		return false, nil
+	}
+	s.revoked[id] = expiresAt
+	return true, nil
+}
+
+// Revoked 返回ids中是否有已吊销的ID
+func (s *MemoryRevocationStore) Revoked(ctx context.Context, ids ...string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, id := range ids {
+		if _, ok := s.revoked[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MongoRevocationStore 基于MongoDB的吊销记录，expiresAt上的TTL索引负责删除过期记录
+type MongoRevocationStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoRevocationStore 创建MongoDB吊销记录并确保TTL索引存在
+func NewMongoRevocationStore(ctx context.Context, coll *mongo.Collection) (*MongoRevocationStore, error) {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建令牌吊销TTL索引失败: %w", err)
+	}
+	return &MongoRevocationStore{coll: coll}, nil
+}
+
+// Revoke 吊销ID，_id的唯一性保证同一ID只有一次调用返回true
+func (s *MongoRevocationStore) Revoke(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	_, err := s.coll.InsertOne(ctx, bson.M{"_id": id, "expiresAt": expiresAt})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Revoked 返回ids中是否有已吊销的ID
+func (s *MongoRevocationStore) Revoked(ctx context.Context, ids ...string) (bool, error) {
+	count, err := s.coll.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 令牌类型，刷新令牌不能用于访问接口，访问令牌不能用于刷新
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	// ErrInvalidToken 令牌格式、签名或类型无效
+	ErrInvalidToken = errors.New("令牌无效")
+	// ErrTokenExpired 令牌已过期
+	ErrTokenExpired = errors.New("令牌已过期")
+)
+
+// jwtHeader 所有令牌使用HS256签名
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims 令牌中的声明
+// 同一次登录签发的访问令牌和刷新令牌共享登录ID（sid），注销时按登录ID吊销
+type Claims struct {
+	Subject   string `json:"sub"`           // 用户ID
+	Username  string `json:"name"`          // 用户名
+	SessionID string `json:"sid"`           // 登录ID
+	Type      string `json:"typ"`           // access或refresh
+	ID        string `json:"jti"`           // 令牌ID
+	Version   int    `json:"ver,omitempty"` // 签发时用户的令牌版本，修改密码后旧版本的令牌失效
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Expiry 返回令牌的过期时间
+func (c *Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// signer 使用HMAC-SHA256签名和验证令牌
+type signer struct {
+	key []byte
+}
+
+// sign 签发令牌
+func (s signer) sign(claims *Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.signature(unsigned), nil
+}
+
+// parse 验证令牌的签名和有效期并返回声明
+func (s signer) parse(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var alg struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &alg); err != nil || alg.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	expected := s.signature(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Subject == "" || claims.SessionID == "" || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	if !now.Before(claims.Expiry()) {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+// signature 计算签名
+func (s signer) signature(unsigned string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
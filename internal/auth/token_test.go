@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// forge 用任意头部和声明构造令牌，key为nil时签名为空
+func forge(t *testing.T, header string, claims *Claims, key []byte) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if key == nil {
+		return unsigned + "."
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignerParse(t *testing.T) {
+	key := []byte("test-secret")
+	s := signer{key: key}
+	now := time.Unix(1700000000, 0)
+	claims := &Claims{
+		Subject:   "user-1",
+		Username:  "alice",
+		SessionID: "login-1",
+		Type:      TokenTypeAccess,
+		ID:        "token-1",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+	valid, err := s.sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, ".")
+	otherPayload := *claims
+	otherPayload.Subject = "admin"
+	otherPayloadJSON, _ := json.Marshal(&otherPayload)
+	missingSubject := *claims
+	missingSubject.Subject = ""
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{"有效令牌", valid, now, nil},
+		{"过期前一秒", valid, now.Add(time.Hour - time.Second), nil},
+		{"恰好过期", valid, now.Add(time.Hour), ErrTokenExpired},
+		{"已过期", valid, now.Add(2 * time.Hour), ErrTokenExpired},
+		{"其他密钥签名", forge(t, `{"alg":"HS256","typ":"JWT"}`, claims, []byte("other-secret")), now, ErrInvalidToken},
+		{"修改声明后沿用原签名", parts[0] + "." + base64.RawURLEncoding.EncodeToString(otherPayloadJSON) + "." + parts[2], now, ErrInvalidToken},
+		{"签名被截断", valid[:len(valid)-2], now, ErrInvalidToken},
+		{"alg为none且没有签名", forge(t, `{"alg":"none","typ":"JWT"}`, claims, nil), now, ErrInvalidToken},
+		{"alg为none但带有HS256签名", forge(t, `{"alg":"none","typ":"JWT"}`, claims, key), now, ErrInvalidToken},
+		{"alg为HS512", forge(t, `{"alg":"HS512","typ":"JWT"}`, claims, key), now, ErrInvalidToken},
+		{"alg为RS256", forge(t, `{"alg":"RS256","typ":"JWT"}`, claims, key), now, ErrInvalidToken},
+		{"alg大小写不同", forge(t, `{"alg":"hs256","typ":"JWT"}`, claims, key), now, ErrInvalidToken},
+		{"缺少alg", forge(t, `{"typ":"JWT"}`, claims, key), now, ErrInvalidToken},
+		{"缺少用户ID", forge(t, `{"alg":"HS256","typ":"JWT"}`, &missingSubject, key), now, ErrInvalidToken},
+		{"只有两段", parts[0] + "." + parts[1], now, ErrInvalidToken},
+		{"头部不是base64", "!!." + parts[1] + "." + parts[2], now, ErrInvalidToken},
+		{"空令牌", "", now, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.parse(tt.token, tt.now)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("parse() err = %v, 期望 %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && *got != *claims {
+				t.Errorf("parse() = %+v, 期望 %+v", got, claims)
+			}
+		})
+	}
+}
+
+func TestServiceTokens(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewService(nil, nil, nil, Options{Secret: "test-secret", AccessTTL: time.Hour, RefreshTTL: 2 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateUser(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, _, err := service.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewService(nil, nil, nil, Options{Secret: "other-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.CreateUser(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	otherTokens, _, err := other.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("访问令牌", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			token   string
+			wantErr error
+		}{
+			{"访问令牌可以访问接口", tokens.AccessToken, nil},
+			{"刷新令牌不能访问接口", tokens.RefreshToken, ErrInvalidToken},
+			{"其他服务签发的令牌", otherTokens.AccessToken, ErrInvalidToken},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, user, err := service.Authenticate(ctx, tt.token)
+				if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+					t.Fatalf("Authenticate() err = %v, 期望 %v", err, tt.wantErr)
+				}
+				if tt.wantErr == nil && user.Username != "alice" {
+					t.Errorf("Authenticate() 用户 = %s, 期望 alice", user.Username)
+				}
+			})
+		}
+	})
+
+	t.Run("访问令牌不能用于刷新", func(t *testing.T) {
+		if _, err := service.Refresh(ctx, tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Refresh() err = %v, 期望 ErrInvalidToken", err)
+		}
+	})
+
+	t.Run("刷新后旧的刷新令牌失效", func(t *testing.T) {
+		refreshed, err := service.Refresh(ctx, tokens.RefreshToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("再次Refresh() err = %v, 期望 ErrTokenRevoked", err)
+		}
+		claims, _, err := service.Authenticate(ctx, refreshed.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 注销后同一次登录的所有令牌失效
+		if err := service.Logout(ctx, claims); err != nil {
+			t.Fatal(err)
+		}
+		for _, token := range []string{tokens.AccessToken, refreshed.AccessToken} {
+			if _, _, err := service.Authenticate(ctx, token); !errors.Is(err, ErrTokenRevoked) {
+				t.Errorf("注销后Authenticate() err = %v, 期望 ErrTokenRevoked", err)
+			}
+		}
+		if _, err := service.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("注销后Refresh() err = %v, 期望 ErrTokenRevoked", err)
+		}
+	})
+
+	t.Run("并发刷新只有一个请求成功", func(t *testing.T) {
+		login, _, err := service.Login(ctx, "alice", "password123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		const workers = 8
+		var wg sync.WaitGroup
+		errs := make([]error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = service.Refresh(ctx, login.RefreshToken)
+			}(i)
+		}
+		wg.Wait()
+		succeeded := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, ErrTokenRevoked):
+				t.Errorf("Refresh() err = %v, 期望 ErrTokenRevoked", err)
+			}
+		}
+		if succeeded != 1 {
+			t.Errorf("成功刷新 %d 次, 期望 1 次", succeeded)
+		}
+	})
+}
+
+// laggingRevocations 吊销检查总是返回未吊销，模拟两个请求同时通过检查
+type laggingRevocations struct {
+	*MemoryRevocationStore
+}
+
+func (laggingRevocations) Revoked(ctx context.Context, ids ...string) (bool, error) {
+	return false, nil
+}
+
+func TestRefreshRevokesAtomically(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewService(nil, laggingRevocations{NewMemoryRevocationStore()}, nil, Options{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateUser(ctx, "alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, _, err := service.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Refresh(ctx, tokens.RefreshToken); err != nil {
+		t.Fatalf("第一次Refresh() err = %v", err)
+	}
+	if _, err := service.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("第二次Refresh() err = %v, 期望 ErrTokenRevoked", err)
+	}
+}
+
+func TestChangePasswordRevokesTokens(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewService(nil, nil, nil, Options{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := service.CreateUser(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := service.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := service.Login(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ChangePassword(ctx, user.ID, "wrong-password", "password456"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("旧密码错误时ChangePassword() err = %v, 期望 ErrInvalidCredentials", err)
+	}
+	if _, _, err := service.Authenticate(ctx, first.AccessToken); err != nil {
+		t.Fatalf("修改密码失败后Authenticate() err = %v", err)
+	}
+	changed, err := service.ChangePassword(ctx, user.ID, "password123", "password456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		refresh bool
+		wantErr error
+	}{
+		{"修改前的访问令牌", first.AccessToken, false, ErrTokenRevoked},
+		{"其他登录的访问令牌", second.AccessToken, false, ErrTokenRevoked},
+		{"修改前的刷新令牌", first.RefreshToken, true, ErrTokenRevoked},
+		{"其他登录的刷新令牌", second.RefreshToken, true, ErrTokenRevoked},
+		{"修改后签发的访问令牌", changed.AccessToken, false, nil},
+		{"修改后签发的刷新令牌", changed.RefreshToken, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.refresh {
+				_, err = service.Refresh(ctx, tt.token)
+			} else {
+				_, _, err = service.Authenticate(ctx, tt.token)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, 期望 %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, _, err := service.Login(ctx, "alice", "password123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("使用旧密码Login() err = %v, 期望 ErrInvalidCredentials", err)
+	}
+}
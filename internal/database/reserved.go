@@ -0,0 +1,29 @@
+package database
+
+import (
+	"strings"
+	"sync"
+)
+
+// reservedCollections 服务自身使用的集合，由使用这些集合的包在init中登记
+var (
+	reservedCollections = make(map[string]bool)
+	reservedMutex       sync.RWMutex
+)
+
+// ReserveCollections 登记服务自身使用的集合，模型不能使用这些集合，删除模型时也不会删除
+func ReserveCollections(names ...string) {
+	reservedMutex.Lock()
+	defer reservedMutex.Unlock()
+	for _, name := range names {
+		reservedCollections[strings.ToLower(name)] = true
+	}
+}
+
+// IsReservedCollection 集合是否由服务自身使用，集合名称按不区分大小写比较，system.开头的集合由MongoDB使用
+func IsReservedCollection(name string) bool {
+	name = strings.ToLower(name)
+	reservedMutex.RLock()
+	defer reservedMutex.RUnlock()
+	return reservedCollections[name] || strings.HasPrefix(name, "system.")
+}
@@ -0,0 +1,484 @@
+package pool
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Kind 连接池对应的数据源类型
+type Kind string
+
+const (
+	MySQL   Kind = "mysql"
+	SQLite  Kind = "sqlite"
+	MongoDB Kind = "mongodb"
+)
+
+// connMaxLifetime SQL连接的最长存活时间，避免长期占用服务器端已失效的连接
+const connMaxLifetime = time.Hour
+
+// ErrLimitReached 全局或单个目标的连接数已达上限，且没有可以释放的空闲连接池
+var ErrLimitReached = errors.New("已达到连接数上限")
+
+// Limits 连接数限制
+// 每个连接池按其最大连接数计入限制，而不是按当前实际打开的连接数，因此限制不会被突发请求突破
+type Limits struct {
+	MaxConnections int           // 所有连接池的最大连接数之和的上限，0表示不限制
+	MaxPerTarget   int           // 同一目标（MySQL服务器、SQLite文件或MongoDB主机列表）的连接数上限，0表示不限制
+	PoolSize       int           // 单个连接池的最大连接数
+	IdleTimeout    time.Duration // 连接池不再被引用后保留的时间，期间再次使用可以直接复用
+}
+
+// DefaultLimits 返回默认的连接数限制
+func DefaultLimits() Limits {
+	return Limits{
+		MaxConnections: 200,
+		MaxPerTarget:   50,
+		PoolSize:       10,
+		IdleTimeout:    5 * time.Minute,
+	}
+}
+
+// pool 一个共享的连接池
+// 除db、client和counters在打开完成后只读外，其余字段由Registry.mutex保护
+type pool struct {
+	key      string
+	kind     Kind
+	target   string
+	size     int // 最大连接数，即计入限制的连接数
+	refs     int
+	created  time.Time
+	lastUsed time.Time
+	ready    chan struct{} // 打开完成后关闭
+	err      error         // 打开失败的原因
+	idle     *time.Timer   // 引用归零后到期关闭连接池
+
+	db       *sql.DB
+	client   *mongo.Client
+	counters *mongoCounters
+}
+
+// isReady 连接池是否已成功打开，调用方需持有Registry.mutex
+func (p *pool) isReady() bool {
+	select {
+	case <-p.ready:
+		return p.err == nil
+	default:
+		return false
+	}
+}
+
+// close 关闭连接池的底层连接
+func (p *pool) close() {
+	if p.client != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.client.Disconnect(ctx)
+	}
+	if p.db != nil {
+		p.db.Close()
+	}
+}
+
+// ping 检查连接池是否可用
+func (p *pool) ping(ctx context.Context) error {
+	if p.client != nil {
+		return p.client.Ping(ctx, nil)
+	}
+	return p.db.PingContext(ctx)
+}
+
+// Registry 按连接参数共享连接池
+// 连接参数（含凭据）完全相同的使用方共享同一个连接池，连接池按引用计数在最后一个使用方释放后关闭
+type Registry struct {
+	limits Limits
+	pools  map[string]*pool
+	mutex  sync.Mutex
+}
+
+// NewRegistry 创建连接池注册表
+func NewRegistry(limits Limits) *Registry {
+	return &Registry{
+		limits: normalizeLimits(limits),
+		pools:  make(map[string]*pool),
+	}
+}
+
+// normalizeLimits 为未设置的项使用默认值
+func normalizeLimits(limits Limits) Limits {
+	defaults := DefaultLimits()
+	if limits.PoolSize <= 0 {
+		limits.PoolSize = defaults.PoolSize
+	}
+	if limits.MaxConnections < 0 {
+		limits.MaxConnections = 0
+	}
+	if limits.MaxPerTarget < 0 {
+		limits.MaxPerTarget = 0
+	}
+	if limits.IdleTimeout < 0 {
+		limits.IdleTimeout = 0
+	}
+	return limits
+}
+
+// SetLimits 修改连接数限制，只影响之后新建的连接池
+func (r *Registry) SetLimits(limits Limits) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.limits = normalizeLimits(limits)
+}
+
+// Limits 返回当前的连接数限制
+func (r *Registry) Limits() Limits {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.limits
+}
+
+// AcquireSQL 获取MySQL或SQLite连接池，返回的函数用于释放引用，调用方不能关闭返回的*sql.DB
+// target为连接的目标：MySQL为host:port，SQLite为文件路径；maxSize限制连接池的最大连接数，0表示使用默认大小
+// 返回前会验证连接可用
+func (r *Registry) AcquireSQL(ctx context.Context, kind Kind, dsn, target string, maxSize int) (*sql.DB, func(), error) {
+	var driver string
+	switch kind {
+	case MySQL:
+		driver = "mysql"
+		config, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("无效的MySQL连接字符串: %w", err)
+		}
+		dsn = config.FormatDSN()
+	case SQLite:
+		driver = "sqlite3"
+		if abs, err := filepath.Abs(target); err == nil {
+			target = abs
+		}
+	default:
+		return nil, nil, fmt.Errorf("不支持的连接池类型: %s", kind)
+	}
+
+	p, release, err := r.acquire(ctx, kind, dsn, target, maxSize, func(ctx context.Context, p *pool) error {
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			return err
+		}
+		db.SetMaxOpenConns(p.size)
+		db.SetMaxIdleConns(p.size)
+		db.SetConnMaxLifetime(connMaxLifetime)
+		p.db = db
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.db, release, nil
+}
+
+// AcquireMongo 获取MongoDB连接池，返回的函数用于释放引用，调用方不能断开返回的*mongo.Client
+// target为连接的主机列表；readOnly为true时优先从从节点读取，与可写连接使用不同的连接池
+// 返回前会验证连接可用
+func (r *Registry) AcquireMongo(ctx context.Context, uri string, readOnly bool, target string) (*mongo.Client, func(), error) {
+	key := normalizeMongoURI(uri)
+	if readOnly {
+		key += "\x00readOnly"
+	}
+
+	p, release, err := r.acquire(ctx, MongoDB, key, target, 0, func(ctx context.Context, p *pool) error {
+		p.counters = &mongoCounters{}
+		clientOptions := options.Client().ApplyURI(uri).
+			SetMaxPoolSize(uint64(p.size)).
+			SetPoolMonitor(p.counters.monitor())
+		if readOnly {
+			// 驱动无法拦截写命令，只读会话的写命令由会话查询接口拒绝
+			clientOptions.SetReadPreference(readpref.SecondaryPreferred())
+		}
+		client, err := mongo.Connect(ctx, clientOptions)
+		if err != nil {
+			return err
+		}
+		p.client = client
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.client, release, nil
+}
+
+// acquire 返回已有的连接池或新建连接池，并增加一次引用
+// 连接池在锁外打开和验证；同一连接参数的并发请求等待第一个请求打开连接池
+func (r *Registry) acquire(ctx context.Context, kind Kind, dsn, target string, maxSize int, open func(context.Context, *pool) error) (*pool, func(), error) {
+	key := poolKey(kind, dsn)
+
+	r.mutex.Lock()
+	p, exists := r.pools[key]
+	var evicted []*pool
+	if exists {
+		p.refs++
+		p.lastUsed = time.Now()
+		if p.idle != nil {
+			p.idle.Stop()
+			p.idle = nil
+		}
+	} else {
+		var size int
+		var err error
+		size, evicted, err = r.reserve(target, maxSize)
+		if err != nil {
+			r.mutex.Unlock()
+			return nil, nil, err
+		}
+		now := time.Now()
+		p = &pool{
+			key:      key,
+			kind:     kind,
+			target:   target,
+			size:     size,
+			refs:     1,
+			created:  now,
+			lastUsed: now,
+			ready:    make(chan struct{}),
+		}
+		r.pools[key] = p
+	}
+	r.mutex.Unlock()
+
+	for _, old := range evicted {
+		old.close()
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() { r.release(p) })
+	}
+
+	if !exists {
+		err := open(ctx, p)
+		if err == nil {
+			err = p.ping(ctx)
+		}
+		if err != nil {
+			p.close()
+			r.mutex.Lock()
+			if r.pools[key] == p {
+				delete(r.pools, key)
+			}
+			p.err = err
+			close(p.ready)
+			r.mutex.Unlock()
+			return nil, nil, err
+		}
+		close(p.ready)
+		return p, release, nil
+	}
+
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		release()
+		return nil, nil, ctx.Err()
+	}
+	if p.err != nil {
+		release()
+		return nil, nil, p.err
+	}
+	// 复用的连接池同样在返回前验证，连接池本身会重新建立失效的连接
+	if err := p.ping(ctx); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return p, release, nil
+}
+
+// reserve 为新连接池计算最大连接数，必要时关闭最久未使用的空闲连接池以腾出名额，调用方需持有r.mutex
+// 返回被移除的连接池，由调用方在锁外关闭
+func (r *Registry) reserve(target string, maxSize int) (int, []*pool, error) {
+	size := r.limits.PoolSize
+	if maxSize > 0 && maxSize < size {
+		size = maxSize
+	}
+
+	// 可以关闭的空闲连接池，最久未使用的在前
+	var candidates []*pool
+	for _, p := range r.pools {
+		if p.refs == 0 && p.isReady() {
+			candidates = append(candidates, p)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed.Before(candidates[j].lastUsed)
+	})
+
+	var evicted []*pool
+	for {
+		available := r.available(target)
+		if available >= size {
+			break
+		}
+		// 只关闭能腾出名额的空闲连接池：受单个目标限制时只能关闭同一目标的连接池
+		targetLimited := r.targetAvailable(target) < size
+		index := -1
+		for i, p := range candidates {
+			if targetLimited && p.target != target {
+				continue
+			}
+			index = i
+			break
+		}
+		if index < 0 {
+			if available > 0 {
+				// 没有可以关闭的连接池时缩小新连接池
+				size = available
+				break
+			}
+			return 0, nil, fmt.Errorf("%w: 全局上限%d，目标 %s 上限%d", ErrLimitReached, r.limits.MaxConnections, target, r.limits.MaxPerTarget)
+		}
+		p := candidates[index]
+		candidates = append(candidates[:index], candidates[index+1:]...)
+		r.remove(p)
+		evicted = append(evicted, p)
+	}
+	return size, evicted, nil
+}
+
+// available 返回目标还可以使用的连接数，调用方需持有r.mutex
+func (r *Registry) available(target string) int {
+	available := r.targetAvailable(target)
+	if r.limits.MaxConnections > 0 {
+		reserved := 0
+		for _, p := range r.pools {
+			reserved += p.size
+		}
+		if global := r.limits.MaxConnections - reserved; global < available {
+			available = global
+		}
+	}
+	return available
+}
+
+// targetAvailable 只按单个目标的限制返回还可以使用的连接数，调用方需持有r.mutex
+func (r *Registry) targetAvailable(target string) int {
+	if r.limits.MaxPerTarget <= 0 {
+		return int(^uint(0) >> 1)
+	}
+	reserved := 0
+	for _, p := range r.pools {
+		if p.target == target {
+			reserved += p.size
+		}
+	}
+	return r.limits.MaxPerTarget - reserved
+}
+
+// remove 从注册表移除连接池，调用方需持有r.mutex
+func (r *Registry) remove(p *pool) {
+	if p.idle != nil {
+		p.idle.Stop()
+		p.idle = nil
+	}
+	if r.pools[p.key] == p {
+		delete(r.pools, p.key)
+	}
+}
+
+// release 减少一次引用，引用归零后连接池在空闲超时后关闭
+func (r *Registry) release(p *pool) {
+	r.mutex.Lock()
+	p.refs--
+	p.lastUsed = time.Now()
+	if p.refs > 0 || r.pools[p.key] != p || !p.isReady() {
+		r.mutex.Unlock()
+		return
+	}
+	if r.limits.IdleTimeout > 0 {
+		p.idle = time.AfterFunc(r.limits.IdleTimeout, func() { r.expire(p) })
+		r.mutex.Unlock()
+		return
+	}
+	r.remove(p)
+	r.mutex.Unlock()
+	p.close()
+}
+
+// expire 关闭空闲超时且仍未被重新使用的连接池
+func (r *Registry) expire(p *pool) {
+	r.mutex.Lock()
+	if p.refs > 0 || r.pools[p.key] != p {
+		r.mutex.Unlock()
+		return
+	}
+	r.remove(p)
+	r.mutex.Unlock()
+	p.close()
+}
+
+// poolKey 根据类型和连接参数生成连接池的键，连接参数中的凭据不会以明文保存
+func poolKey(kind Kind, dsn string) string {
+	sum := sha256.Sum256([]byte(string(kind) + "\x00" + dsn))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeMongoURI 规范化MongoDB连接字符串，使等价的连接字符串使用同一个连接池
+// 协议、主机名和选项名不区分大小写，主机和选项的顺序不影响连接；选项值和用户信息保持原样
+// 同名选项（如readPreferenceTags）保持原有的相对顺序；无法解析时返回原字符串
+func normalizeMongoURI(uri string) string {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok || rest == "" {
+		return uri
+	}
+	scheme = strings.ToLower(scheme)
+
+	rest, query, _ := strings.Cut(rest, "?")
+	authority, database, _ := strings.Cut(rest, "/")
+	userInfo := ""
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		userInfo, authority = authority[:at+1], authority[at+1:]
+	}
+
+	hosts := strings.Split(strings.ToLower(authority), ",")
+	sort.Strings(hosts)
+
+	var options []string
+	for _, option := range strings.FieldsFunc(query, func(r rune) bool { return r == '&' || r == ';' }) {
+		name, value, _ := strings.Cut(option, "=")
+		options = append(options, strings.ToLower(name)+"="+value)
+	}
+	sort.SliceStable(options, func(i, j int) bool {
+		nameI, _, _ := strings.Cut(options[i], "=")
+		nameJ, _, _ := strings.Cut(options[j], "=")
+		return nameI < nameJ
+	})
+
+	normalized := scheme + "://" + userInfo + strings.Join(hosts, ",") + "/" + database
+	if len(options) > 0 {
+		normalized += "?" + strings.Join(options, "&")
+	}
+	return normalized
+}
+
+// 全局连接池注册表
+var globalRegistry *Registry
+var once sync.Once
+
+// GetRegistry 获取全局连接池注册表
+func GetRegistry() *Registry {
+	once.Do(func() {
+		globalRegistry = NewRegistry(DefaultLimits())
+	})
+	return globalRegistry
+}
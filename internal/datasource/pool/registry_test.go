@@ -0,0 +1,30 @@
+package pool
+
+import "testing"
+
+func TestNormalizeMongoURI(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"选项顺序", "mongodb://localhost:27017/db?w=majority&retryWrites=true", "mongodb://localhost:27017/db?retryWrites=true&w=majority", true},
+		{"主机名大小写", "mongodb://LocalHost:27017/db", "mongodb://localhost:27017/db", true},
+		{"协议大小写", "MongoDB://localhost:27017", "mongodb://localhost:27017/", true},
+		{"选项名大小写", "mongodb://h/db?ReplicaSet=rs0", "mongodb://h/db?replicaset=rs0", true},
+		{"主机顺序", "mongodb://b:27017,a:27017/db", "mongodb://a:27017,b:27017/db", true},
+		{"分号分隔选项", "mongodb://h/db?w=1;journal=true", "mongodb://h/db?journal=true&w=1", true},
+		{"选项值区分大小写", "mongodb://h/db?replicaSet=RS0", "mongodb://h/db?replicaSet=rs0", false},
+		{"用户不同", "mongodb://alice:pw@h/db", "mongodb://bob:pw@h/db", false},
+		{"数据库不同", "mongodb://h/a", "mongodb://h/b", false},
+		{"同名选项顺序", "mongodb://h/?readPreferenceTags=dc:a&readPreferenceTags=dc:b", "mongodb://h/?readPreferenceTags=dc:b&readPreferenceTags=dc:a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := normalizeMongoURI(tt.a), normalizeMongoURI(tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("normalizeMongoURI(%q) = %q, normalizeMongoURI(%q) = %q, 期望相同: %v", tt.a, a, tt.b, b, tt.same)
+			}
+		})
+	}
+}
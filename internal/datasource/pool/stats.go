@@ -0,0 +1,131 @@
+package pool
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// mongoCounters 通过连接池事件统计MongoDB客户端的连接数
+type mongoCounters struct {
+	open      atomic.Int64
+	inUse     atomic.Int64
+	getFailed atomic.Int64
+}
+
+// monitor 返回更新计数的连接池监视器
+func (c *mongoCounters) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				c.open.Add(1)
+			case event.ConnectionClosed:
+				c.open.Add(-1)
+			case event.GetSucceeded:
+				c.inUse.Add(1)
+			case event.ConnectionReturned:
+				c.inUse.Add(-1)
+			case event.GetFailed:
+				c.getFailed.Add(1)
+			}
+		},
+	}
+}
+
+// PoolStats 单个连接池的状态
+type PoolStats struct {
+	ID             string    `json:"id"` // 连接参数摘要的前12位，不包含凭据
+	Kind           Kind      `json:"kind"`
+	Target         string    `json:"target"`
+	Refs           int       `json:"refs"`    // 正在使用连接池的会话和连接器数量，为0时连接池在空闲超时后关闭
+	MaxOpen        int       `json:"maxOpen"` // 最大连接数，计入连接数限制
+	Ready          bool      `json:"ready"`   // 为false时连接池正在打开
+	Created        time.Time `json:"created"`
+	LastUsed       time.Time `json:"lastUsed"`
+	Open           int       `json:"open"`                     // 已建立的连接数
+	InUse          int       `json:"inUse"`                    // 正在使用的连接数
+	Idle           int       `json:"idle"`                     // 空闲连接数
+	WaitCount      int64     `json:"waitCount,omitempty"`      // SQL：因连接数已满而等待的次数
+	WaitDurationMs float64   `json:"waitDurationMs,omitempty"` // SQL：等待连接的总时间
+	GetFailed      int64     `json:"getFailed,omitempty"`      // MongoDB：获取连接失败的次数
+}
+
+// Stats 所有连接池的状态和连接数限制
+type Stats struct {
+	MaxConnections     int            `json:"maxConnections"` // 0表示不限制
+	MaxPerTarget       int            `json:"maxPerTarget"`   // 0表示不限制
+	PoolSize           int            `json:"poolSize"`
+	IdleTimeoutSeconds int64          `json:"idleTimeoutSeconds"`
+	Reserved           int            `json:"reserved"` // 所有连接池的最大连接数之和
+	Targets            map[string]int `json:"targets"`  // 每个目标的最大连接数之和
+	Pools              []PoolStats    `json:"pools"`
+}
+
+// Stats 返回所有连接池的状态，按目标和创建时间排序
+func (r *Registry) Stats() *Stats {
+	r.mutex.Lock()
+	stats := &Stats{
+		MaxConnections:     r.limits.MaxConnections,
+		MaxPerTarget:       r.limits.MaxPerTarget,
+		PoolSize:           r.limits.PoolSize,
+		IdleTimeoutSeconds: int64(r.limits.IdleTimeout / time.Second),
+		Targets:            make(map[string]int),
+		Pools:              []PoolStats{},
+	}
+	// 与stats.Pools一一对应，未打开完成的连接池为nil
+	var handles []*pool
+	for _, p := range r.pools {
+		stats.Reserved += p.size
+		stats.Targets[p.target] += p.size
+		item := PoolStats{
+			ID:       p.key[:12],
+			Kind:     p.kind,
+			Target:   p.target,
+			Refs:     p.refs,
+			MaxOpen:  p.size,
+			Ready:    p.isReady(),
+			Created:  p.created,
+			LastUsed: p.lastUsed,
+		}
+		stats.Pools = append(stats.Pools, item)
+		if item.Ready {
+			handles = append(handles, p)
+		} else {
+			handles = append(handles, nil)
+		}
+	}
+	r.mutex.Unlock()
+
+	// 连接数在锁外读取，打开完成的连接池的句柄不会再改变
+	for i, p := range handles {
+		if p == nil {
+			continue
+		}
+		item := &stats.Pools[i]
+		if p.db != nil {
+			dbStats := p.db.Stats()
+			item.Open = dbStats.OpenConnections
+			item.InUse = dbStats.InUse
+			item.Idle = dbStats.Idle
+			item.WaitCount = dbStats.WaitCount
+			item.WaitDurationMs = float64(dbStats.WaitDuration.Microseconds()) / 1000
+		}
+		if p.counters != nil {
+			item.Open = int(p.counters.open.Load())
+			item.InUse = int(p.counters.inUse.Load())
+			item.Idle = item.Open - item.InUse
+			item.GetFailed = p.counters.getFailed.Load()
+		}
+	}
+
+	sort.Slice(stats.Pools, func(i, j int) bool {
+		if stats.Pools[i].Target != stats.Pools[j].Target {
+			return stats.Pools[i].Target < stats.Pools[j].Target
+		}
+		return stats.Pools[i].Created.Before(stats.Pools[j].Created)
+	})
+	return stats
+}
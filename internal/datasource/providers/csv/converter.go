@@ -2,10 +2,9 @@ package csv
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
-	"time"
 
+	"minds_iolite_backend/internal/datasource/typesystem"
 	"minds_iolite_backend/internal/models/datasource"
 )
 
@@ -161,65 +160,22 @@ func (c *CSVConverter) ValidateData(csvData *CSVData) []datasource.ValidationErr
 	return errors
 }
 
-// convertValue 根据类型转换值
+// convertValue 根据类型转换值，使用typesystem的文本转换规则
 func (c *CSVConverter) convertValue(value string, columnType datasource.ColumnType) (interface{}, error) {
-	value = strings.TrimSpace(value)
-
-	// 处理空值
-	if value == "" {
-		return nil, nil
-	}
-
-	switch columnType {
-	case datasource.ColumnTypeInteger:
-		return strconv.ParseInt(value, 10, 64)
-	case datasource.ColumnTypeFloat:
-		return strconv.ParseFloat(value, 64)
-	case datasource.ColumnTypeBoolean:
-		return parseBool(value)
-	case datasource.ColumnTypeDate:
-		return parseDate(value)
-	case datasource.ColumnTypeObject:
+	if columnType == datasource.ColumnTypeObject {
+		if value = strings.TrimSpace(value); value == "" {
+			return nil, nil
+		}
 		// 简单实现，可以扩展为JSON解析
 		return map[string]interface{}{"value": value}, nil
-	default:
-		return value, nil
 	}
+	return typesystem.ParseText(value, typesystem.FromColumnType(columnType))
 }
 
 // validateValue 验证值是否符合类型要求
 func (c *CSVConverter) validateValue(value string, columnType datasource.ColumnType) error {
-	value = strings.TrimSpace(value)
-
-	// 空值直接通过
-	if value == "" {
-		return nil
-	}
-
-	switch columnType {
-	case datasource.ColumnTypeInteger:
-		_, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return fmt.Errorf("值 '%s' 不是有效的整数", value)
-		}
-	case datasource.ColumnTypeFloat:
-		_, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return fmt.Errorf("值 '%s' 不是有效的浮点数", value)
-		}
-	case datasource.ColumnTypeBoolean:
-		_, err := parseBool(value)
-		if err != nil {
-			return fmt.Errorf("值 '%s' 不是有效的布尔值", value)
-		}
-	case datasource.ColumnTypeDate:
-		_, err := parseDate(value)
-		if err != nil {
-			return fmt.Errorf("值 '%s' 不是有效的日期", value)
-		}
-	}
-
-	return nil
+	_, err := c.convertValue(value, columnType)
+	return err
 }
 
 // getDisplayName 从字段名生成显示名称
@@ -238,39 +194,3 @@ func (c *CSVConverter) getDisplayName(fieldName string) string {
 
 	return strings.Join(words, " ")
 }
-
-// 辅助函数
-
-// parseBool 解析布尔值
-func parseBool(value string) (bool, error) {
-	value = strings.ToLower(value)
-	switch value {
-	case "true", "yes", "1", "y", "t":
-		return true, nil
-	case "false", "no", "0", "n", "f":
-		return false, nil
-	default:
-		return false, fmt.Errorf("无法将 '%s' 解析为布尔值", value)
-	}
-}
-
-// parseDate 解析日期
-func parseDate(value string) (time.Time, error) {
-	formats := []string{
-		"2006-01-02",
-		"2006/01/02",
-		"02-01-2006",
-		"02/01/2006",
-		"2006-01-02 15:04:05",
-		"2006/01/02 15:04:05",
-		time.RFC3339,
-	}
-
-	for _, format := range formats {
-		if t, err := time.Parse(format, value); err == nil {
-			return t, nil
-		}
-	}
-
-	return time.Time{}, fmt.Errorf("无法将 '%s' 解析为日期", value)
-}
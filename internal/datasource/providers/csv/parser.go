@@ -6,10 +6,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"time"
 
+	"minds_iolite_backend/internal/datasource/typesystem"
 	"minds_iolite_backend/internal/models/datasource"
 )
 
@@ -211,78 +210,18 @@ func (p *CSVParser) inferColumnTypes(data *CSVData) error {
 }
 
 // inferColumnTypesFromSample 从样本数据推断列类型
+// 使用typesystem的文本推断规则，与其他数据源对整数、布尔值和日期的判断一致；整列为空时为字符串
 func (p *CSVParser) inferColumnTypesFromSample(headers []string, rows [][]string) map[string]datasource.ColumnType {
 	columnTypes := make(map[string]datasource.ColumnType)
 
-	// 初始化所有列为字符串类型
-	for _, header := range headers {
-		columnTypes[header] = datasource.ColumnTypeString
-	}
-
-	// 对每一列进行类型推断
 	for colIndex, header := range headers {
-		// 统计不同类型的出现次数
-		typeCount := map[datasource.ColumnType]int{
-			datasource.ColumnTypeInteger: 0,
-			datasource.ColumnTypeFloat:   0,
-			datasource.ColumnTypeBoolean: 0,
-			datasource.ColumnTypeDate:    0,
-			datasource.ColumnTypeString:  0,
-		}
-
-		// 检查每一行的值
+		values := make([]string, 0, len(rows))
 		for _, row := range rows {
-			if colIndex >= len(row) {
-				continue
-			}
-			value := strings.TrimSpace(row[colIndex])
-
-			// 忽略空值
-			if value == "" {
-				continue
+			if colIndex < len(row) {
+				values = append(values, row[colIndex])
 			}
-
-			// 尝试各种类型转换
-			if isBoolean(value) {
-				typeCount[datasource.ColumnTypeBoolean]++
-			} else if isInteger(value) {
-				typeCount[datasource.ColumnTypeInteger]++
-			} else if isFloat(value) {
-				typeCount[datasource.ColumnTypeFloat]++
-			} else if isDate(value) {
-				typeCount[datasource.ColumnTypeDate]++
-			} else {
-				typeCount[datasource.ColumnTypeString]++
-			}
-		}
-
-		// 确定最可能的类型
-		var mostLikelyType datasource.ColumnType = datasource.ColumnTypeString
-
-		// 按优先级顺序检查
-		booleanRatio := float64(typeCount[datasource.ColumnTypeBoolean]) / float64(len(rows))
-		integerRatio := float64(typeCount[datasource.ColumnTypeInteger]) / float64(len(rows))
-		floatRatio := float64(typeCount[datasource.ColumnTypeFloat]) / float64(len(rows))
-		dateRatio := float64(typeCount[datasource.ColumnTypeDate]) / float64(len(rows))
-
-		// 1. 如果布尔值占比高，判断为布尔型
-		if booleanRatio > 0.8 {
-			mostLikelyType = datasource.ColumnTypeBoolean
-		} else if integerRatio > 0.8 {
-			// 2. 如果整数占比高，判断为整数型
-			mostLikelyType = datasource.ColumnTypeInteger
-		} else if floatRatio > 0.8 {
-			// 3. 如果浮点数占比高，判断为浮点型
-			mostLikelyType = datasource.ColumnTypeFloat
-		} else if dateRatio > 0.8 {
-			// 4. 如果日期占比高，判断为日期型
-			mostLikelyType = datasource.ColumnTypeDate
-		} else {
-			// 5. 其他情况，判断为字符串型
-			mostLikelyType = datasource.ColumnTypeString
 		}
-
-		columnTypes[header] = mostLikelyType
+		columnTypes[header] = typesystem.ToColumnType(typesystem.FromTexts(values))
 	}
 
 	return columnTypes
@@ -334,40 +273,3 @@ func normalizeHeader(header string) string {
 
 	return header
 }
-
-// 类型判断辅助函数
-func isInteger(s string) bool {
-	_, err := strconv.ParseInt(s, 10, 64)
-	return err == nil
-}
-
-func isFloat(s string) bool {
-	_, err := strconv.ParseFloat(s, 64)
-	return err == nil
-}
-
-func isBoolean(s string) bool {
-	s = strings.ToLower(s)
-	return s == "true" || s == "false" || s == "yes" || s == "no" || s == "1" || s == "0"
-}
-
-func isDate(s string) bool {
-	formats := []string{
-		"2006-01-02",
-		"2006/01/02",
-		"02-01-2006",
-		"02/01/2006",
-		"2006-01-02 15:04:05",
-		"2006/01/02 15:04:05",
-		time.RFC3339,
-	}
-
-	for _, format := range formats {
-		_, err := time.Parse(format, s)
-		if err == nil {
-			return true
-		}
-	}
-
-	return false
-}
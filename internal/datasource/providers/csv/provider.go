@@ -0,0 +1,177 @@
+package csv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+	"minds_iolite_backend/internal/models/datasource"
+)
+
+// ProviderName CSV数据源类型名
+const ProviderName = "csv"
+
+func init() {
+	providers.Register(Provider{})
+}
+
+// Provider CSV数据源提供者，一个文件对应一个实体，实体名为不含扩展名的文件名
+// 配置项: filePath、delimiter(默认逗号)、hasHeader(默认true)、skipRows、encoding
+type Provider struct{}
+
+// Name 返回数据源类型名
+func (Provider) Name() string {
+	return ProviderName
+}
+
+// ValidateConfig 检查文件和解析选项
+func (Provider) ValidateConfig(cfg providers.Config) error {
+	_, err := sourceFromConfig(cfg)
+	return err
+}
+
+// Connect 校验文件并读取表头和列类型
+func (Provider) Connect(ctx context.Context, cfg providers.Config) (providers.Connection, error) {
+	source, err := sourceFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := NewCSVParser(source).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return &connection{
+		source:      source,
+		name:        strings.TrimSuffix(filepath.Base(source.FilePath), filepath.Ext(source.FilePath)),
+		headers:     data.Headers,
+		columnTypes: data.ColumnTypes,
+		converter:   NewCSVConverter(nil, nil),
+	}, nil
+}
+
+// sourceFromConfig 从通用配置构建CSV数据源配置
+func sourceFromConfig(cfg providers.Config) (*datasource.CSVSource, error) {
+	source := datasource.NewCSVSource(cfg.String("filePath", "file_path", "path"))
+	if delimiter := cfg.String("delimiter"); delimiter != "" {
+		source.Delimiter = delimiter
+	}
+	if hasHeader, ok := cfg.Bool("hasHeader", "has_header"); ok {
+		source.HasHeader = hasHeader
+	}
+	skipRows, err := cfg.Int(0, "skipRows", "skip_rows")
+	if err != nil {
+		return nil, err
+	}
+	source.SkipRows = skipRows
+	if encoding := cfg.String("encoding"); encoding != "" {
+		source.Encoding = encoding
+	}
+
+	if err := source.Validate(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// connection CSV文件的通用连接，只缓存表头和推断的列类型
+type connection struct {
+	source      *datasource.CSVSource
+	name        string
+	headers     []string
+	columnTypes map[string]datasource.ColumnType
+	converter   *CSVConverter
+}
+
+// ListEntities 返回文件本身
+func (c *connection) ListEntities(ctx context.Context) ([]providers.Entity, error) {
+	return []providers.Entity{{Name: c.name, Kind: "file"}}, nil
+}
+
+// Describe 返回表头和推断的列类型
+func (c *connection) Describe(ctx context.Context, entity string) (*providers.EntitySchema, error) {
+	if err := c.checkEntity(entity); err != nil {
+		return nil, err
+	}
+	schema := &providers.EntitySchema{Name: c.name, Fields: make([]providers.Field, 0, len(c.headers))}
+	for _, header := range c.headers {
+		columnType := c.columnType(header)
+		schema.Fields = append(schema.Fields, providers.Field{
+			Name:       header,
+			Type:       typesystem.FromColumnType(columnType),
+			NativeType: string(columnType),
+			Nullable:   true,
+		})
+	}
+	return schema, nil
+}
+
+// Sample 读取前limit行
+func (c *connection) Sample(ctx context.Context, entity string, limit int) ([]providers.Row, error) {
+	limit = providers.NormalizeLimit(limit)
+	rows := []providers.Row{}
+	err := c.StreamRows(ctx, entity, func(row providers.Row) error {
+		rows = append(rows, row)
+		if len(rows) >= limit {
+			return errStopStream
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopStream) {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// errStopStream 用于提前结束流式读取
+var errStopStream = errors.New("stop")
+
+// StreamRows 流式读取文件，按推断的列类型转换值，转换失败时保留原始字符串
+func (c *connection) StreamRows(ctx context.Context, entity string, fn providers.RowHandler) error {
+	if err := c.checkEntity(entity); err != nil {
+		return err
+	}
+	return NewCSVParser(c.source).ParseStream(func(rowIndex int, values []string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row := make(providers.Row, len(c.headers))
+		for i, header := range c.headers {
+			if i >= len(values) {
+				row[header] = nil
+				continue
+			}
+			value, err := c.converter.convertValue(values[i], c.columnType(header))
+			if err != nil {
+				value = values[i]
+			}
+			row[header] = value
+		}
+		return fn(row)
+	})
+}
+
+// columnType 返回列的推断类型，未知时为字符串
+func (c *connection) columnType(header string) datasource.ColumnType {
+	if t, ok := c.columnTypes[header]; ok {
+		return t
+	}
+	return datasource.ColumnTypeString
+}
+
+// checkEntity 实体名为空或与文件名一致时有效
+func (c *connection) checkEntity(entity string) error {
+	if entity != "" && entity != c.name {
+		return fmt.Errorf("实体 %s 不存在", entity)
+	}
+	return nil
+}
+
+// Close CSV连接不持有资源
+func (c *connection) Close() error {
+	return nil
+}
@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIntrospectConcurrency 默认同时推断的表/集合数
+	DefaultIntrospectConcurrency = 4
+	// MaxIntrospectConcurrency 允许的最大并发数
+	MaxIntrospectConcurrency = 32
+	// DefaultTableTimeout 单个表/集合推断的默认超时时间
+	DefaultTableTimeout = 30 * time.Second
+)
+
+// IntrospectOptions 控制批量结构推断的范围和并发
+// 名称模式使用glob语法（* ? [...]），不区分大小写；Include为空时包含全部，Exclude优先
+type IntrospectOptions struct {
+	Include      []string      // 只推断匹配任一模式的表/集合
+	Exclude      []string      // 跳过匹配任一模式的表/集合
+	Concurrency  int           // 并发数，<=0时使用默认值
+	TableTimeout time.Duration // 单个表/集合的超时时间，<=0时使用默认值
+}
+
+// Normalize 填充默认值并限制并发上限
+func (o IntrospectOptions) Normalize() IntrospectOptions {
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultIntrospectConcurrency
+	}
+	if o.Concurrency > MaxIntrospectConcurrency {
+		o.Concurrency = MaxIntrospectConcurrency
+	}
+	if o.TableTimeout <= 0 {
+		o.TableTimeout = DefaultTableTimeout
+	}
+	return o
+}
+
+// Validate 检查名称模式的语法
+func (o IntrospectOptions) Validate() error {
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("无效的名称模式 %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match 判断名称是否在推断范围内
+func (o IntrospectOptions) Match(name string) bool {
+	if matchAny(o.Exclude, name) {
+		return false
+	}
+	return len(o.Include) == 0 || matchAny(o.Include, name)
+}
+
+// Filter 返回在推断范围内的名称，保持原有顺序
+func (o IntrospectOptions) Filter(names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if o.Match(name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// matchAny 判断名称是否匹配任一模式
+func matchAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IntrospectEach 按并发限制对每个名称调用fn，每次调用使用独立的超时
+// 返回失败的名称及其错误；某个名称失败不影响其他名称
+func IntrospectEach(ctx context.Context, names []string, opts IntrospectOptions, fn func(ctx context.Context, name string) error) map[string]error {
+	opts = opts.Normalize()
+
+	var mu sync.Mutex
+	failures := make(map[string]error)
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		// 整体请求已取消时，剩余的名称直接记为失败
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			failures[name] = ctx.Err()
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			tableCtx, cancel := context.WithTimeout(ctx, opts.TableTimeout)
+			defer cancel()
+
+			if err := fn(tableCtx, name); err != nil {
+				if tableCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+					err = fmt.Errorf("超过 %s 超时: %w", opts.TableTimeout, err)
+				}
+				mu.Lock()
+				failures[name] = err
+				mu.Unlock()
+			}
+		}(name)
+	}
+
+	wg.Wait()
+	return failures
+}
@@ -0,0 +1,95 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DatabaseInfo 表示服务器上的一个数据库
+type DatabaseInfo struct {
+	Name       string `json:"name"`
+	SizeOnDisk int64  `json:"sizeOnDisk"` // 磁盘占用(字节)
+	Empty      bool   `json:"empty"`
+	System     bool   `json:"system"` // 是否为admin/config/local系统库
+}
+
+// CollectionSummary 表示集合的轻量元信息，不读取文档
+type CollectionSummary struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"` // collection/view/timeseries
+	ReadOnly       bool   `json:"readOnly"`
+	EstimatedCount int64  `json:"estimatedCount"` // 基于集合元数据的估算文档数，视图为0
+}
+
+// systemDatabases MongoDB内置的系统库
+var systemDatabases = map[string]bool{
+	"admin":  true,
+	"config": true,
+	"local":  true,
+}
+
+// ListDatabases 列出当前用户有权限访问的数据库
+func (c *MongoDBConnector) ListDatabases(ctx context.Context) ([]DatabaseInfo, error) {
+	result, err := c.client.ListDatabases(ctx, bson.M{}, options.ListDatabases().SetAuthorizedDatabases(true))
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库列表失败: %w", err)
+	}
+
+	databases := make([]DatabaseInfo, 0, len(result.Databases))
+	for _, spec := range result.Databases {
+		databases = append(databases, DatabaseInfo{
+			Name:       spec.Name,
+			SizeOnDisk: spec.SizeOnDisk,
+			Empty:      spec.Empty,
+			System:     systemDatabases[spec.Name],
+		})
+	}
+	sort.Slice(databases, func(i, j int) bool {
+		return databases[i].Name < databases[j].Name
+	})
+	return databases, nil
+}
+
+// ListCollections 列出数据库中的集合及其类型和估算文档数
+func (c *MongoDBConnector) ListCollections(ctx context.Context, dbName string) ([]CollectionSummary, error) {
+	db := c.client.Database(dbName)
+
+	cursor, err := db.ListCollections(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("获取集合列表失败: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var specs []collectionSpec
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, fmt.Errorf("读取集合列表失败: %w", err)
+	}
+
+	collections := make([]CollectionSummary, 0, len(specs))
+	for _, spec := range specs {
+		summary := CollectionSummary{
+			Name:     spec.Name,
+			Type:     spec.Type,
+			ReadOnly: spec.Info.ReadOnly,
+		}
+		if summary.Type == "" {
+			summary.Type = CollectionTypeCollection
+		}
+		// 视图不支持估算计数
+		if summary.Type != CollectionTypeView {
+			count, err := db.Collection(spec.Name).EstimatedDocumentCount(ctx)
+			if err == nil {
+				summary.EstimatedCount = count
+			}
+		}
+		collections = append(collections, summary)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].Name < collections[j].Name
+	})
+	return collections, nil
+}
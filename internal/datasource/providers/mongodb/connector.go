@@ -4,34 +4,56 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"minds_iolite_backend/internal/datasource/pool"
+	"minds_iolite_backend/internal/datasource/providers"
+
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MongoDBConnectionInfo 表示MongoDB连接信息
+// Host/Port为实际连接的第一个主机，完整主机列表见Hosts；密码永远不会返回
 type MongoDBConnectionInfo struct {
-	Host        string                           `json:"host"`
-	Port        int                              `json:"port"`
-	Username    string                           `json:"username"`
-	Password    string                           `json:"password,omitempty"`
-	Database    string                           `json:"database"`
-	Collections map[string]CollectionInformation `json:"collections"`
+	Host          string                           `json:"host"`
+	Port          int                              `json:"port"`
+	Hosts         []string                         `json:"hosts,omitempty"`
+	Scheme        string                           `json:"scheme,omitempty"`
+	ReplicaSet    string                           `json:"replicaSet,omitempty"`
+	TLS           bool                             `json:"tls"`
+	AuthMechanism string                           `json:"authMechanism,omitempty"`
+	AuthSource    string                           `json:"authSource,omitempty"`
+	Username      string                           `json:"username"`
+	Password      string                           `json:"password,omitempty"`
+	Database      string                           `json:"database"`
+	Collections   map[string]CollectionInformation `json:"collections"`
 }
 
 // CollectionInformation 表示集合信息
 type CollectionInformation struct {
-	Fields     map[string]string `json:"fields"`
-	SampleData string            `json:"sample_data"`
+	Fields     map[string]string  `json:"fields"`            // 顶层字段及其主要类型
+	SampleData string             `json:"sample_data"`       // 第一个样本文档
+	Schema     *CollectionSchema  `json:"schema,omitempty"`  // 多文档采样推断的完整结构
+	Details    *CollectionDetails `json:"details,omitempty"` // 索引、校验规则、集合类型和统计（按需提供）
+	Error      string             `json:"error,omitempty"`   // 批量推断时该集合失败的原因，此时fields为空
+}
+
+// ExtractOptions 控制连接信息的提取内容
+type ExtractOptions struct {
+	Schema         SchemaOptions               // 结构推断的采样方式
+	IncludeDetails bool                        // 是否包含索引、校验规则、集合类型和存储统计
+	Introspect     providers.IntrospectOptions // 集合名过滤、并发数和单集合超时
 }
 
 // MongoDBConnector MongoDB连接器
 type MongoDBConnector struct {
-	client *mongo.Client
-	uri    string
+	client  *mongo.Client
+	release func() // 释放共享连接池的引用
+	uri     string
+	uriInfo *URIInfo
 }
 
 // NewMongoDBConnector 创建MongoDB连接器
@@ -40,51 +62,47 @@ func NewMongoDBConnector(uri string) (*MongoDBConnector, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 创建连接选项
-	clientOptions := options.Client().ApplyURI(uri)
-
-	// 连接MongoDB
-	client, err := mongo.Connect(ctx, clientOptions)
+	// 解析连接字符串，用于报告实际的连接信息
+	uriInfo, err := ParseURI(uri)
 	if err != nil {
-		return nil, fmt.Errorf("连接MongoDB失败: %w", err)
+		return nil, err
 	}
 
-	// 测试连接
-	if err := client.Ping(ctx, nil); err != nil {
-		return nil, fmt.Errorf("MongoDB服务器无响应: %w", err)
+	// 从共享连接池获取连接，连接字符串相同的连接器复用同一个客户端
+	client, release, err := pool.GetRegistry().AcquireMongo(ctx, uri, false, strings.Join(uriInfo.Hosts, ","))
+	if err != nil {
+		return nil, fmt.Errorf("连接MongoDB失败: %w", err)
 	}
 
 	return &MongoDBConnector{
-		client: client,
-		uri:    uri,
+		client:  client,
+		release: release,
+		uri:     uri,
+		uriInfo: uriInfo,
 	}, nil
 }
 
-// Close 关闭连接
+// Close 释放连接，共享的客户端在没有其他使用方时由连接池注册表断开
 func (c *MongoDBConnector) Close() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return c.client.Disconnect(ctx)
+	if c.release != nil {
+		c.release()
+	}
+	return nil
 }
 
 // ExtractConnectionInfo 提取数据库连接信息
-func (c *MongoDBConnector) ExtractConnectionInfo(dbName string) (*MongoDBConnectionInfo, error) {
-	// 创建上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// 每个集合按opts进行多文档采样推断结构，空集合同样会出现在结果中；
+// 集合按opts.Introspect过滤并发推断，单个集合失败时在该集合的error中记录原因，不影响其他集合
+func (c *MongoDBConnector) ExtractConnectionInfo(ctx context.Context, dbName string, opts ExtractOptions) (*MongoDBConnectionInfo, error) {
+	if err := opts.Introspect.Validate(); err != nil {
+		return nil, err
+	}
 
 	// 获取数据库
 	db := c.client.Database(dbName)
 
 	// 创建连接信息
-	connInfo := &MongoDBConnectionInfo{
-		Host:        "localhost", // 假设是本地MongoDB
-		Port:        27017,       // 默认端口
-		Username:    "",          // 本地通常无用户名
-		Password:    "",          // 密码隐藏
-		Database:    dbName,
-		Collections: make(map[string]CollectionInformation),
-	}
+	connInfo := c.newConnectionInfo(dbName)
 
 	// 获取集合列表
 	collections, err := db.ListCollectionNames(ctx, bson.M{})
@@ -97,70 +115,84 @@ func (c *MongoDBConnector) ExtractConnectionInfo(dbName string) (*MongoDBConnect
 		return nil, fmt.Errorf("数据库 %s 中没有集合", dbName)
 	}
 
-	// 处理每个集合
-	for _, collName := range collections {
-		// 获取集合
-		coll := db.Collection(collName)
-
-		// 获取样本文档
-		var sampleDoc bson.M
-		err := coll.FindOne(ctx, bson.M{}).Decode(&sampleDoc)
+	// 并发处理每个集合
+	var mu sync.Mutex
+	failures := providers.IntrospectEach(ctx, opts.Introspect.Filter(collections), opts.Introspect, func(ctx context.Context, collName string) error {
+		collInfo, err := c.ExtractCollectionInfo(ctx, dbName, collName, opts)
 		if err != nil {
-			// 如果集合为空，跳过
-			if err == mongo.ErrNoDocuments {
-				continue
-			}
-			return nil, fmt.Errorf("获取集合 %s 的样本文档失败: %w", collName, err)
+			return err
 		}
-
-		// 获取字段类型信息
-		fields := make(map[string]string)
-		for key, value := range sampleDoc {
-			fields[key] = getMongoType(value)
+		mu.Lock()
+		connInfo.Collections[collName] = *collInfo
+		mu.Unlock()
+		return nil
+	})
+	for collName, err := range failures {
+		connInfo.Collections[collName] = CollectionInformation{
+			Fields:     map[string]string{},
+			SampleData: "{}",
+			Error:      err.Error(),
 		}
+	}
+
+	return connInfo, nil
+}
+
+// URIInfo 返回连接字符串解析出的连接元数据
+func (c *MongoDBConnector) URIInfo() *URIInfo {
+	return c.uriInfo
+}
+
+// newConnectionInfo 根据实际使用的连接字符串生成连接信息，密码始终为空
+func (c *MongoDBConnector) newConnectionInfo(dbName string) *MongoDBConnectionInfo {
+	host, port := c.uriInfo.PrimaryHost()
+	return &MongoDBConnectionInfo{
+		Host:          host,
+		Port:          port,
+		Hosts:         c.uriInfo.Hosts,
+		Scheme:        c.uriInfo.Scheme,
+		ReplicaSet:    c.uriInfo.ReplicaSet,
+		TLS:           c.uriInfo.TLS,
+		AuthMechanism: c.uriInfo.AuthMechanism,
+		AuthSource:    c.uriInfo.AuthSource,
+		Username:      c.uriInfo.Username,
+		Database:      dbName,
+		Collections:   make(map[string]CollectionInformation),
+	}
+}
+
+// ExtractCollectionInfo 采样推断单个集合的结构并生成集合信息
+func (c *MongoDBConnector) ExtractCollectionInfo(ctx context.Context, dbName, collName string, opts ExtractOptions) (*CollectionInformation, error) {
+	schema, sampleDoc, err := c.InferCollectionSchema(ctx, dbName, collName, opts.Schema)
+	if err != nil {
+		return nil, err
+	}
 
-		// 将样本文档转换为JSON字符串
+	// 空集合没有样本文档
+	sampleData := "{}"
+	if sampleDoc != nil {
 		sampleJSON, err := json.Marshal(sampleDoc)
 		if err != nil {
 			return nil, fmt.Errorf("转换样本数据失败: %w", err)
 		}
-
-		// 添加集合信息
-		connInfo.Collections[collName] = CollectionInformation{
-			Fields:     fields,
-			SampleData: string(sampleJSON),
-		}
+		sampleData = string(sampleJSON)
 	}
 
-	return connInfo, nil
-}
+	collInfo := &CollectionInformation{
+		Fields:     schema.TopLevelFields(),
+		SampleData: sampleData,
+		Schema:     schema,
+	}
 
-// getMongoType 获取MongoDB字段类型名称
-func getMongoType(value interface{}) string {
-	switch value.(type) {
-	case nil:
-		return "null"
-	case bool:
-		return "bool"
-	case int, int32, int64:
-		return "int"
-	case float32, float64:
-		return "float"
-	case string:
-		return "str"
-	case time.Time:
-		return "date"
-	case bson.D:
-		return "object"
-	case bson.A:
-		return "array"
-	case bson.M:
-		return "object"
-	case primitive.ObjectID:
-		return "ObjectId"
-	default:
-		return "unknown"
+	if opts.IncludeDetails {
+		details, err := c.DescribeCollection(ctx, dbName, collName)
+		if err != nil {
+			return nil, err
+		}
+		collInfo.Details = details
 	}
+
+	return collInfo, nil
 }
 
 // GetClient 获取MongoDB客户端
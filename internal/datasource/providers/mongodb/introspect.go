@@ -0,0 +1,296 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 集合类型
+const (
+	CollectionTypeCollection = "collection"
+	CollectionTypeView       = "view"
+	CollectionTypeTimeSeries = "timeseries"
+)
+
+// IndexKey 表示索引中的一个键
+type IndexKey struct {
+	Field string      `json:"field"` // 字段路径
+	Kind  interface{} `json:"kind"`  // 1/-1 表示升降序，或 "text"、"2dsphere"、"hashed" 等
+}
+
+// IndexInfo 表示集合的一个索引
+type IndexInfo struct {
+	Name          string           `json:"name"`
+	Keys          []IndexKey       `json:"keys"`
+	Unique        bool             `json:"unique"`
+	Sparse        bool             `json:"sparse"`
+	Text          bool             `json:"text"`                    // 是否为全文索引
+	TTLSeconds    *int32           `json:"ttlSeconds,omitempty"`    // TTL索引的过期秒数
+	PartialFilter bson.M           `json:"partialFilter,omitempty"` // 部分索引的过滤条件
+	TextWeights   map[string]int32 `json:"textWeights,omitempty"`   // 全文索引的字段权重
+}
+
+// Validation 表示集合的文档校验规则
+type Validation struct {
+	JSONSchema bson.M `json:"jsonSchema,omitempty"` // $jsonSchema校验规则
+	Validator  bson.M `json:"validator,omitempty"`  // 完整的validator（包含$jsonSchema以外的查询表达式）
+	Level      string `json:"level,omitempty"`      // validationLevel: off/strict/moderate
+	Action     string `json:"action,omitempty"`     // validationAction: error/warn
+}
+
+// CollectionStats 表示集合的存储统计
+type CollectionStats struct {
+	Count          int64   `json:"count"`          // 文档数
+	Size           int64   `json:"size"`           // 未压缩的数据大小(字节)
+	StorageSize    int64   `json:"storageSize"`    // 占用的存储空间(字节)
+	AvgObjSize     float64 `json:"avgObjSize"`     // 平均文档大小(字节)
+	TotalIndexSize int64   `json:"totalIndexSize"` // 索引总大小(字节)
+}
+
+// CollectionDetails 表示集合的详细元信息，用于迁移规划
+type CollectionDetails struct {
+	Type       string           `json:"type"`                 // collection/view/timeseries
+	ReadOnly   bool             `json:"readOnly"`             // 是否只读（视图为只读）
+	Capped     bool             `json:"capped"`               // 是否为固定集合
+	MaxSize    int64            `json:"maxSize,omitempty"`    // 固定集合的最大字节数
+	MaxDocs    int64            `json:"maxDocs,omitempty"`    // 固定集合的最大文档数
+	ViewOn     string           `json:"viewOn,omitempty"`     // 视图的源集合
+	Pipeline   []bson.M         `json:"pipeline,omitempty"`   // 视图的聚合管道
+	TimeSeries bson.M           `json:"timeseries,omitempty"` // 时序集合配置(timeField/metaField/granularity)
+	Validation *Validation      `json:"validation,omitempty"` // 文档校验规则
+	Indexes    []IndexInfo      `json:"indexes"`              // 索引列表（视图没有索引）
+	Stats      *CollectionStats `json:"stats,omitempty"`      // 存储统计（视图不提供）
+}
+
+// collectionSpec 对应listCollections返回的文档
+type collectionSpec struct {
+	Name    string `bson:"name"`
+	Type    string `bson:"type"`
+	Options bson.M `bson:"options"`
+	Info    struct {
+		ReadOnly bool `bson:"readOnly"`
+	} `bson:"info"`
+}
+
+// indexSpec 对应listIndexes返回的文档
+type indexSpec struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	Unique             bool   `bson:"unique"`
+	Sparse             bool   `bson:"sparse"`
+	ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+	PartialFilter      bson.M `bson:"partialFilterExpression"`
+	Weights            bson.M `bson:"weights"`
+}
+
+// DescribeCollection 获取集合的类型、校验规则、索引和存储统计
+func (c *MongoDBConnector) DescribeCollection(ctx context.Context, dbName, collName string) (*CollectionDetails, error) {
+	db := c.client.Database(dbName)
+
+	spec, err := getCollectionSpec(ctx, db, collName)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &CollectionDetails{
+		Type:     spec.Type,
+		ReadOnly: spec.Info.ReadOnly,
+		Indexes:  []IndexInfo{},
+	}
+	if details.Type == "" {
+		details.Type = CollectionTypeCollection
+	}
+	applyCollectionOptions(details, spec.Options)
+
+	// 视图没有索引和存储统计
+	if details.Type == CollectionTypeView {
+		return details, nil
+	}
+
+	coll := db.Collection(collName)
+	indexes, err := listIndexes(ctx, coll)
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s 的索引失败: %w", collName, err)
+	}
+	details.Indexes = indexes
+
+	stats, err := collectionStats(ctx, db, coll)
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s 的统计信息失败: %w", collName, err)
+	}
+	details.Stats = stats
+
+	return details, nil
+}
+
+// getCollectionSpec 获取单个集合的listCollections结果
+func getCollectionSpec(ctx context.Context, db *mongo.Database, collName string) (*collectionSpec, error) {
+	cursor, err := db.ListCollections(ctx, bson.M{"name": collName})
+	if err != nil {
+		return nil, fmt.Errorf("获取集合 %s 的定义失败: %w", collName, err)
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, fmt.Errorf("获取集合 %s 的定义失败: %w", collName, err)
+		}
+		return nil, fmt.Errorf("集合 %s 不存在", collName)
+	}
+
+	var spec collectionSpec
+	if err := cursor.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("解析集合 %s 的定义失败: %w", collName, err)
+	}
+	return &spec, nil
+}
+
+// applyCollectionOptions 从集合创建选项中提取固定集合、视图、时序和校验信息
+func applyCollectionOptions(details *CollectionDetails, opts bson.M) {
+	if opts == nil {
+		return
+	}
+
+	if capped, ok := opts["capped"].(bool); ok {
+		details.Capped = capped
+	}
+	details.MaxSize = toInt64(opts["size"])
+	details.MaxDocs = toInt64(opts["max"])
+
+	if viewOn, ok := opts["viewOn"].(string); ok {
+		details.ViewOn = viewOn
+	}
+	if pipeline, ok := opts["pipeline"].(bson.A); ok {
+		for _, stage := range pipeline {
+			if m, ok := stage.(bson.M); ok {
+				details.Pipeline = append(details.Pipeline, m)
+			}
+		}
+	}
+
+	if ts, ok := opts["timeseries"].(bson.M); ok {
+		details.TimeSeries = ts
+		// 旧版本服务器的listCollections可能不返回timeseries类型
+		details.Type = CollectionTypeTimeSeries
+	}
+
+	validator, _ := opts["validator"].(bson.M)
+	level, _ := opts["validationLevel"].(string)
+	action, _ := opts["validationAction"].(string)
+	if len(validator) > 0 || level != "" || action != "" {
+		validation := &Validation{Validator: validator, Level: level, Action: action}
+		if schema, ok := validator["$jsonSchema"].(bson.M); ok {
+			validation.JSONSchema = schema
+		}
+		details.Validation = validation
+	}
+}
+
+// listIndexes 列出集合的所有索引
+func listIndexes(ctx context.Context, coll *mongo.Collection) ([]IndexInfo, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var specs []indexSpec
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]IndexInfo, 0, len(specs))
+	for _, spec := range specs {
+		index := IndexInfo{
+			Name:          spec.Name,
+			Keys:          make([]IndexKey, 0, len(spec.Key)),
+			Unique:        spec.Unique,
+			Sparse:        spec.Sparse,
+			TTLSeconds:    spec.ExpireAfterSeconds,
+			PartialFilter: spec.PartialFilter,
+		}
+		for _, elem := range spec.Key {
+			index.Keys = append(index.Keys, IndexKey{Field: elem.Key, Kind: elem.Value})
+			if kind, ok := elem.Value.(string); ok && kind == "text" {
+				index.Text = true
+			}
+		}
+		if index.Text && len(spec.Weights) > 0 {
+			index.TextWeights = make(map[string]int32, len(spec.Weights))
+			for field, weight := range spec.Weights {
+				index.TextWeights[field] = int32(toInt64(weight))
+			}
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// collectionStats 通过$collStats获取集合存储统计，失败时回退到collStats命令
+func collectionStats(ctx context.Context, db *mongo.Database, coll *mongo.Collection) (*CollectionStats, error) {
+	pipeline := mongo.Pipeline{{{Key: "$collStats", Value: bson.M{"storageStats": bson.M{}}}}}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err == nil {
+		defer cursor.Close(ctx)
+		var result struct {
+			StorageStats bson.M `bson:"storageStats"`
+		}
+		if cursor.Next(ctx) {
+			if err := cursor.Decode(&result); err == nil && result.StorageStats != nil {
+				return statsFromDocument(result.StorageStats), nil
+			}
+		}
+	}
+
+	var raw bson.M
+	if err := db.RunCommand(ctx, bson.D{{Key: "collStats", Value: coll.Name()}}).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return statsFromDocument(raw), nil
+}
+
+// statsFromDocument 从统计文档中提取常用字段
+func statsFromDocument(doc bson.M) *CollectionStats {
+	return &CollectionStats{
+		Count:          toInt64(doc["count"]),
+		Size:           toInt64(doc["size"]),
+		StorageSize:    toInt64(doc["storageSize"]),
+		AvgObjSize:     toFloat64(doc["avgObjSize"]),
+		TotalIndexSize: toInt64(doc["totalIndexSize"]),
+	}
+}
+
+// toInt64 将BSON数值转换为int64
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case int:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+// toFloat64 将BSON数值转换为float64
+func toFloat64(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,203 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProviderName MongoDB数据源类型名
+const ProviderName = "mongodb"
+
+func init() {
+	providers.Register(Provider{})
+}
+
+// Provider MongoDB数据源提供者
+// 配置项: connectionURI，或 host、port、username、password、authSource、authMechanism、replicaSet、tls、srv；
+// 以及必填的 database
+type Provider struct{}
+
+// Name 返回数据源类型名
+func (Provider) Name() string {
+	return ProviderName
+}
+
+// ValidateConfig 检查数据库名和连接字符串
+func (Provider) ValidateConfig(cfg providers.Config) error {
+	_, _, err := parseConfig(cfg)
+	return err
+}
+
+// Connect 建立MongoDB连接
+func (Provider) Connect(ctx context.Context, cfg providers.Config) (providers.Connection, error) {
+	connector, dbName, err := NewMongoDBConnectorFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &connection{connector: connector, dbName: dbName}, nil
+}
+
+// WrapClient 使用已建立的客户端创建通用连接，供自行管理连接生命周期的调用方（如会话）使用
+// 返回的连接Close时不会断开client
+func WrapClient(client *mongo.Client, dbName string) providers.Connection {
+	return &connection{connector: &MongoDBConnector{client: client}, dbName: dbName, borrowed: true}
+}
+
+// NewMongoDBConnectorFromConfig 根据通用配置创建MongoDB连接器，同时返回配置中的数据库名
+func NewMongoDBConnectorFromConfig(cfg providers.Config) (*MongoDBConnector, string, error) {
+	uri, dbName, err := parseConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	connector, err := NewMongoDBConnector(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	return connector, dbName, nil
+}
+
+// parseConfig 从通用配置中解析连接字符串和数据库名
+func parseConfig(cfg providers.Config) (string, string, error) {
+	dbName := cfg.String("database", "db", "dbname")
+	if dbName == "" {
+		return "", "", fmt.Errorf("缺少必要参数: database")
+	}
+
+	uri := cfg.String("connectionURI", "connection_uri", "uri")
+	if uri == "" {
+		port, err := cfg.Int(DefaultPort, "port")
+		if err != nil {
+			return "", "", err
+		}
+		params := URIParams{
+			Host:          cfg.String("host"),
+			Port:          strconv.Itoa(port),
+			Username:      cfg.String("username", "user"),
+			Password:      cfg.String("password", "pwd", "passwd"),
+			AuthSource:    cfg.String("authSource", "auth_source"),
+			AuthMechanism: cfg.String("authMechanism", "auth_mechanism"),
+			ReplicaSet:    cfg.String("replicaSet", "replica_set"),
+		}
+		if tls, ok := cfg.Bool("tls", "ssl"); ok {
+			params.TLS = &tls
+		}
+		params.SRV, _ = cfg.Bool("srv")
+		uri = BuildURI(params)
+	}
+
+	// 完整的连接字符串校验（含SRV查询）在连接时进行
+	if !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://") {
+		return "", "", fmt.Errorf("无效的MongoDB连接字符串: 必须以 mongodb:// 或 mongodb+srv:// 开头")
+	}
+	return uri, dbName, nil
+}
+
+// connection 基于MongoDBConnector的通用连接
+type connection struct {
+	connector *MongoDBConnector
+	dbName    string
+	borrowed  bool // 连接由调用方管理，Close时不断开
+}
+
+// ListEntities 列出集合和视图
+func (c *connection) ListEntities(ctx context.Context) ([]providers.Entity, error) {
+	collections, err := c.connector.ListCollections(ctx, c.dbName)
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]providers.Entity, 0, len(collections))
+	for _, coll := range collections {
+		entities = append(entities, providers.Entity{
+			Name:          coll.Name,
+			Kind:          coll.Type,
+			EstimatedRows: coll.EstimatedCount,
+		})
+	}
+	return entities, nil
+}
+
+// Describe 采样推断集合的顶层字段
+func (c *connection) Describe(ctx context.Context, entity string) (*providers.EntitySchema, error) {
+	if entity == "" {
+		return nil, providers.ErrEntityRequired
+	}
+	schema, _, err := c.connector.InferCollectionSchema(ctx, c.dbName, entity, SchemaOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &providers.EntitySchema{Name: entity, Fields: []providers.Field{}}
+	for i := range schema.Fields {
+		field := &schema.Fields[i]
+		if !isTopLevel(field.Path) {
+			continue
+		}
+		typ := typesystem.Parse(field.DominantType())
+		result.Fields = append(result.Fields, providers.Field{
+			Name:       field.Path,
+			Type:       typ,
+			NativeType: typesystem.ToBSON(typ),
+			Nullable:   !field.Required || field.Types[typesystem.Null.String()] > 0,
+			PrimaryKey: field.Path == "_id",
+		})
+	}
+	return result, nil
+}
+
+// Sample 读取前limit个文档
+func (c *connection) Sample(ctx context.Context, entity string, limit int) ([]providers.Row, error) {
+	if entity == "" {
+		return nil, providers.ErrEntityRequired
+	}
+	rows := []providers.Row{}
+	err := c.find(ctx, entity, options.Find().SetLimit(int64(providers.NormalizeLimit(limit))), func(row providers.Row) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// StreamRows 逐个读取集合中的全部文档
+func (c *connection) StreamRows(ctx context.Context, entity string, fn providers.RowHandler) error {
+	if entity == "" {
+		return providers.ErrEntityRequired
+	}
+	return c.find(ctx, entity, options.Find(), fn)
+}
+
+// find 执行查询并逐个处理文档
+func (c *connection) find(ctx context.Context, entity string, opts *options.FindOptions, fn providers.RowHandler) error {
+	cursor, err := c.connector.client.Database(c.dbName).Collection(entity).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return fmt.Errorf("读取集合 %s 失败: %w", entity, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return fmt.Errorf("解析文档失败: %w", err)
+		}
+		if err := fn(providers.Row(doc)); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Close 关闭连接
+func (c *connection) Close() error {
+	if c.borrowed {
+		return nil
+	}
+	return c.connector.Close()
+}
@@ -0,0 +1,257 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"minds_iolite_backend/internal/datasource/typesystem"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DefaultSampleSize 默认的结构推断采样文档数
+	DefaultSampleSize = 100
+	// MaxSampleSize 允许的最大采样文档数
+	MaxSampleSize = 10000
+)
+
+// SchemaOptions 控制集合结构推断的采样方式
+type SchemaOptions struct {
+	SampleSize int  `json:"sampleSize"` // 采样文档数量，<=0时使用默认值
+	UseSample  bool `json:"useSample"`  // 是否使用$sample随机采样，否则按自然顺序取前N个文档
+}
+
+// normalize 填充默认值并限制采样上限
+func (o SchemaOptions) normalize() SchemaOptions {
+	if o.SampleSize <= 0 {
+		o.SampleSize = DefaultSampleSize
+	}
+	if o.SampleSize > MaxSampleSize {
+		o.SampleSize = MaxSampleSize
+	}
+	return o
+}
+
+// FieldSchema 表示一个字段路径的推断结果
+// 嵌套文档使用点号连接路径，如 "address.city"；
+// 数组中的文档沿用数组字段的路径（与MongoDB查询语义一致），数组中的标量元素记录在 "路径[]" 下
+type FieldSchema struct {
+	Path      string         `json:"path"`      // 字段路径
+	Types     map[string]int `json:"types"`     // 各类型出现的文档数
+	Count     int            `json:"count"`     // 包含该字段的文档数
+	Frequency float64        `json:"frequency"` // 包含该字段的文档比例
+	Required  bool           `json:"required"`  // 是否在所有采样文档中都出现
+}
+
+// DominantType 返回出现次数最多的非null类型，全为null时返回null
+func (f *FieldSchema) DominantType() string {
+	best, bestCount := "", -1
+	for t, n := range f.Types {
+		if t == typesystem.Null.String() {
+			continue
+		}
+		if n > bestCount || (n == bestCount && t < best) {
+			best, bestCount = t, n
+		}
+	}
+	if best == "" {
+		return typesystem.Null.String()
+	}
+	return best
+}
+
+// CollectionSchema 表示集合的推断结构
+type CollectionSchema struct {
+	SampledDocuments int           `json:"sampledDocuments"` // 实际采样的文档数
+	SampleMethod     string        `json:"sampleMethod"`     // 采样方式: "$sample" 或 "first"
+	Fields           []FieldSchema `json:"fields"`           // 按路径排序的字段列表
+}
+
+// schemaBuilder 累积多个文档的字段统计
+type schemaBuilder struct {
+	docs   int
+	fields map[string]*FieldSchema
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{fields: make(map[string]*FieldSchema)}
+}
+
+// add 将一个文档计入统计，同一文档内同一路径的同一类型只计一次
+func (b *schemaBuilder) add(doc bson.M) {
+	b.docs++
+	seen := make(map[string]map[string]bool)
+	walkDocument("", doc, seen)
+
+	for path, types := range seen {
+		field, ok := b.fields[path]
+		if !ok {
+			field = &FieldSchema{Path: path, Types: make(map[string]int)}
+			b.fields[path] = field
+		}
+		field.Count++
+		for t := range types {
+			field.Types[t]++
+		}
+	}
+}
+
+// build 生成最终的集合结构
+func (b *schemaBuilder) build(method string) *CollectionSchema {
+	schema := &CollectionSchema{
+		SampledDocuments: b.docs,
+		SampleMethod:     method,
+		Fields:           make([]FieldSchema, 0, len(b.fields)),
+	}
+	for _, field := range b.fields {
+		if b.docs > 0 {
+			field.Frequency = float64(field.Count) / float64(b.docs)
+		}
+		field.Required = field.Count == b.docs
+		schema.Fields = append(schema.Fields, *field)
+	}
+	sort.Slice(schema.Fields, func(i, j int) bool {
+		return schema.Fields[i].Path < schema.Fields[j].Path
+	})
+	return schema
+}
+
+// TopLevelFields 返回顶层字段及其主要类型，用于兼容原有的fields输出
+func (s *CollectionSchema) TopLevelFields() map[string]string {
+	fields := make(map[string]string)
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		if isTopLevel(field.Path) {
+			fields[field.Path] = field.DominantType()
+		}
+	}
+	return fields
+}
+
+// isTopLevel 判断路径是否为顶层字段
+func isTopLevel(path string) bool {
+	for _, c := range path {
+		if c == '.' || c == '[' {
+			return false
+		}
+	}
+	return true
+}
+
+// record 记录某路径在当前文档中出现的类型
+func record(seen map[string]map[string]bool, path string, t typesystem.Type) {
+	types, ok := seen[path]
+	if !ok {
+		types = make(map[string]bool)
+		seen[path] = types
+	}
+	types[t.String()] = true
+}
+
+// joinPath 拼接字段路径
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// walkDocument 遍历嵌套文档
+func walkDocument(prefix string, doc map[string]interface{}, seen map[string]map[string]bool) {
+	for key, value := range doc {
+		walkValue(joinPath(prefix, key), value, seen)
+	}
+}
+
+// walkOrderedDocument 遍历有序的嵌套文档
+func walkOrderedDocument(prefix string, doc bson.D, seen map[string]map[string]bool) {
+	for _, elem := range doc {
+		walkValue(joinPath(prefix, elem.Key), elem.Value, seen)
+	}
+}
+
+// walkValue 记录值的类型，并递归进入文档和数组
+func walkValue(path string, value interface{}, seen map[string]map[string]bool) {
+	record(seen, path, typesystem.FromValue(value))
+
+	switch v := value.(type) {
+	case bson.M:
+		walkDocument(path, v, seen)
+	case map[string]interface{}:
+		walkDocument(path, v, seen)
+	case bson.D:
+		walkOrderedDocument(path, v, seen)
+	case bson.A:
+		walkArray(path, v, seen)
+	case []interface{}:
+		walkArray(path, v, seen)
+	}
+}
+
+// walkArray 遍历数组元素
+func walkArray(path string, items []interface{}, seen map[string]map[string]bool) {
+	for _, item := range items {
+		switch v := item.(type) {
+		case bson.M:
+			walkDocument(path, v, seen)
+		case map[string]interface{}:
+			walkDocument(path, v, seen)
+		case bson.D:
+			walkOrderedDocument(path, v, seen)
+		default:
+			walkValue(path+"[]", item, seen)
+		}
+	}
+}
+
+// sampleDocuments 按选项从集合中读取样本文档
+func sampleDocuments(ctx context.Context, coll *mongo.Collection, opts SchemaOptions) ([]bson.M, string, error) {
+	var cursor *mongo.Cursor
+	var err error
+	method := "first"
+
+	if opts.UseSample {
+		method = "$sample"
+		pipeline := mongo.Pipeline{{{Key: "$sample", Value: bson.M{"size": opts.SampleSize}}}}
+		cursor, err = coll.Aggregate(ctx, pipeline)
+	} else {
+		cursor, err = coll.Find(ctx, bson.M{}, options.Find().SetLimit(int64(opts.SampleSize)))
+	}
+	if err != nil {
+		return nil, method, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, method, err
+	}
+	return docs, method, nil
+}
+
+// InferCollectionSchema 对集合进行多文档采样并推断其结构
+// 返回推断结果以及第一个样本文档（集合为空时为nil）
+func (c *MongoDBConnector) InferCollectionSchema(ctx context.Context, dbName, collName string, opts SchemaOptions) (*CollectionSchema, bson.M, error) {
+	opts = opts.normalize()
+	coll := c.client.Database(dbName).Collection(collName)
+
+	docs, method, err := sampleDocuments(ctx, coll, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("采样集合 %s 失败: %w", collName, err)
+	}
+
+	builder := newSchemaBuilder()
+	for _, doc := range docs {
+		builder.add(doc)
+	}
+
+	var first bson.M
+	if len(docs) > 0 {
+		first = docs[0]
+	}
+	return builder.build(method), first, nil
+}
@@ -0,0 +1,151 @@
+package mongodb
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// DefaultPort MongoDB默认端口
+const DefaultPort = 27017
+
+// URIInfo 表示从连接字符串解析出的连接元数据，不包含密码
+type URIInfo struct {
+	Scheme        string   `json:"scheme"`                  // mongodb 或 mongodb+srv
+	Hosts         []string `json:"hosts"`                   // 实际连接的主机列表(host:port)，SRV记录会被解析
+	SRVHost       string   `json:"srvHost,omitempty"`       // mongodb+srv 中的原始主机名
+	ReplicaSet    string   `json:"replicaSet,omitempty"`    // 复制集名称
+	TLS           bool     `json:"tls"`                     // 是否启用TLS
+	AuthMechanism string   `json:"authMechanism,omitempty"` // 认证机制，如 SCRAM-SHA-256
+	AuthSource    string   `json:"authSource,omitempty"`    // 认证数据库
+	Username      string   `json:"username,omitempty"`      // 用户名
+	Database      string   `json:"database,omitempty"`      // 连接字符串中的默认数据库
+	Direct        bool     `json:"directConnection"`        // 是否为直连模式
+}
+
+// ParseURI 解析MongoDB连接字符串
+// mongodb+srv 连接字符串会进行DNS查询以得到实际主机列表
+func ParseURI(uri string) (*URIInfo, error) {
+	cs, err := connstring.ParseAndValidate(uri)
+	if err != nil {
+		return nil, fmt.Errorf("解析MongoDB连接字符串失败: %w", err)
+	}
+
+	info := &URIInfo{
+		Scheme:        cs.Scheme,
+		Hosts:         cs.Hosts,
+		ReplicaSet:    cs.ReplicaSet,
+		TLS:           cs.SSL,
+		AuthMechanism: cs.AuthMechanism,
+		AuthSource:    cs.AuthSource,
+		Username:      cs.Username,
+		Database:      cs.Database,
+		Direct:        cs.DirectConnection,
+	}
+	if cs.Scheme == connstring.SchemeMongoDBSRV {
+		if len(cs.RawHosts) > 0 {
+			info.SRVHost = cs.RawHosts[0]
+		}
+		// SRV连接默认启用TLS
+		if !cs.SSLSet {
+			info.TLS = true
+		}
+	}
+	// 指定了用户名但未指定认证库时，驱动使用连接字符串中的数据库或admin
+	if info.Username != "" && info.AuthSource == "" {
+		info.AuthSource = cs.Database
+		if info.AuthSource == "" {
+			info.AuthSource = "admin"
+		}
+	}
+	return info, nil
+}
+
+// PrimaryHost 返回第一个主机的主机名和端口
+func (i *URIInfo) PrimaryHost() (string, int) {
+	if len(i.Hosts) == 0 {
+		return "", 0
+	}
+	host, portStr, err := net.SplitHostPort(i.Hosts[0])
+	if err != nil {
+		return i.Hosts[0], DefaultPort
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		port = DefaultPort
+	}
+	return host, port
+}
+
+// URIParams 表示通过独立字段构建连接字符串时的参数
+type URIParams struct {
+	Host          string // 主机名，可以是逗号分隔的多个 host[:port]
+	Port          string // 端口，仅在Host未包含端口时使用
+	Username      string
+	Password      string
+	Database      string // 连接字符串中的默认数据库
+	AuthSource    string
+	AuthMechanism string
+	ReplicaSet    string
+	TLS           *bool // 为nil时使用驱动默认值
+	SRV           bool  // 是否使用 mongodb+srv 协议
+}
+
+// BuildURI 根据独立字段构建MongoDB连接字符串，用户名和密码会被正确转义
+func BuildURI(p URIParams) string {
+	u := url.URL{Scheme: "mongodb"}
+	if p.SRV {
+		u.Scheme = "mongodb+srv"
+	}
+
+	host := strings.TrimSpace(p.Host)
+	if host == "" {
+		host = "localhost"
+	}
+	// SRV连接不允许指定端口，多主机或已带端口的主机保持原样
+	if !p.SRV && !strings.Contains(host, ",") && !hasPort(host) {
+		port := p.Port
+		if port == "" {
+			port = strconv.Itoa(DefaultPort)
+		}
+		host = net.JoinHostPort(host, port)
+	}
+	u.Host = host
+
+	if p.Username != "" {
+		if p.Password != "" {
+			u.User = url.UserPassword(p.Username, p.Password)
+		} else {
+			u.User = url.User(p.Username)
+		}
+	}
+
+	u.Path = "/" + p.Database
+
+	query := url.Values{}
+	if p.AuthSource != "" {
+		query.Set("authSource", p.AuthSource)
+	}
+	if p.AuthMechanism != "" {
+		query.Set("authMechanism", p.AuthMechanism)
+	}
+	if p.ReplicaSet != "" {
+		query.Set("replicaSet", p.ReplicaSet)
+	}
+	if p.TLS != nil {
+		query.Set("tls", strconv.FormatBool(*p.TLS))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// hasPort 判断主机字符串是否已包含端口
+func hasPort(host string) bool {
+	_, _, err := net.SplitHostPort(host)
+	return err == nil
+}
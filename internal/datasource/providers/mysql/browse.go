@@ -0,0 +1,182 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"minds_iolite_backend/internal/datasource/typesystem"
+	"minds_iolite_backend/internal/services/datastorage"
+)
+
+// DatabaseInfo 表示服务器上的一个数据库（schema）
+type DatabaseInfo struct {
+	Name   string `json:"name"`
+	System bool   `json:"system"` // 是否为MySQL系统库
+}
+
+// TableSummary 表示表的轻量元信息，来自information_schema，不扫描表数据
+type TableSummary struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`          // BASE TABLE / VIEW / SYSTEM VIEW
+	Engine        string `json:"engine"`        // 存储引擎，视图为空
+	EstimatedRows int64  `json:"estimatedRows"` // 统计信息中的估算行数
+	DataLength    int64  `json:"dataLength"`    // 数据大小(字节)
+	Comment       string `json:"comment,omitempty"`
+}
+
+// systemDatabases MySQL内置的系统库
+var systemDatabases = map[string]bool{
+	"information_schema": true,
+	"mysql":              true,
+	"performance_schema": true,
+	"sys":                true,
+}
+
+// quoteIdentifier 使用反引号引用标识符
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// qualifiedName 返回带库名的表名，库名为空时只返回表名
+func qualifiedName(database, table string) string {
+	if database == "" {
+		return quoteIdentifier(table)
+	}
+	return quoteIdentifier(database) + "." + quoteIdentifier(table)
+}
+
+// ListDatabases 列出当前用户可访问的数据库
+func (c *MySQLConnector) ListDatabases() ([]DatabaseInfo, error) {
+	rows, err := c.db.Query("SHOW DATABASES")
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	databases := []DatabaseInfo{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("读取数据库名失败: %w", err)
+		}
+		databases = append(databases, DatabaseInfo{Name: name, System: systemDatabases[strings.ToLower(name)]})
+	}
+	return databases, rows.Err()
+}
+
+// ListTables 列出数据库中的表和视图，只读取information_schema中的元信息
+func (c *MySQLConnector) ListTables(database string) ([]TableSummary, error) {
+	if database == "" {
+		database = c.database
+	}
+	if database == "" {
+		return nil, fmt.Errorf("缺少数据库名")
+	}
+
+	rows, err := c.db.Query(`SELECT TABLE_NAME, TABLE_TYPE, ENGINE, TABLE_ROWS, DATA_LENGTH, TABLE_COMMENT
+		FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME`, database)
+	if err != nil {
+		return nil, fmt.Errorf("获取表列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []TableSummary{}
+	for rows.Next() {
+		var name, tableType string
+		var engine, comment sql.NullString
+		var tableRows, dataLength sql.NullInt64
+		if err := rows.Scan(&name, &tableType, &engine, &tableRows, &dataLength, &comment); err != nil {
+			return nil, fmt.Errorf("读取表信息失败: %w", err)
+		}
+		tables = append(tables, TableSummary{
+			Name:          name,
+			Type:          tableType,
+			Engine:        engine.String,
+			EstimatedRows: tableRows.Int64,
+			DataLength:    dataLength.Int64,
+			Comment:       comment.String,
+		})
+	}
+	return tables, rows.Err()
+}
+
+// ExtractTableInfo 获取单个表的字段类型和样本数据
+// database为空时使用连接时指定的数据库
+func (c *MySQLConnector) ExtractTableInfo(ctx context.Context, database, tableName string) (*datastorage.TableInformation, error) {
+	if database == "" {
+		database = c.database
+	}
+	table := qualifiedName(database, tableName)
+
+	// 获取表结构
+	columnsRows, err := c.db.QueryContext(ctx, fmt.Sprintf("DESCRIBE %s", table))
+	if err != nil {
+		return nil, fmt.Errorf("获取表 %s 结构失败: %w", tableName, err)
+	}
+
+	fields := make(map[string]string)
+	for columnsRows.Next() {
+		var field, fieldType, null, key, extra string
+		var defaultValue sql.NullString
+		if err := columnsRows.Scan(&field, &fieldType, &null, &key, &defaultValue, &extra); err != nil {
+			columnsRows.Close()
+			return nil, fmt.Errorf("读取表结构失败: %w", err)
+		}
+		fields[field] = typesystem.FromMySQL(fieldType).String()
+	}
+	columnsRows.Close()
+
+	// 获取样本数据
+	sampleRows, err := c.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT 1", table))
+	if err != nil {
+		return nil, fmt.Errorf("获取表 %s 的样本数据失败: %w", tableName, err)
+	}
+	defer sampleRows.Close()
+
+	// 获取列名
+	columns, err := sampleRows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("获取列名失败: %w", err)
+	}
+
+	if !sampleRows.Next() {
+		// 表为空，添加空样本
+		return &datastorage.TableInformation{Fields: fields, SampleData: "{}"}, nil
+	}
+
+	// 准备扫描目标
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	// 扫描一行数据
+	if err := sampleRows.Scan(scanArgs...); err != nil {
+		return nil, fmt.Errorf("扫描数据失败: %w", err)
+	}
+
+	// 构建样本数据
+	sampleData := make(map[string]interface{})
+	for i, col := range columns {
+		switch v := values[i].(type) {
+		case nil:
+			sampleData[col] = nil
+		case []byte:
+			sampleData[col] = string(v)
+		default:
+			sampleData[col] = v
+		}
+	}
+
+	// 转换为JSON
+	sampleJSON, err := json.Marshal(sampleData)
+	if err != nil {
+		return nil, fmt.Errorf("转换样本数据失败: %w", err)
+	}
+
+	return &datastorage.TableInformation{Fields: fields, SampleData: string(sampleJSON)}, nil
+}
@@ -1,20 +1,23 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"strings"
+	"sync"
 	"time"
 
+	"minds_iolite_backend/internal/datasource/pool"
+	"minds_iolite_backend/internal/datasource/providers"
 	"minds_iolite_backend/internal/services/datastorage"
 
-	_ "github.com/go-sql-driver/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
 )
 
 // MySQLConnector MySQL连接器
 type MySQLConnector struct {
 	db       *sql.DB
+	release  func() // 释放共享连接池的引用
 	dsn      string
 	host     string
 	port     int
@@ -22,30 +25,51 @@ type MySQLConnector struct {
 	database string
 }
 
-// NewMySQLConnector 创建MySQL连接器
+// BuildDSN 构建MySQL连接字符串
+// readOnly为true时每个连接建立后都会设置 transaction_read_only=1（等同于SET SESSION TRANSACTION READ ONLY），
+// 服务器会拒绝该连接上的所有写操作，需要MySQL 5.7.20及以上版本
+func BuildDSN(host string, port int, username, password, database string, readOnly bool) string {
+	cfg := mysqldriver.NewConfig()
+	cfg.User = username
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", host, port)
+	cfg.DBName = database
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.Params = map[string]string{"charset": "utf8mb4"}
+	if readOnly {
+		cfg.Params["transaction_read_only"] = "1"
+	}
+	return cfg.FormatDSN()
+}
+
+// NewMySQLConnector 创建可读写的MySQL连接器
 func NewMySQLConnector(host string, port int, username, password, database string) (*MySQLConnector, error) {
+	return newMySQLConnector(host, port, username, password, database, false)
+}
+
+// NewReadOnlyMySQLConnector 创建只读的MySQL连接器，用于读取外部数据源
+func NewReadOnlyMySQLConnector(host string, port int, username, password, database string) (*MySQLConnector, error) {
+	return newMySQLConnector(host, port, username, password, database, true)
+}
+
+// newMySQLConnector 创建MySQL连接器
+func newMySQLConnector(host string, port int, username, password, database string, readOnly bool) (*MySQLConnector, error) {
 	// 构建DSN (Data Source Name)
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true&loc=Local",
-		username, password, host, port, database)
+	dsn := BuildDSN(host, port, username, password, database, readOnly)
 
-	// 连接数据库
-	db, err := sql.Open("mysql", dsn)
+	// 从共享连接池获取连接，连接参数相同的连接器复用同一个连接池
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db, release, err := pool.GetRegistry().AcquireSQL(ctx, pool.MySQL, dsn, fmt.Sprintf("%s:%d", host, port), 0)
 	if err != nil {
 		return nil, fmt.Errorf("连接MySQL失败: %w", err)
 	}
 
-	// 设置连接池参数
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Minute * 3)
-
-	// 测试连接
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("MySQL服务器无响应: %w", err)
-	}
-
 	return &MySQLConnector{
 		db:       db,
+		release:  release,
 		dsn:      dsn,
 		host:     host,
 		port:     port,
@@ -54,13 +78,31 @@ func NewMySQLConnector(host string, port int, username, password, database strin
 	}, nil
 }
 
-// Close 关闭连接
+// Close 释放连接，共享的连接池在没有其他使用方时由连接池注册表关闭
 func (c *MySQLConnector) Close() error {
-	return c.db.Close()
+	if c.release != nil {
+		c.release()
+	}
+	return nil
+}
+
+// DB 返回底层数据库连接
+func (c *MySQLConnector) DB() *sql.DB {
+	return c.db
+}
+
+// Database 返回连接时指定的数据库名
+func (c *MySQLConnector) Database() string {
+	return c.database
 }
 
 // ExtractConnectionInfo 提取数据库连接信息
-func (c *MySQLConnector) ExtractConnectionInfo() (*datastorage.MySQLConnectionInfo, error) {
+// 按opts过滤表名并发推断，单个表失败时在该表的error中记录原因，不影响其他表
+func (c *MySQLConnector) ExtractConnectionInfo(ctx context.Context, opts providers.IntrospectOptions) (*datastorage.MySQLConnectionInfo, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	// 创建连接信息
 	connInfo := &datastorage.MySQLConnectionInfo{
 		Host:     c.host,
@@ -72,7 +114,7 @@ func (c *MySQLConnector) ExtractConnectionInfo() (*datastorage.MySQLConnectionIn
 	}
 
 	// 获取表列表
-	rows, err := c.db.Query("SHOW TABLES")
+	rows, err := c.db.QueryContext(ctx, "SHOW TABLES")
 	if err != nil {
 		return nil, fmt.Errorf("获取表列表失败: %w", err)
 	}
@@ -86,124 +128,32 @@ func (c *MySQLConnector) ExtractConnectionInfo() (*datastorage.MySQLConnectionIn
 		}
 		tables = append(tables, tableName)
 	}
+	rows.Close()
 
 	// 若没有表，返回错误
 	if len(tables) == 0 {
 		return nil, fmt.Errorf("数据库 %s 中没有表", c.database)
 	}
 
-	// 处理每个表
-	for _, tableName := range tables {
-		// 获取表结构
-		columnsRows, err := c.db.Query(fmt.Sprintf("DESCRIBE %s", tableName))
-		if err != nil {
-			return nil, fmt.Errorf("获取表 %s 结构失败: %w", tableName, err)
-		}
-
-		fields := make(map[string]string)
-		for columnsRows.Next() {
-			var field, fieldType, null, key, extra string
-			var defaultValue sql.NullString
-			if err := columnsRows.Scan(&field, &fieldType, &null, &key, &defaultValue, &extra); err != nil {
-				columnsRows.Close()
-				return nil, fmt.Errorf("读取表结构失败: %w", err)
-			}
-			fields[field] = getMySQLType(fieldType)
-		}
-		columnsRows.Close()
-
-		// 获取样本数据
-		sampleRows, err := c.db.Query(fmt.Sprintf("SELECT * FROM %s LIMIT 1", tableName))
+	// 并发处理每个表
+	var mu sync.Mutex
+	failures := providers.IntrospectEach(ctx, opts.Filter(tables), opts, func(ctx context.Context, tableName string) error {
+		tableInfo, err := c.ExtractTableInfo(ctx, c.database, tableName)
 		if err != nil {
-			return nil, fmt.Errorf("获取表 %s 的样本数据失败: %w", tableName, err)
+			return err
 		}
-		defer sampleRows.Close()
-
-		// 获取列名
-		columns, err := sampleRows.Columns()
-		if err != nil {
-			return nil, fmt.Errorf("获取列名失败: %w", err)
-		}
-
-		if !sampleRows.Next() {
-			// 表为空，添加空样本
-			connInfo.Tables[tableName] = datastorage.TableInformation{
-				Fields:     fields,
-				SampleData: "{}",
-			}
-			continue
-		}
-
-		// 准备扫描目标
-		values := make([]interface{}, len(columns))
-		scanArgs := make([]interface{}, len(columns))
-		for i := range values {
-			scanArgs[i] = &values[i]
-		}
-
-		// 扫描一行数据
-		if err := sampleRows.Scan(scanArgs...); err != nil {
-			return nil, fmt.Errorf("扫描数据失败: %w", err)
-		}
-
-		// 构建样本数据
-		sampleData := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-			// 处理NULL值
-			if val == nil {
-				sampleData[col] = nil
-				continue
-			}
-
-			// 根据类型进行转换
-			switch v := val.(type) {
-			case []byte:
-				// 尝试解析日期或数字
-				sampleData[col] = string(v)
-			default:
-				sampleData[col] = v
-			}
-		}
-
-		// 转换为JSON
-		sampleJSON, err := json.Marshal(sampleData)
-		if err != nil {
-			return nil, fmt.Errorf("转换样本数据失败: %w", err)
-		}
-
-		// 添加表信息
+		mu.Lock()
+		connInfo.Tables[tableName] = *tableInfo
+		mu.Unlock()
+		return nil
+	})
+	for tableName, err := range failures {
 		connInfo.Tables[tableName] = datastorage.TableInformation{
-			Fields:     fields,
-			SampleData: string(sampleJSON),
+			Fields:     map[string]string{},
+			SampleData: "{}",
+			Error:      err.Error(),
 		}
 	}
 
 	return connInfo, nil
 }
-
-// getMySQLType 根据MySQL类型返回简化类型名称
-func getMySQLType(mysqlType string) string {
-	mysqlType = strings.ToLower(mysqlType)
-
-	if strings.Contains(mysqlType, "int") {
-		return "int"
-	}
-	if strings.Contains(mysqlType, "float") || strings.Contains(mysqlType, "double") || strings.Contains(mysqlType, "decimal") {
-		return "float"
-	}
-	if strings.Contains(mysqlType, "char") || strings.Contains(mysqlType, "text") {
-		return "str"
-	}
-	if strings.Contains(mysqlType, "date") || strings.Contains(mysqlType, "time") {
-		return "date"
-	}
-	if strings.Contains(mysqlType, "blob") || strings.Contains(mysqlType, "binary") {
-		return "binary"
-	}
-	if mysqlType == "tinyint(1)" {
-		return "bool"
-	}
-
-	return "unknown"
-}
@@ -0,0 +1,198 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+)
+
+// ProviderName MySQL数据源类型名
+const ProviderName = "mysql"
+
+func init() {
+	providers.Register(Provider{})
+}
+
+// Provider MySQL数据源提供者
+// 配置项: host、port(默认3306)、username、password、database、readOnly(默认true)
+type Provider struct{}
+
+// Name 返回数据源类型名
+func (Provider) Name() string {
+	return ProviderName
+}
+
+// ValidateConfig 检查连接配置
+func (Provider) ValidateConfig(cfg providers.Config) error {
+	_, err := parseConfig(cfg)
+	return err
+}
+
+// Connect 建立MySQL连接，除非配置readOnly为false，否则连接是只读的
+func (Provider) Connect(ctx context.Context, cfg providers.Config) (providers.Connection, error) {
+	params, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	readOnly, ok := cfg.Bool("readOnly", "read_only")
+	if !ok {
+		readOnly = true
+	}
+	connector, err := newMySQLConnector(params.host, params.port, params.username, params.password, params.database, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	return &connection{connector: connector}, nil
+}
+
+// WrapDB 使用已建立的连接池创建通用连接，供自行管理连接生命周期的调用方（如会话）使用
+// 返回的连接Close时不会关闭db
+func WrapDB(db *sql.DB, database string) providers.Connection {
+	return &connection{connector: &MySQLConnector{db: db, database: database}, borrowed: true}
+}
+
+// NewMySQLConnectorFromConfig 根据通用配置创建可读写的MySQL连接器，用于写入目标
+func NewMySQLConnectorFromConfig(cfg providers.Config) (*MySQLConnector, error) {
+	params, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewMySQLConnector(params.host, params.port, params.username, params.password, params.database)
+}
+
+// connectionParams 解析后的连接参数
+type connectionParams struct {
+	host     string
+	port     int
+	username string
+	password string
+	database string
+}
+
+// parseConfig 从通用配置中解析连接参数
+func parseConfig(cfg providers.Config) (*connectionParams, error) {
+	port, err := cfg.Int(3306, "port")
+	if err != nil {
+		return nil, err
+	}
+	params := &connectionParams{
+		host:     cfg.String("host"),
+		port:     port,
+		username: cfg.String("username", "user"),
+		password: cfg.String("password", "pwd", "passwd"),
+		database: cfg.String("database", "db", "dbname"),
+	}
+	if params.host == "" {
+		return nil, fmt.Errorf("缺少必要参数: host")
+	}
+	if params.username == "" {
+		return nil, fmt.Errorf("缺少必要参数: username")
+	}
+	if params.database == "" {
+		return nil, fmt.Errorf("缺少必要参数: database")
+	}
+	return params, nil
+}
+
+// connection 基于MySQLConnector的通用连接
+type connection struct {
+	connector *MySQLConnector
+	borrowed  bool // 连接由调用方管理，Close时不关闭
+}
+
+// ListEntities 列出表和视图
+func (c *connection) ListEntities(ctx context.Context) ([]providers.Entity, error) {
+	tables, err := c.connector.ListTables("")
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]providers.Entity, 0, len(tables))
+	for _, table := range tables {
+		kind := "table"
+		if table.Type != "BASE TABLE" {
+			kind = "view"
+		}
+		entities = append(entities, providers.Entity{
+			Name:          table.Name,
+			Kind:          kind,
+			EstimatedRows: table.EstimatedRows,
+		})
+	}
+	return entities, nil
+}
+
+// Describe 从information_schema读取列定义
+func (c *connection) Describe(ctx context.Context, entity string) (*providers.EntitySchema, error) {
+	if entity == "" {
+		return nil, providers.ErrEntityRequired
+	}
+	rows, err := c.connector.db.QueryContext(ctx, `SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY
+		FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`,
+		c.connector.database, entity)
+	if err != nil {
+		return nil, fmt.Errorf("获取表 %s 结构失败: %w", entity, err)
+	}
+	defer rows.Close()
+
+	schema := &providers.EntitySchema{Name: entity, Fields: []providers.Field{}}
+	for rows.Next() {
+		var name, columnType, nullable, key string
+		if err := rows.Scan(&name, &columnType, &nullable, &key); err != nil {
+			return nil, fmt.Errorf("读取表结构失败: %w", err)
+		}
+		schema.Fields = append(schema.Fields, providers.Field{
+			Name:       name,
+			Type:       typesystem.FromMySQL(columnType),
+			NativeType: columnType,
+			Nullable:   nullable == "YES",
+			PrimaryKey: key == "PRI",
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(schema.Fields) == 0 {
+		return nil, fmt.Errorf("表 %s 不存在", entity)
+	}
+	return schema, nil
+}
+
+// Sample 读取前limit行
+func (c *connection) Sample(ctx context.Context, entity string, limit int) ([]providers.Row, error) {
+	if entity == "" {
+		return nil, providers.ErrEntityRequired
+	}
+	rows, err := c.connector.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT * FROM %s LIMIT ?", qualifiedName(c.connector.database, entity)),
+		providers.NormalizeLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("获取表 %s 的样本数据失败: %w", entity, err)
+	}
+	defer rows.Close()
+	return providers.CollectRows(rows)
+}
+
+// StreamRows 逐行读取整张表
+func (c *connection) StreamRows(ctx context.Context, entity string, fn providers.RowHandler) error {
+	if entity == "" {
+		return providers.ErrEntityRequired
+	}
+	rows, err := c.connector.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT * FROM %s", qualifiedName(c.connector.database, entity)))
+	if err != nil {
+		return fmt.Errorf("读取表 %s 失败: %w", entity, err)
+	}
+	defer rows.Close()
+	return providers.ScanRows(rows, fn)
+}
+
+// Close 关闭连接
+func (c *connection) Close() error {
+	if c.borrowed {
+		return nil
+	}
+	return c.connector.Close()
+}
@@ -0,0 +1,200 @@
+// Package providers 定义数据源提供者的通用接口和注册表
+// 各数据源（mongodb、mysql、sqlite、csv）在自己的包中实现Provider并在init中注册，
+// 通用的 /api/datasource/:type/... 接口通过注册表查找提供者，新增数据源无需修改处理器
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"minds_iolite_backend/internal/datasource/typesystem"
+)
+
+const (
+	// DefaultSampleLimit 默认的样本行数
+	DefaultSampleLimit = 10
+	// MaxSampleLimit 允许的最大样本行数
+	MaxSampleLimit = 1000
+)
+
+// ErrEntityRequired 未指定实体名时返回的错误
+var ErrEntityRequired = errors.New("缺少必要参数: 实体名")
+
+// Entity 表示数据源中的一个实体（表、视图、集合或文件）
+type Entity struct {
+	Name          string `json:"name"`
+	Kind          string `json:"kind"`                    // table/view/collection/timeseries/file
+	EstimatedRows int64  `json:"estimatedRows,omitempty"` // 估算行数，未知时为0
+}
+
+// Field 表示实体中的一个字段
+type Field struct {
+	Name       string          `json:"name"`
+	Type       typesystem.Type `json:"type"`                 // 规范类型
+	NativeType string          `json:"nativeType,omitempty"` // 数据源原生类型
+	Nullable   bool            `json:"nullable"`
+	PrimaryKey bool            `json:"primaryKey,omitempty"`
+}
+
+// EntitySchema 表示实体的字段结构
+type EntitySchema struct {
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields"`
+}
+
+// Row 表示一行数据或一个文档
+type Row map[string]interface{}
+
+// RowHandler 逐行处理数据的回调，返回错误时停止读取
+type RowHandler func(row Row) error
+
+// Connection 表示一个已建立的数据源连接
+type Connection interface {
+	// ListEntities 列出可读取的实体
+	ListEntities(ctx context.Context) ([]Entity, error)
+	// Describe 返回实体的字段结构
+	Describe(ctx context.Context, entity string) (*EntitySchema, error)
+	// Sample 读取实体的前limit行
+	Sample(ctx context.Context, entity string, limit int) ([]Row, error)
+	// StreamRows 逐行读取实体的全部数据
+	StreamRows(ctx context.Context, entity string, fn RowHandler) error
+	// Close 关闭连接
+	Close() error
+}
+
+// Provider 表示一种数据源类型
+type Provider interface {
+	// Name 返回数据源类型名，用于路由中的 :type
+	Name() string
+	// ValidateConfig 检查连接配置是否完整，不建立连接
+	ValidateConfig(cfg Config) error
+	// Connect 根据配置建立连接
+	Connect(ctx context.Context, cfg Config) (Connection, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Provider)
+)
+
+// Register 注册数据源提供者，重复注册同名提供者会panic
+func Register(p Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if p == nil {
+		panic("providers: Register provider is nil")
+	}
+	name := strings.ToLower(p.Name())
+	if _, dup := registry[name]; dup {
+		panic("providers: Register called twice for provider " + name)
+	}
+	registry[name] = p
+}
+
+// Get 按类型名查找提供者，不区分大小写
+func Get(name string) (Provider, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	p, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("不支持的数据源类型: %s", name)
+	}
+	return p, nil
+}
+
+// Names 返回所有已注册的数据源类型名
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NormalizeLimit 填充默认样本行数并限制上限
+func NormalizeLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultSampleLimit
+	}
+	if limit > MaxSampleLimit {
+		return MaxSampleLimit
+	}
+	return limit
+}
+
+// Config 表示数据源连接配置，键名不区分大小写
+type Config map[string]interface{}
+
+// Get 按顺序查找第一个存在的键
+func (c Config) Get(keys ...string) (interface{}, bool) {
+	for _, key := range keys {
+		for k, v := range c {
+			if strings.EqualFold(k, key) {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// String 返回字符串配置项，不存在或类型不符时返回空串
+func (c Config) String(keys ...string) string {
+	v, _ := c.Get(keys...)
+	s, _ := v.(string)
+	return s
+}
+
+// Int 返回整数配置项，支持数字和字符串，不存在时返回def
+func (c Config) Int(def int, keys ...string) (int, error) {
+	v, ok := c.Get(keys...)
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		return int(n), nil
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case string:
+		if n == "" {
+			return def, nil
+		}
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("配置项 %s 不是有效的整数: %s", keys[0], n)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("配置项 %s 不是有效的整数", keys[0])
+	}
+}
+
+// Bool 返回布尔配置项，第二个返回值表示是否设置
+func (c Config) Bool(keys ...string) (bool, bool) {
+	v, ok := c.Get(keys...)
+	if !ok {
+		return false, false
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		parsed, err := strconv.ParseBool(b)
+		return parsed, err == nil
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,197 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrNotReadOnly 语句不是只读查询时返回的错误
+var ErrNotReadOnly = errors.New("只允许执行只读查询")
+
+// readOnlyStatements 允许作为语句开头的关键字
+var readOnlyStatements = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"EXPLAIN":  true,
+	"PRAGMA":   true,
+	"VALUES":   true,
+}
+
+// writeKeywords 出现在语句任意位置即视为写操作的关键字
+// 包含SELECT ... INTO OUTFILE、SELECT ... FOR UPDATE等带副作用的写法
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "REPLACE": true, "MERGE": true, "UPSERT": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "TRUNCATE": true, "RENAME": true,
+	"GRANT": true, "REVOKE": true, "ATTACH": true, "DETACH": true, "VACUUM": true, "REINDEX": true,
+	"LOCK": true, "UNLOCK": true, "CALL": true, "EXEC": true, "EXECUTE": true, "PREPARE": true,
+	"LOAD": true, "HANDLER": true, "SET": true, "INTO": true, "OUTFILE": true, "DUMPFILE": true,
+}
+
+// 只读检查支持的SQL方言，与数据源类型名称相同
+const (
+	DialectMySQL  = "mysql"
+	DialectSQLite = "sqlite"
+)
+
+// readPragmas 带参数时仍只读取信息的PRAGMA，其他PRAGMA带参数（=或函数形式）时都是修改设置
+var readPragmas = map[string]bool{
+	"TABLE_INFO": true, "TABLE_XINFO": true, "TABLE_LIST": true,
+	"INDEX_INFO": true, "INDEX_XINFO": true, "INDEX_LIST": true,
+	"FOREIGN_KEY_LIST": true, "FOREIGN_KEY_CHECK": true,
+	"INTEGRITY_CHECK": true, "QUICK_CHECK": true,
+}
+
+// sideEffectPragmas 不带参数也会修改数据库或连接状态的PRAGMA
+var sideEffectPragmas = map[string]bool{
+	"OPTIMIZE": true, "SHRINK_MEMORY": true, "WAL_CHECKPOINT": true, "INCREMENTAL_VACUUM": true,
+}
+
+// CheckReadOnlySQL 检查SQL是否为单条只读语句，dialect为DialectMySQL或DialectSQLite
+// 注释和字符串字面量按方言的规则去掉后再检查；这是第一道防线，执行时仍应使用只读事务或只读连接
+func CheckReadOnlySQL(query, dialect string) error {
+	if dialect != DialectMySQL && dialect != DialectSQLite {
+		return fmt.Errorf("不支持检查 %s 方言的SQL", dialect)
+	}
+	code, err := stripSQLLiterals(query, dialect)
+	if err != nil {
+		return err
+	}
+
+	// 只允许结尾的分号，拒绝多语句
+	code = strings.TrimSpace(code)
+	code = strings.TrimRight(code, "; \t\r\n")
+	if strings.Contains(code, ";") {
+		return fmt.Errorf("%w: 不允许一次执行多条语句", ErrNotReadOnly)
+	}
+
+	words := strings.FieldsFunc(strings.ToUpper(code), func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+	})
+	if len(words) == 0 {
+		return fmt.Errorf("查询语句不能为空")
+	}
+	if !readOnlyStatements[words[0]] {
+		return fmt.Errorf("%w: 不支持 %s 语句", ErrNotReadOnly, words[0])
+	}
+	if words[0] == "PRAGMA" {
+		if err := checkPragma(code); err != nil {
+			return err
+		}
+	}
+	for _, word := range words[1:] {
+		if writeKeywords[word] {
+			return fmt.Errorf("%w: 语句中包含 %s", ErrNotReadOnly, word)
+		}
+	}
+	return nil
+}
+
+// checkPragma 只允许读取PRAGMA：不带参数，或readPragmas中以函数形式传入参数
+// code为去掉注释和字面量后的语句，格式为 PRAGMA [schema.]name [= value | (value)]
+func checkPragma(code string) error {
+	rest := strings.TrimSpace(code[len("PRAGMA"):])
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || unicode.IsSpace(r))
+	})
+	name, args := rest, ""
+	if end >= 0 {
+		name, args = rest[:end], strings.TrimSpace(rest[end:])
+	}
+	name = strings.ToUpper(strings.Join(strings.Fields(name), ""))
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	if name == "" {
+		return fmt.Errorf("%w: PRAGMA缺少名称", ErrNotReadOnly)
+	}
+	if sideEffectPragmas[name] {
+		return fmt.Errorf("%w: 不允许执行 PRAGMA %s", ErrNotReadOnly, strings.ToLower(name))
+	}
+	if args == "" {
+		return nil
+	}
+	if strings.HasPrefix(args, "(") && strings.HasSuffix(args, ")") && readPragmas[name] {
+		return nil
+	}
+	return fmt.Errorf("%w: 不允许修改PRAGMA设置", ErrNotReadOnly)
+}
+
+// stripSQLLiterals 按方言去掉注释，并将字符串和引用标识符替换为空字面量
+//   - 反斜杠转义和#注释只属于MySQL，SQLite中反斜杠是普通字符，#不是注释
+//   - MySQL中--之后必须是空白才是注释，SQLite中--总是注释
+//   - SQLite的[...]是引用标识符
+//
+// MySQL的可执行注释 /*! ... */ 会被执行，因此直接拒绝
+func stripSQLLiterals(query, dialect string) (string, error) {
+	mysql := dialect == DialectMySQL
+	var b strings.Builder
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case r == '-' && next == '-' && (!mysql || i+2 >= len(runes) || unicode.IsSpace(runes[i+2]) || unicode.IsControl(runes[i+2])),
+			r == '#' && mysql:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			b.WriteRune(' ')
+		case r == '/' && next == '*':
+			if i+2 < len(runes) && runes[i+2] == '!' {
+				return "", fmt.Errorf("%w: 不允许使用可执行注释", ErrNotReadOnly)
+			}
+			j := i + 2
+			for j+1 < len(runes) && !(runes[j] == '*' && runes[j+1] == '/') {
+				j++
+			}
+			if j+1 >= len(runes) {
+				return "", fmt.Errorf("注释未结束")
+			}
+			i = j + 1
+			b.WriteRune(' ')
+		case r == '[' && !mysql:
+			j := i + 1
+			for j < len(runes) && runes[j] != ']' {
+				j++
+			}
+			if j >= len(runes) {
+				return "", fmt.Errorf("字符串或标识符未结束")
+			}
+			i = j
+			b.WriteString(" '' ")
+		case r == '\'' || r == '"' || r == '`':
+			quote := r
+			closed := false
+			for i++; i < len(runes); i++ {
+				if mysql && runes[i] == '\\' && quote != '`' {
+					i++
+					continue
+				}
+				if runes[i] == quote {
+					// 连续两个引号表示转义
+					if i+1 < len(runes) && runes[i+1] == quote {
+						i++
+						continue
+					}
+					closed = true
+					break
+				}
+			}
+			if !closed {
+				return "", fmt.Errorf("字符串或标识符未结束")
+			}
+			b.WriteString(" '' ")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
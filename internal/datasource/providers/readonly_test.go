@@ -0,0 +1,87 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckReadOnlySQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		query   string
+		wantErr error // nil表示允许；errAny表示拒绝但不是ErrNotReadOnly
+	}{
+		// 基本语句
+		{"SELECT", DialectSQLite, "SELECT * FROM t", nil},
+		{"WITH", DialectMySQL, "WITH x AS (SELECT 1) SELECT * FROM x", nil},
+		{"SHOW", DialectMySQL, "SHOW TABLES", nil},
+		{"结尾的分号", DialectSQLite, "SELECT 1;  ", nil},
+		{"INSERT", DialectSQLite, "INSERT INTO t VALUES (1)", ErrNotReadOnly},
+		{"SELECT INTO OUTFILE", DialectMySQL, "SELECT * FROM t INTO OUTFILE '/tmp/x'", ErrNotReadOnly},
+		{"SELECT FOR UPDATE", DialectMySQL, "SELECT * FROM t FOR UPDATE", ErrNotReadOnly},
+		{"空语句", DialectSQLite, " ; ", errAny},
+		{"不支持的方言", "postgres", "SELECT 1", errAny},
+
+		// 多条语句
+		{"多条语句", DialectSQLite, "SELECT 1; DROP TABLE t", ErrNotReadOnly},
+		{"两条只读语句", DialectMySQL, "SELECT 1; SELECT 2", ErrNotReadOnly},
+		{"字符串中的分号", DialectSQLite, "SELECT 'a;b' FROM t", nil},
+
+		// 注释
+		{"行注释中的关键字", DialectSQLite, "SELECT 1 -- DROP TABLE t", nil},
+		{"块注释中的关键字", DialectMySQL, "SELECT /* DELETE */ 1", nil},
+		{"注释后的语句", DialectSQLite, "SELECT 1 -- x\n; DROP TABLE t", ErrNotReadOnly},
+		{"MySQL的#注释", DialectMySQL, "SELECT 1 # DROP TABLE t", nil},
+		{"SQLite中#不是注释", DialectSQLite, "SELECT #x; DROP TABLE t", ErrNotReadOnly},
+		{"MySQL的--后没有空白不是注释", DialectMySQL, "SELECT 1--1; DROP TABLE t", ErrNotReadOnly},
+		{"SQLite的--总是注释", DialectSQLite, "SELECT 1--; DROP TABLE t", nil},
+		{"MySQL可执行注释", DialectMySQL, "SELECT /*! 1 */", ErrNotReadOnly},
+		{"注释未结束", DialectSQLite, "SELECT 1 /* x", errAny},
+
+		// 引号
+		{"SQLite中反斜杠不转义", DialectSQLite, "SELECT 'a\\'; DROP TABLE t --'", ErrNotReadOnly},
+		{"MySQL中反斜杠转义", DialectMySQL, "SELECT 'a\\'; DROP TABLE t --'", nil},
+		{"SQLite双引号标识符中的反斜杠", DialectSQLite, "SELECT \"a\\\"; DROP TABLE t --\"", ErrNotReadOnly},
+		{"连续两个引号", DialectSQLite, "SELECT 'it''s; DROP' FROM t", nil},
+		{"反引号标识符中的关键字", DialectMySQL, "SELECT `delete` FROM t", nil},
+		{"反引号标识符中没有反斜杠转义", DialectMySQL, "SELECT `a\\`; DROP TABLE t", ErrNotReadOnly},
+		{"SQLite方括号标识符", DialectSQLite, "SELECT [update] FROM t", nil},
+		{"SQLite方括号中的引号", DialectSQLite, "SELECT [']; DROP TABLE t; SELECT ']'", ErrNotReadOnly},
+		{"字符串未结束", DialectMySQL, "SELECT 'abc", errAny},
+		{"方括号未结束", DialectSQLite, "SELECT [abc", errAny},
+
+		// PRAGMA
+		{"读取PRAGMA", DialectSQLite, "PRAGMA journal_mode", nil},
+		{"读取表结构", DialectSQLite, "PRAGMA table_info(t)", nil},
+		{"带schema读取表结构", DialectSQLite, "PRAGMA main.table_info('t')", nil},
+		{"读取索引", DialectSQLite, "pragma index_list( \"t\" );", nil},
+		{"赋值形式修改设置", DialectSQLite, "PRAGMA query_only = 0", ErrNotReadOnly},
+		{"函数形式修改设置", DialectSQLite, "PRAGMA query_only(0)", ErrNotReadOnly},
+		{"带schema的函数形式修改设置", DialectSQLite, "PRAGMA main.journal_mode(DELETE)", ErrNotReadOnly},
+		{"注释隔开的函数形式", DialectSQLite, "PRAGMA query_only /* x */ (0)", ErrNotReadOnly},
+		{"读取PRAGMA后还有其他内容", DialectSQLite, "PRAGMA table_info(t) x", ErrNotReadOnly},
+		{"有副作用的PRAGMA", DialectSQLite, "PRAGMA wal_checkpoint", ErrNotReadOnly},
+		{"缺少名称", DialectSQLite, "PRAGMA", ErrNotReadOnly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckReadOnlySQL(tt.query, tt.dialect)
+			switch {
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Errorf("CheckReadOnlySQL(%q) 错误 = %v, 期望允许", tt.query, err)
+				}
+			case tt.wantErr == errAny:
+				if err == nil || errors.Is(err, ErrNotReadOnly) {
+					t.Errorf("CheckReadOnlySQL(%q) 错误 = %v, 期望格式错误", tt.query, err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("CheckReadOnlySQL(%q) 错误 = %v, 期望 %v", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// errAny 表示期望返回不是ErrNotReadOnly的错误
+var errAny = errors.New("any")
@@ -1,12 +1,18 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"net/url"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"minds_iolite_backend/internal/datasource/pool"
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
 	"minds_iolite_backend/internal/services/datastorage"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -15,36 +21,55 @@ import (
 // SQLiteConnector SQLite连接器
 type SQLiteConnector struct {
 	db       *sql.DB
+	release  func() // 释放共享连接池的引用
 	filePath string
 }
 
-// NewSQLiteConnector 创建SQLite连接器
+// ReadOnlyDSN 返回以只读方式打开SQLite文件的连接字符串
+// mode=ro使SQLite拒绝写入且不会在文件不存在时创建文件，_query_only再在连接上禁止修改数据的语句
+func ReadOnlyDSN(filePath string) string {
+	path := (&url.URL{Path: filepath.ToSlash(filePath)}).EscapedPath()
+	return "file:" + path + "?mode=ro&_query_only=true"
+}
+
+// NewSQLiteConnector 创建可读写的SQLite连接器
 func NewSQLiteConnector(filePath string) (*SQLiteConnector, error) {
-	// 连接SQLite数据库
-	db, err := sql.Open("sqlite3", filePath)
-	if err != nil {
-		return nil, fmt.Errorf("连接SQLite数据库失败: %w", err)
-	}
+	return openSQLite(filePath, filePath)
+}
 
-	// 设置连接参数
-	db.SetMaxOpenConns(1) // SQLite建议只用一个连接
-	db.SetMaxIdleConns(1)
-	db.SetConnMaxLifetime(time.Minute * 3)
+// NewReadOnlySQLiteConnector 以只读方式打开SQLite文件，用于读取外部数据源
+func NewReadOnlySQLiteConnector(filePath string) (*SQLiteConnector, error) {
+	return openSQLite(ReadOnlyDSN(filePath), filePath)
+}
 
-	// 测试连接
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("SQLite数据库无响应: %w", err)
+// openSQLite 按连接字符串打开SQLite数据库
+func openSQLite(dsn, filePath string) (*SQLiteConnector, error) {
+	// 从共享连接池获取连接，SQLite建议只用一个连接
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db, release, err := pool.GetRegistry().AcquireSQL(ctx, pool.SQLite, dsn, filePath, 1)
+	if err != nil {
+		return nil, fmt.Errorf("连接SQLite数据库失败: %w", err)
 	}
 
 	return &SQLiteConnector{
 		db:       db,
+		release:  release,
 		filePath: filePath,
 	}, nil
 }
 
-// Close 关闭连接
+// Close 释放连接，共享的连接池在没有其他使用方时由连接池注册表关闭
 func (c *SQLiteConnector) Close() error {
-	return c.db.Close()
+	if c.release != nil {
+		c.release()
+	}
+	return nil
+}
+
+// DB 返回底层数据库连接
+func (c *SQLiteConnector) DB() *sql.DB {
+	return c.db
 }
 
 // GetTableNames 获取所有表名
@@ -77,9 +102,9 @@ func (c *SQLiteConnector) GetTableNames() ([]string, error) {
 }
 
 // ExtractTableInfo 提取表结构信息
-func (c *SQLiteConnector) ExtractTableInfo(tableName string) (*datastorage.TableInformation, error) {
+func (c *SQLiteConnector) ExtractTableInfo(ctx context.Context, tableName string) (*datastorage.TableInformation, error) {
 	// 获取表结构
-	rows, err := c.db.Query(fmt.Sprintf("PRAGMA table_info('%s')", tableName))
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdentifier(tableName)))
 	if err != nil {
 		return nil, fmt.Errorf("获取表 %s 结构失败: %w", tableName, err)
 	}
@@ -94,12 +119,12 @@ func (c *SQLiteConnector) ExtractTableInfo(tableName string) (*datastorage.Table
 		if err := rows.Scan(&cid, &name, &dataType, &notNull, &dfltValue, &pk); err != nil {
 			return nil, fmt.Errorf("读取表结构失败: %w", err)
 		}
-		fields[name] = getSQLiteType(dataType)
+		fields[name] = typesystem.FromSQLite(dataType).String()
 	}
 
 	// 获取样本数据
 	var sampleData string
-	sampleRow, err := c.db.Query(fmt.Sprintf("SELECT * FROM '%s' LIMIT 1", tableName))
+	sampleRow, err := c.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT 1", quoteIdentifier(tableName)))
 	if err != nil {
 		return nil, fmt.Errorf("获取表 %s 的样本数据失败: %w", tableName, err)
 	}
@@ -155,7 +180,13 @@ func (c *SQLiteConnector) ExtractTableInfo(tableName string) (*datastorage.Table
 }
 
 // ExtractConnectionInfo 提取数据库连接信息
-func (c *SQLiteConnector) ExtractConnectionInfo() (*datastorage.SQLiteConnectionInfo, error) {
+// 按opts过滤表名推断结构，单个表失败时在该表的error中记录原因，不影响其他表
+// SQLite只使用一个连接，并发数实际为1，但单表超时仍然生效
+func (c *SQLiteConnector) ExtractConnectionInfo(ctx context.Context, opts providers.IntrospectOptions) (*datastorage.SQLiteConnectionInfo, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	// 获取表名列表
 	tables, err := c.GetTableNames()
 	if err != nil {
@@ -169,12 +200,23 @@ func (c *SQLiteConnector) ExtractConnectionInfo() (*datastorage.SQLiteConnection
 	}
 
 	// 获取各表信息
-	for _, tableName := range tables {
-		tableInfo, err := c.ExtractTableInfo(tableName)
+	var mu sync.Mutex
+	failures := providers.IntrospectEach(ctx, opts.Filter(tables), opts, func(ctx context.Context, tableName string) error {
+		tableInfo, err := c.ExtractTableInfo(ctx, tableName)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		mu.Lock()
 		connInfo.TableInfo[tableName] = *tableInfo
+		mu.Unlock()
+		return nil
+	})
+	for tableName, err := range failures {
+		connInfo.TableInfo[tableName] = datastorage.TableInformation{
+			Fields:     map[string]string{},
+			SampleData: "{}",
+			Error:      err.Error(),
+		}
 	}
 
 	return connInfo, nil
@@ -189,7 +231,7 @@ func (c *SQLiteConnector) ExtractTableConnectionInfo(tableName string) (*datasto
 	}
 
 	// 获取表信息
-	tableInfo, err := c.ExtractTableInfo(tableName)
+	tableInfo, err := c.ExtractTableInfo(context.Background(), tableName)
 	if err != nil {
 		return nil, err
 	}
@@ -197,25 +239,3 @@ func (c *SQLiteConnector) ExtractTableConnectionInfo(tableName string) (*datasto
 
 	return connInfo, nil
 }
-
-// 根据SQLite类型返回统一类型
-func getSQLiteType(sqliteType string) string {
-	sqliteType = strings.ToLower(sqliteType)
-
-	switch sqliteType {
-	case "integer", "int":
-		return "int"
-	case "real", "float", "double", "numeric", "decimal":
-		return "float"
-	case "text", "char", "varchar", "varying character", "character":
-		return "str"
-	case "boolean":
-		return "bool"
-	case "date", "datetime", "timestamp":
-		return "date"
-	case "blob":
-		return "binary"
-	default:
-		return "unknown"
-	}
-}
@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+	"minds_iolite_backend/internal/models/datasource"
+)
+
+// ProviderName SQLite数据源类型名
+const ProviderName = "sqlite"
+
+func init() {
+	providers.Register(Provider{})
+}
+
+// Provider SQLite数据源提供者
+// 配置项: filePath、readOnly(默认true)
+type Provider struct{}
+
+// Name 返回数据源类型名
+func (Provider) Name() string {
+	return ProviderName
+}
+
+// ValidateConfig 检查文件路径是否存在且扩展名正确
+func (Provider) ValidateConfig(cfg providers.Config) error {
+	return datasource.NewSQLiteSource(cfg.String("filePath", "file_path", "path")).Validate()
+}
+
+// Connect 打开SQLite文件，除非配置readOnly为false，否则以只读方式打开
+func (p Provider) Connect(ctx context.Context, cfg providers.Config) (providers.Connection, error) {
+	if err := p.ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	open := NewReadOnlySQLiteConnector
+	if readOnly, ok := cfg.Bool("readOnly", "read_only"); ok && !readOnly {
+		open = NewSQLiteConnector
+	}
+	connector, err := open(cfg.String("filePath", "file_path", "path"))
+	if err != nil {
+		return nil, err
+	}
+	return &connection{connector: connector}, nil
+}
+
+// WrapDB 使用已打开的数据库连接创建通用连接，供自行管理连接生命周期的调用方（如会话）使用
+// 返回的连接Close时不会关闭db
+func WrapDB(db *sql.DB) providers.Connection {
+	return &connection{connector: &SQLiteConnector{db: db}, borrowed: true}
+}
+
+// quoteIdentifier 使用双引号引用标识符
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// connection 基于SQLiteConnector的通用连接
+type connection struct {
+	connector *SQLiteConnector
+	borrowed  bool // 连接由调用方管理，Close时不关闭
+}
+
+// ListEntities 列出表和视图
+func (c *connection) ListEntities(ctx context.Context) ([]providers.Entity, error) {
+	rows, err := c.connector.db.QueryContext(ctx, `
+		SELECT name, type FROM sqlite_master
+		WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("获取表列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	entities := []providers.Entity{}
+	for rows.Next() {
+		var entity providers.Entity
+		if err := rows.Scan(&entity.Name, &entity.Kind); err != nil {
+			return nil, fmt.Errorf("读取表名失败: %w", err)
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
+
+// Describe 通过PRAGMA table_info读取列定义
+func (c *connection) Describe(ctx context.Context, entity string) (*providers.EntitySchema, error) {
+	if entity == "" {
+		return nil, providers.ErrEntityRequired
+	}
+	rows, err := c.connector.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", quoteIdentifier(entity)))
+	if err != nil {
+		return nil, fmt.Errorf("获取表 %s 结构失败: %w", entity, err)
+	}
+	defer rows.Close()
+
+	schema := &providers.EntitySchema{Name: entity, Fields: []providers.Field{}}
+	for rows.Next() {
+		var cid int
+		var name, dataType string
+		var notNull, pk int
+		var dfltValue interface{}
+		if err := rows.Scan(&cid, &name, &dataType, &notNull, &dfltValue, &pk); err != nil {
+			return nil, fmt.Errorf("读取表结构失败: %w", err)
+		}
+		schema.Fields = append(schema.Fields, providers.Field{
+			Name:       name,
+			Type:       typesystem.FromSQLite(dataType),
+			NativeType: dataType,
+			Nullable:   notNull == 0 && pk == 0,
+			PrimaryKey: pk > 0,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(schema.Fields) == 0 {
+		return nil, fmt.Errorf("表 %s 不存在", entity)
+	}
+	return schema, nil
+}
+
+// Sample 读取前limit行
+func (c *connection) Sample(ctx context.Context, entity string, limit int) ([]providers.Row, error) {
+	if entity == "" {
+		return nil, providers.ErrEntityRequired
+	}
+	rows, err := c.connector.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT * FROM %s LIMIT ?", quoteIdentifier(entity)), providers.NormalizeLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("获取表 %s 的样本数据失败: %w", entity, err)
+	}
+	defer rows.Close()
+	return providers.CollectRows(rows)
+}
+
+// StreamRows 逐行读取整张表
+func (c *connection) StreamRows(ctx context.Context, entity string, fn providers.RowHandler) error {
+	if entity == "" {
+		return providers.ErrEntityRequired
+	}
+	rows, err := c.connector.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s", quoteIdentifier(entity)))
+	if err != nil {
+		return fmt.Errorf("读取表 %s 失败: %w", entity, err)
+	}
+	defer rows.Close()
+	return providers.ScanRows(rows, fn)
+}
+
+// Close 关闭连接
+func (c *connection) Close() error {
+	if c.borrowed {
+		return nil
+	}
+	return c.connector.Close()
+}
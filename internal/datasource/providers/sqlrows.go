@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ScanRows 将查询结果逐行转换为Row并交给fn处理，[]byte值转换为字符串
+// 供基于database/sql的提供者共用，调用方负责关闭rows
+func ScanRows(rows *sql.Rows, fn RowHandler) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("获取列名失败: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return fmt.Errorf("扫描数据失败: %w", err)
+		}
+
+		row := make(Row, len(columns))
+		for i, col := range columns {
+			switch v := values[i].(type) {
+			case []byte:
+				row[col] = string(v)
+			default:
+				row[col] = v
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CollectRows 读取全部查询结果
+func CollectRows(rows *sql.Rows) ([]Row, error) {
+	result := []Row{}
+	err := ScanRows(rows, func(row Row) error {
+		result = append(result, row)
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,445 @@
+package typesystem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/models/datasource"
+	"minds_iolite_backend/internal/models/metadata"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Type 表示跨数据源统一的规范类型
+// 所有数据源API返回的字段类型（如 "int"、"str"）都使用该类型
+type Type string
+
+// 支持的规范类型
+const (
+	Int      Type = "int"      // 整数
+	Float    Type = "float"    // 浮点数/定点数
+	Str      Type = "str"      // 字符串
+	Bool     Type = "bool"     // 布尔值
+	Date     Type = "date"     // 日期/时间
+	Binary   Type = "binary"   // 二进制
+	ObjectID Type = "ObjectId" // MongoDB ObjectId
+	Array    Type = "array"    // 数组
+	Object   Type = "object"   // 嵌套对象/JSON
+	Null     Type = "null"     // 空值
+	Unknown  Type = "unknown"  // 无法识别的类型
+)
+
+// All 返回所有规范类型
+func All() []Type {
+	return []Type{Int, Float, Str, Bool, Date, Binary, ObjectID, Array, Object, Null, Unknown}
+}
+
+// String 返回类型名称
+func (t Type) String() string {
+	return string(t)
+}
+
+// Parse 将类型名称解析为规范类型，无法识别时返回Unknown
+func Parse(name string) Type {
+	for _, t := range All() {
+		if string(t) == name {
+			return t
+		}
+	}
+	return Unknown
+}
+
+// Merge 合并同一字段在不同值上推断出的类型
+// 空值和未知类型不影响已知类型，整数与浮点数合并为浮点数，其他冲突按字符串处理
+func Merge(current, next Type) Type {
+	switch {
+	case current == next:
+		return current
+	case next == Null || next == Unknown:
+		return current
+	case current == Null || current == Unknown:
+		return next
+	case (current == Int && next == Float) || (current == Float && next == Int):
+		return Float
+	default:
+		return Str
+	}
+}
+
+// baseType 提取原生类型的基础名称，如 "int(11) unsigned" 返回 "int"
+func baseType(nativeType string) string {
+	t := strings.ToLower(strings.TrimSpace(nativeType))
+	if i := strings.IndexAny(t, "( "); i >= 0 {
+		t = t[:i]
+	}
+	return t
+}
+
+// FromMySQL 将MySQL原生列类型映射为规范类型
+func FromMySQL(nativeType string) Type {
+	full := strings.ToLower(strings.TrimSpace(nativeType))
+	switch baseType(full) {
+	case "tinyint":
+		if strings.HasPrefix(full, "tinyint(1)") {
+			return Bool
+		}
+		return Int
+	case "bool", "boolean":
+		return Bool
+	case "bit":
+		if full == "bit" || strings.HasPrefix(full, "bit(1)") {
+			return Bool
+		}
+		return Binary
+	case "smallint", "mediumint", "int", "integer", "bigint", "year":
+		return Int
+	case "decimal", "numeric", "dec", "fixed", "float", "double", "real":
+		return Float
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set":
+		return Str
+	case "date", "datetime", "timestamp", "time":
+		return Date
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return Binary
+	case "json":
+		return Object
+	default:
+		return Unknown
+	}
+}
+
+// FromSQLite 将SQLite声明类型映射为规范类型
+// 遵循SQLite的类型亲和性规则，并额外识别布尔和日期类型
+func FromSQLite(nativeType string) Type {
+	t := strings.ToLower(strings.TrimSpace(nativeType))
+	switch {
+	case t == "":
+		return Unknown
+	case strings.Contains(t, "bool"):
+		return Bool
+	case strings.Contains(t, "int"):
+		return Int
+	case strings.Contains(t, "char"), strings.Contains(t, "clob"), strings.Contains(t, "text"):
+		return Str
+	case strings.Contains(t, "blob"):
+		return Binary
+	case strings.Contains(t, "real"), strings.Contains(t, "floa"), strings.Contains(t, "doub"),
+		strings.Contains(t, "numeric"), strings.Contains(t, "decimal"):
+		return Float
+	case strings.Contains(t, "date"), strings.Contains(t, "time"):
+		return Date
+	case t == "json":
+		return Object
+	default:
+		return Unknown
+	}
+}
+
+// FromValue 根据Go值（包括MongoDB驱动解码出的值）推断规范类型
+func FromValue(value interface{}) Type {
+	switch value.(type) {
+	case nil:
+		return Null
+	case bool:
+		return Bool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return Int
+	case float32, float64, primitive.Decimal128:
+		return Float
+	case string:
+		return Str
+	case time.Time, primitive.DateTime, primitive.Timestamp:
+		return Date
+	case primitive.ObjectID:
+		return ObjectID
+	case bson.D, bson.M, map[string]interface{}:
+		return Object
+	case bson.A, []interface{}:
+		return Array
+	case primitive.Binary, []byte:
+		return Binary
+	default:
+		return Unknown
+	}
+}
+
+// textDateLayouts 文本中识别为日期的格式
+var textDateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"02-01-2006",
+	"02/01/2006",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	time.RFC3339,
+}
+
+// FromText 根据文本值（如CSV单元格）推断规范类型，与FromValue对解码后的值的推断一致
+// 空白为Null；只有true/false/yes/no（不区分大小写）是布尔值，0和1是整数
+func FromText(value string) Type {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return Null
+	case textBool(value):
+		return Bool
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return Int
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return Float
+	}
+	if _, err := parseTextDate(value); err == nil {
+		return Date
+	}
+	return Str
+}
+
+// FromTexts 按Merge的规则合并一列文本值的类型，全部为空时返回Null
+func FromTexts(values []string) Type {
+	result := Null
+	for _, value := range values {
+		result = Merge(result, FromText(value))
+	}
+	return result
+}
+
+// ParseText 将文本值转换为规范类型对应的Go值，空白返回nil
+// 布尔值还接受t/f/y/n/1/0，用于显式指定为布尔类型的列
+func ParseText(value string, t Type) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	switch t {
+	case Int:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("值 '%s' 不是有效的整数", value)
+		}
+		return n, nil
+	case Float:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("值 '%s' 不是有效的浮点数", value)
+		}
+		return f, nil
+	case Bool:
+		switch strings.ToLower(value) {
+		case "true", "yes", "t", "y", "1":
+			return true, nil
+		case "false", "no", "f", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("值 '%s' 不是有效的布尔值", value)
+	case Date:
+		return parseTextDate(value)
+	default:
+		return value, nil
+	}
+}
+
+// textBool 文本是否为推断时识别的布尔值
+func textBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no":
+		return true
+	}
+	return false
+}
+
+// parseTextDate 按textDateLayouts解析日期
+func parseTextDate(value string) (time.Time, error) {
+	for _, layout := range textDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("值 '%s' 不是有效的日期", value)
+}
+
+// FromBSON 将BSON类型别名（$jsonSchema中的bsonType）映射为规范类型
+func FromBSON(bsonType string) Type {
+	switch bsonType {
+	case "int", "long":
+		return Int
+	case "double", "decimal":
+		return Float
+	case "string":
+		return Str
+	case "bool":
+		return Bool
+	case "date", "timestamp":
+		return Date
+	case "binData":
+		return Binary
+	case "objectId":
+		return ObjectID
+	case "array":
+		return Array
+	case "object":
+		return Object
+	case "null":
+		return Null
+	default:
+		return Unknown
+	}
+}
+
+// ToBSON 返回规范类型对应的BSON类型别名，Unknown返回空字符串
+func ToBSON(t Type) string {
+	switch t {
+	case Int:
+		return "long"
+	case Float:
+		return "double"
+	case Str:
+		return "string"
+	case Bool:
+		return "bool"
+	case Date:
+		return "date"
+	case Binary:
+		return "binData"
+	case ObjectID:
+		return "objectId"
+	case Array:
+		return "array"
+	case Object:
+		return "object"
+	case Null:
+		return "null"
+	default:
+		return ""
+	}
+}
+
+// ToMySQL 返回创建MySQL表时规范类型对应的列类型
+func ToMySQL(t Type) string {
+	switch t {
+	case Int:
+		return "BIGINT"
+	case Float:
+		return "DOUBLE"
+	case Bool:
+		return "TINYINT(1)"
+	case Date:
+		return "DATETIME"
+	case Binary:
+		return "LONGBLOB"
+	case ObjectID:
+		return "VARCHAR(24)"
+	case Array, Object:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+// ToSQLite 返回创建SQLite表时规范类型对应的列类型
+func ToSQLite(t Type) string {
+	switch t {
+	case Int:
+		return "INTEGER"
+	case Float:
+		return "REAL"
+	case Bool:
+		return "BOOLEAN"
+	case Date:
+		return "DATETIME"
+	case Binary:
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+// ToColumnType 将规范类型映射为统一数据模型的列类型
+func ToColumnType(t Type) datasource.ColumnType {
+	switch t {
+	case Int:
+		return datasource.ColumnTypeInteger
+	case Float:
+		return datasource.ColumnTypeFloat
+	case Bool:
+		return datasource.ColumnTypeBoolean
+	case Date:
+		return datasource.ColumnTypeDate
+	case Array:
+		return datasource.ColumnTypeArray
+	case Object:
+		return datasource.ColumnTypeObject
+	default:
+		return datasource.ColumnTypeString
+	}
+}
+
+// FromColumnType 将统一数据模型的列类型映射为规范类型
+func FromColumnType(ct datasource.ColumnType) Type {
+	switch ct {
+	case datasource.ColumnTypeInteger:
+		return Int
+	case datasource.ColumnTypeFloat:
+		return Float
+	case datasource.ColumnTypeBoolean:
+		return Bool
+	case datasource.ColumnTypeDate, datasource.ColumnTypeDateTime, datasource.ColumnTypeTimestamp:
+		return Date
+	case datasource.ColumnTypeArray:
+		return Array
+	case datasource.ColumnTypeObject:
+		return Object
+	case datasource.ColumnTypeString:
+		return Str
+	default:
+		return Unknown
+	}
+}
+
+// ToFieldType 将规范类型映射为元数据模型的字段类型
+func ToFieldType(t Type) metadata.FieldType {
+	switch t {
+	case Int:
+		return metadata.FieldTypeInteger
+	case Float:
+		return metadata.FieldTypeNumber
+	case Bool:
+		return metadata.FieldTypeBoolean
+	case Date:
+		return metadata.FieldTypeDateTime
+	case Array:
+		return metadata.FieldTypeArray
+	case Object:
+		return metadata.FieldTypeObject
+	default:
+		return metadata.FieldTypeString
+	}
+}
+
+// FromFieldType 将元数据模型的字段类型映射为规范类型
+func FromFieldType(ft metadata.FieldType) Type {
+	switch ft {
+	case metadata.FieldTypeInteger:
+		return Int
+	case metadata.FieldTypeNumber:
+		return Float
+	case metadata.FieldTypeBoolean:
+		return Bool
+	case metadata.FieldTypeDate, metadata.FieldTypeDateTime:
+		return Date
+	case metadata.FieldTypeArray:
+		return Array
+	case metadata.FieldTypeObject:
+		return Object
+	case metadata.FieldTypeReference:
+		return ObjectID
+	case metadata.FieldTypeString, metadata.FieldTypeEnum, metadata.FieldTypeFile, metadata.FieldTypeImage:
+		return Str
+	default:
+		return Unknown
+	}
+}
@@ -0,0 +1,367 @@
+package typesystem
+
+import (
+	"testing"
+	"time"
+
+	"minds_iolite_backend/internal/models/datasource"
+	"minds_iolite_backend/internal/models/metadata"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFromMySQL(t *testing.T) {
+	cases := map[string]Type{
+		"int":                 Int,
+		"int(11)":             Int,
+		"int(11) unsigned":    Int,
+		"INT(10) UNSIGNED":    Int,
+		"bigint(20)":          Int,
+		"smallint":            Int,
+		"mediumint(8)":        Int,
+		"tinyint(4)":          Int,
+		"tinyint(1)":          Bool,
+		"tinyint(1) unsigned": Bool,
+		"boolean":             Bool,
+		"bit(1)":              Bool,
+		"bit(8)":              Binary,
+		"year(4)":             Int,
+		"decimal(10,2)":       Float,
+		"float":               Float,
+		"double":              Float,
+		"double precision":    Float,
+		"real":                Float,
+		"char(3)":             Str,
+		"varchar(255)":        Str,
+		"text":                Str,
+		"longtext":            Str,
+		"enum('a','b')":       Str,
+		"set('x')":            Str,
+		"date":                Date,
+		"datetime":            Date,
+		"datetime(6)":         Date,
+		"timestamp":           Date,
+		"time":                Date,
+		"blob":                Binary,
+		"longblob":            Binary,
+		"varbinary(16)":       Binary,
+		"json":                Object,
+		"geometry":            Unknown,
+		"":                    Unknown,
+	}
+	for native, want := range cases {
+		if got := FromMySQL(native); got != want {
+			t.Errorf("FromMySQL(%q) = %q, want %q", native, got, want)
+		}
+	}
+}
+
+func TestFromSQLite(t *testing.T) {
+	cases := map[string]Type{
+		"INTEGER":           Int,
+		"int":               Int,
+		"BIGINT":            Int,
+		"UNSIGNED BIG INT":  Int,
+		"REAL":              Float,
+		"FLOAT":             Float,
+		"DOUBLE":            Float,
+		"NUMERIC":           Float,
+		"DECIMAL(10,5)":     Float,
+		"TEXT":              Str,
+		"VARCHAR(255)":      Str,
+		"NVARCHAR(100)":     Str,
+		"CHARACTER(20)":     Str,
+		"varying character": Str,
+		"CLOB":              Str,
+		"BOOLEAN":           Bool,
+		"bool":              Bool,
+		"DATE":              Date,
+		"DATETIME":          Date,
+		"TIMESTAMP":         Date,
+		"BLOB":              Binary,
+		"json":              Object,
+		"":                  Unknown,
+		"whatever":          Unknown,
+	}
+	for native, want := range cases {
+		if got := FromSQLite(native); got != want {
+			t.Errorf("FromSQLite(%q) = %q, want %q", native, got, want)
+		}
+	}
+}
+
+func TestFromValue(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		want  Type
+	}{
+		{nil, Null},
+		{true, Bool},
+		{int(1), Int},
+		{int32(1), Int},
+		{int64(1), Int},
+		{uint8(1), Int},
+		{float32(1.5), Float},
+		{float64(1.5), Float},
+		{primitive.NewDecimal128(1, 0), Float},
+		{"a", Str},
+		{time.Now(), Date},
+		{primitive.NewDateTimeFromTime(time.Now()), Date},
+		{primitive.Timestamp{T: 1}, Date},
+		{primitive.NewObjectID(), ObjectID},
+		{bson.D{{Key: "a", Value: 1}}, Object},
+		{bson.M{"a": 1}, Object},
+		{map[string]interface{}{"a": 1}, Object},
+		{bson.A{1, 2}, Array},
+		{[]interface{}{1}, Array},
+		{primitive.Binary{Data: []byte{1}}, Binary},
+		{[]byte{1}, Binary},
+		{struct{}{}, Unknown},
+	}
+	for _, c := range cases {
+		if got := FromValue(c.value); got != c.want {
+			t.Errorf("FromValue(%T) = %q, want %q", c.value, got, c.want)
+		}
+	}
+}
+
+func TestFromText(t *testing.T) {
+	cases := []struct {
+		value string
+		want  Type
+	}{
+		{"", Null},
+		{"  ", Null},
+		{"true", Bool},
+		{"FALSE", Bool},
+		{"yes", Bool},
+		{"1", Int},
+		{"0", Int},
+		{" -42 ", Int},
+		{"1.5", Float},
+		{"1e3", Float},
+		{"2024-01-02", Date},
+		{"2024/01/02 15:04:05", Date},
+		{"2024-01-02T15:04:05Z", Date},
+		{"2024-13-45", Str},
+		{"t", Str},
+		{"abc", Str},
+	}
+	for _, c := range cases {
+		if got := FromText(c.value); got != c.want {
+			t.Errorf("FromText(%q) = %q, want %q", c.value, got, c.want)
+		}
+	}
+}
+
+func TestFromTexts(t *testing.T) {
+	cases := []struct {
+		values []string
+		want   Type
+	}{
+		{nil, Null},
+		{[]string{"", " "}, Null},
+		{[]string{"1", "", "2"}, Int},
+		{[]string{"0", "1"}, Int},
+		{[]string{"1", "2.5"}, Float},
+		{[]string{"true", "no"}, Bool},
+		{[]string{"true", "1"}, Str},
+		{[]string{"2024-01-02", "2024-01-03 10:00:00"}, Date},
+		{[]string{"2024-01-02", "n/a"}, Str},
+	}
+	for _, c := range cases {
+		if got := FromTexts(c.values); got != c.want {
+			t.Errorf("FromTexts(%q) = %q, want %q", c.values, got, c.want)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	cases := []struct {
+		value   string
+		typ     Type
+		want    interface{}
+		wantErr bool
+	}{
+		{"", Int, nil, false},
+		{" 42 ", Int, int64(42), false},
+		{"4.2", Int, nil, true},
+		{"4.2", Float, 4.2, false},
+		{"yes", Bool, true, false},
+		{"0", Bool, false, false},
+		{"maybe", Bool, nil, true},
+		{"2024-01-02", Date, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), false},
+		{"tomorrow", Date, nil, true},
+		{"abc", Str, "abc", false},
+		{"abc", Unknown, "abc", false},
+	}
+	for _, c := range cases {
+		got, err := ParseText(c.value, c.typ)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseText(%q, %q) error = %v, wantErr %v", c.value, c.typ, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && got != c.want {
+			t.Errorf("ParseText(%q, %q) = %#v, want %#v", c.value, c.typ, got, c.want)
+		}
+	}
+}
+
+// 文本推断的类型与值解析后FromValue推断的类型一致
+func TestFromTextMatchesFromValue(t *testing.T) {
+	for _, value := range []string{"1", "1.5", "true", "2024-01-02", "abc", ""} {
+		typ := FromText(value)
+		parsed, err := ParseText(value, typ)
+		if err != nil {
+			t.Fatalf("ParseText(%q, %q) error = %v", value, typ, err)
+		}
+		if got := FromValue(parsed); got != typ {
+			t.Errorf("FromValue(ParseText(%q)) = %q, want %q", value, got, typ)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, typ := range All() {
+		if got := Parse(typ.String()); got != typ {
+			t.Errorf("Parse(%q) = %q", typ, got)
+		}
+	}
+	if got := Parse("nope"); got != Unknown {
+		t.Errorf("Parse(nope) = %q, want unknown", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		current, next, want Type
+	}{
+		{Int, Int, Int},
+		{Null, Int, Int},
+		{Int, Null, Int},
+		{Unknown, Date, Date},
+		{Int, Float, Float},
+		{Float, Int, Float},
+		{Int, Str, Str},
+		{Bool, Date, Str},
+	}
+	for _, tc := range cases {
+		if got := Merge(tc.current, tc.next); got != tc.want {
+			t.Errorf("Merge(%q, %q) = %q, want %q", tc.current, tc.next, got, tc.want)
+		}
+	}
+}
+
+func TestBSONRoundTrip(t *testing.T) {
+	for _, typ := range All() {
+		alias := ToBSON(typ)
+		if typ == Unknown {
+			if alias != "" {
+				t.Errorf("ToBSON(unknown) = %q, want empty", alias)
+			}
+			continue
+		}
+		if got := FromBSON(alias); got != typ {
+			t.Errorf("FromBSON(ToBSON(%q)) = %q", typ, got)
+		}
+	}
+	if FromBSON("int") != Int || FromBSON("decimal") != Float || FromBSON("timestamp") != Date {
+		t.Error("FromBSON 未识别 int/decimal/timestamp 别名")
+	}
+}
+
+func TestMySQLRoundTrip(t *testing.T) {
+	// 创建表时生成的列类型再读回时应得到相同的规范类型（数组/对象统一为JSON）
+	for _, typ := range []Type{Int, Float, Str, Bool, Date, Binary, Object} {
+		if got := FromMySQL(ToMySQL(typ)); got != typ {
+			t.Errorf("FromMySQL(ToMySQL(%q)) = %q", typ, got)
+		}
+	}
+	if FromMySQL(ToMySQL(Array)) != Object {
+		t.Error("数组应以JSON列存储")
+	}
+	if FromMySQL(ToMySQL(ObjectID)) != Str {
+		t.Error("ObjectId应以字符串列存储")
+	}
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	for _, typ := range []Type{Int, Float, Str, Bool, Date, Binary} {
+		if got := FromSQLite(ToSQLite(typ)); got != typ {
+			t.Errorf("FromSQLite(ToSQLite(%q)) = %q", typ, got)
+		}
+	}
+	for _, typ := range []Type{ObjectID, Array, Object, Null, Unknown} {
+		if got := ToSQLite(typ); got != "TEXT" {
+			t.Errorf("ToSQLite(%q) = %q, want TEXT", typ, got)
+		}
+	}
+}
+
+func TestColumnTypeMapping(t *testing.T) {
+	// 规范类型 -> 列类型 -> 规范类型
+	for _, typ := range []Type{Int, Float, Str, Bool, Date, Array, Object} {
+		if got := FromColumnType(ToColumnType(typ)); got != typ {
+			t.Errorf("FromColumnType(ToColumnType(%q)) = %q", typ, got)
+		}
+	}
+	for _, typ := range []Type{Binary, ObjectID, Null, Unknown} {
+		if got := ToColumnType(typ); got != datasource.ColumnTypeString {
+			t.Errorf("ToColumnType(%q) = %q, want string", typ, got)
+		}
+	}
+
+	// 所有列类型都应有对应的规范类型
+	all := []datasource.ColumnType{
+		datasource.ColumnTypeString, datasource.ColumnTypeInteger, datasource.ColumnTypeFloat,
+		datasource.ColumnTypeBoolean, datasource.ColumnTypeDateTime, datasource.ColumnTypeDate,
+		datasource.ColumnTypeTimestamp, datasource.ColumnTypeArray, datasource.ColumnTypeObject,
+	}
+	for _, ct := range all {
+		if got := FromColumnType(ct); got == Unknown {
+			t.Errorf("FromColumnType(%q) = unknown", ct)
+		}
+	}
+	if FromColumnType("bogus") != Unknown {
+		t.Error("未知列类型应映射为unknown")
+	}
+}
+
+func TestFieldTypeMapping(t *testing.T) {
+	for _, typ := range []Type{Int, Float, Str, Bool, Date, Array, Object} {
+		if got := FromFieldType(ToFieldType(typ)); got != typ {
+			t.Errorf("FromFieldType(ToFieldType(%q)) = %q", typ, got)
+		}
+	}
+	for _, typ := range []Type{Binary, ObjectID, Null, Unknown} {
+		if got := ToFieldType(typ); got != metadata.FieldTypeString {
+			t.Errorf("ToFieldType(%q) = %q, want string", typ, got)
+		}
+	}
+
+	all := []metadata.FieldType{
+		metadata.FieldTypeString, metadata.FieldTypeNumber, metadata.FieldTypeInteger,
+		metadata.FieldTypeBoolean, metadata.FieldTypeDate, metadata.FieldTypeDateTime,
+		metadata.FieldTypeObject, metadata.FieldTypeArray, metadata.FieldTypeReference,
+		metadata.FieldTypeFile, metadata.FieldTypeImage, metadata.FieldTypeEnum,
+	}
+	for _, ft := range all {
+		if got := FromFieldType(ft); got == Unknown {
+			t.Errorf("FromFieldType(%q) = unknown", ft)
+		}
+	}
+	if FromFieldType(metadata.FieldTypeReference) != ObjectID {
+		t.Error("引用字段应映射为ObjectId")
+	}
+}
+
+func TestFieldTypesAreValid(t *testing.T) {
+	// 映射得到的字段类型必须能通过元数据字段校验
+	for _, typ := range All() {
+		field := metadata.FieldDefinition{Name: "value", Type: ToFieldType(typ)}
+		if err := field.Validate(); err != nil {
+			t.Errorf("ToFieldType(%q) 生成的字段无效: %v", typ, err)
+		}
+	}
+}
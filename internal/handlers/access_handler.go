@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+	"minds_iolite_backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 检查当前用户能否连接各类数据源的权限服务
+var accessControl *rbac.Service
+
+// InitAccessControl 设置检查数据源权限的服务
+func InitAccessControl(access *rbac.Service) {
+	accessControl = access
+}
+
+// RequireSessionAccess 返回检查会话权限的中间件：会话必须由当前用户创建（管理员可以使用所有会话），
+// 且当前用户必须有权连接会话的数据源类型
+// 路由没有:sessionId参数或会话不存在时交给后续处理器处理
+func RequireSessionAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.Param("sessionId")
+		if sessionID == "" {
+			c.Next()
+			return
+		}
+		state, exists := sessionManager.GetSession(sessionID)
+		if !exists || (checkSessionOwner(c, state) && accessControl.Check(c, rbac.DataSourcePermission(state.Info.Type))) {
+			c.Next()
+		}
+	}
+}
+
+// ownsSession 会话是否由当前用户创建，或当前用户有admin权限
+// 旧版本创建的会话没有记录创建者，只有管理员可以使用
+func ownsSession(c *gin.Context, state *SessionState) (bool, error) {
+	if state.OwnerID != "" && state.OwnerID == requestUserID(c) {
+		return true, nil
+	}
+	return accessControl.Granted(c, rbac.PermissionAdmin)
+}
+
+// checkSessionOwner 当前用户不能使用会话时中止请求并返回404，不暴露其他用户的会话是否存在
+func checkSessionOwner(c *gin.Context, state *SessionState) bool {
+	owned, err := ownsSession(c, state)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "检查权限失败: " + err.Error()})
+		return false
+	}
+	if !owned {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return false
+	}
+	return true
+}
+
+// AccessHandler 角色和角色分配处理器
+type AccessHandler struct {
+	access *rbac.Service
+	users  *auth.Service
+}
+
+// NewAccessHandler 创建新的角色处理器
+func NewAccessHandler(access *rbac.Service, users *auth.Service) *AccessHandler {
+	return &AccessHandler{access: access, users: users}
+}
+
+// accessStatus 将角色错误映射为HTTP状态码
+func accessStatus(err error) int {
+	switch {
+	case errors.Is(err, rbac.ErrInvalidRole):
+		return http.StatusBadRequest
+	case errors.Is(err, rbac.ErrBuiltinRole):
+		return http.StatusForbidden
+	case errors.Is(err, rbac.ErrRoleNotFound), errors.Is(err, auth.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, rbac.ErrDuplicateRole), errors.Is(err, rbac.ErrRoleInUse), errors.Is(err, rbac.ErrLastAdmin):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// roleRequest 创建或修改角色的请求
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// GetPermissions 返回当前用户的角色和权限，使用API密钥时还返回密钥的权限范围
+func (h *AccessHandler) GetPermissions(c *gin.Context) {
+	userID := ""
+	if user, ok := auth.CurrentUser(c); ok {
+		userID = user.ID
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	roles, permissions, err := h.access.Permissions(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取权限失败: " + err.Error()})
+		return
+	}
+	response := gin.H{"success": true, "roles": roles, "permissions": permissions}
+	if key, ok := auth.CurrentAPIKey(c); ok {
+		// 使用API密钥的请求只拥有权限与密钥权限范围的交集
+		response["scopes"] = key.Scopes
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListRoles 按名称返回所有角色
+func (h *AccessHandler) ListRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	roles, err := h.access.ListRoles(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取角色失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "roles": roles, "count": len(roles)})
+}
+
+// CreateRole 创建自定义角色，角色名已存在时返回409
+func (h *AccessHandler) CreateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	role, err := h.access.CreateRole(ctx, req.Name, req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(accessStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "role": role})
+}
+
+// UpdateRole 替换角色的描述和权限，admin角色的权限不能修改
+func (h *AccessHandler) UpdateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	role, err := h.access.UpdateRole(ctx, c.Param("name"), req.Description, req.Permissions)
+	if err != nil {
+		c.JSON(accessStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "role": role})
+}
+
+// DeleteRole 删除自定义角色，内置角色和仍分配给用户的角色不能删除
+func (h *AccessHandler) DeleteRole(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if err := h.access.DeleteRole(ctx, c.Param("name")); err != nil {
+		c.JSON(accessStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "角色已删除"})
+}
+
+// GetUserRoles 返回用户的角色和合并后的权限
+func (h *AccessHandler) GetUserRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	user, err := h.users.GetUser(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(accessStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	roles, permissions, err := h.access.Permissions(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取权限失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "user": user, "roles": roles, "permissions": permissions})
+}
+
+// SetUserRoles 替换用户的角色，角色必须已存在
+func (h *AccessHandler) SetUserRoles(c *gin.Context) {
+	var req struct {
+		Roles []string `json:"roles" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	user, err := h.users.GetUser(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(accessStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	assignment, err := h.access.SetUserRoles(ctx, user.ID, req.Roles)
+	if errors.Is(err, rbac.ErrRoleNotFound) {
+		// 请求中的角色不存在
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(accessStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "user": user, "roles": assignment.Roles})
+}
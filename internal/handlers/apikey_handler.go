@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyRequest 创建API密钥的请求
+type apiKeyRequest struct {
+	Name      string      `json:"name" binding:"required"`
+	Scopes    []string    `json:"scopes" binding:"required"` // 权限范围，"*"表示用户的全部权限
+	Limits    auth.Limits `json:"limits"`                    // 该密钥的限额，0表示使用默认值
+	ExpiresAt *time.Time  `json:"expiresAt"`                 // RFC3339格式的过期时间，为空时不过期
+}
+
+// ListAPIKeys 按创建时间返回当前用户的API密钥，包括已吊销的密钥
+func (h *AuthHandler) ListAPIKeys(c *gin.Context) {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "未登录"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	keys, err := h.service.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取API密钥失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "apiKeys": keys, "count": len(keys)})
+}
+
+// CreateAPIKey 为当前用户创建API密钥，完整密钥只在响应中返回一次
+func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "未登录"})
+		return
+	}
+	if !requireLogin(c) {
+		return
+	}
+	var req apiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	key, secret, err := h.service.CreateAPIKey(ctx, user.ID, req.Name, req.Scopes, req.Limits, req.ExpiresAt)
+	if err != nil {
+		c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "apiKey": key, "key": secret})
+}
+
+// RevokeAPIKey 吊销当前用户的API密钥
+func (h *AuthHandler) RevokeAPIKey(c *gin.Context) {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "未登录"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	key, err := h.service.GetAPIKey(ctx, c.Param("id"))
+	if err == nil && key.UserID != user.ID {
+		// 不暴露其他用户的密钥是否存在
+		err = auth.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	h.revokeAPIKey(ctx, c, key.ID)
+}
+
+// ListAllAPIKeys 按创建时间返回所有用户的API密钥，userId查询参数只返回该用户的密钥
+func (h *AuthHandler) ListAllAPIKeys(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	keys, err := h.service.ListAPIKeys(ctx, c.Query("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取API密钥失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "apiKeys": keys, "count": len(keys)})
+}
+
+// AdminRevokeAPIKey 吊销任意用户的API密钥
+func (h *AuthHandler) AdminRevokeAPIKey(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	h.revokeAPIKey(ctx, c, c.Param("id"))
+}
+
+// revokeAPIKey 吊销密钥并返回吊销后的密钥信息，已吊销的密钥保持原吊销时间
+func (h *AuthHandler) revokeAPIKey(ctx context.Context, c *gin.Context, id string) {
+	key, err := h.service.RevokeAPIKey(ctx, id)
+	if err != nil {
+		c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "apiKey": key})
+}
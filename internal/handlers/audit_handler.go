@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"minds_iolite_backend/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 记录通过会话导入和修改数据的审计日志
+var changeLog audit.Log
+
+// InitChangeLog 设置记录通过会话导入和修改数据的审计日志
+func InitChangeLog(l audit.Log) {
+	changeLog = l
+}
+
+// AuditHandler 审计日志查询处理器
+type AuditHandler struct {
+	log audit.Log
+}
+
+// NewAuditHandler 创建新的审计日志处理器
+func NewAuditHandler(l audit.Log) *AuditHandler {
+	return &AuditHandler{log: l}
+}
+
+// List 按时间倒序返回满足条件的审计记录
+// 支持actorId、action、model、recordId、requestId、since、until(RFC3339)、limit和offset查询参数
+func (h *AuditHandler) List(c *gin.Context) {
+	filter := audit.Filter{
+		ActorID:   c.Query("actorId"),
+		Action:    c.Query("action"),
+		Model:     c.Query("model"),
+		RecordID:  c.Query("recordId"),
+		RequestID: c.Query("requestId"),
+	}
+
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的" + name + "参数，需要RFC3339格式的时间"})
+			return
+		}
+		*target = t
+	}
+	for name, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的" + name + "参数"})
+			return
+		}
+		*target = n
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	entries, total, err := h.log.Query(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "查询审计日志失败: " + err.Error()})
+		return
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = audit.DefaultLimit
+	}
+	if limit > audit.MaxLimit {
+		limit = audit.MaxLimit
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  filter.Offset,
+	})
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler 登录、令牌和用户账号处理器
+type AuthHandler struct {
+	service *auth.Service
+}
+
+// NewAuthHandler 创建新的认证处理器
+func NewAuthHandler(service *auth.Service) *AuthHandler {
+	return &AuthHandler{service: service}
+}
+
+// authStatus 将认证错误映射为HTTP状态码
+func authStatus(err error) int {
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrInvalidToken),
+		errors.Is(err, auth.ErrTokenExpired), errors.Is(err, auth.ErrTokenRevoked), errors.Is(err, auth.ErrUserDisabled):
+		return http.StatusUnauthorized
+	case errors.Is(err, auth.ErrInvalidAccount), errors.Is(err, auth.ErrInvalidAPIKeySettings), errors.Is(err, auth.ErrInvalidLimits):
+		return http.StatusBadRequest
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, auth.ErrDuplicateUser):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// requireLogin 检查请求使用的是登录令牌而不是API密钥，使用API密钥时返回403
+// 修改密码和管理API密钥需要登录，泄露的API密钥不能用来获取更多权限
+func requireLogin(c *gin.Context) bool {
+	if _, ok := auth.CurrentAPIKey(c); ok {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "API密钥不能执行此操作，请使用登录后的访问令牌"})
+		return false
+	}
+	return true
+}
+
+// credentialsRequest 用户名和密码
+type credentialsRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Login 验证用户名和密码，返回访问令牌和刷新令牌
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req credentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	tokens, user, err := h.service.Login(ctx, req.Username, req.Password)
+	if err != nil {
+		c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "tokens": tokens, "user": user})
+}
+
+// Refresh 用刷新令牌换取新的令牌，旧的刷新令牌随即失效
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	tokens, err := h.service.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "tokens": tokens})
+}
+
+// Logout 注销当前登录，本次登录签发的访问令牌和刷新令牌都失效
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if _, ok := auth.CurrentAPIKey(c); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "API密钥没有登录可注销，请吊销密钥"})
+		return
+	}
+	claims, ok := auth.CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "未登录"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if err := h.service.Logout(ctx, claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "注销失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已注销"})
+}
+
+// Me 返回当前登录的用户
+func (h *AuthHandler) Me(c *gin.Context) {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "未登录"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "user": user})
+}
+
+// ChangePassword 校验旧密码后修改当前用户的密码，之前签发的令牌全部失效，返回新的令牌
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "未登录"})
+		return
+	}
+	if !requireLogin(c) {
+		return
+	}
+	var req struct {
+		OldPassword string `json:"oldPassword" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	tokens, err := h.service.ChangePassword(ctx, user.ID, req.OldPassword, req.NewPassword)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "旧密码错误"})
+		return
+	}
+	if err != nil {
+		c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "密码已修改", "tokens": tokens})
+}
+
+// ListUsers 按用户名返回所有用户
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	users, err := h.service.ListUsers(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取用户失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "users": users, "count": len(users)})
+}
+
+// CreateUser 创建用户，用户名已存在时返回409
+func (h *AuthHandler) CreateUser(c *gin.Context) {
+	var req credentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	user, err := h.service.CreateUser(ctx, req.Username, req.Password)
+	if err != nil {
+		c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "user": user})
+}
+
+// UpdateUser 禁用或启用用户，或替换用户的限额，禁用后该用户已签发的令牌和API密钥立即失效
+func (h *AuthHandler) UpdateUser(c *gin.Context) {
+	var req struct {
+		Disabled *bool        `json:"disabled"`
+		Limits   *auth.Limits `json:"limits"` // 值为0的项使用默认限额
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	if req.Disabled == nil && req.Limits == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "缺少必要参数: disabled或limits"})
+		return
+	}
+	if current, ok := auth.CurrentUser(c); ok && current.ID == c.Param("id") && req.Disabled != nil && *req.Disabled {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "不能禁用当前登录的用户"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	var user *auth.User
+	var err error
+	if req.Limits != nil {
+		if user, err = h.service.SetLimits(ctx, c.Param("id"), *req.Limits); err != nil {
+			c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+	if req.Disabled != nil {
+		if user, err = h.service.SetDisabled(ctx, c.Param("id"), *req.Disabled); err != nil {
+			c.JSON(authStatus(err), gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "user": user})
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"minds_iolite_backend/internal/datasource/pool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PoolHandler 外部数据源连接池管理处理器
+type PoolHandler struct{}
+
+// NewPoolHandler 创建新的连接池管理处理器
+func NewPoolHandler() *PoolHandler {
+	return &PoolHandler{}
+}
+
+// GetPools 返回所有共享连接池的状态和连接数限制
+func (h *PoolHandler) GetPools(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"stats":   pool.GetRegistry().Stats(),
+	})
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/queries"
+	"minds_iolite_backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultHistoryLimit 默认返回的查询历史条数
+	DefaultHistoryLimit = 100
+	// MaxHistoryLimit 一次最多返回的查询历史条数
+	MaxHistoryLimit = 1000
+)
+
+// anonymousUser 未经认证的请求共享的用户ID
+const anonymousUser = "anonymous"
+
+// 查询历史和已保存查询的存储
+var queryStore queries.Store
+
+// InitQueryStore 初始化查询存储，store为nil时记录只保存在本进程内存中
+func InitQueryStore(store queries.Store) {
+	if store == nil {
+		store = queries.NewMemoryStore()
+	}
+	queryStore = store
+}
+
+// QueryHandler 查询历史和已保存查询处理器
+type QueryHandler struct{}
+
+// NewQueryHandler 创建新的查询处理器
+func NewQueryHandler() *QueryHandler {
+	return &QueryHandler{}
+}
+
+// requestUserID 返回访问令牌或API密钥所属的用户ID，路由不需要登录时返回anonymousUser
+func requestUserID(c *gin.Context) string {
+	if user, ok := auth.CurrentUser(c); ok {
+		return user.ID
+	}
+	return anonymousUser
+}
+
+// statement 返回请求中的查询语句部分
+func (r *QueryRequest) statement() queries.Statement {
+	return queries.Statement{
+		SQL:        r.SQL,
+		Params:     r.Params,
+		Collection: r.Collection,
+		Filter:     r.Filter,
+		Projection: r.Projection,
+		Sort:       r.Sort,
+		Pipeline:   r.Pipeline,
+	}
+}
+
+// applyStatement 用保存的查询语句填充请求
+func (r *QueryRequest) applyStatement(statement queries.Statement) {
+	r.SQL = statement.SQL
+	r.Params = statement.Params
+	r.Collection = statement.Collection
+	r.Filter = statement.Filter
+	r.Projection = statement.Projection
+	r.Sort = statement.Sort
+	r.Pipeline = statement.Pipeline
+}
+
+// recordHistory 保存一条查询历史，失败只记录日志，不影响查询结果
+func recordHistory(entry *queries.HistoryEntry, start time.Time, rowCount int, message string) {
+	if queryStore == nil {
+		return
+	}
+	entry.Time = start
+	entry.DurationMs = time.Since(start).Milliseconds()
+	entry.RowCount = rowCount
+	entry.Error = message
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := queryStore.RecordHistory(ctx, entry); err != nil {
+		log.Printf("保存查询历史失败: %v", err)
+	}
+}
+
+// GetHistory 按时间倒序返回当前用户的查询历史，可以按sessionId或source筛选
+func (h *QueryHandler) GetHistory(c *gin.Context) {
+	limit := DefaultHistoryLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit必须为正整数"})
+			return
+		}
+		limit = parsed
+	}
+	if limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	entries, err := queryStore.ListHistory(ctx, queries.HistoryFilter{
+		UserID:    requestUserID(c),
+		SessionID: c.Query("sessionId"),
+		Source:    c.Query("source"),
+	}, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取查询历史失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "entries": entries, "count": len(entries)})
+}
+
+// ClearHistory 删除当前用户的全部查询历史
+func (h *QueryHandler) ClearHistory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	deleted, err := queryStore.ClearHistory(ctx, requestUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "删除查询历史失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "deleted": deleted})
+}
+
+// savedQueryRequest 创建或修改已保存查询的请求，语句字段与会话查询相同
+type savedQueryRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"` // 适用的数据源类型：mysql、sqlite或mongodb
+	queries.Statement
+}
+
+// validate 检查名称、类型和语句，语句的校验规则与会话查询相同
+func (r *savedQueryRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("缺少必要参数: name")
+	}
+	r.Type = strings.ToLower(r.Type)
+	switch r.Type {
+	case "mysql", "sqlite":
+		return providers.CheckReadOnlySQL(r.SQL, r.Type)
+	case "mongodb":
+		req := &QueryRequest{}
+		req.applyStatement(r.Statement)
+		_, err := parseMongoQuery(req)
+		return err
+	case "":
+		return errors.New("缺少必要参数: type")
+	default:
+		return fmt.Errorf("%s 数据源不支持保存查询", r.Type)
+	}
+}
+
+// savedQueryStatus 将存储错误映射为HTTP状态码
+func savedQueryStatus(err error) int {
+	switch {
+	case errors.Is(err, queries.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, queries.ErrDuplicateName):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListSavedQueries 按名称返回当前用户保存的查询，可以按type筛选
+func (h *QueryHandler) ListSavedQueries(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	saved, err := queryStore.ListSaved(ctx, requestUserID(c), strings.ToLower(c.Query("type")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取已保存的查询失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "queries": saved, "count": len(saved)})
+}
+
+// CreateSavedQuery 保存命名查询，同一用户内名称不能重复
+func (h *QueryHandler) CreateSavedQuery(c *gin.Context) {
+	var req savedQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	query := &queries.SavedQuery{
+		UserID:      requestUserID(c),
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        req.Type,
+		Statement:   req.Statement,
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if err := queryStore.CreateSaved(ctx, query); err != nil {
+		c.JSON(savedQueryStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "query": query})
+}
+
+// GetSavedQuery 返回当前用户保存的一个查询
+func (h *QueryHandler) GetSavedQuery(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	query, err := queryStore.GetSaved(ctx, requestUserID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(savedQueryStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "query": query})
+}
+
+// UpdateSavedQuery 用请求中的内容替换已保存的查询
+func (h *QueryHandler) UpdateSavedQuery(c *gin.Context) {
+	var req savedQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	query := &queries.SavedQuery{
+		ID:          c.Param("id"),
+		UserID:      requestUserID(c),
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        req.Type,
+		Statement:   req.Statement,
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if err := queryStore.UpdateSaved(ctx, query); err != nil {
+		c.JSON(savedQueryStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "query": query})
+}
+
+// DeleteSavedQuery 删除已保存的查询，查询历史中的记录保留
+func (h *QueryHandler) DeleteSavedQuery(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if err := queryStore.DeleteSaved(ctx, requestUserID(c), c.Param("id")); err != nil {
+		c.JSON(savedQueryStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "查询已删除"})
+}
+
+// runSavedQueryRequest 在会话上执行已保存查询的请求
+type runSavedQueryRequest struct {
+	SessionID string        `json:"sessionId"`
+	Params    []interface{} `json:"params"` // 提供时替换保存的SQL参数
+	Limit     int           `json:"limit"`
+	Offset    int           `json:"offset"`
+	Timeout   int           `json:"timeout"`
+	Stream    bool          `json:"stream"`
+}
+
+// RunSavedQuery 在任意同类型数据源的会话上执行已保存的查询，返回格式与会话查询相同
+func (h *QueryHandler) RunSavedQuery(c *gin.Context) {
+	var req runSavedQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	if req.SessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "缺少必要参数: sessionId"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	saved, err := queryStore.GetSaved(ctx, requestUserID(c), c.Param("id"))
+	cancel()
+	if err != nil {
+		c.JSON(savedQueryStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	state, exists := sessionManager.GetSession(req.SessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+	if !checkSessionOwner(c, state) || !accessControl.Check(c, rbac.DataSourcePermission(state.Info.Type)) {
+		return
+	}
+	if state.Info.Type != saved.Type {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("查询适用于 %s 数据源，会话类型为 %s", saved.Type, state.Info.Type),
+		})
+		return
+	}
+
+	query := &QueryRequest{Limit: req.Limit, Offset: req.Offset, Timeout: req.Timeout, Stream: req.Stream}
+	query.applyStatement(saved.Statement)
+	if req.Params != nil {
+		query.Params = req.Params
+	}
+	executeQuery(c, req.SessionID, state, query, saved.ID)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/secrets"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 保存数据源凭据的凭据库
+var secretVault *secrets.Vault
+
+// InitSecretVault 设置凭据库
+func InitSecretVault(vault *secrets.Vault) {
+	secretVault = vault
+}
+
+// SecretHandler 凭据库管理处理器
+type SecretHandler struct{}
+
+// NewSecretHandler 创建新的凭据库管理处理器
+func NewSecretHandler() *SecretHandler {
+	return &SecretHandler{}
+}
+
+// GetStatus 返回当前密钥和每个密钥加密的凭据数，不返回密钥内容
+func (h *SecretHandler) GetStatus(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	status, err := secretVault.Status(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取凭据库状态失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": status})
+}
+
+// ReEncrypt 用当前密钥重新加密所有使用旧密钥加密的凭据，轮换密钥后调用
+func (h *SecretHandler) ReEncrypt(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+	result, err := secretVault.ReEncrypt(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "重新加密凭据失败: " + err.Error(), "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": len(result.Failed) == 0, "result": result})
+}
+
+// DescribeSecret 返回凭据的元数据，用于检查passwordSecretId是否有效，不返回凭据明文
+func (h *SecretHandler) DescribeSecret(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	info, err := secretVault.Describe(ctx, c.Param("id"))
+	if errors.Is(err, secrets.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取凭据失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "secret": info})
+}
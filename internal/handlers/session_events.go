@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseKeepAlive SSE连接的保活间隔，避免代理因长时间无数据断开连接
+const sseKeepAlive = 15 * time.Second
+
+// heartbeatRequest 会话心跳请求，请求体可以为空
+type heartbeatRequest struct {
+	TTL int `json:"ttl"` // 新的会话有效期(秒)，为0时保持不变
+}
+
+// Heartbeat 顺延会话的过期时间
+// 与刷新不同，心跳不检查数据库连接，适合前端定期调用以保持会话
+func (h *SessionHandler) Heartbeat(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+
+	var req heartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	var ttl time.Duration
+	if req.TTL != 0 {
+		var err error
+		if ttl, err = sessionManager.ResolveTTL(req.TTL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	state, err := sessionManager.Heartbeat(sessionID, ttl)
+	if errors.Is(err, errSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "更新会话失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"expiresAt":  state.ExpiresAt,
+		"ttlSeconds": state.TTLSeconds,
+	})
+}
+
+// SessionEvents 以SSE推送会话事件：expiring(即将过期)、renewed(已续期)、expired(已过期)、closed(已关闭)
+// 只推送当前用户创建的会话的事件，管理员接收所有会话的事件；可以通过重复的sessionId参数只接收指定会话的事件
+func (h *SessionHandler) SessionEvents(c *gin.Context) {
+	filter := make(map[string]bool)
+	for _, id := range c.QueryArray("sessionId") {
+		filter[id] = true
+	}
+	admin, err := accessControl.Granted(c, rbac.PermissionAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "检查权限失败: " + err.Error()})
+		return
+	}
+	userID := requestUserID(c)
+
+	events, unsubscribe := sessionManager.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteString(": connected\n\n")
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if len(filter) > 0 && !filter[event.SessionID] {
+				continue
+			}
+			if !admin && (event.OwnerID == "" || event.OwnerID != userID) {
+				continue
+			}
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
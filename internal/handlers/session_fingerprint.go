@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/session"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxSchemaSnapshots 每个会话保留的结构指纹数，客户端的指纹早于这些记录时只能重新获取完整结构
+const maxSchemaSnapshots = 20
+
+// schemaChanges 两次结构指纹之间数据源中新增、删除和结构变化的实体
+type schemaChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// entityMetadata 读取每个实体的结构元数据：MySQL为列定义，SQLite为建表和索引语句，
+// MongoDB为集合类型、选项和UUID，CSV为文件大小和修改时间；返回实体名 -> 元数据
+// 这些查询不读取数据，开销远小于推断结构
+func entityMetadata(ctx context.Context, sessionID string, state *SessionState, entities []providers.Entity) (map[string]string, error) {
+	if state.Info.Type == "csv" {
+		info, err := os.Stat(state.Info.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		metadata := make(map[string]string, len(entities))
+		for _, entity := range entities {
+			metadata[entity.Name] = fmt.Sprintf("%d|%d", info.Size(), info.ModTime().UnixNano())
+		}
+		return metadata, nil
+	}
+
+	conn, err := sessionManager.Connection(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	switch state.Info.Type {
+	case "mysql":
+		return sqlMetadata(ctx, conn.DB, `SELECT TABLE_NAME, CONCAT_WS('|', ORDINAL_POSITION, COLUMN_NAME, COLUMN_TYPE,
+				IS_NULLABLE, COLUMN_KEY, IFNULL(COLUMN_DEFAULT, 'NULL'), EXTRA)
+			FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE()
+			ORDER BY TABLE_NAME, ORDINAL_POSITION`)
+	case "sqlite":
+		return sqlMetadata(ctx, conn.DB, `SELECT tbl_name, type || '|' || name || '|' || IFNULL(sql, '')
+			FROM sqlite_master
+			WHERE name NOT LIKE 'sqlite_%'
+			ORDER BY tbl_name, type, name`)
+	case "mongodb":
+		if conn.Database == "" {
+			return nil, errors.New("会话未指定MongoDB数据库名")
+		}
+		specs, err := conn.Client.Database(conn.Database).ListCollectionSpecifications(ctx, bson.D{})
+		if err != nil {
+			return nil, err
+		}
+		metadata := make(map[string]string, len(specs))
+		for _, spec := range specs {
+			uuid := ""
+			if spec.UUID != nil {
+				uuid = hex.EncodeToString(spec.UUID.Data)
+			}
+			metadata[spec.Name] = fmt.Sprintf("%s|%t|%s|%s", spec.Type, spec.ReadOnly, uuid, spec.Options.String())
+		}
+		return metadata, nil
+	default:
+		return nil, fmt.Errorf("%s 会话不支持读取结构", state.Info.Type)
+	}
+}
+
+// sqlMetadata 执行返回(实体名, 元数据行)的查询，并按实体拼接元数据行
+func sqlMetadata(ctx context.Context, db *sql.DB, query string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("读取结构元数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	lines := make(map[string][]string)
+	for rows.Next() {
+		var name, line string
+		if err := rows.Scan(&name, &line); err != nil {
+			return nil, fmt.Errorf("读取结构元数据失败: %w", err)
+		}
+		lines[name] = append(lines[name], line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取结构元数据失败: %w", err)
+	}
+
+	metadata := make(map[string]string, len(lines))
+	for name, entityLines := range lines {
+		metadata[name] = strings.Join(entityLines, "\n")
+	}
+	return metadata, nil
+}
+
+// entityFingerprints 计算数据源中每个实体的指纹，实体类型也计入指纹
+func entityFingerprints(ctx context.Context, sessionID string, state *SessionState, entities []providers.Entity) (map[string]string, error) {
+	metadata, err := entityMetadata(ctx, sessionID, state, entities)
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make(map[string]string, len(entities))
+	for _, entity := range entities {
+		fingerprints[entity.Name] = fingerprint(entity.Kind + "\n" + metadata[entity.Name])
+	}
+	return fingerprints, nil
+}
+
+// fingerprint 返回内容摘要的前16个十六进制字符
+func fingerprint(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:8])
+}
+
+// newSchemaSnapshot 由实体指纹计算整个数据源的指纹
+func newSchemaSnapshot(entities map[string]string) session.SchemaSnapshot {
+	names := make([]string, 0, len(entities))
+	for name := range entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var content strings.Builder
+	for _, name := range names {
+		content.WriteString(name)
+		content.WriteByte(0)
+		content.WriteString(entities[name])
+		content.WriteByte('\n')
+	}
+	return session.SchemaSnapshot{
+		Fingerprint: fingerprint(content.String()),
+		Entities:    entities,
+		Time:        time.Now(),
+	}
+}
+
+// appendSchemaSnapshot 指纹变化时记录新的快照，只保留最近的maxSchemaSnapshots个
+// 返回新的切片，不修改history
+func appendSchemaSnapshot(history []session.SchemaSnapshot, snapshot session.SchemaSnapshot) []session.SchemaSnapshot {
+	if len(history) > 0 && history[len(history)-1].Fingerprint == snapshot.Fingerprint {
+		return history
+	}
+	updated := make([]session.SchemaSnapshot, 0, len(history)+1)
+	updated = append(updated, history...)
+	updated = append(updated, snapshot)
+	if len(updated) > maxSchemaSnapshots {
+		updated = updated[len(updated)-maxSchemaSnapshots:]
+	}
+	return updated
+}
+
+// latestSnapshot 返回最近一次的结构指纹，没有记录时返回nil
+func latestSnapshot(history []session.SchemaSnapshot) *session.SchemaSnapshot {
+	if len(history) == 0 {
+		return nil
+	}
+	return &history[len(history)-1]
+}
+
+// changesSince 返回指定指纹之后数据源结构的变化，指纹不在保留的记录中时返回nil
+func changesSince(history []session.SchemaSnapshot, since string) *schemaChanges {
+	current := latestSnapshot(history)
+	if current == nil {
+		return nil
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Fingerprint != since {
+			continue
+		}
+		changes := &schemaChanges{Added: []string{}, Removed: []string{}, Changed: []string{}}
+		for name, value := range current.Entities {
+			old, exists := history[i].Entities[name]
+			if !exists {
+				changes.Added = append(changes.Added, name)
+			} else if old != value {
+				changes.Changed = append(changes.Changed, name)
+			}
+		}
+		for name := range history[i].Entities {
+			if _, exists := current.Entities[name]; !exists {
+				changes.Removed = append(changes.Removed, name)
+			}
+		}
+		sort.Strings(changes.Added)
+		sort.Strings(changes.Removed)
+		sort.Strings(changes.Changed)
+		return changes
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"minds_iolite_backend/internal/session"
+)
+
+func TestNewSchemaSnapshot(t *testing.T) {
+	base := newSchemaSnapshot(map[string]string{"users": "u1", "orders": "o1"})
+
+	tests := []struct {
+		name     string
+		entities map[string]string
+		same     bool
+	}{
+		{"内容相同", map[string]string{"orders": "o1", "users": "u1"}, true},
+		{"实体指纹变化", map[string]string{"users": "u2", "orders": "o1"}, false},
+		{"新增实体", map[string]string{"users": "u1", "orders": "o1", "items": "i1"}, false},
+		{"删除实体", map[string]string{"users": "u1"}, false},
+		{"实体改名", map[string]string{"customers": "u1", "orders": "o1"}, false},
+		{"名称和指纹的边界不同", map[string]string{"users": "u1", "order": "so1"}, false},
+		{"没有实体", map[string]string{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newSchemaSnapshot(tt.entities)
+			if (got.Fingerprint == base.Fingerprint) != tt.same {
+				t.Errorf("指纹 %s 与 %s 相同 = %v, 期望 %v", got.Fingerprint, base.Fingerprint, !tt.same, tt.same)
+			}
+			if len(got.Fingerprint) != 16 {
+				t.Errorf("指纹长度 = %d, 期望 16", len(got.Fingerprint))
+			}
+		})
+	}
+}
+
+func TestAppendSchemaSnapshot(t *testing.T) {
+	first := newSchemaSnapshot(map[string]string{"users": "u1"})
+	second := newSchemaSnapshot(map[string]string{"users": "u2"})
+
+	t.Run("指纹未变化时不记录", func(t *testing.T) {
+		history := appendSchemaSnapshot([]session.SchemaSnapshot{first}, newSchemaSnapshot(map[string]string{"users": "u1"}))
+		if len(history) != 1 {
+			t.Errorf("记录数 = %d, 期望 1", len(history))
+		}
+	})
+
+	t.Run("变回之前的结构时重新记录", func(t *testing.T) {
+		history := appendSchemaSnapshot([]session.SchemaSnapshot{first, second}, first)
+		if len(history) != 3 || history[2].Fingerprint != first.Fingerprint {
+			t.Errorf("记录 = %v, 期望追加到末尾", history)
+		}
+	})
+
+	t.Run("不修改原切片", func(t *testing.T) {
+		original := make([]session.SchemaSnapshot, 1, 4)
+		original[0] = first
+		history := appendSchemaSnapshot(original, second)
+		history[0] = second
+		if original[0].Fingerprint != first.Fingerprint {
+			t.Errorf("原切片被修改")
+		}
+	})
+
+	t.Run("只保留最近的记录", func(t *testing.T) {
+		var history []session.SchemaSnapshot
+		for i := 0; i < maxSchemaSnapshots+5; i++ {
+			history = appendSchemaSnapshot(history, newSchemaSnapshot(map[string]string{"users": fmt.Sprint(i)}))
+		}
+		if len(history) != maxSchemaSnapshots {
+			t.Fatalf("记录数 = %d, 期望 %d", len(history), maxSchemaSnapshots)
+		}
+		if got := history[0].Entities["users"]; got != "5" {
+			t.Errorf("最早的记录 = %s, 期望 5", got)
+		}
+	})
+}
+
+func TestChangesSince(t *testing.T) {
+	v1 := newSchemaSnapshot(map[string]string{"users": "u1", "orders": "o1", "logs": "l1"})
+	v2 := newSchemaSnapshot(map[string]string{"users": "u2", "orders": "o1", "logs": "l1"})
+	v3 := newSchemaSnapshot(map[string]string{"users": "u2", "orders": "o2", "items": "i1", "audit": "a1"})
+	history := []session.SchemaSnapshot{v1, v2, v3}
+
+	tests := []struct {
+		name    string
+		history []session.SchemaSnapshot
+		since   string
+		want    *schemaChanges
+	}{
+		{
+			name:    "与最新指纹相同",
+			history: history,
+			since:   v3.Fingerprint,
+			want:    &schemaChanges{Added: []string{}, Removed: []string{}, Changed: []string{}},
+		},
+		{
+			name:    "一次变化",
+			history: history,
+			since:   v2.Fingerprint,
+			want:    &schemaChanges{Added: []string{"audit", "items"}, Removed: []string{"logs"}, Changed: []string{"orders"}},
+		},
+		{
+			name:    "跨多次变化",
+			history: history,
+			since:   v1.Fingerprint,
+			want:    &schemaChanges{Added: []string{"audit", "items"}, Removed: []string{"logs"}, Changed: []string{"orders", "users"}},
+		},
+		{
+			name:    "变化后又恢复",
+			history: []session.SchemaSnapshot{v1, v2, v1},
+			since:   v2.Fingerprint,
+			want:    &schemaChanges{Added: []string{}, Removed: []string{}, Changed: []string{"users"}},
+		},
+		{
+			name:    "指纹出现多次时与最近一次比较",
+			history: []session.SchemaSnapshot{v1, v2, v1},
+			since:   v1.Fingerprint,
+			want:    &schemaChanges{Added: []string{}, Removed: []string{}, Changed: []string{}},
+		},
+		{
+			name:    "未知指纹",
+			history: history,
+			since:   "0000000000000000",
+		},
+		{
+			name:    "空指纹",
+			history: history,
+			since:   "",
+		},
+		{
+			name:  "没有记录",
+			since: v1.Fingerprint,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changesSince(tt.history, tt.since)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changesSince() = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
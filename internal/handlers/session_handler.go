@@ -1,173 +1,31 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"sync"
-	"time"
+
+	"minds_iolite_backend/internal/datasource/pool"
+	"minds_iolite_backend/internal/models/datasource"
+	"minds_iolite_backend/internal/rbac"
+	"minds_iolite_backend/internal/session"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // SessionState 表示会话的当前状态
-type SessionState struct {
-	Info        ConnectionInfo         `json:"info"`
-	Connected   bool                   `json:"connected"`
-	LastActive  time.Time              `json:"lastActive"`
-	Collections map[string]interface{} `json:"collections,omitempty"`
-	Tables      map[string]interface{} `json:"tables,omitempty"`
-}
+type SessionState = session.SessionState
 
 // ConnectionInfo 表示连接信息
-type ConnectionInfo struct {
-	Type     string `json:"type"`
-	Host     string `json:"host,omitempty"`
-	Port     int    `json:"port,omitempty"`
-	Username string `json:"username,omitempty"`
-	Password string `json:"-"` // 不在JSON中暴露密码
-	Database string `json:"database,omitempty"`
-	URI      string `json:"-"` // 不在JSON中暴露完整URI
-}
+type ConnectionInfo = session.ConnectionInfo
 
-// Manager 管理所有会话
-type Manager struct {
-	sessions        map[string]*SessionState
-	mutex           sync.RWMutex
-	cleanupInterval time.Duration // 每隔30分钟检查一次过期会话
-	sessionTimeout  time.Duration // 会话超时时间为30分钟
-}
+// errSessionNotFound 会话不存在或已过期
+var errSessionNotFound = session.ErrSessionNotFound
 
 // 全局会话管理器
-var sessionManager *Manager
-
-// 创建新的会话管理器
-func NewManager() *Manager {
-	manager := &Manager{
-		sessions:        make(map[string]*SessionState),
-		mutex:           sync.RWMutex{},
-		cleanupInterval: 30 * time.Minute,
-		sessionTimeout:  30 * time.Minute,
-	}
-
-	// 启动定期清理过期会话的goroutine
-	go manager.cleanup()
-
-	return manager
-}
-
-// CreateSession 创建新会话
-func (m *Manager) CreateSession(info ConnectionInfo, collections, tables map[string]interface{}) string {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	// 生成唯一会话ID
-	sessionID := uuid.New().String()
-
-	// 创建会话状态
-	state := &SessionState{
-		Info:        info,
-		Connected:   true,
-		LastActive:  time.Now(),
-		Collections: collections,
-		Tables:      tables,
-	}
-
-	// 保存会话
-	m.sessions[sessionID] = state
-
-	return sessionID
-}
-
-// GetSession 获取特定会话状态
-func (m *Manager) GetSession(sessionID string) (*SessionState, bool) {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
-
-	state, exists := m.sessions[sessionID]
-	if !exists {
-		return nil, false
-	}
-
-	// 检查会话是否过期
-	if time.Since(state.LastActive) > m.sessionTimeout {
-		// 会话已过期，但我们在读锁中，所以不能删除
-		// 返回nil表示不存在
-		return nil, false
-	}
-
-	return state, true
-}
-
-// RefreshSession 刷新会话活动时间
-func (m *Manager) RefreshSession(sessionID string) bool {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+var sessionManager *session.Manager
 
-	state, exists := m.sessions[sessionID]
-	if !exists {
-		return false
-	}
-
-	// 检查会话是否过期
-	if time.Since(state.LastActive) > m.sessionTimeout {
-		// 会话已过期，删除它
-		delete(m.sessions, sessionID)
-		return false
-	}
-
-	// 更新最后活动时间
-	state.LastActive = time.Now()
-	return true
-}
-
-// CloseSession 关闭并删除会话
-func (m *Manager) CloseSession(sessionID string) bool {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	_, exists := m.sessions[sessionID]
-	if !exists {
-		return false
-	}
-
-	// 删除会话
-	delete(m.sessions, sessionID)
-	return true
-}
-
-// GetAllSessions 获取所有活动会话
-func (m *Manager) GetAllSessions() map[string]*SessionState {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
-
-	// 创建一个副本以避免并发访问问题
-	result := make(map[string]*SessionState)
-	for id, state := range m.sessions {
-		// 只返回未过期的会话
-		if time.Since(state.LastActive) <= m.sessionTimeout {
-			result[id] = state
-		}
-	}
-
-	return result
-}
-
-// cleanup 定期清理过期会话
-func (m *Manager) cleanup() {
-	ticker := time.NewTicker(m.cleanupInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		m.mutex.Lock()
-		now := time.Now()
-		for id, state := range m.sessions {
-			if now.Sub(state.LastActive) > m.sessionTimeout {
-				delete(m.sessions, id)
-			}
-		}
-		m.mutex.Unlock()
-	}
-}
+// auditLog 通过会话修改数据的审计日志
+var auditLog session.AuditLog
 
 // SessionHandler 会话处理器
 type SessionHandler struct{}
@@ -177,9 +35,13 @@ func NewSessionHandler() *SessionHandler {
 	return &SessionHandler{}
 }
 
-// InitSessionManager 初始化会话管理器
-func InitSessionManager() {
-	sessionManager = NewManager()
+// InitSessionManager 初始化会话管理器，store为nil时会话只保存在本进程内存中
+func InitSessionManager(store session.Store, audit session.AuditLog, options session.Options) {
+	sessionManager = session.NewManager(store, options)
+	if audit == nil {
+		audit = session.NewMemoryAuditLog()
+	}
+	auditLog = audit
 }
 
 // CreateSessionRequest 创建会话请求
@@ -196,6 +58,13 @@ type CreateSessionRequest struct {
 	MongoDbName   string                 `json:"mongoDbName,omitempty"`
 	MongoCollName string                 `json:"mongoCollName,omitempty"`
 	Collections   map[string]interface{} `json:"collections,omitempty"`
+	ReadOnly      *bool                  `json:"readOnly,omitempty"` // 默认为true，只有显式设置为false时才允许写入
+	TTL           int                    `json:"ttl,omitempty"`      // 会话有效期(秒)，默认使用配置的有效期
+}
+
+// readOnly 返回会话是否以只读方式连接，未指定时默认只读
+func (r *CreateSessionRequest) readOnly() bool {
+	return r.ReadOnly == nil || *r.ReadOnly
 }
 
 // CreateSession 创建新的持久连接会话
@@ -229,11 +98,19 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 			})
 			return
 		}
+	} else if req.Type == "sqlite" {
+		if err := datasource.NewSQLiteSource(req.FilePath).Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
 	} else if req.Type == "csv" {
-		if req.FilePath == "" {
+		if err := datasource.NewCSVSource(req.FilePath).Validate(); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"error":   "CSV处理需要提供文件路径",
+				"error":   err.Error(),
 			})
 			return
 		}
@@ -245,6 +122,18 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		return
 	}
 
+	// 检查当前用户是否有权连接该类型的数据源
+	if !accessControl.Check(c, rbac.DataSourcePermission(req.Type)) {
+		return
+	}
+
+	// 校验会话有效期
+	ttl, err := sessionManager.ResolveTTL(req.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
 	// 创建连接信息
 	info := ConnectionInfo{
 		Type:     req.Type,
@@ -254,10 +143,20 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		Password: req.Password,
 		Database: req.Database,
 		URI:      req.URI,
+		FilePath: req.FilePath,
+		ReadOnly: req.readOnly(),
 	}
 
-	// 创建会话
-	sessionID := sessionManager.CreateSession(info, req.Collections, nil)
+	// 创建会话并建立连接
+	sessionID, err := sessionManager.CreateSession(requestUserID(c), info, ttl, req.Collections, nil)
+	if errors.Is(err, pool.ErrLimitReached) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": "连接数据源失败: " + err.Error()})
+		return
+	}
 
 	// 获取创建的会话状态
 	state, _ := sessionManager.GetSession(sessionID)
@@ -290,9 +189,27 @@ func (h *SessionHandler) GetSession(c *gin.Context) {
 	})
 }
 
-// GetAllSessions 获取所有活动会话
+// GetAllSessions 获取当前用户有权连接的所有活动会话
 func (h *SessionHandler) GetAllSessions(c *gin.Context) {
 	sessions := sessionManager.GetAllSessions()
+	for sessionID, state := range sessions {
+		owned, err := ownsSession(c, state)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "检查权限失败: " + err.Error()})
+			return
+		}
+		granted := false
+		if owned {
+			granted, err = accessControl.Granted(c, rbac.DataSourcePermission(state.Info.Type))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "检查权限失败: " + err.Error()})
+				return
+			}
+		}
+		if !granted {
+			delete(sessions, sessionID)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
 		"sessions": sessions,
@@ -307,19 +224,46 @@ func (h *SessionHandler) RefreshSession(c *gin.Context) {
 		return
 	}
 
-	success := sessionManager.RefreshSession(sessionID)
-	if !success {
+	state, err := sessionManager.RefreshSession(sessionID)
+	if errors.Is(err, errSessionNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "会话不存在或已过期"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"error":   "重新连接失败: " + err.Error(),
+			"state":   state,
+		})
+		return
+	}
 
-	state, _ := sessionManager.GetSession(sessionID)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"state":   state,
 	})
 }
 
+// GetSessionHealth 返回会话连接的健康状态、状态变化和延迟样本
+// 后台会定期检查每个连接，连接断开时按指数退避重新连接
+func (h *SessionHandler) GetSessionHealth(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	health, err := sessionManager.Health(sessionID)
+	if errors.Is(err, errSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "会话不存在或已过期"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": "连接数据源失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"health":  health,
+	})
+}
+
 // CloseSession 关闭会话
 func (h *SessionHandler) CloseSession(c *gin.Context) {
 	sessionID := c.Param("sessionId")
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+	"minds_iolite_backend/internal/queries"
+	"minds_iolite_backend/internal/session"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DefaultQueryLimit 默认每次返回的行数
+	DefaultQueryLimit = 100
+	// MaxQueryLimit 服务端允许的最大返回行数
+	MaxQueryLimit = 10000
+	// DefaultQueryTimeout 默认的语句超时时间
+	DefaultQueryTimeout = 30 * time.Second
+	// MaxQueryTimeout 允许的最大语句超时时间
+	MaxQueryTimeout = 5 * time.Minute
+)
+
+// QueryRequest 会话查询请求
+// MySQL/SQLite会话使用sql；MongoDB会话使用collection加filter（find）或pipeline（aggregate）
+type QueryRequest struct {
+	SQL    string        `json:"sql"`
+	Params []interface{} `json:"params"` // SQL占位符参数
+
+	Collection string          `json:"collection"`
+	Filter     json.RawMessage `json:"filter"`     // 扩展JSON格式的查询条件
+	Projection json.RawMessage `json:"projection"` // 扩展JSON格式的投影
+	Sort       json.RawMessage `json:"sort"`       // 扩展JSON格式的排序
+	Pipeline   json.RawMessage `json:"pipeline"`   // 扩展JSON格式的聚合管道，提供时执行aggregate
+
+	Limit   int  `json:"limit"`   // 返回行数，默认100，最大10000
+	Offset  int  `json:"offset"`  // 跳过的行数，用于分页
+	Timeout int  `json:"timeout"` // 语句超时秒数，默认30，最大300
+	Stream  bool `json:"stream"`  // 以NDJSON流式返回
+}
+
+// QueryColumn 查询结果中的列
+type QueryColumn struct {
+	Name       string          `json:"name"`
+	Type       typesystem.Type `json:"type"`
+	NativeType string          `json:"nativeType,omitempty"`
+}
+
+// normalize 填充默认值并限制行数和超时
+func (r *QueryRequest) normalize() error {
+	if r.Limit <= 0 {
+		r.Limit = DefaultQueryLimit
+	}
+	if r.Limit > MaxQueryLimit {
+		r.Limit = MaxQueryLimit
+	}
+	if r.Offset < 0 {
+		return fmt.Errorf("offset不能为负数")
+	}
+	return nil
+}
+
+// timeout 返回语句超时时间
+func (r *QueryRequest) timeout() time.Duration {
+	timeout := time.Duration(r.Timeout) * time.Second
+	if timeout <= 0 {
+		return DefaultQueryTimeout
+	}
+	if timeout > MaxQueryTimeout {
+		return MaxQueryTimeout
+	}
+	return timeout
+}
+
+// errStopQuery 读取到足够的行后停止迭代
+var errStopQuery = errors.New("stop query")
+
+// queryOutcome 查询完成后的汇总信息
+type queryOutcome struct {
+	Columns []QueryColumn // 最终的列，MongoDB为合并全部返回文档后的结果
+	HasMore bool          // limit之后是否还有数据
+}
+
+// queryWriter 接收查询结果的列和行
+type queryWriter interface {
+	columns(columns []QueryColumn) error
+	row(row providers.Row) error
+}
+
+// QuerySession 在会话连接上执行只读查询
+// 返回的行数受服务端上限约束，超过limit时hasMore为true，可以通过offset翻页
+func (h *SessionHandler) QuerySession(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	state, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+
+	var req QueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	executeQuery(c, sessionID, state, &req, "")
+}
+
+// executeQuery 校验并执行查询，将结果写入响应
+// 执行过的查询（包括失败的）记录在当前用户的查询历史中，savedQueryID不为空时表示执行的是已保存的查询
+func executeQuery(c *gin.Context, sessionID string, state *SessionState, req *QueryRequest, savedQueryID string) {
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// 在建立连接前校验语句，避免无效请求占用连接
+	var run func(ctx context.Context, conn *session.Connection, w queryWriter) (*queryOutcome, error)
+	switch state.Info.Type {
+	case "mysql", "sqlite":
+		if err := providers.CheckReadOnlySQL(req.SQL, state.Info.Type); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		run = func(ctx context.Context, conn *session.Connection, w queryWriter) (*queryOutcome, error) {
+			return runSQLQuery(ctx, conn, state.Info.Type, req, w)
+		}
+	case "mongodb":
+		query, err := parseMongoQuery(req)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		run = func(ctx context.Context, conn *session.Connection, w queryWriter) (*queryOutcome, error) {
+			return query.run(ctx, conn.Client.Database(conn.Database), req, w)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": state.Info.Type + " 会话不支持查询"})
+		return
+	}
+
+	history := &queries.HistoryEntry{
+		UserID:       requestUserID(c),
+		SessionID:    sessionID,
+		Source:       state.Info.SourceKey(),
+		Type:         state.Info.Type,
+		SavedQueryID: savedQueryID,
+		Statement:    req.statement(),
+	}
+	start := time.Now()
+
+	conn, err := sessionManager.Connection(sessionID)
+	if err != nil {
+		recordHistory(history, start, 0, "连接数据库失败: "+err.Error())
+		status := http.StatusBadGateway
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": "连接数据库失败: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), req.timeout())
+	defer cancel()
+
+	if req.Stream {
+		rowCount, message := streamQuery(c, ctx, run, conn, start)
+		recordHistory(history, start, rowCount, message)
+		return
+	}
+
+	result := &pagedQueryWriter{rows: []providers.Row{}}
+	outcome, err := run(ctx, conn, result)
+	if err != nil {
+		recordHistory(history, start, 0, queryErrorMessage(ctx, err))
+		c.JSON(queryErrorStatus(ctx, err), gin.H{"success": false, "error": queryErrorMessage(ctx, err)})
+		return
+	}
+	recordHistory(history, start, len(result.rows), "")
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"columns":    outcome.Columns,
+		"rows":       result.rows,
+		"rowCount":   len(result.rows),
+		"offset":     req.Offset,
+		"limit":      req.Limit,
+		"hasMore":    outcome.HasMore,
+		"durationMs": time.Since(start).Milliseconds(),
+	})
+}
+
+// pagedQueryWriter 将结果收集到内存中，一次性返回
+type pagedQueryWriter struct {
+	rows []providers.Row
+}
+
+func (w *pagedQueryWriter) columns(columns []QueryColumn) error {
+	return nil
+}
+
+func (w *pagedQueryWriter) row(row providers.Row) error {
+	w.rows = append(w.rows, row)
+	return nil
+}
+
+// ndjsonQueryWriter 每行写出一个JSON对象
+type ndjsonQueryWriter struct {
+	c        *gin.Context
+	encoder  *json.Encoder
+	rowCount int
+}
+
+func (w *ndjsonQueryWriter) columns(columns []QueryColumn) error {
+	return w.write(gin.H{"columns": columns})
+}
+
+func (w *ndjsonQueryWriter) row(row providers.Row) error {
+	w.rowCount++
+	return w.write(row)
+}
+
+func (w *ndjsonQueryWriter) write(value interface{}) error {
+	if err := w.encoder.Encode(value); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// streamQuery 以NDJSON格式返回查询结果，返回写出的行数和失败原因
+// 第一行为 {"columns": [...]}，之后每行一条记录，最后一行为 {"done": true, ...} 或 {"error": "..."}
+func streamQuery(c *gin.Context, ctx context.Context, run func(context.Context, *session.Connection, queryWriter) (*queryOutcome, error), conn *session.Connection, start time.Time) (int, string) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	w := &ndjsonQueryWriter{c: c, encoder: json.NewEncoder(c.Writer)}
+	outcome, err := run(ctx, conn, w)
+	if err != nil {
+		message := queryErrorMessage(ctx, err)
+		w.write(gin.H{"error": message})
+		return w.rowCount, message
+	}
+	w.write(gin.H{
+		"done":       true,
+		"columns":    outcome.Columns,
+		"rowCount":   w.rowCount,
+		"hasMore":    outcome.HasMore,
+		"durationMs": time.Since(start).Milliseconds(),
+	})
+	return w.rowCount, ""
+}
+
+// queryErrorStatus 根据错误类型返回HTTP状态码
+func queryErrorStatus(ctx context.Context, err error) int {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusRequestTimeout
+	}
+	if errors.Is(err, providers.ErrNotReadOnly) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// queryErrorMessage 超时时返回明确的提示
+func queryErrorMessage(ctx context.Context, err error) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "查询超时: " + err.Error()
+	}
+	return err.Error()
+}
+
+// runSQLQuery 在只读事务中执行SQL查询，跳过offset行后最多返回limit行
+func runSQLQuery(ctx context.Context, source *session.Connection, dbType string, req *QueryRequest, w queryWriter) (*queryOutcome, error) {
+	conn, err := source.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// SQLite驱动会忽略只读事务选项，改为在本次使用的连接上开启query_only；
+	// 只读会话的连接已经以只读方式打开，不能再关闭query_only
+	if dbType == "sqlite" && !source.ReadOnly {
+		if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			return nil, err
+		}
+		defer conn.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+	}
+
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, req.SQL, req.Params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]QueryColumn, len(columnTypes))
+	for i, ct := range columnTypes {
+		nativeType := strings.ToLower(ct.DatabaseTypeName())
+		columnType := typesystem.FromSQLite(nativeType)
+		if dbType == "mysql" {
+			columnType = typesystem.FromMySQL(nativeType)
+		}
+		columns[i] = QueryColumn{Name: ct.Name(), Type: columnType, NativeType: nativeType}
+	}
+	if err := w.columns(append([]QueryColumn{}, columns...)); err != nil {
+		return nil, err
+	}
+
+	index, written, hasMore := 0, 0, false
+	err = providers.ScanRows(rows, func(row providers.Row) error {
+		index++
+		if index <= req.Offset {
+			return nil
+		}
+		if written >= req.Limit {
+			hasMore = true
+			return errStopQuery
+		}
+		written++
+		// 表达式列没有声明类型，根据返回的值推断
+		for i := range columns {
+			if columns[i].NativeType == "" || columns[i].Type == typesystem.Unknown {
+				columns[i].Type = typesystem.Merge(columns[i].Type, typesystem.FromValue(row[columns[i].Name]))
+			}
+		}
+		return w.row(row)
+	})
+	if err != nil && err != errStopQuery {
+		return nil, err
+	}
+	return &queryOutcome{Columns: columns, HasMore: hasMore}, nil
+}
+
+// mongoQuery 解析后的MongoDB查询
+type mongoQuery struct {
+	filter     interface{}
+	projection interface{}
+	sort       interface{}
+	pipeline   []bson.D // 非空时执行aggregate
+}
+
+// parseMongoQuery 解析扩展JSON格式的查询条件，拒绝写入型聚合阶段
+func parseMongoQuery(req *QueryRequest) (*mongoQuery, error) {
+	if req.Collection == "" {
+		return nil, fmt.Errorf("缺少必要参数: collection")
+	}
+	query := &mongoQuery{filter: bson.D{}}
+	if len(req.Pipeline) > 0 {
+		var pipeline []bson.D
+		if err := bson.UnmarshalExtJSON(req.Pipeline, false, &pipeline); err != nil {
+			return nil, fmt.Errorf("无效的pipeline: %w", err)
+		}
+		if err := checkReadOnlyPipeline(pipeline); err != nil {
+			return nil, err
+		}
+		query.pipeline = pipeline
+		return query, nil
+	}
+
+	for _, part := range []struct {
+		name   string
+		raw    json.RawMessage
+		target *interface{}
+	}{
+		{"filter", req.Filter, &query.filter},
+		{"projection", req.Projection, &query.projection},
+		{"sort", req.Sort, &query.sort},
+	} {
+		if len(part.raw) == 0 || string(part.raw) == "null" {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(part.raw, false, &doc); err != nil {
+			return nil, fmt.Errorf("无效的%s: %w", part.name, err)
+		}
+		*part.target = doc
+	}
+	return query, nil
+}
+
+// checkReadOnlyPipeline 检查聚合管道（包括$lookup、$facet等嵌套管道）中没有$out和$merge阶段
+func checkReadOnlyPipeline(pipeline []bson.D) error {
+	var check func(value interface{}) error
+	check = func(value interface{}) error {
+		switch v := value.(type) {
+		case bson.D:
+			for _, elem := range v {
+				if elem.Key == "$out" || elem.Key == "$merge" {
+					return fmt.Errorf("%w: 不允许使用 %s 阶段", providers.ErrNotReadOnly, elem.Key)
+				}
+				if err := check(elem.Value); err != nil {
+					return err
+				}
+			}
+		case bson.A:
+			for _, item := range v {
+				if err := check(item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, stage := range pipeline {
+		if err := check(stage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run 执行查询；多取一条以判断是否还有更多数据
+// MongoDB的列由返回的文档推断，流式返回时首行的列来自第一个文档
+func (q *mongoQuery) run(ctx context.Context, db *mongo.Database, req *QueryRequest, w queryWriter) (*queryOutcome, error) {
+	coll := db.Collection(req.Collection)
+	maxTime := req.timeout()
+
+	var cursor *mongo.Cursor
+	var err error
+	if q.pipeline != nil {
+		pipeline := append(append([]bson.D{}, q.pipeline...),
+			bson.D{{Key: "$skip", Value: int64(req.Offset)}},
+			bson.D{{Key: "$limit", Value: int64(req.Limit + 1)}})
+		cursor, err = coll.Aggregate(ctx, pipeline, options.Aggregate().SetMaxTime(maxTime))
+	} else {
+		opts := options.Find().
+			SetSkip(int64(req.Offset)).
+			SetLimit(int64(req.Limit + 1)).
+			SetMaxTime(maxTime)
+		if q.projection != nil {
+			opts.SetProjection(q.projection)
+		}
+		if q.sort != nil {
+			opts.SetSort(q.sort)
+		}
+		cursor, err = coll.Find(ctx, q.filter, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	columns := newColumnTracker()
+	outcome := &queryOutcome{}
+	written := 0
+	for cursor.Next(ctx) {
+		if written >= req.Limit {
+			outcome.HasMore = true
+			break
+		}
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		columns.observe(doc)
+		if written == 0 {
+			if err := w.columns(columns.list()); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.row(providers.Row(doc)); err != nil {
+			return nil, err
+		}
+		written++
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if written == 0 {
+		if err := w.columns([]QueryColumn{}); err != nil {
+			return nil, err
+		}
+	}
+	outcome.Columns = columns.list()
+	return outcome, nil
+}
+
+// columnTracker 按出现顺序记录文档字段并合并类型
+type columnTracker struct {
+	columns []QueryColumn
+	index   map[string]int
+}
+
+func newColumnTracker() *columnTracker {
+	return &columnTracker{index: make(map[string]int)}
+}
+
+// observe 记录文档的顶层字段
+func (t *columnTracker) observe(doc bson.M) {
+	names := make([]string, 0, len(doc))
+	for name := range doc {
+		names = append(names, name)
+	}
+	// _id排在最前，其余字段按名称排序保证顺序稳定
+	sortFieldNames(names)
+	for _, name := range names {
+		valueType := typesystem.FromValue(doc[name])
+		if i, ok := t.index[name]; ok {
+			t.columns[i].Type = typesystem.Merge(t.columns[i].Type, valueType)
+			continue
+		}
+		t.index[name] = len(t.columns)
+		t.columns = append(t.columns, QueryColumn{Name: name, Type: valueType})
+	}
+}
+
+// list 返回列的副本
+func (t *columnTracker) list() []QueryColumn {
+	return append([]QueryColumn{}, t.columns...)
+}
+
+// sortFieldNames 将字段名排序，_id排在最前
+func sortFieldNames(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "_id" || names[j] == "_id" {
+			return names[i] == "_id" && names[j] != "_id"
+		}
+		return names[i] < names[j]
+	})
+}
@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DefaultBrowseLimit 默认每页行数
+	DefaultBrowseLimit = 50
+	// MaxBrowseLimit 每页允许的最大行数
+	MaxBrowseLimit = 1000
+	// browseTimeout 单次翻页的超时时间
+	browseTimeout = 30 * time.Second
+	// countTimeout 带过滤条件计数的超时时间，超时后不返回总数
+	countTimeout = 5 * time.Second
+)
+
+// errBadBrowseRequest 翻页参数无效
+var errBadBrowseRequest = errors.New("无效的翻页参数")
+
+// sortKey 排序字段
+type sortKey struct {
+	Name string
+	Desc bool
+}
+
+// columnFilter 列过滤条件，op为eq/ne/gt/gte/lt/lte/like/null/notnull
+type columnFilter struct {
+	Name  string
+	Op    string
+	Value string
+}
+
+// browseRequest 解析后的翻页参数
+type browseRequest struct {
+	Limit   int
+	Offset  int
+	Sort    []sortKey
+	Filters []columnFilter
+	Count   bool // 是否返回总数
+}
+
+// sortSpec 返回排序条件的文本形式，用于校验游标
+func sortSpec(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Name
+		if key.Desc {
+			parts[i] = "-" + key.Name
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseBrowseRequest 解析查询参数
+// sort=name,-age；filter=age:gte:18（可重复）；after为上一页返回的nextCursor
+func parseBrowseRequest(c *gin.Context) (*browseRequest, string, error) {
+	req := &browseRequest{Limit: DefaultBrowseLimit, Count: c.DefaultQuery("count", "true") != "false"}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, "", fmt.Errorf("%w: limit必须为正整数", errBadBrowseRequest)
+		}
+		req.Limit = limit
+	}
+	if req.Limit > MaxBrowseLimit {
+		req.Limit = MaxBrowseLimit
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("%w: offset必须为非负整数", errBadBrowseRequest)
+		}
+		req.Offset = offset
+	}
+	for _, item := range strings.Split(c.Query("sort"), ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key := sortKey{Name: item}
+		if strings.HasPrefix(item, "-") {
+			key = sortKey{Name: item[1:], Desc: true}
+		} else if strings.HasPrefix(item, "+") {
+			key.Name = item[1:]
+		}
+		if key.Name == "" {
+			return nil, "", fmt.Errorf("%w: 无效的排序字段 %q", errBadBrowseRequest, item)
+		}
+		req.Sort = append(req.Sort, key)
+	}
+	for _, item := range c.QueryArray("filter") {
+		parts := strings.SplitN(item, ":", 3)
+		if len(parts) < 2 || parts[0] == "" {
+			return nil, "", fmt.Errorf("%w: 过滤条件格式应为 字段:操作符:值，实际为 %q", errBadBrowseRequest, item)
+		}
+		filter := columnFilter{Name: parts[0], Op: strings.ToLower(parts[1])}
+		if len(parts) == 3 {
+			filter.Value = parts[2]
+		}
+		switch filter.Op {
+		case "eq", "ne", "gt", "gte", "lt", "lte", "like":
+			if len(parts) < 3 {
+				return nil, "", fmt.Errorf("%w: 过滤条件 %q 缺少值", errBadBrowseRequest, item)
+			}
+		case "null", "notnull":
+		default:
+			return nil, "", fmt.Errorf("%w: 不支持的操作符 %s", errBadBrowseRequest, filter.Op)
+		}
+		req.Filters = append(req.Filters, filter)
+	}
+	return req, c.Query("after"), nil
+}
+
+// browseCursor 键集分页游标，使用扩展JSON保留值的类型
+type browseCursor struct {
+	Sort   string `bson:"s"`
+	Values bson.A `bson:"v"`
+}
+
+// encodeCursor 生成下一页游标
+func encodeCursor(keys []sortKey, values []interface{}) (string, error) {
+	data, err := bson.MarshalExtJSON(browseCursor{Sort: sortSpec(keys), Values: bson.A(values)}, true, false)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor 解析游标，游标必须与当前的排序条件一致
+func decodeCursor(cursor string, keys []sortKey) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的游标", errBadBrowseRequest)
+	}
+	var decoded browseCursor
+	if err := bson.UnmarshalExtJSON(data, true, &decoded); err != nil {
+		return nil, fmt.Errorf("%w: 无效的游标", errBadBrowseRequest)
+	}
+	if decoded.Sort != sortSpec(keys) || len(decoded.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: 游标与当前排序条件不一致", errBadBrowseRequest)
+	}
+	return decoded.Values, nil
+}
+
+// browseResult 一页数据
+type browseResult struct {
+	Columns    []QueryColumn   `json:"columns"`
+	Rows       []providers.Row `json:"rows"`
+	Sort       string          `json:"sort"`                 // 实际使用的排序，包含自动追加的主键
+	HasMore    bool            `json:"hasMore"`              // 之后是否还有数据
+	NextCursor string          `json:"nextCursor,omitempty"` // 键集分页游标，作为下一页的after参数
+	Total      *int64          `json:"total"`                // 总行数，计数超时或未请求时为null
+	TotalExact bool            `json:"totalExact"`           // 总行数是精确值还是统计信息中的估算值
+}
+
+// BrowseEntityRows 分页浏览会话中的表或集合
+// 支持offset分页和基于排序字段的键集分页；排序字段后会自动追加主键保证顺序稳定
+func (h *SessionHandler) BrowseEntityRows(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	entity := c.Param("name")
+	state, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+	req, after, err := parseBrowseRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	conn, err := sessionManager.Connection(sessionID)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": "连接数据库失败: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), browseTimeout)
+	defer cancel()
+
+	var result *browseResult
+	switch state.Info.Type {
+	case "mysql", "sqlite":
+		result, err = browseSQL(ctx, conn.DB, state.Info.Type, entity, req, after)
+	case "mongodb":
+		result, err = browseMongo(ctx, conn.Client.Database(conn.Database), entity, req, after)
+	default:
+		err = fmt.Errorf("%w: %s 会话不支持浏览数据", errBadBrowseRequest, state.Info.Type)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errBadBrowseRequest) {
+			status = http.StatusBadRequest
+		} else if errors.Is(err, errEntityNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"entity":     entity,
+		"offset":     req.Offset,
+		"limit":      req.Limit,
+		"columns":    result.Columns,
+		"rows":       result.Rows,
+		"sort":       result.Sort,
+		"hasMore":    result.HasMore,
+		"nextCursor": result.NextCursor,
+		"total":      result.Total,
+		"totalExact": result.TotalExact,
+	})
+}
+
+// errEntityNotFound 表或集合不存在
+var errEntityNotFound = errors.New("表或集合不存在")
+
+// withKeyColumns 在排序字段后追加未出现的主键字段
+func withKeyColumns(keys []sortKey, primaryKeys []string) []sortKey {
+	result := append([]sortKey{}, keys...)
+	for _, pk := range primaryKeys {
+		found := false
+		for _, key := range keys {
+			if key.Name == pk {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, sortKey{Name: pk})
+		}
+	}
+	return result
+}
+
+// sqlTableColumns 读取表的列定义和主键
+func sqlTableColumns(ctx context.Context, db *sql.DB, dbType, table string) ([]QueryColumn, []string, error) {
+	var rows *sql.Rows
+	var err error
+	if dbType == "mysql" {
+		rows, err = db.QueryContext(ctx, `SELECT COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY = 'PRI'
+			FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`, table)
+	} else {
+		rows, err = db.QueryContext(ctx, `SELECT name, type, pk > 0 FROM pragma_table_info(?) ORDER BY cid`, table)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取表 %s 结构失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []QueryColumn
+	var primaryKeys []string
+	for rows.Next() {
+		var name, nativeType string
+		var isKey bool
+		if err := rows.Scan(&name, &nativeType, &isKey); err != nil {
+			return nil, nil, err
+		}
+		columnType := typesystem.FromSQLite(nativeType)
+		if dbType == "mysql" {
+			columnType = typesystem.FromMySQL(nativeType)
+		}
+		columns = append(columns, QueryColumn{Name: name, Type: columnType, NativeType: strings.ToLower(nativeType)})
+		if isKey {
+			primaryKeys = append(primaryKeys, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", errEntityNotFound, table)
+	}
+	return columns, primaryKeys, nil
+}
+
+// sqlOperators 过滤操作符对应的SQL比较符
+var sqlOperators = map[string]string{"eq": "=", "ne": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+
+// likeEscaper 转义LIKE通配符，使过滤值按字面匹配；使用!作为转义符以避免MySQL和SQLite对反斜杠的不同处理
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// browseSQL 分页读取MySQL/SQLite表
+func browseSQL(ctx context.Context, db *sql.DB, dbType, table string, req *browseRequest, after string) (*browseResult, error) {
+	columns, primaryKeys, err := sqlTableColumns(ctx, db, dbType, table)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column.Name] = true
+	}
+	quote := func(name string) string {
+		return quoteIdentifier(dbType, name)
+	}
+
+	var where []string
+	var args []interface{}
+	for _, filter := range req.Filters {
+		if !known[filter.Name] {
+			return nil, fmt.Errorf("%w: 列 %s 不存在", errBadBrowseRequest, filter.Name)
+		}
+		column := quote(filter.Name)
+		switch filter.Op {
+		case "null":
+			where = append(where, column+" IS NULL")
+		case "notnull":
+			where = append(where, column+" IS NOT NULL")
+		case "like":
+			where = append(where, column+" LIKE ? ESCAPE '!'")
+			args = append(args, "%"+likeEscaper.Replace(filter.Value)+"%")
+		default:
+			where = append(where, column+" "+sqlOperators[filter.Op]+" ?")
+			args = append(args, filter.Value)
+		}
+	}
+	filterWhere := append([]string{}, where...)
+	filterArgs := append([]interface{}{}, args...)
+
+	for _, key := range req.Sort {
+		if !known[key.Name] {
+			return nil, fmt.Errorf("%w: 列 %s 不存在", errBadBrowseRequest, key.Name)
+		}
+	}
+	keys := withKeyColumns(req.Sort, primaryKeys)
+	// 无主键且未指定排序时不保证顺序，也无法使用键集分页
+	keyset := len(primaryKeys) > 0
+
+	offset := req.Offset
+	if after != "" {
+		if !keyset {
+			return nil, fmt.Errorf("%w: 表 %s 没有主键，不支持键集分页", errBadBrowseRequest, table)
+		}
+		values, err := decodeCursor(after, keys)
+		if err != nil {
+			return nil, err
+		}
+		// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+		var branches []string
+		for i, key := range keys {
+			var parts []string
+			for j := 0; j < i; j++ {
+				parts = append(parts, quote(keys[j].Name)+" = ?")
+				args = append(args, sqlCursorValue(values[j]))
+			}
+			op := ">"
+			if key.Desc {
+				op = "<"
+			}
+			parts = append(parts, quote(key.Name)+" "+op+" ?")
+			args = append(args, sqlCursorValue(values[i]))
+			branches = append(branches, "("+strings.Join(parts, " AND ")+")")
+		}
+		where = append(where, "("+strings.Join(branches, " OR ")+")")
+		offset = 0
+	}
+
+	query := "SELECT * FROM " + quote(table)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if len(keys) > 0 {
+		orderBy := make([]string, len(keys))
+		for i, key := range keys {
+			orderBy[i] = quote(key.Name)
+			if key.Desc {
+				orderBy[i] += " DESC"
+			}
+		}
+		query += " ORDER BY " + strings.Join(orderBy, ", ")
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, req.Limit+1, offset)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("读取表 %s 失败: %w", table, err)
+	}
+	data, err := providers.CollectRows(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &browseResult{Columns: columns, Rows: data, Sort: sortSpec(keys)}
+	if len(data) > req.Limit {
+		result.Rows = data[:req.Limit]
+		result.HasMore = true
+		if keyset {
+			last := result.Rows[len(result.Rows)-1]
+			values := make([]interface{}, len(keys))
+			for i, key := range keys {
+				values[i] = last[key.Name]
+			}
+			if result.NextCursor, err = encodeCursor(keys, values); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if req.Count {
+		result.Total, result.TotalExact = countSQL(ctx, db, dbType, table, quote(table), filterWhere, filterArgs)
+	}
+	return result, nil
+}
+
+// sqlCursorValue 将游标中的扩展JSON值转换为SQL参数
+// 时间使用UTC，SQLite按文本比较时间，本地时区会使比较结果出错
+func sqlCursorValue(value interface{}) interface{} {
+	if dt, ok := value.(primitive.DateTime); ok {
+		return dt.Time().UTC()
+	}
+	return value
+}
+
+// countSQL 返回表的总行数
+// MySQL无过滤条件时使用统计信息中的估算值；其余情况在超时时间内精确计数，超时返回nil
+func countSQL(ctx context.Context, db *sql.DB, dbType, table, quotedTable string, where []string, args []interface{}) (*int64, bool) {
+	if dbType == "mysql" && len(where) == 0 {
+		var estimate sql.NullInt64
+		err := db.QueryRowContext(ctx, `SELECT TABLE_ROWS FROM information_schema.TABLES
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`, table).Scan(&estimate)
+		if err == nil && estimate.Valid {
+			return &estimate.Int64, false
+		}
+	}
+
+	countCtx, cancel := context.WithTimeout(ctx, countTimeout)
+	defer cancel()
+	query := "SELECT COUNT(*) FROM " + quotedTable
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	var total int64
+	if err := db.QueryRowContext(countCtx, query, args...).Scan(&total); err != nil {
+		return nil, false
+	}
+	return &total, true
+}
+
+// mongoOperators 过滤操作符对应的MongoDB比较符
+var mongoOperators = map[string]string{"eq": "$eq", "ne": "$ne", "gt": "$gt", "gte": "$gte", "lt": "$lt", "lte": "$lte"}
+
+// mongoFilterValue 将查询参数中的值转换为MongoDB值
+// 按JSON解析数字、布尔值和带引号的字符串，_id字段的24位十六进制字符串转换为ObjectId
+func mongoFilterValue(field, value string) interface{} {
+	if field == "_id" {
+		if oid, err := primitive.ObjectIDFromHex(value); err == nil {
+			return oid
+		}
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		switch parsed.(type) {
+		case float64, bool, string:
+			return parsed
+		}
+	}
+	return value
+}
+
+// browseMongo 分页读取MongoDB集合
+func browseMongo(ctx context.Context, db *mongo.Database, collection string, req *browseRequest, after string) (*browseResult, error) {
+	names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: collection}})
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: %s", errEntityNotFound, collection)
+	}
+	coll := db.Collection(collection)
+
+	var conditions bson.A
+	for _, filter := range req.Filters {
+		if strings.HasPrefix(filter.Name, "$") {
+			return nil, fmt.Errorf("%w: 无效的字段名 %s", errBadBrowseRequest, filter.Name)
+		}
+		switch filter.Op {
+		case "null":
+			conditions = append(conditions, bson.D{{Key: filter.Name, Value: nil}})
+		case "notnull":
+			conditions = append(conditions, bson.D{{Key: filter.Name, Value: bson.D{{Key: "$ne", Value: nil}}}})
+		case "like":
+			conditions = append(conditions, bson.D{{Key: filter.Name, Value: primitive.Regex{Pattern: regexp.QuoteMeta(filter.Value), Options: "i"}}})
+		default:
+			conditions = append(conditions, bson.D{{Key: filter.Name, Value: bson.D{{Key: mongoOperators[filter.Op], Value: mongoFilterValue(filter.Name, filter.Value)}}}})
+		}
+	}
+	countFilter := bson.D{}
+	if len(conditions) > 0 {
+		countFilter = bson.D{{Key: "$and", Value: conditions}}
+	}
+
+	for _, key := range req.Sort {
+		if strings.HasPrefix(key.Name, "$") {
+			return nil, fmt.Errorf("%w: 无效的字段名 %s", errBadBrowseRequest, key.Name)
+		}
+	}
+	keys := withKeyColumns(req.Sort, []string{"_id"})
+
+	offset := req.Offset
+	if after != "" {
+		values, err := decodeCursor(after, keys)
+		if err != nil {
+			return nil, err
+		}
+		var branches bson.A
+		for i, key := range keys {
+			branch := bson.D{}
+			for j := 0; j < i; j++ {
+				branch = append(branch, bson.E{Key: keys[j].Name, Value: values[j]})
+			}
+			op := "$gt"
+			if key.Desc {
+				op = "$lt"
+			}
+			branch = append(branch, bson.E{Key: key.Name, Value: bson.D{{Key: op, Value: values[i]}}})
+			branches = append(branches, branch)
+		}
+		conditions = append(conditions, bson.D{{Key: "$or", Value: branches}})
+		offset = 0
+	}
+	filter := bson.D{}
+	if len(conditions) > 0 {
+		filter = bson.D{{Key: "$and", Value: conditions}}
+	}
+
+	sort := bson.D{}
+	for _, key := range keys {
+		direction := 1
+		if key.Desc {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: key.Name, Value: direction})
+	}
+	cursor, err := coll.Find(ctx, filter, options.Find().
+		SetSort(sort).
+		SetSkip(int64(offset)).
+		SetLimit(int64(req.Limit+1)).
+		SetMaxTime(browseTimeout))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	columns := newColumnTracker()
+	result := &browseResult{Rows: []providers.Row{}, Sort: sortSpec(keys)}
+	var last bson.M
+	for cursor.Next(ctx) {
+		if len(result.Rows) >= req.Limit {
+			result.HasMore = true
+			break
+		}
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		columns.observe(doc)
+		result.Rows = append(result.Rows, providers.Row(doc))
+		last = doc
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	result.Columns = columns.list()
+
+	if result.HasMore {
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = lookupPath(last, key.Name)
+		}
+		if result.NextCursor, err = encodeCursor(keys, values); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Count {
+		if len(countFilter) == 0 {
+			if total, err := coll.EstimatedDocumentCount(ctx); err == nil {
+				result.Total, result.TotalExact = &total, false
+			}
+		} else if total, err := coll.CountDocuments(ctx, countFilter, options.Count().SetMaxTime(countTimeout)); err == nil {
+			result.Total, result.TotalExact = &total, true
+		}
+	}
+	return result, nil
+}
+
+// lookupPath 按点分路径读取嵌套字段的值
+func lookupPath(doc bson.M, path string) interface{} {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case bson.M:
+			current = v[part]
+		case bson.D:
+			current = nil
+			for _, elem := range v {
+				if elem.Key == part {
+					current = elem.Value
+					break
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return current
+}
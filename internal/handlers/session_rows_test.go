@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseBrowseRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		query     string
+		want      *browseRequest
+		wantAfter string
+		wantErr   bool
+	}{
+		{
+			name:  "默认值",
+			query: "",
+			want:  &browseRequest{Limit: DefaultBrowseLimit, Count: true},
+		},
+		{
+			name:  "超过最大行数时截断",
+			query: "limit=5000&offset=10&count=false",
+			want:  &browseRequest{Limit: MaxBrowseLimit, Offset: 10},
+		},
+		{
+			name:  "排序字段",
+			query: "sort=name,+age,-created_at,%20",
+			want: &browseRequest{Limit: DefaultBrowseLimit, Count: true, Sort: []sortKey{
+				{Name: "name"}, {Name: "age"}, {Name: "created_at", Desc: true},
+			}},
+		},
+		{
+			name:  "过滤条件的值可以包含冒号",
+			query: "filter=age:GTE:18&filter=created:lt:2024-01-01T00:00:00Z&filter=email:notnull",
+			want: &browseRequest{Limit: DefaultBrowseLimit, Count: true, Filters: []columnFilter{
+				{Name: "age", Op: "gte", Value: "18"},
+				{Name: "created", Op: "lt", Value: "2024-01-01T00:00:00Z"},
+				{Name: "email", Op: "notnull"},
+			}},
+		},
+		{
+			name:  "like的值原样保留",
+			query: "filter=name:like:50%25_off",
+			want: &browseRequest{Limit: DefaultBrowseLimit, Count: true, Filters: []columnFilter{
+				{Name: "name", Op: "like", Value: "50%_off"},
+			}},
+		},
+		{
+			name:      "游标",
+			query:     "after=abc",
+			want:      &browseRequest{Limit: DefaultBrowseLimit, Count: true},
+			wantAfter: "abc",
+		},
+		{name: "limit为0", query: "limit=0", wantErr: true},
+		{name: "limit不是整数", query: "limit=ten", wantErr: true},
+		{name: "offset为负数", query: "offset=-1", wantErr: true},
+		{name: "只有排序方向", query: "sort=-", wantErr: true},
+		{name: "过滤条件缺少操作符", query: "filter=age", wantErr: true},
+		{name: "过滤条件缺少字段", query: "filter=:eq:1", wantErr: true},
+		{name: "过滤条件缺少值", query: "filter=age:eq", wantErr: true},
+		{name: "不支持的操作符", query: "filter=age:between:1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/rows?"+tt.query, nil)
+			got, after, err := parseBrowseRequest(c)
+			if tt.wantErr {
+				if !errors.Is(err, errBadBrowseRequest) {
+					t.Fatalf("parseBrowseRequest() 错误 = %v, 期望 errBadBrowseRequest", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBrowseRequest() 错误 = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBrowseRequest() = %+v, 期望 %+v", got, tt.want)
+			}
+			if after != tt.wantAfter {
+				t.Errorf("after = %q, 期望 %q", after, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestBrowseCursor(t *testing.T) {
+	created := time.Date(2024, 3, 1, 8, 30, 15, 123000000, time.FixedZone("CST", 8*3600))
+	oid := primitive.NewObjectID()
+	keys := []sortKey{{Name: "created", Desc: true}, {Name: "id"}}
+
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   []interface{}
+	}{
+		{"整数和字符串", []interface{}{"a", int64(42)}, []interface{}{"a", int64(42)}},
+		{"小整数保留类型", []interface{}{int32(7), 1.5}, []interface{}{int32(7), 1.5}},
+		{"空值和布尔值", []interface{}{nil, true}, []interface{}{nil, true}},
+		{"时间转为DateTime", []interface{}{created, "x"}, []interface{}{primitive.NewDateTimeFromTime(created), "x"}},
+		{"ObjectId", []interface{}{oid, int64(1)}, []interface{}{oid, int64(1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := encodeCursor(keys, tt.values)
+			if err != nil {
+				t.Fatalf("encodeCursor() 错误 = %v", err)
+			}
+			got, err := decodeCursor(cursor, keys)
+			if err != nil {
+				t.Fatalf("decodeCursor() 错误 = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCursor() = %#v, 期望 %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsMismatch(t *testing.T) {
+	keys := []sortKey{{Name: "name"}, {Name: "id"}}
+	cursor, err := encodeCursor(keys, []interface{}{"a", int64(1)})
+	if err != nil {
+		t.Fatalf("encodeCursor() 错误 = %v", err)
+	}
+	short, err := encodeCursor([]sortKey{{Name: "name"}, {Name: "id"}}, []interface{}{"a"})
+	if err != nil {
+		t.Fatalf("encodeCursor() 错误 = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		keys   []sortKey
+	}{
+		{"排序字段不同", cursor, []sortKey{{Name: "age"}, {Name: "id"}}},
+		{"排序方向不同", cursor, []sortKey{{Name: "name", Desc: true}, {Name: "id"}}},
+		{"排序字段顺序不同", cursor, []sortKey{{Name: "id"}, {Name: "name"}}},
+		{"缺少自动追加的主键", cursor, []sortKey{{Name: "name"}}},
+		{"值的个数不同", short, keys},
+		{"不是base64", "not base64!", keys},
+		{"不是扩展JSON", base64.RawURLEncoding.EncodeToString([]byte("{broken")), keys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor, tt.keys); !errors.Is(err, errBadBrowseRequest) {
+				t.Errorf("decodeCursor() 错误 = %v, 期望 errBadBrowseRequest", err)
+			}
+		})
+	}
+}
+
+func TestSQLCursorValue(t *testing.T) {
+	created := time.Date(2024, 3, 1, 8, 30, 15, 0, time.FixedZone("CST", 8*3600))
+	got := sqlCursorValue(primitive.NewDateTimeFromTime(created))
+	value, ok := got.(time.Time)
+	if !ok || !value.Equal(created) || value.Location() != time.UTC {
+		t.Errorf("sqlCursorValue() = %#v, 期望UTC时间 %v", got, created.UTC())
+	}
+	if got := sqlCursorValue("a"); got != "a" {
+		t.Errorf("sqlCursorValue() = %#v, 期望 \"a\"", got)
+	}
+}
+
+// openBrowseTestDB 创建用于浏览测试的SQLite数据库
+func openBrowseTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "browse.db"))
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	statements := []string{
+		`CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, created DATETIME)`,
+		`CREATE TABLE logs (message TEXT)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("建表失败: %v", err)
+		}
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	names := []string{"50% off", "50 percent", "a_b", "axb", "plain", "a!b", "same", "same"}
+	for i, name := range names {
+		// 最后两行时间相同，用于检查主键作为第二排序字段
+		created := start.Add(time.Duration(i) * time.Hour)
+		if i == len(names)-1 {
+			created = created.Add(-time.Hour)
+		}
+		if _, err := db.Exec(`INSERT INTO items (id, name, created) VALUES (?, ?, ?)`, i+1, name, created); err != nil {
+			t.Fatalf("写入数据失败: %v", err)
+		}
+	}
+	return db
+}
+
+// rowIDs 返回结果中的id列
+func rowIDs(result *browseResult) []int64 {
+	ids := make([]int64, len(result.Rows))
+	for i, row := range result.Rows {
+		ids[i], _ = row["id"].(int64)
+	}
+	return ids
+}
+
+func TestBrowseSQLFilters(t *testing.T) {
+	db := openBrowseTestDB(t)
+
+	tests := []struct {
+		name    string
+		table   string
+		req     browseRequest
+		want    []int64
+		wantErr error
+	}{
+		{
+			name: "like按字面匹配百分号",
+			req:  browseRequest{Filters: []columnFilter{{Name: "name", Op: "like", Value: "50%"}}},
+			want: []int64{1},
+		},
+		{
+			name: "like按字面匹配下划线",
+			req:  browseRequest{Filters: []columnFilter{{Name: "name", Op: "like", Value: "a_b"}}},
+			want: []int64{3},
+		},
+		{
+			name: "like按字面匹配转义符",
+			req:  browseRequest{Filters: []columnFilter{{Name: "name", Op: "like", Value: "a!b"}}},
+			want: []int64{6},
+		},
+		{
+			name: "like不区分大小写",
+			req:  browseRequest{Filters: []columnFilter{{Name: "name", Op: "like", Value: "PLA"}}},
+			want: []int64{5},
+		},
+		{
+			name: "比较和排序",
+			req: browseRequest{
+				Sort:    []sortKey{{Name: "name", Desc: true}},
+				Filters: []columnFilter{{Name: "id", Op: "gte", Value: "5"}},
+			},
+			want: []int64{7, 8, 5, 6},
+		},
+		{
+			name:    "过滤不存在的列",
+			req:     browseRequest{Filters: []columnFilter{{Name: "missing", Op: "eq", Value: "1"}}},
+			wantErr: errBadBrowseRequest,
+		},
+		{
+			name:    "按不存在的列排序",
+			req:     browseRequest{Sort: []sortKey{{Name: "missing"}}},
+			wantErr: errBadBrowseRequest,
+		},
+		{
+			name:    "表不存在",
+			table:   "missing",
+			wantErr: errEntityNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := tt.table
+			if table == "" {
+				table = "items"
+			}
+			req := tt.req
+			req.Limit = MaxBrowseLimit
+			result, err := browseSQL(context.Background(), db, "sqlite", table, &req, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("browseSQL() 错误 = %v, 期望 %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("browseSQL() 错误 = %v", err)
+			}
+			if got := rowIDs(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("browseSQL() id = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrowseSQLKeyset(t *testing.T) {
+	db := openBrowseTestDB(t)
+
+	tests := []struct {
+		name string
+		sort []sortKey
+		want []int64
+	}{
+		{"按主键", nil, []int64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"按时间", []sortKey{{Name: "created"}}, []int64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"按时间倒序", []sortKey{{Name: "created", Desc: true}}, []int64{7, 8, 6, 5, 4, 3, 2, 1}},
+		{"按名称", []sortKey{{Name: "name"}}, []int64{2, 1, 6, 3, 4, 5, 7, 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			after := ""
+			for page := 0; ; page++ {
+				if page > 10 {
+					t.Fatalf("翻页没有结束")
+				}
+				req := &browseRequest{Limit: 3, Sort: tt.sort}
+				result, err := browseSQL(context.Background(), db, "sqlite", "items", req, after)
+				if err != nil {
+					t.Fatalf("browseSQL() 错误 = %v", err)
+				}
+				got = append(got, rowIDs(result)...)
+				if !result.HasMore {
+					break
+				}
+				if result.NextCursor == "" {
+					t.Fatalf("还有数据但没有返回游标")
+				}
+				after = result.NextCursor
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("翻页结果 id = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrowseSQLCursorErrors(t *testing.T) {
+	db := openBrowseTestDB(t)
+	first, err := browseSQL(context.Background(), db, "sqlite", "items",
+		&browseRequest{Limit: 2, Sort: []sortKey{{Name: "created"}}}, "")
+	if err != nil {
+		t.Fatalf("browseSQL() 错误 = %v", err)
+	}
+	if first.Sort != "created,id" || first.NextCursor == "" {
+		t.Fatalf("第一页 sort = %q, nextCursor = %q", first.Sort, first.NextCursor)
+	}
+
+	tests := []struct {
+		name   string
+		table  string
+		sort   []sortKey
+		cursor string
+	}{
+		{"游标与排序条件不一致", "items", []sortKey{{Name: "name"}}, first.NextCursor},
+		{"游标与排序方向不一致", "items", []sortKey{{Name: "created", Desc: true}}, first.NextCursor},
+		{"无效的游标", "items", []sortKey{{Name: "created"}}, "%%%"},
+		{"没有主键的表", "logs", nil, first.NextCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := browseSQL(context.Background(), db, "sqlite", tt.table,
+				&browseRequest{Limit: 2, Sort: tt.sort}, tt.cursor)
+			if !errors.Is(err, errBadBrowseRequest) {
+				t.Errorf("browseSQL() 错误 = %v, 期望 errBadBrowseRequest", err)
+			}
+		})
+	}
+}
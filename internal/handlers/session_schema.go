@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/audit"
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/providers/mongodb"
+	"minds_iolite_backend/internal/datasource/providers/mysql"
+	"minds_iolite_backend/internal/datasource/providers/sqlite"
+	"minds_iolite_backend/internal/services/datacopy"
+	"minds_iolite_backend/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// catalogEntry 会话结构缓存中一个表、集合或文件的结构
+type catalogEntry struct {
+	Kind          string            `json:"kind" bson:"kind"`
+	Fields        map[string]string `json:"fields" bson:"fields"` // 字段名 -> 规范类型
+	PrimaryKey    []string          `json:"primaryKey,omitempty" bson:"primaryKey,omitempty"`
+	EstimatedRows int64             `json:"estimatedRows,omitempty" bson:"estimatedRows,omitempty"`
+	SampleData    string            `json:"sample_data" bson:"sample_data"`                     // 第一行数据的JSON
+	Fingerprint   string            `json:"fingerprint,omitempty" bson:"fingerprint,omitempty"` // 推断时的表结构或集合元数据指纹
+	RefreshedAt   time.Time         `json:"refreshedAt" bson:"refreshedAt"`
+}
+
+// schemaRefreshRequest 刷新会话结构缓存的请求
+// Entities为空时刷新Include/Exclude范围内的全部实体；OnlyMissing为true时只推断缓存中没有的实体
+type schemaRefreshRequest struct {
+	Entities       []string `json:"entities"`
+	Include        []string `json:"include"`
+	Exclude        []string `json:"exclude"`
+	OnlyMissing    bool     `json:"onlyMissing"`
+	Concurrency    int      `json:"concurrency"`
+	TableTimeoutMs int      `json:"tableTimeoutMs"`
+
+	changedOnly bool // 只推断新出现的实体和指纹发生变化的已缓存实体
+}
+
+// schemaRefreshResult 一次刷新的结果
+type schemaRefreshResult struct {
+	Entities  map[string]interface{} `json:"entities"`  // 刷新后的完整缓存
+	Added     []string               `json:"added"`     // 新加入缓存的实体
+	Refreshed []string               `json:"refreshed"` // 重新推断的已有实体
+	Removed   []string               `json:"removed"`   // 数据源中已不存在、从缓存中删除的实体
+	Failed    map[string]string      `json:"failed,omitempty"`
+
+	Fingerprint string                   `json:"fingerprint"` // 数据源结构的指纹
+	history     []session.SchemaSnapshot // 刷新后的指纹记录
+}
+
+// sessionSource 返回读取会话数据的通用连接
+// MySQL/SQLite/MongoDB复用会话持有的连接，CSV会话直接读取文件；调用方用完后需要Close
+func sessionSource(sessionID string, state *SessionState) (providers.Connection, error) {
+	if state.Info.Type == "csv" {
+		provider, err := providers.Get("csv")
+		if err != nil {
+			return nil, err
+		}
+		return provider.Connect(context.Background(), providers.Config{"filePath": state.Info.FilePath})
+	}
+
+	conn, err := sessionManager.Connection(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	switch state.Info.Type {
+	case "mysql":
+		return mysql.WrapDB(conn.DB, state.Info.Database), nil
+	case "sqlite":
+		return sqlite.WrapDB(conn.DB), nil
+	case "mongodb":
+		if conn.Database == "" {
+			return nil, errors.New("会话未指定MongoDB数据库名")
+		}
+		return mongodb.WrapClient(conn.Client, conn.Database), nil
+	default:
+		return nil, fmt.Errorf("%s 会话不支持读取结构", state.Info.Type)
+	}
+}
+
+// sessionCatalog 返回会话的结构缓存，MongoDB会话使用Collections，其他使用Tables
+func sessionCatalog(state *SessionState) map[string]interface{} {
+	if state.Info.Type == "mongodb" {
+		return state.Collections
+	}
+	return state.Tables
+}
+
+// setSessionCatalog 替换会话的结构缓存
+func setSessionCatalog(state *SessionState, catalog map[string]interface{}) {
+	if state.Info.Type == "mongodb" {
+		state.Collections = catalog
+	} else {
+		state.Tables = catalog
+	}
+}
+
+// sessionErrorStatus 将获取会话连接的错误映射为HTTP状态码
+func sessionErrorStatus(err error) int {
+	if errors.Is(err, errSessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+// GetSessionSchema 返回会话的结构缓存
+// 每次请求都会读取数据源的结构元数据并计算指纹，只重新推断新出现的实体和结构发生变化的已缓存实体；
+// 传入ifChanged=<上次的指纹>时，结构未变化只返回指纹，否则同时返回此后新增、删除和变化的实体
+func (h *SessionHandler) GetSessionSchema(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	state, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+
+	result, err := refreshSessionSchema(c.Request.Context(), sessionID, state, &schemaRefreshRequest{changedOnly: true})
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	response := gin.H{"success": true, "type": state.Info.Type, "fingerprint": result.Fingerprint}
+	if since := c.Query("ifChanged"); since != "" {
+		if since == result.Fingerprint {
+			response["changed"] = false
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		response["changed"] = true
+		if changes := changesSince(result.history, since); changes != nil {
+			response["changes"] = changes
+		} else {
+			// 指纹过旧或来自其他会话，客户端需要使用完整结构
+			response["resync"] = true
+		}
+	}
+	response["entities"] = result.Entities
+	if len(result.Failed) > 0 {
+		response["failed"] = result.Failed
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RefreshSessionSchema 使用会话连接重新推断部分或全部实体的结构并更新缓存
+// 未推断的实体保留原有缓存，数据源中已删除的实体从缓存中移除
+func (h *SessionHandler) RefreshSessionSchema(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	state, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+
+	var req schemaRefreshRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+			return
+		}
+	}
+	if err := req.options().Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	result, err := refreshSessionSchema(c.Request.Context(), sessionID, state, &req)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "type": state.Info.Type, "result": result})
+}
+
+// options 转换为批量推断选项
+func (r *schemaRefreshRequest) options() providers.IntrospectOptions {
+	return providers.IntrospectOptions{
+		Include:      r.Include,
+		Exclude:      r.Exclude,
+		Concurrency:  r.Concurrency,
+		TableTimeout: time.Duration(r.TableTimeoutMs) * time.Millisecond,
+	}
+}
+
+// refreshSessionSchema 推断实体结构并合并到会话缓存中
+func refreshSessionSchema(ctx context.Context, sessionID string, state *SessionState, req *schemaRefreshRequest) (*schemaRefreshResult, error) {
+	source, err := sessionSource(sessionID, state)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	entities, err := source.ListEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]providers.Entity, len(entities))
+	names := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		listed[entity.Name] = entity
+		names = append(names, entity.Name)
+	}
+	fingerprints, err := entityFingerprints(ctx, sessionID, state, entities)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := sessionCatalog(state)
+	opts := req.options()
+	targets := opts.Filter(names)
+	unknown := []string{}
+	if req.changedOnly {
+		// 上次检查之后新出现的实体和指纹变化的已缓存实体，没有记录时推断全部实体
+		var previous map[string]string
+		if snapshot := latestSnapshot(state.SchemaHistory); snapshot != nil {
+			previous = snapshot.Entities
+		}
+		targets = []string{}
+		for _, name := range names {
+			old, known := previous[name]
+			_, isCached := cached[name]
+			if !known || (isCached && old != fingerprints[name]) {
+				targets = append(targets, name)
+			}
+		}
+	} else if len(req.Entities) > 0 {
+		targets = make([]string, 0, len(req.Entities))
+		for _, name := range req.Entities {
+			if _, ok := listed[name]; ok {
+				targets = append(targets, name)
+			} else {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	if req.OnlyMissing {
+		missing := make([]string, 0, len(targets))
+		for _, name := range targets {
+			if _, ok := cached[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		targets = missing
+	}
+
+	// 并发推断，每个实体单独超时
+	inferred := make(map[string]catalogEntry, len(targets))
+	var mu sync.Mutex
+	failures := providers.IntrospectEach(ctx, targets, opts, func(ctx context.Context, name string) error {
+		entry, err := describeEntity(ctx, source, listed[name])
+		if err != nil {
+			return err
+		}
+		entry.Fingerprint = fingerprints[name]
+		mu.Lock()
+		inferred[name] = *entry
+		mu.Unlock()
+		return nil
+	})
+
+	result := &schemaRefreshResult{
+		Added:     []string{},
+		Refreshed: []string{},
+		Removed:   []string{},
+	}
+	if len(failures)+len(unknown) > 0 {
+		result.Failed = make(map[string]string, len(failures)+len(unknown))
+		for name, err := range failures {
+			result.Failed[name] = err.Error()
+		}
+		for _, name := range unknown {
+			result.Failed[name] = "数据源中不存在该实体"
+		}
+	}
+
+	// 基于最新的会话状态合并，避免覆盖并发刷新的结果
+	updated, err := sessionManager.UpdateSession(sessionID, func(current *SessionState) {
+		catalog := make(map[string]interface{})
+		for name, entry := range sessionCatalog(current) {
+			if _, ok := listed[name]; ok {
+				catalog[name] = entry
+			} else {
+				result.Removed = append(result.Removed, name)
+			}
+		}
+		for name, entry := range inferred {
+			if _, ok := catalog[name]; ok {
+				result.Refreshed = append(result.Refreshed, name)
+			} else {
+				result.Added = append(result.Added, name)
+			}
+			catalog[name] = entry
+		}
+		setSessionCatalog(current, catalog)
+
+		// 未重新推断的已缓存实体和推断失败的实体沿用上次的指纹，下次检查时仍会被发现变化
+		var previous map[string]string
+		if snapshot := latestSnapshot(current.SchemaHistory); snapshot != nil {
+			previous = snapshot.Entities
+		}
+		recorded := make(map[string]string, len(fingerprints))
+		for name, value := range fingerprints {
+			_, described := inferred[name]
+			_, isCached := catalog[name]
+			_, failed := result.Failed[name]
+			if !described && (isCached || failed) {
+				if old, ok := previous[name]; ok {
+					value = old
+				} else if failed {
+					continue
+				}
+			}
+			recorded[name] = value
+		}
+		current.SchemaHistory = appendSchemaSnapshot(current.SchemaHistory, newSchemaSnapshot(recorded))
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Refreshed)
+	sort.Strings(result.Removed)
+	result.Entities = sessionCatalog(updated)
+	result.history = updated.SchemaHistory
+	result.Fingerprint = latestSnapshot(updated.SchemaHistory).Fingerprint
+	return result, nil
+}
+
+// describeEntity 读取实体的字段结构和一行样本数据
+func describeEntity(ctx context.Context, source providers.Connection, entity providers.Entity) (*catalogEntry, error) {
+	schema, err := source.Describe(ctx, entity.Name)
+	if err != nil {
+		return nil, err
+	}
+	entry := &catalogEntry{
+		Kind:          entity.Kind,
+		Fields:        make(map[string]string, len(schema.Fields)),
+		EstimatedRows: entity.EstimatedRows,
+		RefreshedAt:   time.Now(),
+	}
+	for _, field := range schema.Fields {
+		entry.Fields[field.Name] = field.Type.String()
+		if field.PrimaryKey {
+			entry.PrimaryKey = append(entry.PrimaryKey, field.Name)
+		}
+	}
+
+	rows, err := source.Sample(ctx, entity.Name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		if data, err := json.Marshal(rows[0]); err == nil {
+			entry.SampleData = string(data)
+		}
+	}
+	return entry, nil
+}
+
+// sessionImportRequest 将会话中的实体导入MongoDB的请求
+type sessionImportRequest struct {
+	Entities  []string `json:"entities"`  // 要导入的表、集合或文件，必填
+	MongoURI  string   `json:"mongoUri"`  // 目标MongoDB连接URI，默认本地
+	DBName    string   `json:"dbName"`    // 目标数据库名，默认为 <会话类型>_import
+	Mode      string   `json:"mode"`      // replace/append/upsert，默认append
+	BatchSize int      `json:"batchSize"` // 每批写入的行数
+	KeyFields []string `json:"keyFields"` // upsert使用的主键字段，默认使用源表主键
+}
+
+// ImportSession 使用会话连接将实体导入MongoDB，每个实体写入同名集合
+// 导入完成后刷新这些实体的结构缓存
+func (h *SessionHandler) ImportSession(c *gin.Context) {
+	sessionID := c.Param("sessionId")
+	state, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+
+	var req sessionImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	if len(req.Entities) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "缺少必要参数: entities"})
+		return
+	}
+	mode, err := datacopy.ParseMode(req.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if req.MongoURI == "" {
+		req.MongoURI = "mongodb://localhost:27017"
+	}
+	if req.DBName == "" {
+		req.DBName = strings.ToLower(state.Info.Type) + "_import"
+	}
+
+	source, err := sessionSource(sessionID, state)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer source.Close()
+
+	sink, err := datacopy.NewMongoSink(providers.Config{"uri": req.MongoURI, "database": req.DBName})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": "连接目标MongoDB失败: " + err.Error()})
+		return
+	}
+	defer sink.Close()
+
+	// 导入可能耗时较长，只受客户端连接的生命周期约束
+	ctx := c.Request.Context()
+	results := make(map[string]*datacopy.Result, len(req.Entities))
+	failed := make(map[string]string)
+	imported := make([]string, 0, len(req.Entities))
+	for _, entity := range req.Entities {
+		result, err := datacopy.Copy(ctx, source, entity, sink, entity, datacopy.Options{
+			Mode:      mode,
+			BatchSize: req.BatchSize,
+			KeyFields: req.KeyFields,
+		})
+		if err != nil {
+			failed[entity] = err.Error()
+			continue
+		}
+		results[entity] = result
+		imported = append(imported, entity)
+	}
+
+	// 记录审计日志，每个导入成功的实体一条
+	if changeLog != nil {
+		for _, entity := range imported {
+			audit.Record(c, changeLog, &audit.Entry{
+				Action: audit.ActionImport,
+				Model:  entity,
+				Details: map[string]interface{}{
+					"sourceType":  state.Info.Type,
+					"sessionId":   sessionID,
+					"database":    req.DBName,
+					"mode":        results[entity].Mode,
+					"rowsWritten": results[entity].RowsWritten,
+				},
+			})
+		}
+	}
+
+	if len(imported) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "导入失败", "failed": failed})
+		return
+	}
+
+	response := gin.H{
+		"success": true,
+		"dbName":  req.DBName,
+		"results": results,
+	}
+	if len(failed) > 0 {
+		response["failed"] = failed
+	}
+	// 结构缓存刷新失败不影响导入结果
+	if refreshed, err := refreshSessionSchema(ctx, sessionID, state, &schemaRefreshRequest{Entities: imported}); err != nil {
+		response["schemaError"] = err.Error()
+	} else {
+		response["entities"] = refreshed.Entities
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,617 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/audit"
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+	"minds_iolite_backend/internal/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// writeTimeout 单次修改数据的超时时间
+	writeTimeout = 30 * time.Second
+	// DefaultAuditLimit 默认返回的审计记录数
+	DefaultAuditLimit = 100
+	// MaxAuditLimit 单次允许返回的最大审计记录数
+	MaxAuditLimit = 1000
+)
+
+var (
+	// errBadWriteRequest 修改请求无效
+	errBadWriteRequest = errors.New("无效的修改请求")
+	// errRowConflict 数据在读取后已被修改或删除
+	errRowConflict = errors.New("数据已被修改")
+	// errAuditFailed 保存审计记录失败，修改不会提交
+	errAuditFailed = errors.New("记录审计日志失败")
+	// errReadOnlySession 只读会话不允许修改数据
+	errReadOnlySession = errors.New(`只读会话不允许修改数据，请使用"readOnly": false创建会话`)
+)
+
+// rowConflictError 乐观检查失败，Columns为与原值不一致的列，Current为数据库中的当前行（已删除时为nil）
+type rowConflictError struct {
+	Columns []string
+	Current providers.Row
+}
+
+func (e *rowConflictError) Error() string {
+	if e.Current == nil {
+		return "数据已被修改: 行不存在或已被删除"
+	}
+	return "数据已被修改: 列 " + strings.Join(e.Columns, ", ") + " 的当前值与原值不一致"
+}
+
+func (e *rowConflictError) Unwrap() error {
+	return errRowConflict
+}
+
+// rowWriteRequest 修改一行数据的请求
+// Key为主键的值；Original为读取时各列的值，用于检查数据在读取后是否被修改；Values为要写入的列
+type rowWriteRequest struct {
+	Key      map[string]interface{} `json:"key"`
+	Original map[string]interface{} `json:"original"`
+	Values   map[string]interface{} `json:"values"`
+}
+
+// rowWriteResult 修改结果
+type rowWriteResult struct {
+	Key          map[string]interface{} // 修改后（删除时为被删除行）的主键值，插入时无法确定主键时为nil
+	Row          providers.Row          // 修改后的整行，删除时为nil
+	RowsAffected int64
+}
+
+// writeTable 被修改表的结构
+type writeTable struct {
+	name        string
+	dbType      string
+	columns     map[string]QueryColumn
+	primaryKeys []string
+}
+
+// quoteIdentifier 按数据库类型转义表名或列名
+func quoteIdentifier(dbType, name string) string {
+	if dbType == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// loadWriteTable 读取表结构，没有主键的表不允许修改
+func loadWriteTable(ctx context.Context, db *sql.DB, dbType, table string) (*writeTable, error) {
+	columns, primaryKeys, err := sqlTableColumns(ctx, db, dbType, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(primaryKeys) == 0 {
+		return nil, fmt.Errorf("%w: 表 %s 没有主键，不支持修改", errBadWriteRequest, table)
+	}
+	t := &writeTable{name: table, dbType: dbType, columns: make(map[string]QueryColumn, len(columns)), primaryKeys: primaryKeys}
+	for _, column := range columns {
+		t.columns[column.Name] = column
+	}
+	return t, nil
+}
+
+// quote 转义列名
+func (t *writeTable) quote(name string) string {
+	return quoteIdentifier(t.dbType, name)
+}
+
+// checkColumns 检查字段都是表中的列，返回按名称排序的列名
+func (t *writeTable) checkColumns(field string, values map[string]interface{}) ([]string, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if _, ok := t.columns[name]; !ok {
+			return nil, fmt.Errorf("%w: %s中的列 %s 不存在", errBadWriteRequest, field, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// checkKey 检查主键值完整且没有多余的列
+func (t *writeTable) checkKey(key map[string]interface{}) error {
+	if len(key) != len(t.primaryKeys) {
+		return fmt.Errorf("%w: key必须且只能包含主键列 %s", errBadWriteRequest, strings.Join(t.primaryKeys, ", "))
+	}
+	for _, pk := range t.primaryKeys {
+		value, ok := key[pk]
+		if !ok {
+			return fmt.Errorf("%w: key必须且只能包含主键列 %s", errBadWriteRequest, strings.Join(t.primaryKeys, ", "))
+		}
+		if value == nil {
+			return fmt.Errorf("%w: 主键列 %s 的值不能为null", errBadWriteRequest, pk)
+		}
+	}
+	return nil
+}
+
+// value 将请求中的值转换为SQL参数
+// JSON数字转换为整数或浮点数，数组和对象序列化为JSON字符串，MySQL日期列的RFC3339字符串转换为时间
+func (t *writeTable) value(column string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case string:
+		if t.dbType == "mysql" && t.columns[column].Type == typesystem.Date {
+			if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return parsed
+			}
+		}
+		return v
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return v
+	}
+}
+
+// keyWhere 生成按主键定位一行的条件
+func (t *writeTable) keyWhere(key map[string]interface{}) (string, []interface{}) {
+	parts := make([]string, len(t.primaryKeys))
+	args := make([]interface{}, len(t.primaryKeys))
+	for i, pk := range t.primaryKeys {
+		parts[i] = t.quote(pk) + " = ?"
+		args[i] = t.value(pk, key[pk])
+	}
+	return strings.Join(parts, " AND "), args
+}
+
+// selectRow 在事务中按主键读取一行，行不存在时返回nil；lock为true时MySQL会锁定该行直到事务结束
+func (t *writeTable) selectRow(ctx context.Context, tx *sql.Tx, key map[string]interface{}, lock bool) (providers.Row, error) {
+	where, args := t.keyWhere(key)
+	query := "SELECT * FROM " + t.quote(t.name) + " WHERE " + where
+	if lock && t.dbType == "mysql" {
+		query += " FOR UPDATE"
+	}
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("读取表 %s 失败: %w", t.name, err)
+	}
+	data, err := providers.CollectRows(rows)
+	rows.Close()
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	return data[0], nil
+}
+
+// sameValue 判断数据库中的当前值与客户端提交的原值是否相同
+// 两者按JSON编码比较，因此原值应使用浏览或查询接口返回的值；数字按数值比较，时间按时刻比较
+func sameValue(current, original interface{}) bool {
+	a, errA := json.Marshal(current)
+	b, errB := json.Marshal(original)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if string(a) == string(b) {
+		return true
+	}
+	if x, ok := new(big.Rat).SetString(string(a)); ok {
+		if y, ok := new(big.Rat).SetString(string(b)); ok {
+			return x.Cmp(y) == 0
+		}
+	}
+	if t, ok := current.(time.Time); ok {
+		if s, ok := original.(string); ok {
+			parsed, err := time.Parse(time.RFC3339Nano, s)
+			return err == nil && parsed.Equal(t)
+		}
+	}
+	return false
+}
+
+// checkOriginal 检查当前行与原值是否一致，不一致时返回rowConflictError
+func checkOriginal(current providers.Row, original map[string]interface{}) error {
+	if current == nil {
+		return &rowConflictError{}
+	}
+	var changed []string
+	for name, value := range original {
+		if !sameValue(current[name], value) {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return &rowConflictError{Columns: changed, Current: current}
+	}
+	return nil
+}
+
+// validate 按操作类型检查请求
+func (t *writeTable) validate(operation string, req *rowWriteRequest) error {
+	if _, err := t.checkColumns("values", req.Values); err != nil {
+		return err
+	}
+	if _, err := t.checkColumns("original", req.Original); err != nil {
+		return err
+	}
+	switch operation {
+	case "insert":
+		if len(req.Values) == 0 {
+			return fmt.Errorf("%w: values不能为空", errBadWriteRequest)
+		}
+		return nil
+	case "update":
+		if len(req.Values) == 0 {
+			return fmt.Errorf("%w: values不能为空", errBadWriteRequest)
+		}
+		// 每个被修改的列都必须提供原值
+		for name := range req.Values {
+			if _, ok := req.Original[name]; !ok {
+				return fmt.Errorf("%w: original缺少被修改列 %s 的原值", errBadWriteRequest, name)
+			}
+		}
+	case "delete":
+		if len(req.Original) == 0 {
+			return fmt.Errorf("%w: original不能为空", errBadWriteRequest)
+		}
+	}
+	return t.checkKey(req.Key)
+}
+
+// applyRowWrite 在事务中修改一行数据
+// 更新和删除前锁定并重新读取该行，与original逐列比较，不一致时放弃修改；审计记录保存成功后才提交事务
+func applyRowWrite(ctx context.Context, db *sql.DB, t *writeTable, operation string, req *rowWriteRequest, entry *session.AuditEntry) (*rowWriteResult, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &rowWriteResult{}
+	table := t.quote(t.name)
+	names, _ := t.checkColumns("values", req.Values)
+
+	var before providers.Row
+	if operation != "insert" {
+		if before, err = t.selectRow(ctx, tx, req.Key, true); err != nil {
+			return nil, err
+		}
+		if err := checkOriginal(before, req.Original); err != nil {
+			return nil, err
+		}
+	}
+
+	var res sql.Result
+	switch operation {
+	case "insert":
+		columns := make([]string, len(names))
+		placeholders := make([]string, len(names))
+		args := make([]interface{}, len(names))
+		for i, name := range names {
+			columns[i] = t.quote(name)
+			placeholders[i] = "?"
+			args[i] = t.value(name, req.Values[name])
+		}
+		res, err = tx.ExecContext(ctx, "INSERT INTO "+table+" ("+strings.Join(columns, ", ")+") VALUES ("+strings.Join(placeholders, ", ")+")", args...)
+		if err == nil {
+			result.Key = t.insertedKey(req.Values, res)
+		}
+	case "update":
+		sets := make([]string, len(names))
+		args := make([]interface{}, 0, len(names)+len(t.primaryKeys))
+		for i, name := range names {
+			sets[i] = t.quote(name) + " = ?"
+			args = append(args, t.value(name, req.Values[name]))
+		}
+		where, keyArgs := t.keyWhere(req.Key)
+		res, err = tx.ExecContext(ctx, "UPDATE "+table+" SET "+strings.Join(sets, ", ")+" WHERE "+where, append(args, keyArgs...)...)
+		if err == nil {
+			// 修改了主键时按新的主键读取修改后的行
+			result.Key = make(map[string]interface{}, len(t.primaryKeys))
+			for _, pk := range t.primaryKeys {
+				result.Key[pk] = req.Key[pk]
+				if value, ok := req.Values[pk]; ok {
+					result.Key[pk] = value
+				}
+			}
+		}
+	case "delete":
+		where, args := t.keyWhere(req.Key)
+		res, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE "+where, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("修改表 %s 失败: %w", t.name, err)
+	}
+	if result.RowsAffected, err = res.RowsAffected(); err != nil {
+		return nil, err
+	}
+
+	if operation == "delete" {
+		result.Key = req.Key
+	} else if result.Key != nil {
+		if result.Row, err = t.selectRow(ctx, tx, result.Key, false); err != nil {
+			return nil, err
+		}
+	}
+
+	entry.Key = t.auditKey(result.Key)
+	if operation == "update" {
+		entry.Key = t.auditKey(req.Key)
+	}
+	entry.Before = before
+	entry.After = result.Row
+	entry.RowsAffected = result.RowsAffected
+	if err := auditLog.Record(ctx, entry); err != nil {
+		return nil, fmt.Errorf("%w: %v", errAuditFailed, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return result, nil
+}
+
+// insertedKey 返回插入行的主键，单列整数主键未指定时使用自增ID，无法确定时返回nil
+func (t *writeTable) insertedKey(values map[string]interface{}, res sql.Result) map[string]interface{} {
+	key := make(map[string]interface{}, len(t.primaryKeys))
+	for _, pk := range t.primaryKeys {
+		if value, ok := values[pk]; ok && value != nil {
+			key[pk] = value
+		}
+	}
+	if len(key) == len(t.primaryKeys) {
+		return key
+	}
+	if len(t.primaryKeys) == 1 && t.columns[t.primaryKeys[0]].Type == typesystem.Int {
+		if id, err := res.LastInsertId(); err == nil {
+			return map[string]interface{}{t.primaryKeys[0]: id}
+		}
+	}
+	return nil
+}
+
+// auditKey 返回写入审计记录的主键值
+func (t *writeTable) auditKey(key map[string]interface{}) map[string]interface{} {
+	if key == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(key))
+	for name, value := range key {
+		result[name] = t.value(name, value)
+	}
+	return result
+}
+
+// decodeRowWriteRequest 解析请求体，数字保留为json.Number以免大整数丢失精度
+func decodeRowWriteRequest(c *gin.Context) (*rowWriteRequest, error) {
+	var req rowWriteRequest
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		return nil, fmt.Errorf("无效的请求数据: %w", err)
+	}
+	return &req, nil
+}
+
+// InsertEntityRow 向会话中的表插入一行
+func (h *SessionHandler) InsertEntityRow(c *gin.Context) {
+	h.writeEntityRow(c, "insert")
+}
+
+// UpdateEntityRow 按主键更新会话中表的一行，original中的列必须与数据库中的当前值一致
+func (h *SessionHandler) UpdateEntityRow(c *gin.Context) {
+	h.writeEntityRow(c, "update")
+}
+
+// DeleteEntityRow 按主键删除会话中表的一行，original中的列必须与数据库中的当前值一致
+func (h *SessionHandler) DeleteEntityRow(c *gin.Context) {
+	h.writeEntityRow(c, "delete")
+}
+
+// writeEntityRow 在可写会话上修改MySQL/SQLite表的一行，并记录审计日志
+func (h *SessionHandler) writeEntityRow(c *gin.Context, operation string) {
+	sessionID := c.Param("sessionId")
+	table := c.Param("name")
+	state, exists := sessionManager.GetSession(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": errSessionNotFound.Error()})
+		return
+	}
+	if state.Info.Type != "mysql" && state.Info.Type != "sqlite" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": state.Info.Type + " 会话不支持修改数据"})
+		return
+	}
+	if state.Info.ReadOnly {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": errReadOnlySession.Error()})
+		return
+	}
+	req, err := decodeRowWriteRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	conn, err := sessionManager.Connection(sessionID)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"success": false, "error": "连接数据库失败: " + err.Error()})
+		return
+	}
+	if conn.ReadOnly {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": errReadOnlySession.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), writeTimeout)
+	defer cancel()
+
+	t, err := loadWriteTable(ctx, conn.DB, state.Info.Type, table)
+	if err == nil {
+		err = t.validate(operation, req)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errBadWriteRequest) {
+			status = http.StatusBadRequest
+		} else if errors.Is(err, errEntityNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	entry := &session.AuditEntry{
+		SessionID: sessionID,
+		Type:      state.Info.Type,
+		Database:  state.Info.Database,
+		FilePath:  state.Info.FilePath,
+		Table:     table,
+		Operation: operation,
+		ClientIP:  c.ClientIP(),
+	}
+	result, err := applyRowWrite(ctx, conn.DB, t, operation, req, entry)
+	if err != nil {
+		// 被拒绝或失败的修改同样记录，审计日志本身不可用时除外
+		if !errors.Is(err, errAuditFailed) {
+			failed := *entry
+			failed.ID = ""
+			failed.Key = t.auditKey(req.Key)
+			failed.Error = err.Error()
+			var conflict *rowConflictError
+			if errors.As(err, &conflict) {
+				failed.Before = conflict.Current
+			}
+			recordCtx, recordCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if recordErr := auditLog.Record(recordCtx, &failed); recordErr != nil {
+				log.Printf("记录会话 %s 的审计日志失败: %v", sessionID, recordErr)
+			}
+			recordCancel()
+		}
+
+		var conflict *rowConflictError
+		if errors.As(err, &conflict) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success":   false,
+				"error":     err.Error(),
+				"conflicts": conflict.Columns,
+				"current":   conflict.Current,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	recordRowWrite(c, state, sessionID, operation, entry)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"operation":    operation,
+		"entity":       table,
+		"key":          result.Key,
+		"row":          result.Row,
+		"rowsAffected": result.RowsAffected,
+		"auditId":      entry.ID,
+	})
+}
+
+// rowWriteActions 会话中的修改操作对应的审计日志操作
+var rowWriteActions = map[string]string{
+	"insert": audit.ActionCreate,
+	"update": audit.ActionUpdate,
+	"delete": audit.ActionDelete,
+}
+
+// recordRowWrite 将通过会话成功修改的行同时写入审计日志，记录操作者和请求ID
+// model为表名，recordId为主键值，复合主键为按列名排序的JSON对象
+func recordRowWrite(c *gin.Context, state *SessionState, sessionID, operation string, entry *session.AuditEntry) {
+	if changeLog == nil {
+		return
+	}
+	record := &audit.Entry{
+		Action: rowWriteActions[operation],
+		Model:  entry.Table,
+		Details: map[string]interface{}{
+			"sourceType":     state.Info.Type,
+			"sessionId":      sessionID,
+			"sessionAuditId": entry.ID,
+			"rowsAffected":   entry.RowsAffected,
+		},
+	}
+	if entry.Key != nil {
+		record.RecordID = rowRecordID(entry.Key)
+	}
+	if entry.Before != nil {
+		record.Before = entry.Before
+	}
+	if entry.After != nil {
+		record.After = entry.After
+	}
+	if operation == "update" {
+		record.Changes = audit.Diff(entry.Before, entry.After)
+	}
+	if state.Info.Database != "" {
+		record.Details["database"] = state.Info.Database
+	}
+	if state.Info.FilePath != "" {
+		record.Details["filePath"] = state.Info.FilePath
+	}
+	audit.Record(c, changeLog, record)
+}
+
+// rowRecordID 审计记录中的记录ID：单列主键为主键值，复合主键为JSON对象
+func rowRecordID(key map[string]interface{}) string {
+	if len(key) == 1 {
+		for _, value := range key {
+			return fmt.Sprint(value)
+		}
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Sprint(key)
+	}
+	return string(data)
+}
+
+// GetSessionAudit 返回通过会话修改数据的记录，按时间倒序
+// 审计记录不随会话过期删除，会话关闭后仍可查询
+func (h *SessionHandler) GetSessionAudit(c *gin.Context) {
+	limit := DefaultAuditLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit必须为正整数"})
+			return
+		}
+		limit = parsed
+	}
+	if limit > MaxAuditLimit {
+		limit = MaxAuditLimit
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	entries, err := auditLog.List(ctx, c.Param("sessionId"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取审计日志失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "entries": entries, "count": len(entries)})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"minds_iolite_backend/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageHandler 限额和用量处理器
+type UsageHandler struct {
+	limiter *quota.Limiter
+}
+
+// NewUsageHandler 创建新的用量处理器
+func NewUsageHandler(limiter *quota.Limiter) *UsageHandler {
+	return &UsageHandler{limiter: limiter}
+}
+
+// GetUsage 返回当前用户和所用API密钥的限额和用量
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	usage, err := h.limiter.Usage(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取用量失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "usage": usage})
+}
@@ -0,0 +1,418 @@
+package queries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/database"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 在MongoDB中保存查询历史和已保存查询的集合名称
+const (
+	HistoryCollectionName = "query_history"
+	SavedCollectionName   = "saved_queries"
+)
+
+func init() {
+	database.ReserveCollections(HistoryCollectionName, SavedCollectionName)
+}
+
+// maxMemoryHistoryEntries 进程内查询历史保留的最大记录数，超出后丢弃最早的记录
+const maxMemoryHistoryEntries = 10000
+
+var (
+	// ErrNotFound 已保存的查询不存在或不属于当前用户
+	ErrNotFound = errors.New("查询不存在")
+	// ErrDuplicateName 同一用户已有同名的查询
+	ErrDuplicateName = errors.New("已存在同名的查询")
+)
+
+// Statement 查询语句
+// MySQL/SQLite使用SQL和Params；MongoDB使用Collection加Filter/Projection/Sort（find）或Pipeline（aggregate）
+type Statement struct {
+	SQL    string        `json:"sql,omitempty" bson:"sql,omitempty"`
+	Params []interface{} `json:"params,omitempty" bson:"params,omitempty"` // SQL占位符参数
+
+	Collection string          `json:"collection,omitempty" bson:"collection,omitempty"`
+	Filter     json.RawMessage `json:"filter,omitempty" bson:"filter,omitempty"` // 扩展JSON
+	Projection json.RawMessage `json:"projection,omitempty" bson:"projection,omitempty"`
+	Sort       json.RawMessage `json:"sort,omitempty" bson:"sort,omitempty"`
+	Pipeline   json.RawMessage `json:"pipeline,omitempty" bson:"pipeline,omitempty"`
+}
+
+// HistoryEntry 一次通过会话执行的查询，执行失败的查询同样会被记录
+type HistoryEntry struct {
+	ID           string    `json:"id" bson:"_id"`
+	UserID       string    `json:"userId" bson:"userId"`
+	SessionID    string    `json:"sessionId" bson:"sessionId"`
+	Source       string    `json:"source" bson:"source"` // 不含凭据的数据源标识
+	Type         string    `json:"type" bson:"type"`     // 数据源类型
+	SavedQueryID string    `json:"savedQueryId,omitempty" bson:"savedQueryId,omitempty"`
+	Statement    Statement `json:"statement" bson:"statement"`
+	Time         time.Time `json:"time" bson:"time"`
+	DurationMs   int64     `json:"durationMs" bson:"durationMs"`
+	RowCount     int       `json:"rowCount" bson:"rowCount"`
+	Error        string    `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// HistoryFilter 查询历史的筛选条件，UserID必填，其余为空时不筛选
+type HistoryFilter struct {
+	UserID    string
+	SessionID string
+	Source    string
+}
+
+// matches 记录是否满足筛选条件
+func (f HistoryFilter) matches(entry *HistoryEntry) bool {
+	return entry.UserID == f.UserID &&
+		(f.SessionID == "" || entry.SessionID == f.SessionID) &&
+		(f.Source == "" || entry.Source == f.Source)
+}
+
+// SavedQuery 用户保存的命名查询，可以在任意同类型数据源的会话上重新执行
+type SavedQuery struct {
+	ID          string    `json:"id" bson:"_id"`
+	UserID      string    `json:"userId" bson:"userId"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	Type        string    `json:"type" bson:"type"` // 适用的数据源类型
+	Statement   Statement `json:"statement" bson:"statement"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Store 保存查询历史和已保存的查询，所有操作都限定在单个用户内
+type Store interface {
+	// RecordHistory 保存一条查询历史，未设置ID和时间时自动生成
+	RecordHistory(ctx context.Context, entry *HistoryEntry) error
+	// ListHistory 按时间倒序返回最近的查询历史
+	ListHistory(ctx context.Context, filter HistoryFilter, limit int) ([]*HistoryEntry, error)
+	// ClearHistory 删除用户的全部查询历史，返回删除的记录数
+	ClearHistory(ctx context.Context, userID string) (int64, error)
+
+	// CreateSaved 保存新查询，同名时返回ErrDuplicateName
+	CreateSaved(ctx context.Context, query *SavedQuery) error
+	// UpdateSaved 覆盖已有查询，不存在时返回ErrNotFound，与其他查询同名时返回ErrDuplicateName
+	UpdateSaved(ctx context.Context, query *SavedQuery) error
+	// GetSaved 读取查询，不存在时返回ErrNotFound
+	GetSaved(ctx context.Context, userID, id string) (*SavedQuery, error)
+	// ListSaved 按名称返回用户的查询，dbType不为空时只返回该类型的查询
+	ListSaved(ctx context.Context, userID, dbType string) ([]*SavedQuery, error)
+	// DeleteSaved 删除查询，不存在时返回ErrNotFound
+	DeleteSaved(ctx context.Context, userID, id string) error
+}
+
+// prepareHistoryEntry 填充记录的ID和时间
+func prepareHistoryEntry(entry *HistoryEntry) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+}
+
+// prepareSavedQuery 为新查询填充ID和时间
+func prepareSavedQuery(query *SavedQuery) {
+	if query.ID == "" {
+		query.ID = uuid.New().String()
+	}
+	now := time.Now()
+	if query.CreatedAt.IsZero() {
+		query.CreatedAt = now
+	}
+	query.UpdatedAt = now
+}
+
+// MemoryStore 进程内的查询存储，用于未配置MongoDB时，服务重启后记录会丢失
+type MemoryStore struct {
+	history []*HistoryEntry
+	saved   map[string]*SavedQuery
+	mutex   sync.Mutex
+}
+
+// NewMemoryStore 创建进程内查询存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{saved: make(map[string]*SavedQuery)}
+}
+
+// RecordHistory 保存一条查询历史
+func (s *MemoryStore) RecordHistory(ctx context.Context, entry *HistoryEntry) error {
+	prepareHistoryEntry(entry)
+	snapshot := *entry
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.history = append(s.history, &snapshot)
+	if len(s.history) > maxMemoryHistoryEntries {
+		s.history = append([]*HistoryEntry(nil), s.history[len(s.history)-maxMemoryHistoryEntries:]...)
+	}
+	return nil
+}
+
+// ListHistory 按时间倒序返回最近的查询历史
+func (s *MemoryStore) ListHistory(ctx context.Context, filter HistoryFilter, limit int) ([]*HistoryEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := []*HistoryEntry{}
+	for i := len(s.history) - 1; i >= 0 && len(result) < limit; i-- {
+		if filter.matches(s.history[i]) {
+			snapshot := *s.history[i]
+			result = append(result, &snapshot)
+		}
+	}
+	return result, nil
+}
+
+// ClearHistory 删除用户的全部查询历史
+func (s *MemoryStore) ClearHistory(ctx context.Context, userID string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	kept := make([]*HistoryEntry, 0, len(s.history))
+	for _, entry := range s.history {
+		if entry.UserID != userID {
+			kept = append(kept, entry)
+		}
+	}
+	deleted := int64(len(s.history) - len(kept))
+	s.history = kept
+	return deleted, nil
+}
+
+// CreateSaved 保存新查询
+func (s *MemoryStore) CreateSaved(ctx context.Context, query *SavedQuery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.nameTaken(query.UserID, query.Name, "") {
+		return ErrDuplicateName
+	}
+	prepareSavedQuery(query)
+	snapshot := *query
+	s.saved[query.ID] = &snapshot
+	return nil
+}
+
+// UpdateSaved 覆盖已有查询
+func (s *MemoryStore) UpdateSaved(ctx context.Context, query *SavedQuery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, exists := s.saved[query.ID]
+	if !exists || existing.UserID != query.UserID {
+		return ErrNotFound
+	}
+	if s.nameTaken(query.UserID, query.Name, query.ID) {
+		return ErrDuplicateName
+	}
+	query.CreatedAt = existing.CreatedAt
+	query.UpdatedAt = time.Now()
+	snapshot := *query
+	s.saved[query.ID] = &snapshot
+	return nil
+}
+
+// nameTaken 用户是否已有同名的其他查询，调用方需持有s.mutex
+func (s *MemoryStore) nameTaken(userID, name, exceptID string) bool {
+	for id, query := range s.saved {
+		if id != exceptID && query.UserID == userID && query.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSaved 读取查询
+func (s *MemoryStore) GetSaved(ctx context.Context, userID, id string) (*SavedQuery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query, exists := s.saved[id]
+	if !exists || query.UserID != userID {
+		return nil, ErrNotFound
+	}
+	snapshot := *query
+	return &snapshot, nil
+}
+
+// ListSaved 按名称返回用户的查询
+func (s *MemoryStore) ListSaved(ctx context.Context, userID, dbType string) ([]*SavedQuery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := []*SavedQuery{}
+	for _, query := range s.saved {
+		if query.UserID == userID && (dbType == "" || query.Type == dbType) {
+			snapshot := *query
+			result = append(result, &snapshot)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// DeleteSaved 删除查询
+func (s *MemoryStore) DeleteSaved(ctx context.Context, userID, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query, exists := s.saved[id]
+	if !exists || query.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.saved, id)
+	return nil
+}
+
+// MongoStore 基于MongoDB的查询存储，多个服务实例可以共享
+type MongoStore struct {
+	history *mongo.Collection
+	saved   *mongo.Collection
+}
+
+// NewMongoStore 创建MongoDB查询存储并确保索引存在
+// 查询历史按用户和时间查询；已保存的查询在同一用户内名称唯一
+func NewMongoStore(ctx context.Context, history, saved *mongo.Collection) (*MongoStore, error) {
+	_, err := history.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "time", Value: -1}},
+		Options: options.Index().SetName("userId_time"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建查询历史索引失败: %w", err)
+	}
+	_, err = saved.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetName("userId_name").SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建已保存查询索引失败: %w", err)
+	}
+	return &MongoStore{history: history, saved: saved}, nil
+}
+
+// RecordHistory 保存一条查询历史
+func (s *MongoStore) RecordHistory(ctx context.Context, entry *HistoryEntry) error {
+	prepareHistoryEntry(entry)
+	_, err := s.history.InsertOne(ctx, entry)
+	return err
+}
+
+// ListHistory 按时间倒序返回最近的查询历史
+func (s *MongoStore) ListHistory(ctx context.Context, filter HistoryFilter, limit int) ([]*HistoryEntry, error) {
+	query := bson.M{"userId": filter.UserID}
+	if filter.SessionID != "" {
+		query["sessionId"] = filter.SessionID
+	}
+	if filter.Source != "" {
+		query["source"] = filter.Source
+	}
+	cursor, err := s.history.Find(ctx, query, options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*HistoryEntry{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ClearHistory 删除用户的全部查询历史
+func (s *MongoStore) ClearHistory(ctx context.Context, userID string) (int64, error) {
+	result, err := s.history.DeleteMany(ctx, bson.M{"userId": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// CreateSaved 保存新查询
+func (s *MongoStore) CreateSaved(ctx context.Context, query *SavedQuery) error {
+	prepareSavedQuery(query)
+	_, err := s.saved.InsertOne(ctx, query)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateName
+	}
+	return err
+}
+
+// UpdateSaved 覆盖已有查询
+func (s *MongoStore) UpdateSaved(ctx context.Context, query *SavedQuery) error {
+	existing, err := s.GetSaved(ctx, query.UserID, query.ID)
+	if err != nil {
+		return err
+	}
+	query.CreatedAt = existing.CreatedAt
+	query.UpdatedAt = time.Now()
+	result, err := s.saved.ReplaceOne(ctx, bson.M{"_id": query.ID, "userId": query.UserID}, query)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateName
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetSaved 读取查询
+func (s *MongoStore) GetSaved(ctx context.Context, userID, id string) (*SavedQuery, error) {
+	var query SavedQuery
+	err := s.saved.FindOne(ctx, bson.M{"_id": id, "userId": userID}).Decode(&query)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &query, nil
+}
+
+// ListSaved 按名称返回用户的查询
+func (s *MongoStore) ListSaved(ctx context.Context, userID, dbType string) ([]*SavedQuery, error) {
+	filter := bson.M{"userId": userID}
+	if dbType != "" {
+		filter["type"] = dbType
+	}
+	cursor, err := s.saved.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*SavedQuery{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteSaved 删除查询
+func (s *MongoStore) DeleteSaved(ctx context.Context, userID, id string) error {
+	result, err := s.saved.DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 计数对象的类型
+const (
+	KindUser   = "user"   // 登录用户，包括通过API密钥发出的请求
+	KindAPIKey = "apiKey" // API密钥
+	KindIP     = "ip"     // 未登录请求的客户端IP
+)
+
+// 超出限额时响应中的limit字段
+const (
+	LimitRequestsPerMinute = "requestsPerMinute"
+	LimitConcurrentImports = "concurrentImports"
+	LimitUploadBytesPerDay = "uploadBytesPerDay"
+)
+
+// importRetryAfter 同时进行的导入达到上限时建议客户端等待的时间
+const importRetryAfter = 10 * time.Second
+
+// importLease 导入占用的槽位的有效期，导入进行期间每importRenewInterval续期一次
+// 进程崩溃时槽位最迟在有效期结束后释放
+const (
+	importLease         = time.Minute
+	importRenewInterval = 20 * time.Second
+)
+
+// Defaults 用户和API密钥没有单独设置限额时使用的默认值，0表示不限制
+type Defaults struct {
+	User   auth.Limits // 用户的默认限额，也按客户端IP用于未登录的请求
+	APIKey auth.Limits // API密钥的默认限额，通过密钥发出的请求同时计入所属用户的用量
+}
+
+// subject 一个计数对象：用户、API密钥或未登录请求的客户端IP，以及生效的限额
+type subject struct {
+	kind   string
+	id     string
+	limits auth.Limits
+}
+
+// key 同时进行的导入数的槽位键
+func (s subject) key() string {
+	return s.kind + ":" + s.id
+}
+
+// counterID 时间窗口内用量计数器的ID
+func (s subject) counterID(name string, window time.Time) string {
+	return s.key() + ":" + name + ":" + strconv.FormatInt(window.Unix(), 10)
+}
+
+// describe 错误信息中的计数对象名称
+func (s subject) describe() string {
+	switch s.kind {
+	case KindAPIKey:
+		return "API密钥"
+	case KindIP:
+		return "未登录的客户端"
+	default:
+		return "用户"
+	}
+}
+
+// Usage 一个计数对象的限额和当前用量
+type Usage struct {
+	Kind               string      `json:"kind"`
+	ID                 string      `json:"id"`
+	Limits             auth.Limits `json:"limits"` // 生效的限额，未返回的项不限制
+	RequestsThisMinute int64       `json:"requestsThisMinute"`
+	RunningImports     int         `json:"runningImports"`
+	UploadedBytesToday int64       `json:"uploadedBytesToday"` // 当天(UTC)上传的请求体字节数
+}
+
+// Limiter 按用户、API密钥和客户端IP限制请求频率、同时进行的导入数和每天上传的字节数
+// 请求数、上传字节数和导入占用的槽位都保存在Store中，多个实例共享限额，服务重启后仍然有效
+type Limiter struct {
+	store    Store
+	defaults Defaults
+}
+
+// NewLimiter 创建限额检查器，store为nil时使用进程内计数器
+func NewLimiter(store Store, defaults Defaults) *Limiter {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Limiter{store: store, defaults: defaults}
+}
+
+// subjects 返回请求的计数对象：登录用户和使用的API密钥，未登录时为客户端IP
+func (l *Limiter) subjects(c *gin.Context) []subject {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		return []subject{{kind: KindIP, id: c.ClientIP(), limits: l.defaults.User}}
+	}
+	subjects := []subject{{kind: KindUser, id: user.ID, limits: user.Limits.Merge(l.defaults.User)}}
+	if key, ok := auth.CurrentAPIKey(c); ok {
+		subjects = append(subjects, subject{kind: KindAPIKey, id: key.ID, limits: key.Limits.Merge(l.defaults.APIKey)})
+	}
+	return subjects
+}
+
+// Middleware 返回检查请求频率和上传字节数的中间件，只检查路径以prefixes之一开头的请求
+// 必须注册在认证中间件之后
+func (l *Limiter) Middleware(prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !covered(c.Request.URL.Path, prefixes) || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		subjects := l.subjects(c)
+		if !l.limitRequests(c, subjects, now) || !l.checkUpload(c, subjects, now) {
+			return
+		}
+		body := l.countUpload(c, subjects)
+		c.Next()
+		if body != nil {
+			l.recordUpload(subjects, body.n, now)
+		}
+	}
+}
+
+// LimitImports 返回限制同时进行的导入和复制数的中间件，用于导入和复制路由
+// 每个导入在Store中占用一个槽位，导入结束时释放；读写槽位失败时记录日志并放行请求
+func (l *Limiter) LimitImports() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		holder := uuid.New().String()
+		slots, ok := l.acquireImport(c, l.subjects(c), holder)
+		if !ok {
+			return
+		}
+		if len(slots) == 0 {
+			c.Next()
+			return
+		}
+
+		done := make(chan struct{})
+		go l.renewImport(slots, holder, done)
+		defer func() {
+			close(done)
+			l.releaseImport(slots, holder)
+		}()
+		c.Next()
+	}
+}
+
+// acquireImport 为每个设置了限额的计数对象占用一个槽位，任一计数对象的槽位已满时释放已占用的槽位并返回429
+func (l *Limiter) acquireImport(c *gin.Context, subjects []subject, holder string) ([]string, bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	slots := []string{}
+	for _, s := range subjects {
+		limit := s.limits.ConcurrentImports
+		if limit <= 0 {
+			continue
+		}
+		slot, err := l.store.Acquire(ctx, s.key(), limit, holder, time.Now().Add(importLease))
+		if err != nil {
+			log.Printf("占用%s %s的导入槽位失败: %v", s.describe(), s.id, err)
+			continue
+		}
+		if slot == "" {
+			l.releaseImport(slots, holder)
+			abortThrottled(c, importRetryAfter, LimitConcurrentImports,
+				fmt.Sprintf("同时进行的导入已达上限，%s最多同时进行%d个导入", s.describe(), limit))
+			return nil, false
+		}
+		slots = append(slots, slot)
+	}
+	return slots, true
+}
+
+// renewImport 导入进行期间定期延长槽位，直到done关闭
+func (l *Limiter) renewImport(slots []string, holder string, done <-chan struct{}) {
+	ticker := time.NewTicker(importRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			for _, slot := range slots {
+				if err := l.store.Renew(ctx, slot, holder, time.Now().Add(importLease)); err != nil {
+					log.Printf("延长导入槽位 %s 失败: %v", slot, err)
+				}
+			}
+			cancel()
+		}
+	}
+}
+
+// releaseImport 释放导入占用的槽位，请求已取消时仍然执行
+func (l *Limiter) releaseImport(slots []string, holder string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, slot := range slots {
+		if err := l.store.Release(ctx, slot, holder); err != nil {
+			log.Printf("释放导入槽位 %s 失败，槽位将在%s后自动释放: %v", slot, importLease, err)
+		}
+	}
+}
+
+// Usage 返回当前请求的计数对象的限额和用量
+func (l *Limiter) Usage(c *gin.Context) ([]Usage, error) {
+	now := time.Now()
+	minute, day := now.Truncate(time.Minute), now.UTC().Truncate(24*time.Hour)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	result := []Usage{}
+	for _, s := range l.subjects(c) {
+		requests, err := l.store.Get(ctx, s.counterID("requests", minute))
+		if err != nil {
+			return nil, err
+		}
+		uploaded, err := l.store.Get(ctx, s.counterID("upload", day))
+		if err != nil {
+			return nil, err
+		}
+		running, err := l.store.Occupied(ctx, s.key())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Usage{
+			Kind:               s.kind,
+			ID:                 s.id,
+			Limits:             s.limits,
+			RequestsThisMinute: requests,
+			RunningImports:     running,
+			UploadedBytesToday: uploaded,
+		})
+	}
+	return result, nil
+}
+
+// limitRequests 增加本分钟的请求数，超出任一计数对象的限额时返回429
+// 计数器读写失败时记录日志并放行请求
+func (l *Limiter) limitRequests(c *gin.Context, subjects []subject, now time.Time) bool {
+	minute := now.Truncate(time.Minute)
+	reset := minute.Add(time.Minute)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	lowest := int64(-1)
+	for _, s := range subjects {
+		limit := int64(s.limits.RequestsPerMinute)
+		if limit <= 0 {
+			continue
+		}
+		count, err := l.store.Add(ctx, s.counterID("requests", minute), 1, reset.Add(time.Minute))
+		if err != nil {
+			log.Printf("更新%s %s的请求计数失败: %v", s.describe(), s.id, err)
+			continue
+		}
+		// 响应头返回剩余请求数最少的限额
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		if lowest < 0 || remaining < lowest {
+			lowest = remaining
+			c.Header("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+			c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+			c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		}
+		if count > limit {
+			abortThrottled(c, reset.Sub(now), LimitRequestsPerMinute,
+				fmt.Sprintf("请求过于频繁，%s每分钟最多%d个请求", s.describe(), limit))
+			return false
+		}
+	}
+	return true
+}
+
+// checkUpload 当天上传的字节数已达上限，或请求体会超出上限时返回429
+func (l *Limiter) checkUpload(c *gin.Context, subjects []subject, now time.Time) bool {
+	if !hasBody(c.Request) {
+		return true
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	for _, s := range subjects {
+		limit := s.limits.UploadBytesPerDay
+		if limit <= 0 {
+			continue
+		}
+		used, err := l.store.Get(ctx, s.counterID("upload", day))
+		if err != nil {
+			log.Printf("读取%s %s的上传用量失败: %v", s.describe(), s.id, err)
+			continue
+		}
+		if used >= limit || (c.Request.ContentLength > 0 && used+c.Request.ContentLength > limit) {
+			abortThrottled(c, day.Add(24*time.Hour).Sub(now), LimitUploadBytesPerDay,
+				fmt.Sprintf("今天的上传量已达上限，%s每天最多上传%d字节，已上传%d字节", s.describe(), limit, used))
+			return false
+		}
+	}
+	return true
+}
+
+// countingBody 统计处理器实际读取的请求体字节数
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read 读取请求体并累计字节数
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// countUpload 有计数对象限制上传量时统计请求体字节数，否则返回nil
+// 长度未知的请求体读取完成后才计入用量，超出的部分在之后的请求中生效
+func (l *Limiter) countUpload(c *gin.Context, subjects []subject) *countingBody {
+	if !hasBody(c.Request) {
+		return nil
+	}
+	for _, s := range subjects {
+		if s.limits.UploadBytesPerDay > 0 {
+			body := &countingBody{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+			return body
+		}
+	}
+	return nil
+}
+
+// recordUpload 将请求实际读取的字节数计入当天的上传用量
+func (l *Limiter) recordUpload(subjects []subject, n int64, now time.Time) {
+	if n <= 0 {
+		return
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	// 请求已经处理完成，客户端断开也要计入用量
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, s := range subjects {
+		if s.limits.UploadBytesPerDay <= 0 {
+			continue
+		}
+		if _, err := l.store.Add(ctx, s.counterID("upload", day), n, day.Add(48*time.Hour)); err != nil {
+			log.Printf("更新%s %s的上传用量失败: %v", s.describe(), s.id, err)
+		}
+	}
+}
+
+// abortThrottled 返回429，Retry-After为建议等待的秒数
+func abortThrottled(c *gin.Context, retryAfter time.Duration, limit, message string) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"success":    false,
+		"error":      message,
+		"limit":      limit,
+		"retryAfter": seconds,
+	})
+}
+
+// hasBody 请求是否带有请求体
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// covered 路径是否以某个前缀开头（按路径段匹配，/apis不匹配/api）
+func covered(requestPath string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
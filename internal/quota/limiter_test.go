@@ -0,0 +1,274 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+
+	if got, _ := store.Get(ctx, "missing"); got != 0 {
+		t.Errorf("不存在的计数器 = %d, 期望 0", got)
+	}
+	for i, want := range []int64{1, 3} {
+		got, err := store.Add(ctx, "active", int64(i+1), now.Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("第%d次Add() = %d, 期望 %d", i+1, got, want)
+		}
+	}
+	if got, _ := store.Get(ctx, "active"); got != 3 {
+		t.Errorf("Get() = %d, 期望 3", got)
+	}
+
+	if _, err := store.Add(ctx, "expired", 5, now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Get(ctx, "expired"); got != 0 {
+		t.Errorf("已过期的计数器 = %d, 期望 0", got)
+	}
+	// 再次增加时过期的计数器已被删除，从0开始计数
+	if got, _ := store.Add(ctx, "expired", 1, now.Add(time.Minute)); got != 1 {
+		t.Errorf("过期后Add() = %d, 期望 1", got)
+	}
+}
+
+func TestLimitRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 计数器的过期时间按当前时间计算，窗口必须从当前分钟开始
+	minute := time.Now().Truncate(time.Minute)
+	user := subject{kind: KindUser, id: "user-1", limits: auth.Limits{RequestsPerMinute: 2}}
+	other := subject{kind: KindUser, id: "user-2", limits: auth.Limits{RequestsPerMinute: 2}}
+	unlimited := subject{kind: KindAPIKey, id: "key-1"}
+	limiter := NewLimiter(nil, Defaults{})
+
+	tests := []struct {
+		name          string
+		subjects      []subject
+		now           time.Time
+		want          bool
+		wantRemaining string
+	}{
+		{"第一个请求", []subject{user}, minute.Add(10 * time.Second), true, "1"},
+		{"达到限额", []subject{user}, minute.Add(20 * time.Second), true, "0"},
+		{"超出限额", []subject{user}, minute.Add(30 * time.Second), false, "0"},
+		{"其他用户单独计数", []subject{other}, minute.Add(30 * time.Second), true, "1"},
+		{"不限制的计数对象", []subject{unlimited}, minute.Add(30 * time.Second), true, ""},
+		{"任一计数对象超出限额", []subject{unlimited, user}, minute.Add(40 * time.Second), false, "0"},
+		{"下一分钟重新计数", []subject{user}, minute.Add(time.Minute), true, "1"},
+		{"下一分钟达到限额", []subject{user}, minute.Add(time.Minute + 59*time.Second), true, "0"},
+		{"下一分钟超出限额", []subject{user}, minute.Add(time.Minute + 59*time.Second), false, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/test", nil)
+
+			if got := limiter.limitRequests(c, tt.subjects, tt.now); got != tt.want {
+				t.Fatalf("limitRequests() = %v, 期望 %v", got, tt.want)
+			}
+			if got := w.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("X-RateLimit-Remaining = %q, 期望 %q", got, tt.wantRemaining)
+			}
+			if tt.want {
+				return
+			}
+			if w.Code != http.StatusTooManyRequests {
+				t.Errorf("状态码 = %d, 期望 %d", w.Code, http.StatusTooManyRequests)
+			}
+			reset := tt.now.Truncate(time.Minute).Add(time.Minute)
+			wantRetry := strconv.FormatInt(int64(reset.Sub(tt.now).Seconds()), 10)
+			if got := w.Header().Get("Retry-After"); got != wantRetry {
+				t.Errorf("Retry-After = %q, 期望 %q", got, wantRetry)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewLimiter(nil, Defaults{
+		User:   auth.Limits{RequestsPerMinute: 2},
+		APIKey: auth.Limits{RequestsPerMinute: 1},
+	})
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-User"); id != "" {
+			c.Set(auth.ContextUserKey, &auth.User{ID: id})
+		}
+		if id := c.GetHeader("X-Test-Key"); id != "" {
+			c.Set(auth.ContextAPIKeyKey, &auth.APIKey{ID: id})
+		}
+		c.Next()
+	})
+	router.Use(limiter.Middleware("/api"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/data", ok)
+	router.GET("/health", ok)
+
+	tests := []struct {
+		name   string
+		path   string
+		ip     string
+		userID string
+		keyID  string
+		want   int
+	}{
+		{"未登录的第一个请求", "/api/data", "10.0.0.1", "", "", http.StatusOK},
+		{"未登录的第二个请求", "/api/data", "10.0.0.1", "", "", http.StatusOK},
+		{"未登录超出限额", "/api/data", "10.0.0.1", "", "", http.StatusTooManyRequests},
+		{"其他客户端IP单独计数", "/api/data", "10.0.0.2", "", "", http.StatusOK},
+		{"不检查的路径", "/health", "10.0.0.1", "", "", http.StatusOK},
+		{"登录用户按用户计数", "/api/data", "10.0.0.1", "alice", "", http.StatusOK},
+		{"API密钥使用自己的限额", "/api/data", "10.0.0.3", "bob", "key-1", http.StatusOK},
+		{"API密钥超出限额", "/api/data", "10.0.0.3", "bob", "key-1", http.StatusTooManyRequests},
+		{"用户超出限额时其他密钥也受限", "/api/data", "10.0.0.3", "bob", "key-2", http.StatusTooManyRequests},
+		{"密钥的请求计入用户的用量", "/api/data", "10.0.0.3", "bob", "", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.ip + ":1234"
+			if tt.userID != "" {
+				req.Header.Set("X-Test-User", tt.userID)
+			}
+			if tt.keyID != "" {
+				req.Header.Set("X-Test-Key", tt.keyID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Error("429响应缺少Retry-After")
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSlots(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	later := time.Now().Add(time.Minute)
+
+	first, _ := store.Acquire(ctx, "user:alice", 2, "a", later)
+	second, _ := store.Acquire(ctx, "user:alice", 2, "b", later)
+	if first == "" || second == "" || first == second {
+		t.Fatalf("Acquire() = %q, %q, 期望两个不同的槽位", first, second)
+	}
+	if slot, _ := store.Acquire(ctx, "user:alice", 2, "c", later); slot != "" {
+		t.Errorf("槽位已满时Acquire() = %q, 期望空字符串", slot)
+	}
+	if slot, _ := store.Acquire(ctx, "user:bob", 2, "c", later); slot == "" {
+		t.Error("其他计数对象的槽位不受影响")
+	}
+	if n, _ := store.Occupied(ctx, "user:alice"); n != 2 {
+		t.Errorf("Occupied() = %d, 期望 2", n)
+	}
+
+	// 其他持有者不能释放或延长槽位
+	store.Release(ctx, first, "c")
+	store.Renew(ctx, first, "c", time.Now().Add(-time.Second))
+	if n, _ := store.Occupied(ctx, "user:alice"); n != 2 {
+		t.Errorf("其他持有者释放后 Occupied() = %d, 期望 2", n)
+	}
+	store.Release(ctx, first, "a")
+	if slot, _ := store.Acquire(ctx, "user:alice", 2, "c", later); slot != first {
+		t.Errorf("释放后Acquire() = %q, 期望 %q", slot, first)
+	}
+
+	// 持有者未释放的槽位过期后可以被占用
+	store.Renew(ctx, second, "b", time.Now().Add(-time.Second))
+	if n, _ := store.Occupied(ctx, "user:alice"); n != 1 {
+		t.Errorf("槽位过期后 Occupied() = %d, 期望 1", n)
+	}
+	if slot, _ := store.Acquire(ctx, "user:alice", 2, "d", later); slot != second {
+		t.Errorf("槽位过期后Acquire() = %q, 期望 %q", slot, second)
+	}
+}
+
+func TestLimitImports(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 两个限额检查器共享同一个Store，模拟多个服务实例
+	store := NewMemoryStore()
+	defaults := Defaults{User: auth.Limits{ConcurrentImports: 1}}
+	instances := []*Limiter{NewLimiter(store, defaults), NewLimiter(store, defaults)}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	routers := make([]*gin.Engine, len(instances))
+	for i, limiter := range instances {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set(auth.ContextUserKey, &auth.User{ID: c.GetHeader("X-Test-User")})
+		})
+		router.POST("/import", limiter.LimitImports(), func(c *gin.Context) {
+			if c.Query("wait") != "" {
+				started <- struct{}{}
+				<-release
+			}
+			c.Status(http.StatusOK)
+		})
+		routers[i] = router
+	}
+	serve := func(router *gin.Engine, user, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/import"+query, nil)
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 第一个实例上的导入进行中
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve(routers[0], "alice", "?wait=1") }()
+	<-started
+
+	tests := []struct {
+		name     string
+		instance int
+		user     string
+		want     int
+	}{
+		{"同一实例超出限额", 0, "alice", http.StatusTooManyRequests},
+		{"其他实例超出限额", 1, "alice", http.StatusTooManyRequests},
+		{"其他用户单独计数", 1, "bob", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(routers[tt.instance], tt.user, "")
+			if w.Code != tt.want {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "10" {
+				t.Errorf("Retry-After = %q, 期望 10", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+
+	close(release)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Fatalf("进行中的导入状态码 = %d", w.Code)
+	}
+	if w := serve(routers[1], "alice", ""); w.Code != http.StatusOK {
+		t.Errorf("导入结束后状态码 = %d, 期望 %d", w.Code, http.StatusOK)
+	}
+	if n, _ := store.Occupied(context.Background(), KindUser+":alice"); n != 0 {
+		t.Errorf("导入结束后 Occupied() = %d, 期望 0", n)
+	}
+}
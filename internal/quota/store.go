@@ -0,0 +1,264 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName 在MongoDB中保存用量计数器的集合名称
+const CollectionName = "usage_counters"
+
+func init() {
+	database.ReserveCollections(CollectionName)
+}
+
+// Store 保存按时间窗口计数的用量，计数器ID包含窗口的开始时间，窗口结束后计数器可以删除
+// 同时进行的导入数保存为有效期有限的槽位，占用槽位的进程退出后槽位在过期后自动释放
+type Store interface {
+	// Add 将计数器增加delta并返回增加后的值，计数器不存在时从0开始，expiresAt之后可以删除
+	Add(ctx context.Context, id string, delta int64, expiresAt time.Time) (int64, error)
+	// Get 返回计数器的当前值，不存在时返回0
+	Get(ctx context.Context, id string) (int64, error)
+	// Acquire 为holder占用key的limit个槽位之一，expiresAt之后槽位自动释放
+	// 返回占用的槽位ID，槽位已满时返回空字符串
+	Acquire(ctx context.Context, key string, limit int, holder string, expiresAt time.Time) (string, error)
+	// Renew 延长holder占用的槽位，槽位已过期被他人占用时不做修改
+	Renew(ctx context.Context, slot, holder string, expiresAt time.Time) error
+	// Release 释放holder占用的槽位
+	Release(ctx context.Context, slot, holder string) error
+	// Occupied 返回key当前被占用的槽位数
+	Occupied(ctx context.Context, key string) (int, error)
+}
+
+// slotID 槽位ID，同一key的槽位编号为0到limit-1，编号唯一保证槽位数不超过limit
+func slotID(key string, n int) string {
+	return "slot:" + key + ":" + strconv.Itoa(n)
+}
+
+// lease 被占用的槽位
+type lease struct {
+	key       string
+	holder    string
+	expiresAt time.Time
+}
+
+// counter 进程内的计数器
+type counter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryStore 进程内的用量计数器，用于未配置MongoDB时，服务重启后计数清零
+type MemoryStore struct {
+	counters map[string]*counter
+	leases   map[string]*lease
+	mutex    sync.Mutex
+}
+
+// NewMemoryStore 创建进程内用量计数器
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*counter), leases: make(map[string]*lease)}
+}
+
+// Add 增加计数器，顺带删除已过期的计数器
+func (s *MemoryStore) Add(ctx context.Context, id string, delta int64, expiresAt time.Time) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for counterID, c := range s.counters {
+		if !now.Before(c.expiresAt) {
+			delete(s.counters, counterID)
+		}
+	}
+	c, ok := s.counters[id]
+	if !ok {
+		c = &counter{expiresAt: expiresAt}
+		s.counters[id] = c
+	}
+	c.value += delta
+	return c.value, nil
+}
+
+// Get 返回计数器的当前值
+func (s *MemoryStore) Get(ctx context.Context, id string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if c, ok := s.counters[id]; ok && time.Now().Before(c.expiresAt) {
+		return c.value, nil
+	}
+	return 0, nil
+}
+
+// Acquire 占用第一个空闲或已过期的槽位
+func (s *MemoryStore) Acquire(ctx context.Context, key string, limit int, holder string, expiresAt time.Time) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for n := 0; n < limit; n++ {
+		slot := slotID(key, n)
+		if l, ok := s.leases[slot]; ok && now.Before(l.expiresAt) {
+			continue
+		}
+		s.leases[slot] = &lease{key: key, holder: holder, expiresAt: expiresAt}
+		return slot, nil
+	}
+	return "", nil
+}
+
+// Renew 延长槽位的过期时间
+func (s *MemoryStore) Renew(ctx context.Context, slot, holder string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if l, ok := s.leases[slot]; ok && l.holder == holder {
+		l.expiresAt = expiresAt
+	}
+	return nil
+}
+
+// Release 释放槽位
+func (s *MemoryStore) Release(ctx context.Context, slot, holder string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if l, ok := s.leases[slot]; ok && l.holder == holder {
+		delete(s.leases, slot)
+	}
+	return nil
+}
+
+// Occupied 返回未过期的槽位数
+func (s *MemoryStore) Occupied(ctx context.Context, key string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, l := range s.leases {
+		if l.key == key && now.Before(l.expiresAt) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MongoStore 基于MongoDB的用量计数器，多个服务实例共享计数，服务重启后计数仍然有效
+// expiresAt上的TTL索引负责删除过期的计数器和导入槽位
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore 创建MongoDB用量计数器并确保TTL索引存在
+func NewMongoStore(ctx context.Context, coll *mongo.Collection) (*MongoStore, error) {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建用量计数器TTL索引失败: %w", err)
+	}
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetName("key").SetSparse(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建导入槽位索引失败: %w", err)
+	}
+	return &MongoStore{coll: coll}, nil
+}
+
+// Add 原子地增加计数器
+func (s *MongoStore) Add(ctx context.Context, id string, delta int64, expiresAt time.Time) (int64, error) {
+	var result struct {
+		Value int64 `bson:"value"`
+	}
+	update := bson.M{
+		"$inc":         bson.M{"value": delta},
+		"$setOnInsert": bson.M{"expiresAt": expiresAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&result)
+	if mongo.IsDuplicateKeyError(err) {
+		// 其他请求同时创建了计数器，此时计数器已存在，重试一次即可
+		err = s.coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&result)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+// Get 返回计数器的当前值
+func (s *MongoStore) Get(ctx context.Context, id string) (int64, error) {
+	var result struct {
+		Value int64 `bson:"value"`
+	}
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+// Acquire 依次尝试插入每个槽位，槽位的_id唯一，多个实例同时占用时每个槽位只有一个成功
+// TTL索引删除过期文档有延迟，已过期但尚未删除的槽位直接接管
+func (s *MongoStore) Acquire(ctx context.Context, key string, limit int, holder string, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	for n := 0; n < limit; n++ {
+		slot := slotID(key, n)
+		_, err := s.coll.InsertOne(ctx, bson.M{"_id": slot, "key": key, "holder": holder, "expiresAt": expiresAt})
+		if err == nil {
+			return slot, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return "", err
+		}
+		result, err := s.coll.UpdateOne(ctx,
+			bson.M{"_id": slot, "expiresAt": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"holder": holder, "expiresAt": expiresAt}})
+		if err != nil {
+			return "", err
+		}
+		if result.ModifiedCount > 0 {
+			return slot, nil
+		}
+	}
+	return "", nil
+}
+
+// Renew 延长槽位的过期时间
+func (s *MongoStore) Renew(ctx context.Context, slot, holder string, expiresAt time.Time) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": slot, "holder": holder}, bson.M{"$set": bson.M{"expiresAt": expiresAt}})
+	return err
+}
+
+// Release 删除槽位
+func (s *MongoStore) Release(ctx context.Context, slot, holder string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": slot, "holder": holder})
+	return err
+}
+
+// Occupied 返回未过期的槽位数
+func (s *MongoStore) Occupied(ctx context.Context, key string) (int, error) {
+	count, err := s.coll.CountDocuments(ctx, bson.M{"key": key, "expiresAt": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
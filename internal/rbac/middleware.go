@@ -0,0 +1,68 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"minds_iolite_backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextPermissionsKey 同一请求中多次检查权限时保存在gin.Context中的权限列表
+const ContextPermissionsKey = "rbac.permissions"
+
+// Granted 当前请求的用户是否拥有权限，未登录的请求只拥有anonymous角色的权限
+// 使用API密钥的请求还需要密钥的权限范围包含该权限
+func (s *Service) Granted(c *gin.Context, permission string) (bool, error) {
+	if key, ok := auth.CurrentAPIKey(c); ok && !Allows(key.Scopes, permission) {
+		return false, nil
+	}
+	if value, ok := c.Get(ContextPermissionsKey); ok {
+		if permissions, ok := value.([]string); ok {
+			return Allows(permissions, permission), nil
+		}
+	}
+
+	userID := ""
+	if user, ok := auth.CurrentUser(c); ok {
+		userID = user.ID
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	_, permissions, err := s.Permissions(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	c.Set(ContextPermissionsKey, permissions)
+	return Allows(permissions, permission), nil
+}
+
+// Check 检查当前请求的用户是否拥有权限，没有权限时中止请求并返回401或403
+func (s *Service) Check(c *gin.Context, permission string) bool {
+	granted, err := s.Granted(c, permission)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "error": "检查权限失败: " + err.Error()})
+		return false
+	}
+	if granted {
+		return true
+	}
+	if _, ok := auth.CurrentUser(c); !ok {
+		c.Header("WWW-Authenticate", `Bearer realm="minds_iolite"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": "未登录，请在Authorization请求头中提供访问令牌"})
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "error": "没有权限: " + permission, "permission": permission})
+	return false
+}
+
+// Require 返回检查权限的中间件
+func (s *Service) Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Check(c, permission) {
+			c.Next()
+		}
+	}
+}
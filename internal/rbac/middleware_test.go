@@ -0,0 +1,68 @@
+package rbac
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"minds_iolite_backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGranted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	service := NewService(NewMemoryStore())
+	if err := service.EnsureBuiltinRoles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SetUserRoles(ctx, "viewer-user", []string{RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SetUserRoles(ctx, "admin-user", []string{RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		userID     string
+		scopes     []string // nil表示不使用API密钥
+		permission string
+		want       bool
+	}{
+		{"用户拥有的权限", "viewer-user", nil, "model:customer:read", true},
+		{"用户没有的权限", "viewer-user", nil, "model:customer:write", false},
+		{"未登录", "", nil, "model:customer:read", false},
+		{"密钥的全部权限等于用户的权限", "viewer-user", []string{PermissionAll}, "model:customer:read", true},
+		{"密钥的全部权限不超出用户的权限", "viewer-user", []string{PermissionAll}, "model:customer:write", false},
+		{"用户和密钥都拥有的权限", "viewer-user", []string{"model:customer:read"}, "model:customer:read", true},
+		{"用户拥有但不在密钥范围内", "viewer-user", []string{"model:customer:read"}, "model:order:read", false},
+		{"密钥范围内但用户没有", "viewer-user", []string{"model:*:write"}, "model:customer:write", false},
+		{"管理员的密钥限制在范围内", "admin-user", []string{PermissionModelsRead}, PermissionAdmin, false},
+		{"管理员的密钥范围内的权限", "admin-user", []string{"model:*:write"}, "model:order:write", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			if tt.userID != "" {
+				c.Set(auth.ContextUserKey, &auth.User{ID: tt.userID})
+			}
+			if tt.scopes != nil {
+				c.Set(auth.ContextAPIKeyKey, &auth.APIKey{ID: "key-1", UserID: tt.userID, Scopes: tt.scopes})
+			}
+			got, err := service.Granted(c, tt.permission)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Granted(%q) = %v, 期望 %v", tt.permission, got, tt.want)
+			}
+			// 第二次检查使用缓存的权限列表，结果应当相同
+			if again, _ := service.Granted(c, tt.permission); again != got {
+				t.Errorf("使用缓存时 Granted(%q) = %v, 期望 %v", tt.permission, again, got)
+			}
+		})
+	}
+}
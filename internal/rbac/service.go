@@ -0,0 +1,347 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 内置角色
+const (
+	// RoleAdmin 拥有全部权限，权限列表固定为"*"
+	RoleAdmin = "admin"
+	// RoleViewer 可以查看模型定义和所有模型的数据
+	RoleViewer = "viewer"
+	// RoleAnonymous 未登录请求的权限，同时适用于所有登录用户，默认没有任何权限
+	RoleAnonymous = "anonymous"
+)
+
+// 权限由冒号分隔的段组成，授予的权限中"*"匹配任意一段，单独的"*"匹配所有权限
+const (
+	// PermissionAll 所有权限
+	PermissionAll = "*"
+	// PermissionAdmin 管理用户、角色、连接池和凭据库
+	PermissionAdmin = "admin"
+	// PermissionModelsRead 查看模型定义
+	PermissionModelsRead = "models:read"
+	// PermissionModelsDefine 创建、修改和删除模型定义
+	PermissionModelsDefine = "models:define"
+	// PermissionAuditRead 查询数据和模型定义的修改记录
+	PermissionAuditRead = "audit:read"
+)
+
+// 模型数据的操作
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+)
+
+// ModelPermission 通过动态API读取或写入模型数据的权限，如"model:customer:read"
+func ModelPermission(model, action string) string {
+	return "model:" + model + ":" + action
+}
+
+// DataSourcePermission 连接某种类型数据源的权限，如"datasource:mysql:connect"
+func DataSourcePermission(dataSourceType string) string {
+	return "datasource:" + dataSourceType + ":connect"
+}
+
+var (
+	// ErrBuiltinRole 内置角色不能删除，admin角色的权限不能修改
+	ErrBuiltinRole = errors.New("不能修改内置角色")
+	// ErrRoleInUse 角色仍分配给用户
+	ErrRoleInUse = errors.New("角色仍分配给用户")
+	// ErrLastAdmin 不能移除最后一个管理员的admin角色
+	ErrLastAdmin = errors.New("至少需要保留一个管理员")
+	// ErrInvalidRole 角色名或权限不符合要求
+	ErrInvalidRole = errors.New("角色名或权限不符合要求")
+)
+
+// roleNamePattern 角色名只能包含小写字母、数字、下划线和连字符，且必须以字母开头
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// builtinRoles 返回内置角色的初始定义
+func builtinRoles() []*Role {
+	return []*Role{
+		{Name: RoleAdmin, Description: "管理员，拥有全部权限", Permissions: []string{PermissionAll}},
+		{Name: RoleViewer, Description: "查看模型定义和所有模型的数据", Permissions: []string{PermissionModelsRead, ModelPermission("*", ActionRead)}},
+		{Name: RoleAnonymous, Description: "未登录请求的权限，同时适用于所有登录用户", Permissions: []string{}},
+	}
+}
+
+// Service 角色和权限服务
+type Service struct {
+	store Store
+}
+
+// NewService 创建角色和权限服务，store为nil时使用进程内存储
+func NewService(store Store) *Service {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Service{store: store}
+}
+
+// EnsureBuiltinRoles 创建缺少的内置角色，并将admin角色的权限恢复为"*"
+func (s *Service) EnsureBuiltinRoles(ctx context.Context) error {
+	for _, role := range builtinRoles() {
+		role.Builtin = true
+		role.CreatedAt = time.Now()
+		role.UpdatedAt = role.CreatedAt
+		err := s.store.CreateRole(ctx, role)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrDuplicateRole) {
+			return fmt.Errorf("创建内置角色 %s 失败: %w", role.Name, err)
+		}
+		if role.Name != RoleAdmin {
+			continue
+		}
+		existing, err := s.store.GetRole(ctx, RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if len(existing.Permissions) != 1 || existing.Permissions[0] != PermissionAll || !existing.Builtin {
+			existing.Permissions = []string{PermissionAll}
+			existing.Builtin = true
+			existing.UpdatedAt = time.Now()
+			if err := s.store.UpdateRole(ctx, existing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnsureAdmin 还没有任何管理员时将admin角色分配给用户，返回是否分配
+func (s *Service) EnsureAdmin(ctx context.Context, userID string) (bool, error) {
+	count, err := s.store.CountAssignments(ctx, RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	assignment, err := s.store.GetAssignment(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	assignment.Roles = append(assignment.Roles, RoleAdmin)
+	assignment.UpdatedAt = time.Now()
+	return true, s.store.SetAssignment(ctx, assignment)
+}
+
+// Permissions 返回用户的角色和合并后的权限，userID为空表示未登录的请求
+// 所有请求都拥有anonymous角色的权限
+func (s *Service) Permissions(ctx context.Context, userID string) ([]string, []string, error) {
+	roles := []string{}
+	if userID != "" {
+		assignment, err := s.store.GetAssignment(ctx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		roles = assignment.Roles
+	}
+
+	seen := make(map[string]bool)
+	permissions := []string{}
+	for _, name := range append([]string{RoleAnonymous}, roles...) {
+		role, err := s.store.GetRole(ctx, name)
+		if errors.Is(err, ErrRoleNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, permission := range role.Permissions {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return roles, permissions, nil
+}
+
+// Allows 授予的权限中是否有权限匹配required
+func Allows(granted []string, required string) bool {
+	for _, permission := range granted {
+		if matches(permission, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 授予的权限是否匹配要求的权限，"*"段匹配任意一段
+func matches(granted, required string) bool {
+	if granted == PermissionAll {
+		return true
+	}
+	grantedParts := strings.Split(granted, ":")
+	requiredParts := strings.Split(required, ":")
+	if len(grantedParts) != len(requiredParts) {
+		return false
+	}
+	for i, part := range grantedParts {
+		if part != "*" && part != requiredParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRole 检查角色名和权限是否符合要求
+func validateRole(name string, permissions []string) error {
+	if !roleNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: 角色名必须以小写字母开头，只能包含小写字母、数字、下划线和连字符，最长64个字符", ErrInvalidRole)
+	}
+	for _, permission := range permissions {
+		if permission == "" || strings.ContainsAny(permission, " \t\r\n") {
+			return fmt.Errorf("%w: 权限 %q 无效", ErrInvalidRole, permission)
+		}
+		for _, part := range strings.Split(permission, ":") {
+			if part == "" {
+				return fmt.Errorf("%w: 权限 %q 包含空段", ErrInvalidRole, permission)
+			}
+		}
+	}
+	return nil
+}
+
+// ListRoles 按名称返回所有角色
+func (s *Service) ListRoles(ctx context.Context) ([]*Role, error) {
+	return s.store.ListRoles(ctx)
+}
+
+// GetRole 按名称读取角色
+func (s *Service) GetRole(ctx context.Context, name string) (*Role, error) {
+	return s.store.GetRole(ctx, name)
+}
+
+// CreateRole 创建自定义角色
+func (s *Service) CreateRole(ctx context.Context, name, description string, permissions []string) (*Role, error) {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	if err := validateRole(name, permissions); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	role := &Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.store.CreateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole 修改角色的描述和权限，admin角色的权限不能修改
+func (s *Service) UpdateRole(ctx context.Context, name, description string, permissions []string) (*Role, error) {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	if err := validateRole(name, permissions); err != nil {
+		return nil, err
+	}
+	role, err := s.store.GetRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if name == RoleAdmin {
+		return nil, ErrBuiltinRole
+	}
+	role.Description = description
+	role.Permissions = permissions
+	role.UpdatedAt = time.Now()
+	if err := s.store.UpdateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// DeleteRole 删除自定义角色，仍分配给用户的角色不能删除
+func (s *Service) DeleteRole(ctx context.Context, name string) error {
+	role, err := s.store.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return ErrBuiltinRole
+	}
+	count, err := s.store.CountAssignments(ctx, name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d 个用户拥有角色 %s", ErrRoleInUse, count, name)
+	}
+	return s.store.DeleteRole(ctx, name)
+}
+
+// UserRoles 读取用户的角色分配
+func (s *Service) UserRoles(ctx context.Context, userID string) (*Assignment, error) {
+	return s.store.GetAssignment(ctx, userID)
+}
+
+// SetUserRoles 覆盖用户的角色，角色必须已存在；不能移除最后一个管理员的admin角色
+func (s *Service) SetUserRoles(ctx context.Context, userID string, roles []string) (*Assignment, error) {
+	unique := []string{}
+	seen := make(map[string]bool)
+	for _, name := range roles {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, err := s.store.GetRole(ctx, name); err != nil {
+			if errors.Is(err, ErrRoleNotFound) {
+				return nil, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+			}
+			return nil, err
+		}
+		unique = append(unique, name)
+	}
+	sort.Strings(unique)
+
+	assignment, err := s.store.GetAssignment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if containsString(assignment.Roles, RoleAdmin) && !seen[RoleAdmin] {
+		count, err := s.store.CountAssignments(ctx, RoleAdmin)
+		if err != nil {
+			return nil, err
+		}
+		if count <= 1 {
+			return nil, ErrLastAdmin
+		}
+	}
+
+	assignment.Roles = unique
+	assignment.UpdatedAt = time.Now()
+	if err := s.store.SetAssignment(ctx, assignment); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// containsString 列表中是否包含value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		granted  string
+		required string
+		want     bool
+	}{
+		{"*", "admin", true},
+		{"*", "model:customer:write", true},
+		{"admin", "admin", true},
+		{"admin", "audit:read", false},
+		{"models:read", "models:read", true},
+		{"models:read", "models:define", false},
+		{"model:*:read", "model:customer:read", true},
+		{"model:*:read", "model:customer:write", false},
+		{"model:customer:*", "model:customer:write", true},
+		{"model:customer:*", "model:order:write", false},
+		{"model:*:*", "model:order:write", true},
+		{"datasource:*:connect", "datasource:mysql:connect", true},
+		// "*"只匹配一段，段数不同时不匹配
+		{"model:*", "model:customer:read", false},
+		{"model:*:read", "model:read", false},
+		{"*:read", "models:read", true},
+		{"model:customer:read", "model:customer", false},
+		// 要求的权限中的"*"不是通配符
+		{"model:customer:read", "model:*:read", false},
+		{"", "admin", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.granted+"/"+tt.required, func(t *testing.T) {
+			if got := matches(tt.granted, tt.required); got != tt.want {
+				t.Errorf("matches(%q, %q) = %v, 期望 %v", tt.granted, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required string
+		want     bool
+	}{
+		{"没有权限", nil, "models:read", false},
+		{"全部权限", []string{PermissionAll}, PermissionAdmin, true},
+		{"任一权限匹配", []string{"models:read", "model:*:read"}, "model:customer:read", true},
+		{"都不匹配", []string{"models:read", "model:*:read"}, "model:customer:write", false},
+		{"数据源权限", []string{DataSourcePermission("mysql")}, DataSourcePermission("mysql"), true},
+		{"其他数据源", []string{DataSourcePermission("mysql")}, DataSourcePermission("sqlite"), false},
+		{"模型权限", []string{ModelPermission("*", ActionWrite)}, ModelPermission("order", ActionWrite), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Allows(tt.granted, tt.required); got != tt.want {
+				t.Errorf("Allows(%v, %q) = %v, 期望 %v", tt.granted, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(NewMemoryStore())
+	if err := service.EnsureBuiltinRoles(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.UpdateRole(ctx, RoleAnonymous, "", []string{"model:public:read"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CreateRole(ctx, "editor", "", []string{"model:*:write"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SetUserRoles(ctx, "editor-user", []string{"editor", RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SetUserRoles(ctx, "admin-user", []string{RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		userID   string
+		required string
+		want     bool
+	}{
+		{"未登录时只有anonymous的权限", "", "model:public:read", true},
+		{"未登录时没有其他权限", "", "model:customer:read", false},
+		{"登录用户同时拥有anonymous的权限", "editor-user", "model:public:read", true},
+		{"多个角色的权限合并", "editor-user", "model:customer:write", true},
+		{"viewer可以读取模型数据", "editor-user", "model:customer:read", true},
+		{"没有分配的权限", "editor-user", PermissionAdmin, false},
+		{"没有角色的用户", "nobody", "model:customer:read", false},
+		{"admin拥有全部权限", "admin-user", PermissionAuditRead, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, permissions, err := service.Permissions(ctx, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Allows(permissions, tt.required); got != tt.want {
+				t.Errorf("用户 %q 的权限 %v, Allows(%q) = %v, 期望 %v", tt.userID, permissions, tt.required, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/database"
+	"minds_iolite_backend/internal/models/metadata"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 保存角色和角色分配的系统模型名称，同时也是集合名称
+const (
+	RolesModelName       = "roles"
+	AssignmentsModelName = "role_assignments"
+)
+
+func init() {
+	database.ReserveCollections(RolesModelName, AssignmentsModelName)
+}
+
+var (
+	// ErrRoleNotFound 角色不存在
+	ErrRoleNotFound = errors.New("角色不存在")
+	// ErrDuplicateRole 角色名已被使用
+	ErrDuplicateRole = errors.New("角色已存在")
+)
+
+// Role 角色，拥有角色的用户获得其中的全部权限
+type Role struct {
+	Name        string    `json:"name" bson:"_id"`
+	Description string    `json:"description" bson:"description"`
+	Permissions []string  `json:"permissions" bson:"permissions"`
+	Builtin     bool      `json:"builtin" bson:"builtin"` // 内置角色不能删除
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Assignment 分配给一个用户的角色
+type Assignment struct {
+	UserID    string    `json:"userId" bson:"_id"`
+	Roles     []string  `json:"roles" bson:"roles"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// SystemModels 返回角色和角色分配的模型定义，两者都是系统模型，不生成动态API
+func SystemModels() []*metadata.ModelDefinition {
+	return []*metadata.ModelDefinition{
+		{
+			Name:        RolesModelName,
+			DisplayName: "角色",
+			Description: "角色及其权限，文档_id为角色名",
+			Collection:  RolesModelName,
+			IsSystem:    true,
+			Fields: []metadata.FieldDefinition{
+				{Name: "description", DisplayName: "描述", Type: metadata.FieldTypeString},
+				{Name: "permissions", DisplayName: "权限", Type: metadata.FieldTypeArray, Required: true},
+				{Name: "builtin", DisplayName: "内置角色", Type: metadata.FieldTypeBoolean},
+			},
+		},
+		{
+			Name:        AssignmentsModelName,
+			DisplayName: "角色分配",
+			Description: "分配给用户的角色，文档_id为用户ID",
+			Collection:  AssignmentsModelName,
+			IsSystem:    true,
+			Fields: []metadata.FieldDefinition{
+				{Name: "roles", DisplayName: "角色", Type: metadata.FieldTypeArray, Required: true},
+			},
+			Indexes: []metadata.IndexDefinition{
+				{Fields: []string{"roles"}, Name: "roles"},
+			},
+		},
+	}
+}
+
+// Store 保存角色和角色分配
+type Store interface {
+	// CreateRole 保存新角色，角色名已存在时返回ErrDuplicateRole
+	CreateRole(ctx context.Context, role *Role) error
+	// GetRole 按名称读取角色，不存在时返回ErrRoleNotFound
+	GetRole(ctx context.Context, name string) (*Role, error)
+	// ListRoles 按名称返回所有角色
+	ListRoles(ctx context.Context) ([]*Role, error)
+	// UpdateRole 覆盖已有角色，不存在时返回ErrRoleNotFound
+	UpdateRole(ctx context.Context, role *Role) error
+	// DeleteRole 删除角色，不存在时返回ErrRoleNotFound
+	DeleteRole(ctx context.Context, name string) error
+	// GetAssignment 读取用户的角色分配，没有分配时返回角色为空的分配
+	GetAssignment(ctx context.Context, userID string) (*Assignment, error)
+	// SetAssignment 覆盖用户的角色分配
+	SetAssignment(ctx context.Context, assignment *Assignment) error
+	// CountAssignments 返回拥有角色的用户数
+	CountAssignments(ctx context.Context, role string) (int64, error)
+}
+
+// MemoryStore 进程内的角色存储，用于未配置MongoDB时，服务重启后角色和分配会丢失
+type MemoryStore struct {
+	roles       map[string]*Role
+	assignments map[string]*Assignment
+	mutex       sync.Mutex
+}
+
+// NewMemoryStore 创建进程内角色存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		roles:       make(map[string]*Role),
+		assignments: make(map[string]*Assignment),
+	}
+}
+
+// CreateRole 保存新角色
+func (s *MemoryStore) CreateRole(ctx context.Context, role *Role) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.roles[role.Name]; ok {
+		return ErrDuplicateRole
+	}
+	s.roles[role.Name] = copyRole(role)
+	return nil
+}
+
+// GetRole 按名称读取角色
+func (s *MemoryStore) GetRole(ctx context.Context, name string) (*Role, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	role, ok := s.roles[name]
+	if !ok {
+		return nil, ErrRoleNotFound
+	}
+	return copyRole(role), nil
+}
+
+// ListRoles 按名称返回所有角色
+func (s *MemoryStore) ListRoles(ctx context.Context) ([]*Role, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]*Role, 0, len(s.roles))
+	for _, role := range s.roles {
+		result = append(result, copyRole(role))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// UpdateRole 覆盖已有角色
+func (s *MemoryStore) UpdateRole(ctx context.Context, role *Role) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.roles[role.Name]; !ok {
+		return ErrRoleNotFound
+	}
+	s.roles[role.Name] = copyRole(role)
+	return nil
+}
+
+// DeleteRole 删除角色
+func (s *MemoryStore) DeleteRole(ctx context.Context, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.roles[name]; !ok {
+		return ErrRoleNotFound
+	}
+	delete(s.roles, name)
+	return nil
+}
+
+// GetAssignment 读取用户的角色分配
+func (s *MemoryStore) GetAssignment(ctx context.Context, userID string) (*Assignment, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	assignment, ok := s.assignments[userID]
+	if !ok {
+		return &Assignment{UserID: userID, Roles: []string{}}, nil
+	}
+	snapshot := *assignment
+	snapshot.Roles = append([]string{}, assignment.Roles...)
+	return &snapshot, nil
+}
+
+// SetAssignment 覆盖用户的角色分配
+func (s *MemoryStore) SetAssignment(ctx context.Context, assignment *Assignment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := *assignment
+	snapshot.Roles = append([]string{}, assignment.Roles...)
+	s.assignments[assignment.UserID] = &snapshot
+	return nil
+}
+
+// CountAssignments 返回拥有角色的用户数
+func (s *MemoryStore) CountAssignments(ctx context.Context, role string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var count int64
+	for _, assignment := range s.assignments {
+		if containsString(assignment.Roles, role) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// copyRole 复制角色，调用方修改返回值不影响存储中的角色
+func copyRole(role *Role) *Role {
+	snapshot := *role
+	snapshot.Permissions = append([]string{}, role.Permissions...)
+	return &snapshot
+}
+
+// MongoStore 基于MongoDB的角色存储，集合由系统模型roles和role_assignments定义
+type MongoStore struct {
+	roles       *mongo.Collection
+	assignments *mongo.Collection
+}
+
+// NewMongoStore 创建MongoDB角色存储并确保按角色查找分配的索引存在
+func NewMongoStore(ctx context.Context, roles, assignments *mongo.Collection) (*MongoStore, error) {
+	_, err := assignments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "roles", Value: 1}},
+		Options: options.Index().SetName("roles"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建角色分配索引失败: %w", err)
+	}
+	return &MongoStore{roles: roles, assignments: assignments}, nil
+}
+
+// CreateRole 保存新角色
+func (s *MongoStore) CreateRole(ctx context.Context, role *Role) error {
+	_, err := s.roles.InsertOne(ctx, role)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateRole
+	}
+	return err
+}
+
+// GetRole 按名称读取角色
+func (s *MongoStore) GetRole(ctx context.Context, name string) (*Role, error) {
+	var role Role
+	err := s.roles.FindOne(ctx, bson.M{"_id": name}).Decode(&role)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// ListRoles 按名称返回所有角色
+func (s *MongoStore) ListRoles(ctx context.Context) ([]*Role, error) {
+	cursor, err := s.roles.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*Role{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateRole 覆盖已有角色
+func (s *MongoStore) UpdateRole(ctx context.Context, role *Role) error {
+	result, err := s.roles.ReplaceOne(ctx, bson.M{"_id": role.Name}, role)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// DeleteRole 删除角色
+func (s *MongoStore) DeleteRole(ctx context.Context, name string) error {
+	result, err := s.roles.DeleteOne(ctx, bson.M{"_id": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// GetAssignment 读取用户的角色分配
+func (s *MongoStore) GetAssignment(ctx context.Context, userID string) (*Assignment, error) {
+	var assignment Assignment
+	err := s.assignments.FindOne(ctx, bson.M{"_id": userID}).Decode(&assignment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &Assignment{UserID: userID, Roles: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if assignment.Roles == nil {
+		assignment.Roles = []string{}
+	}
+	return &assignment, nil
+}
+
+// SetAssignment 覆盖用户的角色分配
+func (s *MongoStore) SetAssignment(ctx context.Context, assignment *Assignment) error {
+	_, err := s.assignments.ReplaceOne(ctx, bson.M{"_id": assignment.UserID}, assignment,
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+// CountAssignments 返回拥有角色的用户数
+func (s *MongoStore) CountAssignments(ctx context.Context, role string) (int64, error) {
+	return s.assignments.CountDocuments(ctx, bson.M{"roles": role})
+}
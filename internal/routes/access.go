@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"minds_iolite_backend/config"
+	"minds_iolite_backend/internal/auth"
+	"minds_iolite_backend/internal/database"
+	sessionHandlers "minds_iolite_backend/internal/handlers"
+	"minds_iolite_backend/internal/rbac"
+	metadataService "minds_iolite_backend/internal/services/metadata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAccessRoutes 设置角色和角色分配路由，管理接口需要admin权限
+func SetupAccessRoutes(router *gin.Engine, access *rbac.Service, users *auth.Service) {
+	accessHandler := sessionHandlers.NewAccessHandler(access, users)
+
+	// 当前用户的角色和权限
+	router.GET("/api/auth/permissions", accessHandler.GetPermissions)
+
+	adminGroup := router.Group("/api/admin", access.Require(rbac.PermissionAdmin))
+	{
+		// 角色管理
+		adminGroup.GET("/roles", accessHandler.ListRoles)
+		adminGroup.POST("/roles", accessHandler.CreateRole)
+		adminGroup.PUT("/roles/:name", accessHandler.UpdateRole)
+		adminGroup.DELETE("/roles/:name", accessHandler.DeleteRole)
+
+		// 用户的角色分配
+		adminGroup.GET("/users/:id/roles", accessHandler.GetUserRoles)
+		adminGroup.PUT("/users/:id/roles", accessHandler.SetUserRoles)
+	}
+}
+
+// newAccessControl 创建角色和权限服务，角色和分配保存在系统模型roles和role_assignments中
+// 未连接MongoDB时角色只保存在进程内存中；还没有管理员时将admin角色分配给初始用户
+func newAccessControl(db *database.MongoDB, cfg *config.Config, users *auth.Service) (*rbac.Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var store rbac.Store
+	if db != nil {
+		metaService, err := metadataService.NewService(db)
+		if err != nil {
+			return nil, err
+		}
+		for _, model := range rbac.SystemModels() {
+			if err := metaService.EnsureSystemModel(model); err != nil {
+				return nil, err
+			}
+		}
+		mongoStore, err := rbac.NewMongoStore(ctx, db.Collection(rbac.RolesModelName), db.Collection(rbac.AssignmentsModelName))
+		if err != nil {
+			return nil, err
+		}
+		store = mongoStore
+	}
+
+	access := rbac.NewService(store)
+	if err := access.EnsureBuiltinRoles(ctx); err != nil {
+		return nil, err
+	}
+
+	if cfg == nil || cfg.Auth.InitialUsername == "" {
+		return access, nil
+	}
+	user, err := users.GetUserByUsername(ctx, cfg.Auth.InitialUsername)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return access, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := access.EnsureAdmin(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if assigned {
+		log.Printf("已将admin角色分配给初始用户 %s", user.Username)
+	}
+	return access, nil
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"context"
+	"time"
+
+	"minds_iolite_backend/internal/audit"
+	"minds_iolite_backend/internal/database"
+	sessionHandlers "minds_iolite_backend/internal/handlers"
+	"minds_iolite_backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAuditRoutes 设置审计日志查询路由，需要audit:read权限
+func SetupAuditRoutes(router *gin.Engine, changeLog audit.Log, access *rbac.Service) {
+	auditHandler := sessionHandlers.NewAuditHandler(changeLog)
+
+	// 查询通过动态API、模型定义接口和导入接口修改数据的记录
+	router.GET("/api/audit", access.Require(rbac.PermissionAuditRead), auditHandler.List)
+}
+
+// newChangeLog 创建记录数据和模型定义修改的审计日志，未连接MongoDB时记录只保存在进程内存中
+func newChangeLog(db *database.MongoDB) (audit.Log, error) {
+	if db == nil {
+		return audit.NewMemoryLog(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return audit.NewMongoLog(ctx, db.Collection(audit.CollectionName))
+}
@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"minds_iolite_backend/config"
+	"minds_iolite_backend/internal/auth"
+	"minds_iolite_backend/internal/database"
+	sessionHandlers "minds_iolite_backend/internal/handlers"
+	"minds_iolite_backend/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sampleJWTSecret 配置文件示例中的JWT签名密钥，使用它签名的令牌可以被任何人伪造
+const sampleJWTSecret = "your-secret-key-here"
+
+// SetupAuthRoutes 设置登录、令牌、用户账号和API密钥路由
+// 登录和刷新令牌不需要访问令牌，管理用户账号和所有用户的API密钥需要admin权限
+func SetupAuthRoutes(router *gin.Engine, service *auth.Service, public *auth.PublicRoutes, access *rbac.Service) {
+	authHandler := sessionHandlers.NewAuthHandler(service)
+
+	authGroup := router.Group("/api/auth")
+	{
+		// 登录和刷新令牌
+		public.Handle(authGroup, "POST", "/login", authHandler.Login)
+		public.Handle(authGroup, "POST", "/refresh", authHandler.Refresh)
+
+		// 注销当前登录
+		authGroup.POST("/logout", authHandler.Logout)
+
+		// 当前用户和修改密码
+		authGroup.GET("/me", authHandler.Me)
+		authGroup.PUT("/password", authHandler.ChangePassword)
+
+		// 用户账号管理
+		requireAdmin := access.Require(rbac.PermissionAdmin)
+		authGroup.GET("/users", requireAdmin, authHandler.ListUsers)
+		authGroup.POST("/users", requireAdmin, authHandler.CreateUser)
+		authGroup.PATCH("/users/:id", requireAdmin, authHandler.UpdateUser)
+
+		// 当前用户的API密钥，创建密钥需要使用登录后的访问令牌
+		authGroup.GET("/api-keys", authHandler.ListAPIKeys)
+		authGroup.POST("/api-keys", authHandler.CreateAPIKey)
+		authGroup.DELETE("/api-keys/:id", authHandler.RevokeAPIKey)
+	}
+
+	// 管理所有用户的API密钥
+	adminGroup := router.Group("/api/admin", access.Require(rbac.PermissionAdmin))
+	{
+		adminGroup.GET("/api-keys", authHandler.ListAllAPIKeys)
+		adminGroup.DELETE("/api-keys/:id", authHandler.AdminRevokeAPIKey)
+	}
+}
+
+// jwtSecret 返回JWT签名密钥，密钥为示例值时返回错误
+// 未配置时为本进程生成随机密钥：服务重启后已签发的令牌失效，多个实例之间的令牌不通用
+func jwtSecret(cfg *config.Config) (string, error) {
+	switch cfg.JWT.Secret {
+	case sampleJWTSecret:
+		return "", fmt.Errorf("jwt.secret 仍为示例值，令牌可以被伪造，请修改配置或设置环境变量 %s", config.JWTSecretEnv)
+	case "":
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("生成JWT签名密钥失败: %w", err)
+		}
+		log.Printf("警告: 未配置 jwt.secret 或环境变量 %s，已生成随机签名密钥，服务重启后需要重新登录，多实例部署时必须配置", config.JWTSecretEnv)
+		return hex.EncodeToString(buf), nil
+	}
+	return cfg.JWT.Secret, nil
+}
+
+// newAuthService 创建用户、令牌和API密钥服务，未连接MongoDB时用户和API密钥只保存在进程内存中
+// 还没有任何用户且配置了初始密码时创建初始用户
+func newAuthService(db *database.MongoDB, cfg *config.Config) (*auth.Service, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("接口认证需要配置 jwt.secret 或环境变量 %s", config.JWTSecretEnv)
+	}
+	secret, err := jwtSecret(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var users auth.UserStore
+	var revoked auth.RevocationStore
+	var apiKeys auth.APIKeyStore
+	if db != nil {
+		userStore, err := auth.NewMongoUserStore(ctx, db.Collection(auth.UsersCollectionName))
+		if err != nil {
+			return nil, err
+		}
+		revocationStore, err := auth.NewMongoRevocationStore(ctx, db.Collection(auth.RevokedCollectionName))
+		if err != nil {
+			return nil, err
+		}
+		apiKeyStore, err := auth.NewMongoAPIKeyStore(ctx, db.Collection(auth.APIKeysCollectionName))
+		if err != nil {
+			return nil, err
+		}
+		users, revoked, apiKeys = userStore, revocationStore, apiKeyStore
+	}
+
+	service, err := auth.NewService(users, revoked, apiKeys, auth.Options{
+		Secret: secret,
+		// 配置中的值为小时数
+		AccessTTL:  cfg.JWT.Expiration * time.Hour,
+		RefreshTTL: time.Duration(cfg.JWT.RefreshExpiration) * time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Auth.InitialPassword == "" {
+		return service, nil
+	}
+	created, err := service.EnsureInitialUser(ctx, cfg.Auth.InitialUsername, cfg.Auth.InitialPassword)
+	if err != nil {
+		return nil, fmt.Errorf("创建初始用户失败: %w", err)
+	}
+	if created {
+		log.Printf("已创建初始用户 %s", cfg.Auth.InitialUsername)
+	}
+	return service, nil
+}
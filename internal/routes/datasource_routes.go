@@ -1,26 +1,93 @@
 package routes
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"minds_iolite_backend/config"
 	"minds_iolite_backend/internal/api/handlers"
+	"minds_iolite_backend/internal/audit"
+	"minds_iolite_backend/internal/database"
+	"minds_iolite_backend/internal/datasource/pool"
+	// 注册内置数据源提供者
+	_ "minds_iolite_backend/internal/datasource/providers/csv"
+	_ "minds_iolite_backend/internal/datasource/providers/mongodb"
+	_ "minds_iolite_backend/internal/datasource/providers/mysql"
+	_ "minds_iolite_backend/internal/datasource/providers/sqlite"
 	sessionHandlers "minds_iolite_backend/internal/handlers"
+	"minds_iolite_backend/internal/queries"
+	"minds_iolite_backend/internal/quota"
+	"minds_iolite_backend/internal/rbac"
+	"minds_iolite_backend/internal/secrets"
+	"minds_iolite_backend/internal/session"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupDataSourceRoutes 设置数据源相关路由
-func SetupDataSourceRoutes(router *gin.Engine) {
+// db不为nil时会话保存在MongoDB中，服务重启后仍然有效，并可在多个实例之间共享
+// 连接数据源需要datasource:<类型>:connect权限，管理接口需要admin权限，导入和复制数据记录在changeLog中
+// 同时进行的导入和复制数由limiter限制
+func SetupDataSourceRoutes(router *gin.Engine, db *database.MongoDB, cfg *config.Config, access *rbac.Service, changeLog audit.Log, limiter *quota.Limiter) error {
+	vault, err := newSecretVault(db, cfg)
+	if err != nil {
+		return err
+	}
+	sessionStore, err := newSessionStore(db, cfg, vault)
+	if err != nil {
+		return err
+	}
+	auditLog, err := newAuditLog(db)
+	if err != nil {
+		return err
+	}
+	queryStore, err := newQueryStore(db)
+	if err != nil {
+		return err
+	}
+
+	// 数据源凭据保存在凭据库中，并迁移config.json中旧版本保存的密码
+	handlers.InitSecretVault(vault)
+	sessionHandlers.InitSecretVault(vault)
+	migrateCredentials(sessionStore)
+	secretHandler := sessionHandlers.NewSecretHandler()
+
+	// 按用户的角色检查数据源权限
+	handlers.InitAccessControl(access)
+	sessionHandlers.InitAccessControl(access)
+
+	// 导入和复制数据写入审计日志
+	handlers.InitChangeLog(changeLog)
+	sessionHandlers.InitChangeLog(changeLog)
+
 	// 创建数据源处理器
 	dataSourceHandler := handlers.NewDataSourceHandler()
 
+	// 创建通用数据源处理器
+	providerHandler := handlers.NewProviderHandler()
+
 	// 创建会话处理器并初始化会话管理器
 	sessionHandler := sessionHandlers.NewSessionHandler()
-	sessionHandlers.InitSessionManager()
+	sessionHandlers.InitSessionManager(sessionStore, auditLog, sessionOptions(cfg))
+
+	// 所有外部数据源连接共享连接池，按配置限制连接数
+	pool.GetRegistry().SetLimits(poolLimits(cfg))
+	poolHandler := sessionHandlers.NewPoolHandler()
+
+	// 创建查询历史和已保存查询处理器
+	sessionHandlers.InitQueryStore(queryStore)
+	queryHandler := sessionHandlers.NewQueryHandler()
+
+	// 导入和复制可能耗时较长，按用户和API密钥限制同时进行的数量
+	limitImports := limiter.LimitImports()
 
 	// 数据源API路由组
 	dataSourceGroup := router.Group("/api/datasource")
 	{
 		// CSV相关API
-		csvGroup := dataSourceGroup.Group("/csv")
+		csvGroup := dataSourceGroup.Group("/csv", access.Require(rbac.DataSourcePermission("csv")))
 		{
 			// 处理CSV文件
 			csvGroup.POST("/process", dataSourceHandler.ProcessCSVFile)
@@ -32,36 +99,59 @@ func SetupDataSourceRoutes(router *gin.Engine) {
 			csvGroup.POST("/upload", dataSourceHandler.UploadCSVFile)
 
 			// 导入CSV到MongoDB
-			csvGroup.POST("/import-to-mongo", dataSourceHandler.ImportCSVToMongoDB)
+			csvGroup.POST("/import-to-mongo", limitImports, dataSourceHandler.ImportCSVToMongoDB)
 		}
 
 		// TODO: 添加MongoDB数据源相关路由
-		mongoGroup := dataSourceGroup.Group("/mongodb")
+		mongoGroup := dataSourceGroup.Group("/mongodb", access.Require(rbac.DataSourcePermission("mongodb")))
 		{
 			// 连接到MongoDB
 			mongoGroup.POST("/connect", dataSourceHandler.ConnectToMongoDB)
+
+			// 浏览数据库、集合和单个集合结构
+			mongoGroup.POST("/databases", dataSourceHandler.ListMongoDatabases)
+			mongoGroup.POST("/collections", dataSourceHandler.ListMongoCollections)
+			mongoGroup.POST("/collection", dataSourceHandler.DescribeMongoCollection)
 		}
 
 		// TODO: 添加MySQL数据源相关路由
-		mysqlGroup := dataSourceGroup.Group("/mysql")
+		mysqlGroup := dataSourceGroup.Group("/mysql", access.Require(rbac.DataSourcePermission("mysql")))
 		{
 			// 连接到MySQL
 			mysqlGroup.POST("/connect", dataSourceHandler.ConnectToMySQL)
+
+			// 浏览数据库、表和单个表结构
+			mysqlGroup.POST("/databases", dataSourceHandler.ListMySQLDatabases)
+			mysqlGroup.POST("/tables", dataSourceHandler.ListMySQLTables)
+			mysqlGroup.POST("/table", dataSourceHandler.DescribeMySQLTable)
 		}
 
 		// SQLite数据源相关路由
-		sqliteGroup := dataSourceGroup.Group("/sqlite")
+		sqliteGroup := dataSourceGroup.Group("/sqlite", access.Require(rbac.DataSourcePermission("sqlite")))
 		{
 			// 处理SQLite文件
 			sqliteGroup.POST("/process", dataSourceHandler.ProcessSQLiteFile)
 
 			// 导入SQLite到MongoDB
-			sqliteGroup.POST("/import-to-mongo", dataSourceHandler.ImportSQLiteToMongoDB)
+			sqliteGroup.POST("/import-to-mongo", limitImports, dataSourceHandler.ImportSQLiteToMongoDB)
+		}
+
+		// 通用数据源API，:type为已注册的数据源类型（mongodb/mysql/sqlite/csv）
+		dataSourceGroup.GET("/providers", providerHandler.ListProviders)
+		// 在任意数据源与MongoDB/MySQL/SQLite目标之间复制数据
+		dataSourceGroup.POST("/copy", limitImports, providerHandler.Copy)
+		typeGroup := dataSourceGroup.Group("/:type")
+		{
+			typeGroup.POST("/validate", providerHandler.ValidateConfig)
+			typeGroup.POST("/entities", providerHandler.ListEntities)
+			typeGroup.POST("/describe", providerHandler.Describe)
+			typeGroup.POST("/sample", providerHandler.Sample)
+			typeGroup.POST("/rows", providerHandler.StreamRows)
 		}
 	}
 
-	// 持久会话API路由组
-	sessionsGroup := router.Group("/api/sessions")
+	// 持久会话API路由组，访问已有会话需要连接其数据源类型的权限
+	sessionsGroup := router.Group("/api/sessions", sessionHandlers.RequireSessionAccess())
 	{
 		// 创建会话
 		sessionsGroup.POST("", sessionHandler.CreateSession)
@@ -69,13 +159,205 @@ func SetupDataSourceRoutes(router *gin.Engine) {
 		// 获取所有会话
 		sessionsGroup.GET("", sessionHandler.GetAllSessions)
 
+		// 订阅会话过期预警等事件(SSE)
+		sessionsGroup.GET("/events", sessionHandler.SessionEvents)
+
 		// 获取特定会话
 		sessionsGroup.GET("/:sessionId", sessionHandler.GetSession)
 
 		// 刷新会话
 		sessionsGroup.PUT("/:sessionId/refresh", sessionHandler.RefreshSession)
 
+		// 会话心跳，顺延过期时间
+		sessionsGroup.POST("/:sessionId/heartbeat", sessionHandler.Heartbeat)
+
+		// 会话连接的健康状态和历史
+		sessionsGroup.GET("/:sessionId/health", sessionHandler.GetSessionHealth)
+
 		// 关闭会话
 		sessionsGroup.DELETE("/:sessionId", sessionHandler.CloseSession)
+
+		// 在会话连接上执行只读查询
+		sessionsGroup.POST("/:sessionId/query", sessionHandler.QuerySession)
+
+		// 分页浏览会话中的表或集合
+		sessionsGroup.GET("/:sessionId/entities/:name/rows", sessionHandler.BrowseEntityRows)
+
+		// 在可写会话上插入、更新和删除表中的行
+		sessionsGroup.POST("/:sessionId/entities/:name/rows", sessionHandler.InsertEntityRow)
+		sessionsGroup.PATCH("/:sessionId/entities/:name/rows", sessionHandler.UpdateEntityRow)
+		sessionsGroup.DELETE("/:sessionId/entities/:name/rows", sessionHandler.DeleteEntityRow)
+
+		// 查询通过会话修改数据的审计记录
+		sessionsGroup.GET("/:sessionId/audit", sessionHandler.GetSessionAudit)
+
+		// 读取和刷新会话的结构缓存
+		sessionsGroup.GET("/:sessionId/schema", sessionHandler.GetSessionSchema)
+		sessionsGroup.POST("/:sessionId/schema", sessionHandler.RefreshSessionSchema)
+
+		// 使用会话连接将表或集合导入MongoDB
+		sessionsGroup.POST("/:sessionId/import", limitImports, sessionHandler.ImportSession)
+	}
+
+	// 查询历史和已保存查询API路由组，按访问令牌所属的用户区分
+	queriesGroup := router.Group("/api/queries")
+	{
+		// 查询历史
+		queriesGroup.GET("/history", queryHandler.GetHistory)
+		queriesGroup.DELETE("/history", queryHandler.ClearHistory)
+
+		// 已保存的查询
+		queriesGroup.GET("", queryHandler.ListSavedQueries)
+		queriesGroup.POST("", queryHandler.CreateSavedQuery)
+		queriesGroup.GET("/:id", queryHandler.GetSavedQuery)
+		queriesGroup.PUT("/:id", queryHandler.UpdateSavedQuery)
+		queriesGroup.DELETE("/:id", queryHandler.DeleteSavedQuery)
+
+		// 在同类型数据源的会话上执行已保存的查询
+		queriesGroup.POST("/:id/run", queryHandler.RunSavedQuery)
+	}
+
+	// 管理API路由组
+	adminGroup := router.Group("/api/admin", access.Require(rbac.PermissionAdmin))
+	{
+		// 共享连接池的状态
+		adminGroup.GET("/pools", poolHandler.GetPools)
+
+		// 凭据库状态、轮换密钥后重新加密和按ID查看凭据的元数据（不返回明文）
+		adminGroup.GET("/secrets", secretHandler.GetStatus)
+		adminGroup.POST("/secrets/reencrypt", secretHandler.ReEncrypt)
+		adminGroup.GET("/secrets/:id", secretHandler.DescribeSecret)
+	}
+
+	return nil
+}
+
+// sampleSecretsKey 旧版本配置文件示例中的凭据加密密钥，公开的示例值不能用于加密凭据
+const sampleSecretsKey = "your-secrets-key-here"
+
+// newSecretVault 创建凭据库
+// 未连接MongoDB时凭据只保存在进程内存中，未配置密钥时使用随机密钥；密钥为示例值时返回错误
+func newSecretVault(db *database.MongoDB, cfg *config.Config) (*secrets.Vault, error) {
+	var keys map[string]string
+	activeKey := ""
+	if cfg != nil {
+		keys, activeKey = cfg.Secrets.Keys, cfg.Secrets.ActiveKey
+	}
+	for id, key := range keys {
+		if key == sampleSecretsKey {
+			return nil, fmt.Errorf("secrets.keys.%s 仍为示例值，请改为随机生成的密钥或设置环境变量 %s", id, config.SecretsKeyEnv)
+		}
+	}
+	if db == nil {
+		if len(keys) == 0 {
+			return secrets.NewEphemeralVault()
+		}
+		return secrets.NewVault(nil, keys, activeKey)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("持久保存凭据需要配置 secrets.keys 或环境变量 %s", config.SecretsKeyEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := secrets.NewMongoStore(ctx, db.Collection(secrets.CollectionName))
+	if err != nil {
+		return nil, err
+	}
+	return secrets.NewVault(store, keys, activeKey)
+}
+
+// sampleSessionSecretKey 旧版本配置文件示例中的会话凭据加密密钥
+const sampleSessionSecretKey = "your-session-secret-key-here"
+
+// newSessionStore 创建会话存储，未连接MongoDB时返回nil，会话只保存在进程内存中
+// 配置了 session.secret_key 时可以读取旧版本直接加密保存凭据的会话，密钥为示例值时返回错误
+func newSessionStore(db *database.MongoDB, cfg *config.Config, vault *secrets.Vault) (session.Store, error) {
+	if cfg != nil && cfg.Session.SecretKey == sampleSessionSecretKey {
+		return nil, fmt.Errorf("session.secret_key 仍为示例值，请改为旧版本实际使用的密钥，不需要迁移旧会话时删除该配置")
+	}
+	if db == nil {
+		return nil, nil
+	}
+	var legacy *session.Cipher
+	if cfg != nil && cfg.Session.SecretKey != "" {
+		cipher, err := session.NewCipher(cfg.Session.SecretKey)
+		if err != nil {
+			return nil, err
+		}
+		legacy = cipher
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return session.NewMongoStore(ctx, db.Collection(session.CollectionName), vault, legacy)
+}
+
+// migrateCredentials 将旧版本保存在config.json和会话文档中的凭据移入凭据库
+// 迁移失败不影响启动，旧格式的凭据仍然可以读取，下次启动时重试
+func migrateCredentials(sessionStore session.Store) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if count, err := handlers.MigrateLegacyPasswords(ctx, handlers.DataConfigPaths()...); err != nil {
+		log.Printf("迁移config.json中的密码失败: %v", err)
+	} else if count > 0 {
+		log.Printf("已将config.json中的 %d 个密码移入凭据库", count)
+	}
+
+	mongoStore, ok := sessionStore.(*session.MongoStore)
+	if !ok {
+		return
+	}
+	if count, err := mongoStore.MigrateCredentials(ctx); err != nil {
+		log.Printf("迁移会话凭据失败: %v", err)
+	} else if count > 0 {
+		log.Printf("已将 %d 个会话的凭据移入凭据库", count)
+	}
+}
+
+// newAuditLog 创建修改数据的审计日志，未连接MongoDB时返回nil，记录只保存在进程内存中
+func newAuditLog(db *database.MongoDB) (session.AuditLog, error) {
+	if db == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return session.NewMongoAuditLog(ctx, db.Collection(session.AuditCollectionName))
+}
+
+// newQueryStore 创建查询历史和已保存查询的存储，未连接MongoDB时返回nil，记录只保存在进程内存中
+func newQueryStore(db *database.MongoDB) (queries.Store, error) {
+	if db == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return queries.NewMongoStore(ctx, db.Collection(queries.HistoryCollectionName), db.Collection(queries.SavedCollectionName))
+}
+
+// sessionOptions 从配置读取会话有效期设置，未配置的项使用默认值
+func sessionOptions(cfg *config.Config) session.Options {
+	if cfg == nil {
+		return session.DefaultOptions()
+	}
+	return session.Options{
+		DefaultTTL:    time.Duration(cfg.Session.DefaultTTL) * time.Second,
+		MinTTL:        time.Duration(cfg.Session.MinTTL) * time.Second,
+		MaxTTL:        time.Duration(cfg.Session.MaxTTL) * time.Second,
+		ExpiryWarning: time.Duration(cfg.Session.ExpiryWarning) * time.Second,
+	}
+}
+
+// poolLimits 从配置读取连接数限制，未配置时使用默认值
+func poolLimits(cfg *config.Config) pool.Limits {
+	if cfg == nil {
+		return pool.DefaultLimits()
+	}
+	return pool.Limits{
+		MaxConnections: cfg.Pool.MaxConnections,
+		MaxPerTarget:   cfg.Pool.MaxPerTarget,
+		PoolSize:       cfg.Pool.PoolSize,
+		IdleTimeout:    time.Duration(cfg.Pool.IdleTimeout) * time.Second,
 	}
 }
@@ -1,15 +1,17 @@
 package routes
 
 import (
+	"minds_iolite_backend/internal/audit"
 	"minds_iolite_backend/internal/database"
+	"minds_iolite_backend/internal/rbac"
 	"minds_iolite_backend/internal/services/dynamic"
 	metadataService "minds_iolite_backend/internal/services/metadata"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetupDynamicRoutes 设置动态API路由
-func SetupDynamicRoutes(router *gin.Engine, db *database.MongoDB) error {
+// SetupDynamicRoutes 设置动态API路由，按模型检查读写权限并将修改写入审计日志
+func SetupDynamicRoutes(router *gin.Engine, db *database.MongoDB, access *rbac.Service, auditLog audit.Log) error {
 	// 创建元数据服务
 	metaService, err := metadataService.NewService(db)
 	if err != nil {
@@ -17,7 +19,7 @@ func SetupDynamicRoutes(router *gin.Engine, db *database.MongoDB) error {
 	}
 
 	// 创建动态API生成器
-	dynamicGenerator := dynamic.NewGenerator(db, metaService)
+	dynamicGenerator := dynamic.NewGenerator(db, metaService, access, auditLog)
 
 	// 定义API路由组
 	apiGroup := router.Group("/api")
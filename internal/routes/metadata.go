@@ -1,11 +1,14 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
+	"minds_iolite_backend/internal/audit"
 	"minds_iolite_backend/internal/database"
 	"minds_iolite_backend/internal/models/metadata"
+	"minds_iolite_backend/internal/rbac"
 	metadataService "minds_iolite_backend/internal/services/metadata"
 
 	"github.com/gin-gonic/gin"
@@ -13,7 +16,10 @@ import (
 )
 
 // SetupMetadataRoutes 设置元数据管理路由
-func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB) error {
+// 查看模型定义需要models:read权限，创建、修改和删除需要models:define权限；系统模型不能通过接口创建或修改
+// 模型不能使用服务自身和系统模型的集合（用户、角色、凭据、审计日志等）
+// 模型定义的每次修改都会写入审计日志
+func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB, access *rbac.Service, auditLog audit.Log) error {
 	fmt.Println("设置元数据路由...")
 
 	// 创建元数据服务
@@ -24,29 +30,45 @@ func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB) error {
 
 	// 元数据路由组
 	metaGroup := router.Group("/metadata")
+	requireRead := access.Require(rbac.PermissionModelsRead)
+	requireDefine := access.Require(rbac.PermissionModelsDefine)
 	fmt.Println("已注册 /metadata 路由组")
 	{
 		// 模型相关API
 		modelsGroup := metaGroup.Group("/models")
 		{
 			// 创建模型
-			modelsGroup.POST("", func(c *gin.Context) {
+			modelsGroup.POST("", requireDefine, func(c *gin.Context) {
 				var model metadata.ModelDefinition
 				if err := c.ShouldBindJSON(&model); err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{"error": "无效的模型定义"})
 					return
 				}
 
+				// 系统模型由服务自身创建
+				if model.IsSystem {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "不能通过接口创建系统模型"})
+					return
+				}
+
 				if err := metaService.CreateModel(&model); err != nil {
-					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					c.JSON(modelErrorStatus(err), gin.H{"error": err.Error()})
 					return
 				}
 
+				// 记录审计日志
+				audit.Record(c, auditLog, &audit.Entry{
+					Action:   audit.ActionModelCreate,
+					Model:    model.Name,
+					RecordID: model.ID.Hex(),
+					After:    model,
+				})
+
 				c.JSON(http.StatusCreated, model)
 			})
 
 			// 获取模型列表
-			modelsGroup.GET("", func(c *gin.Context) {
+			modelsGroup.GET("", requireRead, func(c *gin.Context) {
 				// 默认不包含系统模型
 				includeSystem := false
 				if c.Query("includeSystem") == "true" {
@@ -68,7 +90,7 @@ func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB) error {
 			})
 
 			// 获取单个模型
-			modelsGroup.GET("/:id", func(c *gin.Context) {
+			modelsGroup.GET("/:id", requireRead, func(c *gin.Context) {
 				id := c.Param("id")
 				model, err := metaService.GetModelByID(id)
 				if err != nil {
@@ -80,7 +102,7 @@ func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB) error {
 			})
 
 			// 更新模型
-			modelsGroup.PUT("/:id", func(c *gin.Context) {
+			modelsGroup.PUT("/:id", requireDefine, func(c *gin.Context) {
 				id := c.Param("id")
 				var updates metadata.ModelDefinition
 				if err := c.ShouldBindJSON(&updates); err != nil {
@@ -88,8 +110,19 @@ func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB) error {
 					return
 				}
 
+				// 系统模型的结构由服务自身维护，普通模型也不能改为系统模型
+				original, err := metaService.GetModelByID(id)
+				if err != nil {
+					c.JSON(http.StatusNotFound, gin.H{"error": "模型不存在"})
+					return
+				}
+				if original.IsSystem || updates.IsSystem {
+					c.JSON(http.StatusForbidden, gin.H{"error": "不能通过接口修改系统模型"})
+					return
+				}
+
 				if err := metaService.UpdateModel(id, &updates); err != nil {
-					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					c.JSON(modelErrorStatus(err), gin.H{"error": err.Error()})
 					return
 				}
 
@@ -100,17 +133,40 @@ func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB) error {
 					return
 				}
 
+				// 记录审计日志
+				audit.Record(c, auditLog, &audit.Entry{
+					Action:   audit.ActionModelUpdate,
+					Model:    model.Name,
+					RecordID: id,
+					Before:   original,
+					After:    model,
+					Changes:  audit.Diff(original, model),
+				})
+
 				c.JSON(http.StatusOK, model)
 			})
 
 			// 删除模型
-			modelsGroup.DELETE("/:id", func(c *gin.Context) {
+			modelsGroup.DELETE("/:id", requireDefine, func(c *gin.Context) {
 				id := c.Param("id")
+				original, err := metaService.GetModelByID(id)
+				if err != nil {
+					c.JSON(http.StatusNotFound, gin.H{"error": "模型不存在"})
+					return
+				}
 				if err := metaService.DeleteModel(id); err != nil {
 					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 					return
 				}
 
+				// 记录审计日志
+				audit.Record(c, auditLog, &audit.Entry{
+					Action:   audit.ActionModelDelete,
+					Model:    original.Name,
+					RecordID: id,
+					Before:   original,
+				})
+
 				c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 			})
 		}
@@ -118,3 +174,11 @@ func SetupMetadataRoutes(router *gin.Engine, db *database.MongoDB) error {
 
 	return nil
 }
+
+// modelErrorStatus 创建或修改模型失败时的状态码，使用系统集合时返回403
+func modelErrorStatus(err error) int {
+	if errors.Is(err, metadataService.ErrReservedCollection) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"context"
+	"time"
+
+	"minds_iolite_backend/config"
+	"minds_iolite_backend/internal/auth"
+	"minds_iolite_backend/internal/database"
+	sessionHandlers "minds_iolite_backend/internal/handlers"
+	"minds_iolite_backend/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupUsageRoutes 设置查询当前用户限额和用量的路由
+func SetupUsageRoutes(router *gin.Engine, limiter *quota.Limiter) {
+	usageHandler := sessionHandlers.NewUsageHandler(limiter)
+
+	router.GET("/api/auth/usage", usageHandler.GetUsage)
+}
+
+// newLimiter 创建限额检查器，未连接MongoDB时用量只保存在进程内存中，服务重启后清零
+func newLimiter(db *database.MongoDB, cfg *config.Config) (*quota.Limiter, error) {
+	var defaults quota.Defaults
+	if cfg != nil {
+		defaults = quota.Defaults{
+			User:   limitsFromConfig(cfg.Limits.User),
+			APIKey: limitsFromConfig(cfg.Limits.APIKey),
+		}
+	}
+	if db == nil {
+		return quota.NewLimiter(nil, defaults), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := quota.NewMongoStore(ctx, db.Collection(quota.CollectionName))
+	if err != nil {
+		return nil, err
+	}
+	return quota.NewLimiter(store, defaults), nil
+}
+
+// limitsFromConfig 将配置中的限额转换为auth.Limits
+func limitsFromConfig(limits config.LimitsConfig) auth.Limits {
+	return auth.Limits{
+		RequestsPerMinute: limits.RequestsPerMinute,
+		ConcurrentImports: limits.ConcurrentImports,
+		UploadBytesPerDay: limits.UploadBytesPerDay,
+	}
+}
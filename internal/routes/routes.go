@@ -1,6 +1,9 @@
 package routes
 
 import (
+	"minds_iolite_backend/config"
+	"minds_iolite_backend/internal/audit"
+	"minds_iolite_backend/internal/auth"
 	"minds_iolite_backend/internal/database"
 
 	"fmt"
@@ -9,24 +12,64 @@ import (
 )
 
 // SetupRoutes 设置所有路由
-func SetupRoutes(router *gin.Engine, db *database.MongoDB) error {
+// /api和/metadata下的路由需要登录，通过PublicRoutes或配置中的auth.public_routes排除的路由除外
+// 登录后能访问哪些模型和数据源由用户的角色决定，请求频率和上传量按用户和API密钥限制
+func SetupRoutes(router *gin.Engine, db *database.MongoDB, cfg *config.Config) error {
 	// 添加调试输出
 	fmt.Println("正在设置路由...")
 
+	// 请求ID写入审计记录和响应头，便于关联同一请求的修改
+	router.Use(audit.RequestID())
+
+	// 认证中间件必须在创建任何路由组之前注册，之后注册的路由才会经过它
+	authService, err := newAuthService(db, cfg)
+	if err != nil {
+		return err
+	}
+	access, err := newAccessControl(db, cfg, authService)
+	if err != nil {
+		return err
+	}
+	public := auth.NewPublicRoutes(cfg.Auth.PublicRoutes...)
+	// 会话事件是SSE接口，浏览器的EventSource无法设置请求头
+	public.AllowQueryToken("/api/sessions/events")
+	router.Use(authService.Middleware(public, "/api", "/metadata"))
+
+	// 限额检查需要认证中间件识别的用户和API密钥
+	limiter, err := newLimiter(db, cfg)
+	if err != nil {
+		return err
+	}
+	router.Use(limiter.Middleware("/api", "/metadata"))
+	SetupAuthRoutes(router, authService, public, access)
+	SetupAccessRoutes(router, access, authService)
+	SetupUsageRoutes(router, limiter)
+	fmt.Println("认证路由设置完成")
+
+	// 记录数据和模型定义修改的审计日志
+	changeLog, err := newChangeLog(db)
+	if err != nil {
+		return err
+	}
+	SetupAuditRoutes(router, changeLog, access)
+	fmt.Println("审计日志路由设置完成")
+
 	// 设置元数据管理路由
-	if err := SetupMetadataRoutes(router, db); err != nil {
+	if err := SetupMetadataRoutes(router, db, access, changeLog); err != nil {
 		return err
 	}
 	fmt.Println("元数据路由设置完成")
 
 	// 设置动态API路由
-	if err := SetupDynamicRoutes(router, db); err != nil {
+	if err := SetupDynamicRoutes(router, db, access, changeLog); err != nil {
 		return err
 	}
 	fmt.Println("动态API路由设置完成")
 
 	// 设置数据源路由
-	SetupDataSourceRoutes(router)
+	if err := SetupDataSourceRoutes(router, db, cfg, access, changeLog, limiter); err != nil {
+		return err
+	}
 	fmt.Println("数据源路由设置完成")
 
 	return nil
@@ -0,0 +1,345 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName 在MongoDB中保存凭据的集合名称
+const CollectionName = "secrets"
+
+func init() {
+	database.ReserveCollections(CollectionName)
+}
+
+var (
+	// ErrNotFound 凭据不存在或已过期
+	ErrNotFound = errors.New("凭据不存在")
+	// ErrConflict 重新加密期间凭据已被其他实例修改
+	ErrConflict = errors.New("凭据已被修改")
+)
+
+// Secret 加密保存的凭据，明文只在Vault中解密
+type Secret struct {
+	ID         string    `bson:"_id"`
+	KeyID      string    `bson:"keyId"`      // 加密使用的密钥ID
+	Ciphertext []byte    `bson:"ciphertext"` // 随机nonce + AES-GCM密文，凭据ID作为附加数据
+	CreatedAt  time.Time `bson:"createdAt"`
+	UpdatedAt  time.Time `bson:"updatedAt"`
+	ExpiresAt  time.Time `bson:"expiresAt,omitempty"` // 为零时永不过期
+}
+
+// expired 凭据是否已过期
+func (s *Secret) expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// Store 持久化加密后的凭据
+// 过期的凭据对Get/List不可见，由存储自身负责删除（MongoDB通过TTL索引）
+type Store interface {
+	// Create 保存新凭据
+	Create(ctx context.Context, secret *Secret) error
+	// Get 读取凭据，不存在或已过期时返回ErrNotFound
+	Get(ctx context.Context, id string) (*Secret, error)
+	// Replace 覆盖凭据的密文，凭据的密钥ID不是previousKeyID时返回ErrConflict
+	Replace(ctx context.Context, secret *Secret, previousKeyID string) error
+	// Extend 修改凭据的过期时间，不存在的凭据被忽略
+	Extend(ctx context.Context, ids []string, expiresAt time.Time) error
+	// Delete 删除凭据，返回删除的数量
+	Delete(ctx context.Context, ids []string) (int64, error)
+	// List 按ID顺序返回ID大于afterID、不是用excludeKeyIDs中的密钥加密的凭据，最多limit个
+	// afterID为上一页最后一个凭据的ID，为空时从头开始
+	List(ctx context.Context, afterID string, excludeKeyIDs []string, limit int) ([]*Secret, error)
+	// CountByKey 返回每个密钥加密的凭据数
+	CountByKey(ctx context.Context) (map[string]int64, error)
+	// Persistent 凭据在服务重启后是否仍然存在
+	Persistent() bool
+}
+
+// MemoryStore 进程内的凭据存储，用于未配置MongoDB时，服务重启后凭据会丢失
+type MemoryStore struct {
+	secrets map[string]*Secret
+	mutex   sync.Mutex
+}
+
+// NewMemoryStore 创建进程内凭据存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{secrets: make(map[string]*Secret)}
+}
+
+// Create 保存新凭据
+func (s *MemoryStore) Create(ctx context.Context, secret *Secret) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := *secret
+	s.secrets[secret.ID] = &snapshot
+	return nil
+}
+
+// Get 读取凭据
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Secret, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secret, ok := s.live(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	snapshot := *secret
+	return &snapshot, nil
+}
+
+// Replace 覆盖凭据的密文
+func (s *MemoryStore) Replace(ctx context.Context, secret *Secret, previousKeyID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, ok := s.live(secret.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	if current.KeyID != previousKeyID {
+		return ErrConflict
+	}
+	current.KeyID = secret.KeyID
+	current.Ciphertext = secret.Ciphertext
+	current.UpdatedAt = secret.UpdatedAt
+	return nil
+}
+
+// Extend 修改凭据的过期时间
+func (s *MemoryStore) Extend(ctx context.Context, ids []string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, id := range ids {
+		if secret, ok := s.live(id); ok {
+			secret.ExpiresAt = expiresAt
+		}
+	}
+	return nil
+}
+
+// Delete 删除凭据
+func (s *MemoryStore) Delete(ctx context.Context, ids []string) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var deleted int64
+	for _, id := range ids {
+		if _, ok := s.live(id); ok {
+			deleted++
+		}
+		delete(s.secrets, id)
+	}
+	return deleted, nil
+}
+
+// List 按ID顺序返回afterID之后不是用excludeKeyIDs中的密钥加密的凭据
+func (s *MemoryStore) List(ctx context.Context, afterID string, excludeKeyIDs []string, limit int) ([]*Secret, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ids := make([]string, 0, len(s.secrets))
+	for id := range s.secrets {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	result := []*Secret{}
+	for _, id := range ids {
+		if len(result) >= limit {
+			break
+		}
+		if secret, ok := s.live(id); ok && !slices.Contains(excludeKeyIDs, secret.KeyID) {
+			snapshot := *secret
+			result = append(result, &snapshot)
+		}
+	}
+	return result, nil
+}
+
+// CountByKey 返回每个密钥加密的凭据数
+func (s *MemoryStore) CountByKey(ctx context.Context) (map[string]int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counts := make(map[string]int64)
+	for id := range s.secrets {
+		if secret, ok := s.live(id); ok {
+			counts[secret.KeyID]++
+		}
+	}
+	return counts, nil
+}
+
+// Persistent 进程内存储在服务重启后丢失
+func (s *MemoryStore) Persistent() bool {
+	return false
+}
+
+// live 返回未过期的凭据，顺带删除已过期的凭据，调用方需持有锁
+func (s *MemoryStore) live(id string) (*Secret, bool) {
+	secret, ok := s.secrets[id]
+	if !ok {
+		return nil, false
+	}
+	if secret.expired(time.Now()) {
+		delete(s.secrets, id)
+		return nil, false
+	}
+	return secret, true
+}
+
+// MongoStore 基于MongoDB的凭据存储，多个服务实例可以共享同一集合
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore 创建MongoDB凭据存储并确保索引存在
+// expiresAt上的TTL索引负责删除过期凭据，keyId索引用于统计和重新加密
+func NewMongoStore(ctx context.Context, coll *mongo.Collection) (*MongoStore, error) {
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "keyId", Value: 1}},
+			Options: options.Index().SetName("keyId"),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建凭据索引失败: %w", err)
+	}
+	return &MongoStore{coll: coll}, nil
+}
+
+// Create 保存新凭据
+func (s *MongoStore) Create(ctx context.Context, secret *Secret) error {
+	_, err := s.coll.InsertOne(ctx, secret)
+	return err
+}
+
+// Get 读取凭据
+func (s *MongoStore) Get(ctx context.Context, id string) (*Secret, error) {
+	var secret Secret
+	err := s.coll.FindOne(ctx, liveFilter(bson.M{"_id": id})).Decode(&secret)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// Replace 覆盖凭据的密文
+func (s *MongoStore) Replace(ctx context.Context, secret *Secret, previousKeyID string) error {
+	result, err := s.coll.UpdateOne(ctx, liveFilter(bson.M{"_id": secret.ID, "keyId": previousKeyID}), bson.M{
+		"$set": bson.M{"keyId": secret.KeyID, "ciphertext": secret.Ciphertext, "updatedAt": secret.UpdatedAt},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Extend 修改凭据的过期时间
+func (s *MongoStore) Extend(ctx context.Context, ids []string, expiresAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := s.coll.UpdateMany(ctx, liveFilter(bson.M{"_id": bson.M{"$in": ids}}), bson.M{
+		"$set": bson.M{"expiresAt": expiresAt},
+	})
+	return err
+}
+
+// Delete 删除凭据
+func (s *MongoStore) Delete(ctx context.Context, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result, err := s.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// List 按ID顺序返回afterID之后不是用excludeKeyIDs中的密钥加密的凭据
+func (s *MongoStore) List(ctx context.Context, afterID string, excludeKeyIDs []string, limit int) ([]*Secret, error) {
+	filter := bson.M{"keyId": bson.M{"$nin": excludeKeyIDs}}
+	if afterID != "" {
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+	cursor, err := s.coll.Find(ctx, liveFilter(filter),
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*Secret{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CountByKey 返回每个密钥加密的凭据数
+func (s *MongoStore) CountByKey(ctx context.Context) (map[string]int64, error) {
+	cursor, err := s.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: liveFilter(bson.M{})}},
+		{{Key: "$group", Value: bson.M{"_id": "$keyId", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := make(map[string]int64)
+	for cursor.Next(ctx) {
+		var group struct {
+			KeyID string `bson:"_id"`
+			Count int64  `bson:"count"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return nil, err
+		}
+		counts[group.KeyID] = group.Count
+	}
+	return counts, cursor.Err()
+}
+
+// Persistent MongoDB中的凭据在服务重启后仍然存在
+func (s *MongoStore) Persistent() bool {
+	return true
+}
+
+// liveFilter 在筛选条件上加上未过期的条件，没有过期时间的凭据永不过期
+// TTL任务约每分钟运行一次，因此读取时仍会按expiresAt过滤
+func liveFilter(filter bson.M) bson.M {
+	filter["$or"] = bson.A{
+		bson.M{"expiresAt": bson.M{"$exists": false}},
+		bson.M{"expiresAt": bson.M{"$gt": time.Now()}},
+	}
+	return filter
+}
@@ -0,0 +1,352 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ephemeralKeyID 未配置密钥时随机生成的进程内密钥的ID
+const ephemeralKeyID = "ephemeral"
+
+// maxCachedSecrets 解密结果缓存的最大条数，超出后清空缓存
+const maxCachedSecrets = 10000
+
+// reencryptBatchSize 重新加密时每次读取的凭据数
+const reencryptBatchSize = 100
+
+// ErrNoKeys 未配置任何加密密钥
+var ErrNoKeys = errors.New("未配置凭据加密密钥")
+
+// Vault 使用AES-GCM加密保存数据库密码等凭据，调用方只持有凭据ID
+// 密钥环中可以有多个密钥：新凭据使用当前密钥加密，旧密钥只用于解密，
+// 轮换密钥时将新密钥设为当前密钥并保留旧密钥，再调用ReEncrypt用新密钥重新加密全部凭据
+type Vault struct {
+	store  Store
+	keys   map[string]cipher.AEAD
+	active string
+
+	// 凭据内容不会改变（重新加密不改变明文），解密结果可以安全地缓存
+	cache      map[string]string
+	cacheMutex sync.Mutex
+}
+
+// NewVault 创建凭据库，keys为密钥ID -> 密钥，密钥经SHA-256派生为256位AES密钥
+// activeKeyID为空且只有一个密钥时使用该密钥；store为nil时凭据只保存在进程内存中
+func NewVault(store Store, keys map[string]string, activeKeyID string) (*Vault, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	if activeKeyID == "" {
+		if len(keys) > 1 {
+			return nil, errors.New("配置了多个凭据加密密钥时必须指定当前密钥")
+		}
+		for id := range keys {
+			activeKeyID = id
+		}
+	}
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("当前凭据加密密钥 %s 不在密钥列表中", activeKeyID)
+	}
+
+	v := &Vault{
+		store:  store,
+		keys:   make(map[string]cipher.AEAD, len(keys)),
+		active: activeKeyID,
+		cache:  make(map[string]string),
+	}
+	for id, secret := range keys {
+		if secret == "" {
+			return nil, fmt.Errorf("凭据加密密钥 %s 不能为空", id)
+		}
+		aead, err := newAEAD(secret)
+		if err != nil {
+			return nil, err
+		}
+		v.keys[id] = aead
+	}
+	return v, nil
+}
+
+// NewEphemeralVault 创建使用随机密钥的进程内凭据库，服务重启后凭据全部失效
+func NewEphemeralVault() (*Vault, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return NewVault(NewMemoryStore(), map[string]string{ephemeralKeyID: hex.EncodeToString(key)}, ephemeralKeyID)
+}
+
+// newAEAD 由密钥派生AES-GCM加密器
+func newAEAD(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Persistent 凭据在服务重启后是否仍然存在
+func (v *Vault) Persistent() bool {
+	return v.store.Persistent()
+}
+
+// Put 加密保存凭据并返回凭据ID，value为空时返回空ID
+// expiresAt不为零时凭据到期后被删除，用于随会话过期的凭据
+func (v *Vault) Put(ctx context.Context, value string, expiresAt time.Time) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	now := time.Now()
+	secret := &Secret{
+		ID:        uuid.New().String(),
+		KeyID:     v.active,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	ciphertext, err := v.seal(v.active, secret.ID, value)
+	if err != nil {
+		return "", err
+	}
+	secret.Ciphertext = ciphertext
+	if err := v.store.Create(ctx, secret); err != nil {
+		return "", fmt.Errorf("保存凭据失败: %w", err)
+	}
+	v.remember(secret.ID, value)
+	return secret.ID, nil
+}
+
+// Get 返回凭据的明文，id为空时返回空字符串
+func (v *Vault) Get(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	v.cacheMutex.Lock()
+	value, ok := v.cache[id]
+	v.cacheMutex.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	secret, err := v.store.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	value, err = v.open(secret)
+	if err != nil {
+		return "", err
+	}
+	v.remember(id, value)
+	return value, nil
+}
+
+// Info 凭据的元数据，不包含明文和密文
+type Info struct {
+	ID         string     `json:"id"`
+	KeyID      string     `json:"keyId"`
+	Configured bool       `json:"configured"` // 为false时加密使用的密钥已不在配置中，凭据无法解密
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Describe 返回凭据的元数据，不解密凭据，不存在或已过期时返回ErrNotFound
+func (v *Vault) Describe(ctx context.Context, id string) (*Info, error) {
+	secret, err := v.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	_, configured := v.keys[secret.KeyID]
+	info := &Info{
+		ID:         secret.ID,
+		KeyID:      secret.KeyID,
+		Configured: configured,
+		CreatedAt:  secret.CreatedAt,
+		UpdatedAt:  secret.UpdatedAt,
+	}
+	if !secret.ExpiresAt.IsZero() {
+		info.ExpiresAt = &secret.ExpiresAt
+	}
+	return info, nil
+}
+
+// Extend 修改凭据的过期时间
+func (v *Vault) Extend(ctx context.Context, ids []string, expiresAt time.Time) error {
+	return v.store.Extend(ctx, nonEmpty(ids), expiresAt)
+}
+
+// Delete 删除凭据，空ID被忽略
+func (v *Vault) Delete(ctx context.Context, ids ...string) error {
+	ids = nonEmpty(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	v.cacheMutex.Lock()
+	for _, id := range ids {
+		delete(v.cache, id)
+	}
+	v.cacheMutex.Unlock()
+	_, err := v.store.Delete(ctx, ids)
+	return err
+}
+
+// seal 用指定密钥加密，凭据ID作为附加数据，密文不能被挪用到其他凭据
+func (v *Vault) seal(keyID, id, value string) ([]byte, error) {
+	aead := v.keys[keyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, []byte(value), []byte(id)), nil
+}
+
+// open 解密凭据
+func (v *Vault) open(secret *Secret) (string, error) {
+	aead, ok := v.keys[secret.KeyID]
+	if !ok {
+		return "", fmt.Errorf("凭据 %s 使用的密钥 %s 不在密钥列表中", secret.ID, secret.KeyID)
+	}
+	nonceSize := aead.NonceSize()
+	if len(secret.Ciphertext) < nonceSize {
+		return "", fmt.Errorf("凭据 %s 格式无效", secret.ID)
+	}
+	plaintext, err := aead.Open(nil, secret.Ciphertext[:nonceSize], secret.Ciphertext[nonceSize:], []byte(secret.ID))
+	if err != nil {
+		return "", fmt.Errorf("解密凭据 %s 失败，请检查密钥 %s", secret.ID, secret.KeyID)
+	}
+	return string(plaintext), nil
+}
+
+// remember 缓存解密结果
+func (v *Vault) remember(id, value string) {
+	v.cacheMutex.Lock()
+	defer v.cacheMutex.Unlock()
+	if len(v.cache) >= maxCachedSecrets {
+		v.cache = make(map[string]string)
+	}
+	v.cache[id] = value
+}
+
+// nonEmpty 去掉空ID
+func nonEmpty(ids []string) []string {
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// ReEncryptFailure 无法重新加密的凭据
+type ReEncryptFailure struct {
+	ID    string `json:"id"`
+	KeyID string `json:"keyId"`
+	Error string `json:"error"`
+}
+
+// ReEncryptResult 重新加密的结果
+type ReEncryptResult struct {
+	ActiveKey   string             `json:"activeKey"`
+	ReEncrypted int                `json:"reencrypted"` // 改用当前密钥加密的凭据数
+	Failed      []ReEncryptFailure `json:"failed"`      // 密钥已不在密钥列表中或无法解密的凭据
+}
+
+// ReEncrypt 用当前密钥重新加密所有使用旧密钥加密的凭据，凭据ID和明文不变
+// 多个实例同时执行时每个凭据只会被重新加密一次；全部完成后可以从配置中移除旧密钥
+func (v *Vault) ReEncrypt(ctx context.Context) (*ReEncryptResult, error) {
+	result := &ReEncryptResult{ActiveKey: v.active, Failed: []ReEncryptFailure{}}
+	// 缺少密钥的凭据无法重新加密，之后的批次跳过这些密钥
+	exclude := []string{v.active}
+
+	// 按ID翻页，无法解密的凭据留在原处也不会被重复读取
+	after := ""
+	for {
+		batch, err := v.store.List(ctx, after, exclude, reencryptBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+		after = batch[len(batch)-1].ID
+		for _, secret := range batch {
+			if err := v.reencrypt(ctx, secret); err != nil {
+				result.Failed = append(result.Failed, ReEncryptFailure{ID: secret.ID, KeyID: secret.KeyID, Error: err.Error()})
+				if _, ok := v.keys[secret.KeyID]; !ok {
+					exclude = append(exclude, secret.KeyID)
+				}
+				continue
+			}
+			result.ReEncrypted++
+		}
+	}
+}
+
+// reencrypt 用当前密钥重新加密单个凭据，凭据已被其他实例重新加密或已删除时视为成功
+func (v *Vault) reencrypt(ctx context.Context, secret *Secret) error {
+	value, err := v.open(secret)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := v.seal(v.active, secret.ID, value)
+	if err != nil {
+		return err
+	}
+	updated := &Secret{ID: secret.ID, KeyID: v.active, Ciphertext: ciphertext, UpdatedAt: time.Now()}
+	err = v.store.Replace(ctx, updated, secret.KeyID)
+	if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// KeyStatus 单个密钥的使用情况
+type KeyStatus struct {
+	ID         string `json:"id"`
+	Active     bool   `json:"active"`     // 新凭据使用该密钥加密
+	Configured bool   `json:"configured"` // 为false时使用该密钥的凭据已无法解密
+	Secrets    int64  `json:"secrets"`    // 使用该密钥加密的凭据数
+}
+
+// Status 凭据库的状态，不包含密钥内容
+type Status struct {
+	ActiveKey  string      `json:"activeKey"`
+	Persistent bool        `json:"persistent"` // 为false时凭据只保存在进程内存中
+	Keys       []KeyStatus `json:"keys"`
+}
+
+// Status 返回每个密钥加密的凭据数，数据库中还有凭据但已不在配置中的密钥也会列出
+func (v *Vault) Status(ctx context.Context) (*Status, error) {
+	counts, err := v.store.CountByKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{ActiveKey: v.active, Persistent: v.Persistent(), Keys: []KeyStatus{}}
+	for id := range v.keys {
+		status.Keys = append(status.Keys, KeyStatus{ID: id, Active: id == v.active, Configured: true, Secrets: counts[id]})
+	}
+	for id, count := range counts {
+		if _, ok := v.keys[id]; !ok {
+			status.Keys = append(status.Keys, KeyStatus{ID: id, Secrets: count})
+		}
+	}
+	sort.Slice(status.Keys, func(i, j int) bool { return status.Keys[i].ID < status.Keys[j].ID })
+	return status, nil
+}
@@ -0,0 +1,282 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewVault(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[string]string
+		active  string
+		wantErr bool
+	}{
+		{"单个密钥不指定当前密钥", map[string]string{"k1": "secret-1"}, "", false},
+		{"多个密钥指定当前密钥", map[string]string{"k1": "secret-1", "k2": "secret-2"}, "k2", false},
+		{"没有密钥", nil, "", true},
+		{"多个密钥未指定当前密钥", map[string]string{"k1": "secret-1", "k2": "secret-2"}, "", true},
+		{"当前密钥不在列表中", map[string]string{"k1": "secret-1"}, "k2", true},
+		{"密钥为空", map[string]string{"k1": ""}, "k1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVault(nil, tt.keys, tt.active)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewVault() err = %v, 期望出错: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	vault, err := NewVault(store, map[string]string{"k1": "secret-1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不共享缓存的凭据库，确保从存储中解密
+	reader, err := NewVault(store, map[string]string{"k1": "secret-1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ASCII", "p@ssw0rd"},
+		{"中文", "数据库密码"},
+		{"连接URI", "mongodb://user:pw@host:27017/db?authSource=admin"},
+		{"长值", strings.Repeat("x", 4096)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := vault.Put(ctx, tt.value, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret, err := store.Get(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(secret.Ciphertext), tt.value) {
+				t.Error("密文中包含明文")
+			}
+			got, err := reader.Get(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.value {
+				t.Errorf("Get() = %q, 期望 %q", got, tt.value)
+			}
+		})
+	}
+
+	t.Run("空值不保存", func(t *testing.T) {
+		id, err := vault.Put(ctx, "", time.Time{})
+		if err != nil || id != "" {
+			t.Errorf("Put(\"\") = %q, %v, 期望空ID", id, err)
+		}
+		if got, err := vault.Get(ctx, ""); err != nil || got != "" {
+			t.Errorf("Get(\"\") = %q, %v, 期望空字符串", got, err)
+		}
+	})
+
+	t.Run("不存在的凭据", func(t *testing.T) {
+		if _, err := reader.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get() err = %v, 期望 ErrNotFound", err)
+		}
+	})
+
+	t.Run("已过期的凭据", func(t *testing.T) {
+		id, err := vault.Put(ctx, "expired", time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reader.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get() err = %v, 期望 ErrNotFound", err)
+		}
+	})
+}
+
+func TestVaultRejectsTamperedSecrets(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	vault, err := NewVault(store, map[string]string{"k1": "secret-1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := vault.Put(ctx, "p@ssw0rd", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		secret func() *Secret
+	}{
+		{"密文挪用到其他凭据ID", func() *Secret {
+			moved := *original
+			moved.ID = "other-id"
+			return &moved
+		}},
+		{"密文被修改", func() *Secret {
+			tampered := *original
+			tampered.Ciphertext = append([]byte(nil), original.Ciphertext...)
+			tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 0xff
+			return &tampered
+		}},
+		{"密文被截断", func() *Secret {
+			truncated := *original
+			truncated.Ciphertext = original.Ciphertext[:4]
+			return &truncated
+		}},
+		{"密钥不在密钥列表中", func() *Secret {
+			unknown := *original
+			unknown.KeyID = "k2"
+			return &unknown
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value, err := vault.open(tt.secret()); err == nil {
+				t.Errorf("open() = %q, 期望解密失败", value)
+			}
+		})
+	}
+
+	t.Run("其他密钥无法解密", func(t *testing.T) {
+		other, err := NewVault(store, map[string]string{"k1": "secret-2"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.Get(ctx, id); err == nil {
+			t.Error("Get() 期望解密失败")
+		}
+	})
+}
+
+func TestVaultReEncrypt(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	old, err := NewVault(store, map[string]string{"old": "old-secret"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, value := range []string{"first", "second", "third"} {
+		id, err := old.Put(ctx, value, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[id] = value
+	}
+	// 使用已移除的密钥加密的凭据无法重新加密
+	lost, err := NewVault(store, map[string]string{"lost": "lost-secret"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lostID, err := lost.Put(ctx, "lost", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewVault(store, map[string]string{"old": "old-secret", "new": "new-secret"}, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := rotated.ReEncrypt(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReEncrypted != len(values) {
+		t.Errorf("ReEncrypted = %d, 期望 %d", result.ReEncrypted, len(values))
+	}
+	if len(result.Failed) != 1 || result.Failed[0].ID != lostID || result.Failed[0].KeyID != "lost" {
+		t.Errorf("Failed = %+v, 期望只有凭据 %s", result.Failed, lostID)
+	}
+
+	// 再次执行没有需要重新加密的凭据
+	again, err := rotated.ReEncrypt(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ReEncrypted != 0 {
+		t.Errorf("第二次 ReEncrypted = %d, 期望 0", again.ReEncrypted)
+	}
+
+	// 移除旧密钥后凭据ID和明文不变
+	current, err := NewVault(store, map[string]string{"new": "new-secret"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, value := range values {
+		got, err := current.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("Get(%s) err = %v", id, err)
+		}
+		if got != value {
+			t.Errorf("Get(%s) = %q, 期望 %q", id, got, value)
+		}
+		info, err := current.Describe(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.KeyID != "new" || !info.Configured {
+			t.Errorf("Describe(%s) = %+v, 期望使用密钥 new", id, info)
+		}
+	}
+}
+
+func TestVaultReEncryptSkipsCorruptSecrets(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	old, err := NewVault(store, map[string]string{"old": "old-secret"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 密钥仍在配置中但密文已损坏的凭据，数量超过一批，ID排在有效凭据之前
+	corrupt := reencryptBatchSize + 5
+	for i := 0; i < corrupt; i++ {
+		secret := &Secret{ID: fmt.Sprintf("!corrupt-%03d", i), KeyID: "old", Ciphertext: []byte("not a valid ciphertext"), CreatedAt: time.Now()}
+		if err := store.Create(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	validID, err := old.Put(ctx, "valid", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewVault(store, map[string]string{"old": "old-secret", "new": "new-secret"}, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := rotated.ReEncrypt(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReEncrypted != 1 {
+		t.Errorf("ReEncrypted = %d, 期望 1", result.ReEncrypted)
+	}
+	if len(result.Failed) != corrupt {
+		t.Errorf("失败 %d 个, 期望 %d 个", len(result.Failed), corrupt)
+	}
+	info, err := rotated.Describe(ctx, validID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.KeyID != "new" {
+		t.Errorf("有效凭据的密钥 = %s, 期望 new", info.KeyID)
+	}
+}
@@ -0,0 +1,311 @@
+package connmanager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"minds_iolite_backend/internal/models/datasource"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/net/context"
+)
+
+// 连接健康状态
+const (
+	StatusHealthy     = "healthy"     // 最近一次检查成功
+	StatusUnreachable = "unreachable" // 检查失败，正在按退避时间重新连接
+)
+
+// HealthOptions 健康检查设置
+type HealthOptions struct {
+	Interval       time.Duration // 连接正常时两次检查的间隔，每个连接另加最多10%的随机偏移
+	PingTimeout    time.Duration // 单次ping的超时时间
+	InitialBackoff time.Duration // 第一次重新连接失败后的等待时间，之后每次失败翻倍
+	MaxBackoff     time.Duration // 重新连接的最长等待时间
+	HistorySize    int           // 保留的状态变化和延迟样本数
+}
+
+// DefaultHealthOptions 返回默认的健康检查设置
+func DefaultHealthOptions() HealthOptions {
+	return HealthOptions{
+		Interval:       30 * time.Second,
+		PingTimeout:    5 * time.Second,
+		InitialBackoff: time.Second,
+		MaxBackoff:     2 * time.Minute,
+		HistorySize:    50,
+	}
+}
+
+// StatusChange 一次状态变化
+type StatusChange struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// LatencySample 一次检查的结果
+type LatencySample struct {
+	Time      time.Time `json:"time"`
+	LatencyMs float64   `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Health 连接健康状态的快照
+type Health struct {
+	Status              string          `json:"status"`
+	Since               time.Time       `json:"since"` // 进入当前状态的时间
+	LastCheck           time.Time       `json:"lastCheck,omitempty"`
+	NextCheck           time.Time       `json:"nextCheck"`
+	LastError           string          `json:"lastError,omitempty"`
+	ConsecutiveFailures int             `json:"consecutiveFailures"` // 连续重新连接失败的次数
+	AverageLatencyMs    float64         `json:"averageLatencyMs"`    // 保留的成功样本的平均延迟
+	Changes             []StatusChange  `json:"changes"`             // 按时间顺序
+	Latency             []LatencySample `json:"latency"`             // 按时间顺序
+}
+
+// ring 固定容量的环形缓冲区，写满后覆盖最早的元素
+type ring[T any] struct {
+	items []T
+	next  int
+	full  bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	if size <= 0 {
+		size = 1
+	}
+	return &ring[T]{items: make([]T, size)}
+}
+
+func (r *ring[T]) add(item T) {
+	r.items[r.next] = item
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list 按写入顺序返回元素的副本
+func (r *ring[T]) list() []T {
+	if !r.full {
+		return append([]T{}, r.items[:r.next]...)
+	}
+	return append(append([]T{}, r.items[r.next:]...), r.items[:r.next]...)
+}
+
+// healthTracker 记录单个连接的健康状态，由ConnectionState.mutex保护
+type healthTracker struct {
+	status    string
+	since     time.Time
+	lastCheck time.Time
+	nextCheck time.Time
+	lastError string
+	failures  int
+	changes   *ring[StatusChange]
+	latency   *ring[LatencySample]
+}
+
+func newHealthTracker(size int) *healthTracker {
+	now := time.Now()
+	tracker := &healthTracker{
+		status:  StatusHealthy,
+		since:   now,
+		changes: newRing[StatusChange](size),
+		latency: newRing[LatencySample](size),
+	}
+	tracker.changes.add(StatusChange{Time: now, Status: StatusHealthy, Reason: "连接已建立"})
+	return tracker
+}
+
+// setStatus 状态变化时记录
+func (t *healthTracker) setStatus(status, reason string, now time.Time) {
+	if t.status == status {
+		return
+	}
+	t.status = status
+	t.since = now
+	t.changes.add(StatusChange{Time: now, Status: status, Reason: reason})
+}
+
+// snapshot 返回当前状态的副本
+func (t *healthTracker) snapshot() *Health {
+	health := &Health{
+		Status:              t.status,
+		Since:               t.since,
+		LastCheck:           t.lastCheck,
+		NextCheck:           t.nextCheck,
+		LastError:           t.lastError,
+		ConsecutiveFailures: t.failures,
+		Changes:             t.changes.list(),
+		Latency:             t.latency.list(),
+	}
+	var total float64
+	var count int
+	for _, sample := range health.Latency {
+		if sample.Error == "" {
+			total += sample.LatencyMs
+			count++
+		}
+	}
+	if count > 0 {
+		health.AverageLatencyMs = total / float64(count)
+	}
+	return health
+}
+
+// startMonitor 为新保存的连接启动健康检查
+func (m *SessionManager) startMonitor(state *ConnectionState) {
+	state.health = newHealthTracker(m.healthOptions.HistorySize)
+	state.health.nextCheck = time.Now().Add(m.healthOptions.Interval)
+	state.stop = make(chan struct{})
+	go m.monitor(state)
+}
+
+// monitor 按连接自己的时间表检查连接，直到连接被关闭
+func (m *SessionManager) monitor(state *ConnectionState) {
+	timer := time.NewTimer(m.jitter(m.healthOptions.Interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-state.stop:
+			return
+		case <-timer.C:
+			delay, _ := m.check(state)
+			timer.Reset(delay)
+		}
+	}
+}
+
+// jitter 在间隔上加最多10%的随机偏移，避免所有连接同时检查
+func (m *SessionManager) jitter(interval time.Duration) time.Duration {
+	if spread := int64(interval / 10); spread > 0 {
+		return interval + time.Duration(rand.Int63n(spread))
+	}
+	return interval
+}
+
+// backoff 返回第failures次重新连接失败后的等待时间
+func (m *SessionManager) backoff(failures int) time.Duration {
+	delay := m.healthOptions.InitialBackoff
+	for i := 1; i < failures && delay < m.healthOptions.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > m.healthOptions.MaxBackoff {
+		delay = m.healthOptions.MaxBackoff
+	}
+	return delay
+}
+
+// check 检查一次连接，连接不可用时尝试重新连接，返回下次检查前的等待时间
+// 同一连接的检查互斥执行；ping和重新连接期间不持有任何锁，不影响其他会话
+func (m *SessionManager) check(state *ConnectionState) (time.Duration, error) {
+	state.checkMutex.Lock()
+	defer state.checkMutex.Unlock()
+
+	state.mutex.Lock()
+	if state.closed {
+		state.mutex.Unlock()
+		return m.healthOptions.Interval, errors.New("会话已关闭")
+	}
+	info, client, db, connected := state.Info, state.MongoConn, state.SQLConn, state.Connected
+	state.mutex.Unlock()
+
+	if connected {
+		start := time.Now()
+		err := m.ping(info, client, db)
+		now := time.Now()
+		sample := LatencySample{Time: now, LatencyMs: float64(now.Sub(start).Microseconds()) / 1000}
+
+		state.mutex.Lock()
+		health := state.health
+		health.lastCheck = now
+		if err == nil {
+			health.latency.add(sample)
+			health.lastError = ""
+			health.nextCheck = now.Add(m.healthOptions.Interval)
+			state.mutex.Unlock()
+			return m.jitter(m.healthOptions.Interval), nil
+		}
+		sample.Error = err.Error()
+		health.latency.add(sample)
+		health.lastError = err.Error()
+		health.setStatus(StatusUnreachable, err.Error(), now)
+		state.Connected = false
+		state.Error = err.Error()
+		state.mutex.Unlock()
+	}
+
+	return m.reconnect(state)
+}
+
+// reconnect 重新获取连接并替换旧连接，失败时按指数退避安排下次尝试
+// 新连接获取成功后才释放旧连接的引用，共享的连接池不会因此被关闭
+func (m *SessionManager) reconnect(state *ConnectionState) (time.Duration, error) {
+	state.mutex.Lock()
+	fresh := &ConnectionState{Info: state.Info}
+	state.mutex.Unlock()
+
+	err := m.connect(fresh)
+	now := time.Now()
+
+	state.mutex.Lock()
+	health := state.health
+	health.lastCheck = now
+	if err != nil {
+		health.failures++
+		health.lastError = err.Error()
+		delay := m.backoff(health.failures)
+		health.nextCheck = now.Add(delay)
+		state.Error = err.Error()
+		state.mutex.Unlock()
+		return delay, err
+	}
+	if state.closed {
+		// 重新连接期间会话被关闭
+		state.mutex.Unlock()
+		releaseHandles(fresh.release)
+		return m.healthOptions.Interval, errors.New("会话已关闭")
+	}
+	oldRelease := state.release
+	state.MongoConn, state.SQLConn, state.release = fresh.MongoConn, fresh.SQLConn, fresh.release
+	state.Connected = true
+	state.Error = ""
+	reason := "重新连接成功"
+	if health.failures > 0 {
+		reason = fmt.Sprintf("重新连接成功（失败%d次后）", health.failures)
+	}
+	health.failures = 0
+	health.lastError = ""
+	health.setStatus(StatusHealthy, reason, now)
+	health.nextCheck = now.Add(m.healthOptions.Interval)
+	state.mutex.Unlock()
+
+	releaseHandles(oldRelease)
+	return m.jitter(m.healthOptions.Interval), nil
+}
+
+// ping 检查连接是否可用，CSV会话检查文件是否仍可读取
+func (m *SessionManager) ping(info ConnectionInfo, client *mongo.Client, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.healthOptions.PingTimeout)
+	defer cancel()
+
+	switch info.Type {
+	case MongoDB:
+		if client == nil {
+			return errors.New("MongoDB连接已关闭")
+		}
+		return client.Ping(ctx, nil)
+	case MySQL, SQLite:
+		if db == nil {
+			return errors.New("SQL连接已关闭")
+		}
+		return db.PingContext(ctx)
+	case CSV:
+		return datasource.NewCSVSource(info.FilePath).Validate()
+	}
+	return errors.New("不支持的数据库类型")
+}
@@ -6,11 +6,19 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"minds_iolite_backend/internal/datasource/pool"
+	"minds_iolite_backend/internal/datasource/providers/mongodb"
+	"minds_iolite_backend/internal/datasource/providers/mysql"
+	"minds_iolite_backend/internal/datasource/providers/sqlite"
+	"minds_iolite_backend/internal/models/datasource"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/net/context"
 )
 
@@ -21,6 +29,7 @@ const (
 	MongoDB ConnectionType = "mongodb"
 	MySQL   ConnectionType = "mysql"
 	SQLite  ConnectionType = "sqlite"
+	CSV     ConnectionType = "csv"
 )
 
 // ConnectionInfo 存储连接信息
@@ -31,11 +40,13 @@ type ConnectionInfo struct {
 	Username string         `json:"username,omitempty"`
 	Password string         `json:"password,omitempty"`
 	Database string         `json:"database,omitempty"`
-	FilePath string         `json:"filePath,omitempty"` // 用于SQLite
+	FilePath string         `json:"filePath,omitempty"` // 用于SQLite和CSV
 	URI      string         `json:"uri,omitempty"`      // 用于MongoDB
+	ReadOnly bool           `json:"readOnly"`           // 以只读方式连接外部数据源
 }
 
 // ConnectionState 记录连接状态
+// 连接句柄和状态由mutex保护，ping和重新连接等网络操作期间不持有锁
 type ConnectionState struct {
 	Info        ConnectionInfo `json:"info"`
 	Connected   bool           `json:"connected"`
@@ -45,14 +56,23 @@ type ConnectionState struct {
 	SQLConn     *sql.DB        `json:"-"`
 	Tables      map[string]any `json:"tables,omitempty"`      // MySQL/SQLite表信息
 	Collections map[string]any `json:"collections,omitempty"` // MongoDB集合信息
+
+	mutex      sync.Mutex
+	checkMutex sync.Mutex     // 保证同一连接同时只有一次健康检查
+	health     *healthTracker // 健康检查记录
+	stop       chan struct{}  // 关闭后停止健康检查
+	closed     bool
+	release    func() // 释放共享连接池的引用
 }
 
 // SessionManager 管理会话和连接
+// mutex只保护会话表，不在持有时执行网络操作
 type SessionManager struct {
 	sessions        map[string]*ConnectionState
 	maxIdle         time.Duration
 	mutex           sync.RWMutex
 	cleanupInterval time.Duration
+	healthOptions   HealthOptions
 }
 
 // 创建新的会话管理器
@@ -61,11 +81,19 @@ func NewSessionManager() *SessionManager {
 		sessions:        make(map[string]*ConnectionState),
 		maxIdle:         30 * time.Minute, // 默认30分钟超时
 		cleanupInterval: 5 * time.Minute,  // 每5分钟清理过期连接
+		healthOptions:   DefaultHealthOptions(),
 	}
 	go manager.cleanupRoutine()
 	return manager
 }
 
+// SetMaxIdle 设置连接的最长空闲时间，超过后连接会被释放
+func (m *SessionManager) SetMaxIdle(maxIdle time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.maxIdle = maxIdle
+}
+
 // 定期清理过期的连接
 func (m *SessionManager) cleanupRoutine() {
 	ticker := time.NewTicker(m.cleanupInterval)
@@ -77,109 +105,196 @@ func (m *SessionManager) cleanupRoutine() {
 }
 
 // CleanupSessions 清理过期连接
+// 只释放本实例长时间未使用的连接，会话本身的过期由会话存储负责，连接被清理后可以重新建立
 func (m *SessionManager) CleanupSessions() {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
 	now := time.Now()
+	expired := make(map[string]*ConnectionState)
 	for sessionID, state := range m.sessions {
-		if now.Sub(state.LastActive) > m.maxIdle {
-			// 关闭连接
-			m.closeConnection(state)
+		state.mutex.Lock()
+		idle := now.Sub(state.LastActive) > m.maxIdle
+		state.mutex.Unlock()
+		if idle {
+			expired[sessionID] = state
 			delete(m.sessions, sessionID)
-			log.Printf("已清理过期连接: %s (%s)", sessionID, state.Info.Type)
 		}
 	}
+	m.mutex.Unlock()
+
+	// 在锁外关闭连接
+	for sessionID, state := range expired {
+		m.closeConnection(state)
+		log.Printf("已清理过期连接: %s (%s)", sessionID, state.Info.Type)
+	}
 }
 
-// GetSession 获取指定会话
-func (m *SessionManager) GetSession(sessionID string) (*ConnectionState, error) {
+// lookup 返回本实例持有的连接状态
+func (m *SessionManager) lookup(sessionID string) (*ConnectionState, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	if session, exists := m.sessions[sessionID]; exists {
-		// 更新最后活跃时间
-		session.LastActive = time.Now()
-		return session, nil
+	state, exists := m.sessions[sessionID]
+	if !exists {
+		return nil, errors.New("会话不存在")
 	}
-	return nil, errors.New("会话不存在")
+	return state, nil
+}
+
+// GetSession 获取指定会话
+func (m *SessionManager) GetSession(sessionID string) (*ConnectionState, error) {
+	session, err := m.lookup(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	// 更新最后活跃时间
+	session.mutex.Lock()
+	session.LastActive = time.Now()
+	session.mutex.Unlock()
+	return session, nil
+}
+
+// Handles 返回会话当前的连接句柄并更新最后活跃时间
+// 连接可能在刷新时被替换，调用方应每次使用前重新获取
+func (m *SessionManager) Handles(sessionID string) (*mongo.Client, *sql.DB, error) {
+	session, err := m.lookup(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if !session.Connected {
+		return nil, nil, fmt.Errorf("连接已断开: %s", session.Error)
+	}
+	session.LastActive = time.Now()
+	return session.MongoConn, session.SQLConn, nil
 }
 
 // CreateSession 创建新会话
+// 连接在锁外建立并验证，失败时不保存会话
 func (m *SessionManager) CreateSession(sessionID string, info ConnectionInfo) (string, error) {
+	// 创建新的连接状态
+	state := &ConnectionState{
+		Info:       info,
+		Connected:  false,
+		LastActive: time.Now(),
+	}
+
+	// 根据类型建立连接
+	if err := m.connect(state); err != nil {
+		return "", err
+	}
+
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	oldSession := m.sessions[sessionID]
+	// 保存会话并开始健康检查
+	m.sessions[sessionID] = state
+	m.startMonitor(state)
+	m.mutex.Unlock()
+
+	// 如果已存在，关闭旧连接
+	m.closeConnection(oldSession)
+	return sessionID, nil
+}
 
-	// 如果已存在，先关闭旧连接
-	if oldSession, exists := m.sessions[sessionID]; exists {
-		m.closeConnection(oldSession)
-		delete(m.sessions, sessionID)
+// EnsureSession 确保本实例持有会话的连接，不存在时按连接信息建立
+// 会话状态持久化后可能由其他实例创建，或本实例的空闲连接已被清理，此时需要重新建立连接
+// 返回值表示本次调用是否新建了连接
+func (m *SessionManager) EnsureSession(sessionID string, info ConnectionInfo) (bool, error) {
+	m.mutex.RLock()
+	_, exists := m.sessions[sessionID]
+	m.mutex.RUnlock()
+	if exists {
+		return false, nil
 	}
 
-	// 创建新的连接状态
 	state := &ConnectionState{
 		Info:       info,
 		Connected:  false,
 		LastActive: time.Now(),
 	}
+	if err := m.connect(state); err != nil {
+		return false, err
+	}
 
-	// 根据类型建立连接
+	m.mutex.Lock()
+	// 并发请求已经建立了连接时，保留已有连接
+	if _, exists := m.sessions[sessionID]; exists {
+		m.mutex.Unlock()
+		releaseHandles(state.release)
+		return false, nil
+	}
+	m.sessions[sessionID] = state
+	m.startMonitor(state)
+	m.mutex.Unlock()
+	return true, nil
+}
+
+// connect 根据连接类型建立连接
+func (m *SessionManager) connect(state *ConnectionState) error {
 	var err error
-	switch info.Type {
+	switch state.Info.Type {
 	case MongoDB:
 		err = m.connectMongoDB(state)
 	case MySQL:
 		err = m.connectMySQL(state)
 	case SQLite:
 		err = m.connectSQLite(state)
+	case CSV:
+		err = m.connectCSV(state)
 	default:
-		return "", errors.New("不支持的数据库类型")
+		err = errors.New("不支持的数据库类型")
 	}
-
 	if err != nil {
 		state.Error = err.Error()
-		return "", err
+		return err
 	}
-
-	// 保存会话
-	m.sessions[sessionID] = state
-	return sessionID, nil
+	state.Error = ""
+	return nil
 }
 
 // CloseSession 关闭指定会话
 func (m *SessionManager) CloseSession(sessionID string) error {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	session, exists := m.sessions[sessionID]
+	delete(m.sessions, sessionID)
+	m.mutex.Unlock()
 
-	if session, exists := m.sessions[sessionID]; exists {
-		m.closeConnection(session)
-		delete(m.sessions, sessionID)
-		return nil
+	if !exists {
+		return errors.New("会话不存在")
 	}
-	return errors.New("会话不存在")
+	m.closeConnection(session)
+	return nil
 }
 
-// 关闭连接
+// closeConnection 停止健康检查并关闭连接，调用方不能持有state.mutex
 func (m *SessionManager) closeConnection(state *ConnectionState) {
 	if state == nil {
 		return
 	}
 
-	switch state.Info.Type {
-	case MongoDB:
-		if state.MongoConn != nil {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			state.MongoConn.Disconnect(ctx)
-			state.MongoConn = nil
-		}
-	case MySQL, SQLite:
-		if state.SQLConn != nil {
-			state.SQLConn.Close()
-			state.SQLConn = nil
-		}
+	state.mutex.Lock()
+	if state.closed {
+		state.mutex.Unlock()
+		return
 	}
+	state.closed = true
+	if state.stop != nil {
+		close(state.stop)
+	}
+	release := state.release
+	state.MongoConn, state.SQLConn, state.release = nil, nil, nil
 	state.Connected = false
+	state.mutex.Unlock()
+
+	releaseHandles(release)
+}
+
+// releaseHandles 释放连接池引用，共享的连接池在没有其他会话使用时由连接池注册表关闭
+func releaseHandles(release func()) {
+	if release != nil {
+		release()
+	}
 }
 
 // 连接MongoDB
@@ -205,44 +320,33 @@ func (m *SessionManager) connectMongoDB(state *ConnectionState) error {
 			}
 		}
 
-		// 构建URI
-		if state.Info.Username != "" && state.Info.Password != "" {
-			uri = fmt.Sprintf("mongodb://%s:%s@%s:%s/%s",
-				state.Info.Username,
-				state.Info.Password,
-				host,
-				portStr,
-				state.Info.Database)
-		} else if state.Info.Username != "" {
-			uri = fmt.Sprintf("mongodb://%s@%s:%s/%s",
-				state.Info.Username,
-				host,
-				portStr,
-				state.Info.Database)
-		} else {
-			uri = fmt.Sprintf("mongodb://%s:%s/%s",
-				host,
-				portStr,
-				state.Info.Database)
-		}
+		// 构建URI，用户名和密码会被转义
+		uri = mongodb.BuildURI(mongodb.URIParams{
+			Host:     host,
+			Port:     portStr,
+			Username: state.Info.Username,
+			Password: state.Info.Password,
+			Database: state.Info.Database,
+		})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	clientOptions := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(ctx, clientOptions)
+	// 连接池按主机列表计入单个目标的连接数限制
+	uriInfo, err := mongodb.ParseURI(uri)
 	if err != nil {
 		return err
 	}
 
-	// 验证连接
-	err = client.Ping(ctx, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 从共享连接池获取客户端并验证连接，只读会话优先从从节点读取
+	client, release, err := pool.GetRegistry().AcquireMongo(ctx, uri, state.Info.ReadOnly, strings.Join(uriInfo.Hosts, ","))
 	if err != nil {
 		return err
 	}
 
 	state.MongoConn = client
+	state.release = release
 	state.Connected = true
 	return nil
 }
@@ -270,53 +374,53 @@ func (m *SessionManager) connectMySQL(state *ConnectionState) error {
 		}
 	}
 
-	// 构建DSN (Data Source Name)
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true&loc=Local",
-		state.Info.Username,
-		state.Info.Password,
-		host,
-		port,
-		state.Info.Database)
-
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return err
-	}
+	// 构建DSN (Data Source Name)，只读会话的连接会设置为只读事务模式
+	dsn := mysql.BuildDSN(host, port, state.Info.Username, state.Info.Password, state.Info.Database, state.Info.ReadOnly)
 
-	// 设置连接池
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
-
-	// 验证连接
-	err = db.Ping()
+	// 从共享连接池获取连接并验证，连接参数相同的会话复用同一个连接池
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db, release, err := pool.GetRegistry().AcquireSQL(ctx, pool.MySQL, dsn, fmt.Sprintf("%s:%d", host, port), 0)
 	if err != nil {
 		return err
 	}
 
 	state.SQLConn = db
+	state.release = release
 	state.Connected = true
 	return nil
 }
 
 // 连接SQLite
 func (m *SessionManager) connectSQLite(state *ConnectionState) error {
-	if state.Info.FilePath == "" {
-		return errors.New("未提供SQLite文件路径")
-	}
-
-	db, err := sql.Open("sqlite3", state.Info.FilePath)
-	if err != nil {
+	// 文件不存在时sql.Open会创建空数据库，因此先检查文件
+	if err := datasource.NewSQLiteSource(state.Info.FilePath).Validate(); err != nil {
 		return err
 	}
 
-	// 验证连接
-	err = db.Ping()
+	dsn := state.Info.FilePath
+	if state.Info.ReadOnly {
+		dsn = sqlite.ReadOnlyDSN(state.Info.FilePath)
+	}
+	// SQLite同一时间只允许一个写连接，连接池只保留一个连接
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db, release, err := pool.GetRegistry().AcquireSQL(ctx, pool.SQLite, dsn, state.Info.FilePath, 1)
 	if err != nil {
 		return err
 	}
 
 	state.SQLConn = db
+	state.release = release
+	state.Connected = true
+	return nil
+}
+
+// 检查CSV文件，CSV会话没有需要保持的连接
+func (m *SessionManager) connectCSV(state *ConnectionState) error {
+	if err := datasource.NewCSVSource(state.Info.FilePath).Validate(); err != nil {
+		return err
+	}
 	state.Connected = true
 	return nil
 }
@@ -334,63 +438,42 @@ func (m *SessionManager) GetAllSessions() map[string]*ConnectionState {
 	return sessions
 }
 
-// RefreshSession 刷新会话状态
+// RefreshSession 立即检查会话的连接，连接不可用时尝试重新连接
+// 连接处于重新连接的退避等待中时不会提前重试，直接返回最近一次的错误
 func (m *SessionManager) RefreshSession(sessionID string) error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return errors.New("会话不存在")
+	session, err := m.lookup(sessionID)
+	if err != nil {
+		return err
 	}
 
-	// 检查连接是否还活跃
-	var err error
-	switch session.Info.Type {
-	case MongoDB:
-		if session.MongoConn != nil {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			err = session.MongoConn.Ping(ctx, nil)
-		} else {
-			err = errors.New("MongoDB连接已关闭")
-		}
-	case MySQL, SQLite:
-		if session.SQLConn != nil {
-			err = session.SQLConn.Ping()
-		} else {
-			err = errors.New("SQL连接已关闭")
-		}
+	session.mutex.Lock()
+	waiting := !session.Connected && time.Now().Before(session.health.nextCheck)
+	lastError, nextCheck := session.Error, session.health.nextCheck
+	session.mutex.Unlock()
+	if waiting {
+		return fmt.Errorf("连接不可用，将于%s重试: %s", nextCheck.Format(time.RFC3339), lastError)
 	}
 
-	if err != nil {
-		// 连接已断开，尝试重新连接
-		session.Connected = false
-		session.Error = err.Error()
-
-		// 关闭旧连接
-		m.closeConnection(session)
-
-		// 尝试重新连接
-		switch session.Info.Type {
-		case MongoDB:
-			err = m.connectMongoDB(session)
-		case MySQL:
-			err = m.connectMySQL(session)
-		case SQLite:
-			err = m.connectSQLite(session)
-		}
-
-		if err != nil {
-			session.Error = err.Error()
-			return err
-		}
+	if _, err := m.check(session); err != nil {
+		return err
 	}
-
+	session.mutex.Lock()
 	session.LastActive = time.Now()
+	session.mutex.Unlock()
 	return nil
 }
 
+// Health 返回会话连接的健康状态和最近的状态变化
+func (m *SessionManager) Health(sessionID string) (*Health, error) {
+	session, err := m.lookup(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	return session.health.snapshot(), nil
+}
+
 // 全局连接管理器实例
 var globalManager *SessionManager
 var once sync.Once
@@ -0,0 +1,265 @@
+// Package datacopy 在任意数据源与目标之间复制数据
+// 源端使用providers中注册的数据源（csv、sqlite、mysql、mongodb），目标端支持MongoDB集合、SQLite文件和MySQL表
+package datacopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/typesystem"
+)
+
+const (
+	// DefaultBatchSize 默认每批写入的行数
+	DefaultBatchSize = 500
+	// MaxBatchSize 允许的最大批大小
+	MaxBatchSize = 10000
+)
+
+// Mode 写入模式
+type Mode string
+
+const (
+	// ModeReplace 删除并重建目标后写入
+	ModeReplace Mode = "replace"
+	// ModeAppend 追加到已有目标，不存在时创建
+	ModeAppend Mode = "append"
+	// ModeUpsert 按主键插入或更新
+	ModeUpsert Mode = "upsert"
+)
+
+// ParseMode 解析写入模式，空字符串返回ModeAppend
+func ParseMode(value string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return ModeAppend, nil
+	case ModeReplace, ModeAppend, ModeUpsert:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("无效的写入模式: %s，可选值为 replace、append、upsert", value)
+	}
+}
+
+// Options 复制选项
+type Options struct {
+	Mode      Mode     // 写入模式
+	BatchSize int      // 每批写入的行数，<=0时使用默认值
+	DryRun    bool     // 只读取源数据并生成建表语句，不写入目标
+	KeyFields []string // upsert使用的主键字段，为空时使用源结构中的主键
+}
+
+// Normalize 填充默认值并限制批大小
+func (o Options) Normalize() Options {
+	if o.Mode == "" {
+		o.Mode = ModeAppend
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.BatchSize > MaxBatchSize {
+		o.BatchSize = MaxBatchSize
+	}
+	return o
+}
+
+// Column 目标表中的一列，嵌套文档展开后的字段名以下划线连接
+type Column struct {
+	Name string          `json:"name"`
+	Type typesystem.Type `json:"type"`
+}
+
+// Sink 数据写入目标
+type Sink interface {
+	// Kind 返回目标类型名
+	Kind() string
+	// Prepare 按写入模式创建、清空或检查目标，返回执行的语句；DryRun时只返回语句不执行
+	Prepare(ctx context.Context, target string, columns []Column, opts Options) ([]string, error)
+	// Write 写入一批行，返回写入（或更新）的行数
+	Write(ctx context.Context, target string, rows []providers.Row, opts Options) (int64, error)
+	// Close 关闭目标连接
+	Close() error
+}
+
+// Result 复制结果
+type Result struct {
+	Source      string        `json:"source"`
+	Target      string        `json:"target"`
+	Sink        string        `json:"sink"`
+	Mode        Mode          `json:"mode"`
+	DryRun      bool          `json:"dryRun"`
+	KeyFields   []string      `json:"keyFields,omitempty"`
+	Columns     []Column      `json:"columns"`
+	Statements  []string      `json:"statements"`
+	RowsRead    int64         `json:"rowsRead"`
+	RowsWritten int64         `json:"rowsWritten"`
+	Batches     int           `json:"batches"`
+	Duration    time.Duration `json:"-"`
+	DurationMs  int64         `json:"durationMs"`
+}
+
+// ErrNoKeyFields upsert模式下没有可用主键时返回的错误
+var ErrNoKeyFields = errors.New("upsert模式需要指定keyFields，或源数据带有主键")
+
+// ErrNoUniqueKey upsert模式下已有目标表没有与keyFields一致的主键或唯一索引时返回的错误
+var ErrNoUniqueKey = errors.New("upsert模式要求目标表已有与keyFields一致的主键或唯一索引")
+
+// Copy 从源连接读取实体entity的全部数据并写入目标target
+// 目标表结构由源结构和第一批数据推断；之后出现的新列由目标自动补充，结果中的列包含全部数据
+// DryRun时读取全部源数据以统计行数和推断完整列集合，但不修改目标
+func Copy(ctx context.Context, source providers.Connection, entity string, sink Sink, target string, opts Options) (*Result, error) {
+	if entity == "" {
+		return nil, providers.ErrEntityRequired
+	}
+	if target == "" {
+		target = entity
+	}
+	opts = opts.Normalize()
+	start := time.Now()
+
+	schema, err := source.Describe(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.KeyFields) == 0 {
+		for _, field := range schema.Fields {
+			if field.PrimaryKey {
+				opts.KeyFields = append(opts.KeyFields, field.Name)
+			}
+		}
+	}
+	if opts.Mode == ModeUpsert && len(opts.KeyFields) == 0 {
+		return nil, ErrNoKeyFields
+	}
+
+	result := &Result{
+		Source:     entity,
+		Target:     target,
+		Sink:       sink.Kind(),
+		Mode:       opts.Mode,
+		DryRun:     opts.DryRun,
+		KeyFields:  opts.KeyFields,
+		Statements: []string{},
+	}
+	columns := newColumnSet(schema)
+	prepared := false
+	batch := make([]providers.Row, 0, opts.BatchSize)
+
+	// prepare 在写入第一批数据前创建目标
+	prepare := func() error {
+		if prepared {
+			return nil
+		}
+		prepared = true
+		statements, err := sink.Prepare(ctx, target, columns.list(), opts)
+		if err != nil {
+			return err
+		}
+		result.Statements = append(result.Statements, statements...)
+		return nil
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := prepare(); err != nil {
+			return err
+		}
+		written, err := sink.Write(ctx, target, batch, opts)
+		if err != nil {
+			return fmt.Errorf("写入第 %d 批数据失败: %w", result.Batches+1, err)
+		}
+		result.RowsWritten += written
+		result.Batches++
+		batch = batch[:0]
+		return nil
+	}
+
+	err = source.StreamRows(ctx, entity, func(row providers.Row) error {
+		result.RowsRead++
+		columns.observe(row)
+		if opts.DryRun {
+			return nil
+		}
+		batch = append(batch, row)
+		if len(batch) >= opts.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && !opts.DryRun {
+		err = flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 只预览时生成完整列集合对应的语句；源为空时也按模式创建目标
+	if err := prepare(); err != nil {
+		return nil, err
+	}
+
+	result.Columns = columns.list()
+	result.Duration = time.Since(start)
+	result.DurationMs = result.Duration.Milliseconds()
+	return result, nil
+}
+
+// columnSet 按出现顺序记录目标列及其类型
+type columnSet struct {
+	columns []Column
+	index   map[string]int
+	fixed   map[string]bool // 类型来自源结构的列，不再根据数据值修改
+}
+
+// newColumnSet 以源结构中的字段初始化列集合
+// 嵌套对象字段会在观察数据时展开，因此不预先加入
+func newColumnSet(schema *providers.EntitySchema) *columnSet {
+	set := &columnSet{index: make(map[string]int), fixed: make(map[string]bool)}
+	for _, field := range schema.Fields {
+		if field.Type == typesystem.Object {
+			continue
+		}
+		set.add(field.Name, field.Type)
+		if field.Type != typesystem.Null && field.Type != typesystem.Unknown {
+			set.fixed[field.Name] = true
+		}
+	}
+	return set
+}
+
+// add 加入一列，已存在时合并类型
+func (s *columnSet) add(name string, t typesystem.Type) {
+	if i, ok := s.index[name]; ok {
+		if !s.fixed[name] {
+			s.columns[i].Type = typesystem.Merge(s.columns[i].Type, t)
+		}
+		return
+	}
+	s.index[name] = len(s.columns)
+	s.columns = append(s.columns, Column{Name: name, Type: t})
+}
+
+// observe 将一行展开后的字段加入列集合
+func (s *columnSet) observe(row providers.Row) {
+	flat := Flatten(row)
+	for _, name := range sortedKeys(flat) {
+		s.add(name, typesystem.FromValue(flat[name]))
+	}
+}
+
+// list 返回列集合，未能确定类型的列按字符串处理
+func (s *columnSet) list() []Column {
+	result := make([]Column, len(s.columns))
+	for i, column := range s.columns {
+		if column.Type == typesystem.Null || column.Type == typesystem.Unknown {
+			column.Type = typesystem.Str
+		}
+		result[i] = column
+	}
+	return result
+}
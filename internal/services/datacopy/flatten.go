@@ -0,0 +1,88 @@
+package datacopy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"minds_iolite_backend/internal/datasource/providers"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FlattenSeparator 嵌套字段展开后父子字段名之间的分隔符
+const FlattenSeparator = "_"
+
+// Flatten 将嵌套文档展开为单层字段，如 {"address": {"city": "x"}} 展开为 {"address_city": "x"}
+// 数组不展开，写入SQL目标时序列化为JSON字符串
+func Flatten(row providers.Row) providers.Row {
+	flat := make(providers.Row, len(row))
+	for key, value := range row {
+		flattenValue(flat, key, value)
+	}
+	return flat
+}
+
+// flattenValue 递归展开嵌套文档
+func flattenValue(flat providers.Row, prefix string, value interface{}) {
+	var nested map[string]interface{}
+	switch v := value.(type) {
+	case bson.M:
+		nested = v
+	case map[string]interface{}:
+		nested = v
+	case providers.Row:
+		nested = v
+	case bson.D:
+		for _, elem := range v {
+			flattenValue(flat, prefix+FlattenSeparator+elem.Key, elem.Value)
+		}
+		return
+	default:
+		flat[prefix] = value
+		return
+	}
+	for key, child := range nested {
+		flattenValue(flat, prefix+FlattenSeparator+key, child)
+	}
+}
+
+// sortedKeys 返回按字典序排列的字段名，保证列顺序稳定
+func sortedKeys(row providers.Row) []string {
+	keys := make([]string, 0, len(row))
+	for key := range row {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sqlValue 将数据值转换为SQL驱动可写入的值
+// MongoDB特有类型转换为对应的字符串、时间或字节，数组和未展开的文档序列化为JSON字符串
+func sqlValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string, []byte, time.Time,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v, nil
+	case primitive.ObjectID:
+		return v.Hex(), nil
+	case primitive.DateTime:
+		return v.Time(), nil
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0), nil
+	case primitive.Decimal128:
+		return v.String(), nil
+	case primitive.Binary:
+		return v.Data, nil
+	case bson.A, []interface{}, bson.M, bson.D, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("序列化字段值失败: %w", err)
+		}
+		return string(data), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
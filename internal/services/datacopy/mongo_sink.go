@@ -0,0 +1,111 @@
+package datacopy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/providers/mongodb"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoSink 将数据写入MongoDB集合，文档保持原有的嵌套结构
+type MongoSink struct {
+	connector *mongodb.MongoDBConnector
+	db        *mongo.Database
+}
+
+// NewMongoSink 创建写入MongoDB数据库的目标，配置项与MongoDB数据源相同
+func NewMongoSink(cfg providers.Config) (*MongoSink, error) {
+	connector, dbName, err := mongodb.NewMongoDBConnectorFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &MongoSink{connector: connector, db: connector.GetClient().Database(dbName)}, nil
+}
+
+// Kind 返回目标类型名
+func (s *MongoSink) Kind() string {
+	return mongodb.ProviderName
+}
+
+// Prepare replace模式删除目标集合，upsert模式为主键字段创建唯一索引
+// MongoDB不需要建表，列定义被忽略
+func (s *MongoSink) Prepare(ctx context.Context, target string, columns []Column, opts Options) ([]string, error) {
+	var statements []string
+	if opts.Mode == ModeReplace {
+		statements = append(statements, fmt.Sprintf("db.%s.drop()", target))
+		if !opts.DryRun {
+			if err := s.db.Collection(target).Drop(ctx); err != nil {
+				return nil, fmt.Errorf("删除集合 %s 失败: %w", target, err)
+			}
+		}
+	}
+	if opts.Mode == ModeUpsert && !isIDOnly(opts.KeyFields) {
+		keys := bson.D{}
+		fields := make([]string, len(opts.KeyFields))
+		for i, key := range opts.KeyFields {
+			keys = append(keys, bson.E{Key: key, Value: 1})
+			fields[i] = key + ": 1"
+		}
+		statements = append(statements, fmt.Sprintf("db.%s.createIndex({%s}, {unique: true})", target, strings.Join(fields, ", ")))
+		if !opts.DryRun {
+			_, err := s.db.Collection(target).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    keys,
+				Options: options.Index().SetUnique(true),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("创建唯一索引失败: %w", err)
+			}
+		}
+	}
+	return statements, nil
+}
+
+// Write 批量写入文档，upsert模式按主键字段替换已有文档
+func (s *MongoSink) Write(ctx context.Context, target string, rows []providers.Row, opts Options) (int64, error) {
+	coll := s.db.Collection(target)
+	if opts.Mode != ModeUpsert {
+		documents := make([]interface{}, len(rows))
+		for i, row := range rows {
+			documents[i] = bson.M(row)
+		}
+		result, err := coll.InsertMany(ctx, documents)
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(result.InsertedIDs)), nil
+	}
+
+	models := make([]mongo.WriteModel, len(rows))
+	for i, row := range rows {
+		filter := bson.M{}
+		for _, key := range opts.KeyFields {
+			value, ok := row[key]
+			if !ok {
+				return 0, fmt.Errorf("第 %d 行缺少主键字段 %s", i+1, key)
+			}
+			filter[key] = value
+		}
+		models[i] = mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(bson.M(row)).SetUpsert(true)
+	}
+	result, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	return result.UpsertedCount + result.MatchedCount, nil
+}
+
+// Close 断开MongoDB连接
+func (s *MongoSink) Close() error {
+	return s.connector.Close()
+}
+
+// isIDOnly 判断主键是否只有_id，_id自带唯一索引
+func isIDOnly(keys []string) bool {
+	return len(keys) == 1 && keys[0] == "_id"
+}
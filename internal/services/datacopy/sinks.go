@@ -0,0 +1,29 @@
+package datacopy
+
+import (
+	"fmt"
+	"strings"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/providers/mongodb"
+	"minds_iolite_backend/internal/datasource/providers/mysql"
+	"minds_iolite_backend/internal/datasource/providers/sqlite"
+)
+
+// SinkTypes 支持的目标类型
+var SinkTypes = []string{mongodb.ProviderName, mysql.ProviderName, sqlite.ProviderName}
+
+// NewSink 根据目标类型和配置创建写入目标
+// mongodb和mysql的配置项与对应数据源相同，sqlite使用filePath指定文件，文件不存在时自动创建
+func NewSink(kind string, cfg providers.Config) (Sink, error) {
+	switch strings.ToLower(kind) {
+	case mongodb.ProviderName:
+		return NewMongoSink(cfg)
+	case mysql.ProviderName:
+		return NewMySQLSink(cfg)
+	case sqlite.ProviderName:
+		return NewSQLiteSink(cfg.String("filePath", "file_path", "path"))
+	default:
+		return nil, fmt.Errorf("不支持的目标类型: %s，可选值为 %s", kind, strings.Join(SinkTypes, "、"))
+	}
+}
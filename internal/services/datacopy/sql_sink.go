@@ -0,0 +1,478 @@
+package datacopy
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"minds_iolite_backend/internal/datasource/providers"
+	"minds_iolite_backend/internal/datasource/providers/mysql"
+	"minds_iolite_backend/internal/datasource/providers/sqlite"
+	"minds_iolite_backend/internal/datasource/typesystem"
+)
+
+// sqlDialect 描述不同SQL数据库在建表、加列和upsert上的差异
+type sqlDialect struct {
+	name string
+	// quote 引用标识符
+	quote func(name string) string
+	// columnType 返回列类型，key为true时返回可作为主键的类型
+	columnType func(t typesystem.Type, key bool) string
+	// existingColumns 返回已有表的列名，表不存在时返回nil
+	existingColumns func(ctx context.Context, db *sql.DB, table string) ([]string, error)
+	// uniqueKeys 返回已有表的主键和唯一索引，每项为一个键包含的列
+	uniqueKeys func(ctx context.Context, db *sql.DB, table string) ([][]string, error)
+	// upsertClause 返回INSERT语句末尾的冲突处理子句
+	upsertClause func(quote func(string) string, columns, keys []string) string
+}
+
+// quoteSQLite 使用双引号引用标识符
+func quoteSQLite(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteMySQL 使用反引号引用标识符
+func quoteMySQL(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// sqliteDialect SQLite方言
+var sqliteDialect = sqlDialect{
+	name:  sqlite.ProviderName,
+	quote: quoteSQLite,
+	columnType: func(t typesystem.Type, key bool) string {
+		return typesystem.ToSQLite(t)
+	},
+	existingColumns: func(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+		rows, err := db.QueryContext(ctx, "PRAGMA table_info("+quoteSQLite(table)+")")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var columns []string
+		for rows.Next() {
+			var cid, notNull, pk int
+			var name, columnType string
+			var defaultValue sql.NullString
+			if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+				return nil, err
+			}
+			columns = append(columns, name)
+		}
+		return columns, rows.Err()
+	},
+	uniqueKeys: sqliteUniqueKeys,
+	upsertClause: func(quote func(string) string, columns, keys []string) string {
+		quotedKeys := make([]string, len(keys))
+		for i, key := range keys {
+			quotedKeys[i] = quote(key)
+		}
+		updates := updateColumns(columns, keys, func(column string) string {
+			return quote(column) + " = excluded." + quote(column)
+		})
+		if len(updates) == 0 {
+			return fmt.Sprintf(" ON CONFLICT(%s) DO NOTHING", strings.Join(quotedKeys, ", "))
+		}
+		return fmt.Sprintf(" ON CONFLICT(%s) DO UPDATE SET %s", strings.Join(quotedKeys, ", "), strings.Join(updates, ", "))
+	},
+}
+
+// mysqlDialect MySQL方言
+var mysqlDialect = sqlDialect{
+	name:  mysql.ProviderName,
+	quote: quoteMySQL,
+	columnType: func(t typesystem.Type, key bool) string {
+		// TEXT和JSON列不能直接作为主键
+		if key && (t == typesystem.Str || t == typesystem.Array || t == typesystem.Object || t == typesystem.Unknown) {
+			return "VARCHAR(255)"
+		}
+		return typesystem.ToMySQL(t)
+	},
+	existingColumns: func(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+		rows, err := db.QueryContext(ctx, `SELECT COLUMN_NAME FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`, table)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var columns []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return nil, err
+			}
+			columns = append(columns, name)
+		}
+		return columns, rows.Err()
+	},
+	uniqueKeys: func(ctx context.Context, db *sql.DB, table string) ([][]string, error) {
+		rows, err := db.QueryContext(ctx, `SELECT INDEX_NAME, COLUMN_NAME FROM information_schema.STATISTICS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND NON_UNIQUE = 0
+			ORDER BY INDEX_NAME, SEQ_IN_INDEX`, table)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var keys [][]string
+		last := ""
+		for rows.Next() {
+			var index, column string
+			if err := rows.Scan(&index, &column); err != nil {
+				return nil, err
+			}
+			if len(keys) == 0 || index != last {
+				keys = append(keys, nil)
+				last = index
+			}
+			keys[len(keys)-1] = append(keys[len(keys)-1], column)
+		}
+		return keys, rows.Err()
+	},
+	upsertClause: func(quote func(string) string, columns, keys []string) string {
+		updates := updateColumns(columns, keys, func(column string) string {
+			return quote(column) + " = VALUES(" + quote(column) + ")"
+		})
+		if len(updates) == 0 {
+			// 所有列都是主键时保持原行不变
+			updates = []string{quote(keys[0]) + " = " + quote(keys[0])}
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	},
+}
+
+// sqliteUniqueKeys 返回SQLite表的主键和唯一索引
+// INTEGER PRIMARY KEY是rowid的别名，不出现在index_list中，因此主键从table_info读取；部分索引不能用于ON CONFLICT，不计入
+func sqliteUniqueKeys(ctx context.Context, db *sql.DB, table string) ([][]string, error) {
+	var keys [][]string
+	rows, err := db.QueryContext(ctx, "PRAGMA table_info("+quoteSQLite(table)+")")
+	if err != nil {
+		return nil, err
+	}
+	var primary []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if pk > 0 {
+			primary = append(primary, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(primary) > 0 {
+		keys = append(keys, primary)
+	}
+
+	rows, err = db.QueryContext(ctx, "PRAGMA index_list("+quoteSQLite(table)+")")
+	if err != nil {
+		return nil, err
+	}
+	var indexes []string
+	for rows.Next() {
+		var seq, unique, partial int
+		var name, origin string
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if unique == 1 && partial == 0 {
+			indexes = append(indexes, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, index := range indexes {
+		rows, err := db.QueryContext(ctx, "PRAGMA index_info("+quoteSQLite(index)+")")
+		if err != nil {
+			return nil, err
+		}
+		var columns []string
+		for rows.Next() {
+			var seqno, cid int
+			var name sql.NullString
+			if err := rows.Scan(&seqno, &cid, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			// 表达式索引的列名为NULL，不能按列匹配
+			if !name.Valid {
+				columns = nil
+				break
+			}
+			columns = append(columns, name.String)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(columns) > 0 {
+			keys = append(keys, columns)
+		}
+	}
+	return keys, nil
+}
+
+// sameColumns 两组列名是否相同，不区分顺序和大小写
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, name := range a {
+		set[strings.ToLower(name)] = true
+	}
+	for _, name := range b {
+		if !set[strings.ToLower(name)] {
+			return false
+		}
+	}
+	return len(set) == len(b)
+}
+
+// updateColumns 为非主键列生成更新表达式
+func updateColumns(columns, keys []string, expr func(column string) string) []string {
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	var updates []string
+	for _, column := range columns {
+		if !isKey[column] {
+			updates = append(updates, expr(column))
+		}
+	}
+	return updates
+}
+
+// SQLSink 将数据写入SQL表，嵌套文档展开为多列
+type SQLSink struct {
+	db      *sql.DB
+	closer  io.Closer
+	dialect sqlDialect
+	columns []string        // 目标表当前的列，按建表顺序
+	present map[string]bool // 目标表已有的列
+}
+
+// NewSQLiteSink 创建写入SQLite文件的目标，文件不存在时自动创建
+func NewSQLiteSink(filePath string) (*SQLSink, error) {
+	if filePath == "" {
+		return nil, fmt.Errorf("SQLite文件路径不能为空")
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if ext != ".db" && ext != ".sqlite" && ext != ".sqlite3" {
+		return nil, fmt.Errorf("不支持的SQLite文件格式: %s, 仅支持.db, .sqlite, .sqlite3", ext)
+	}
+	if dir := filepath.Dir(filePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建目录失败: %w", err)
+		}
+	}
+	connector, err := sqlite.NewSQLiteConnector(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return newSQLSink(connector.DB(), connector, sqliteDialect), nil
+}
+
+// NewMySQLSink 创建写入MySQL数据库的目标，配置项与MySQL数据源相同
+func NewMySQLSink(cfg providers.Config) (*SQLSink, error) {
+	connector, err := mysql.NewMySQLConnectorFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newSQLSink(connector.DB(), connector, mysqlDialect), nil
+}
+
+// newSQLSink 创建SQL目标
+func newSQLSink(db *sql.DB, closer io.Closer, dialect sqlDialect) *SQLSink {
+	return &SQLSink{db: db, closer: closer, dialect: dialect, present: make(map[string]bool)}
+}
+
+// Kind 返回目标类型名
+func (s *SQLSink) Kind() string {
+	return s.dialect.name
+}
+
+// Prepare 按写入模式建表，已有表缺少的列通过ALTER TABLE补充
+func (s *SQLSink) Prepare(ctx context.Context, target string, columns []Column, opts Options) ([]string, error) {
+	table := s.dialect.quote(target)
+	var statements []string
+	var existing []string
+	s.columns = nil
+	s.present = make(map[string]bool)
+
+	if opts.Mode == ModeReplace {
+		statements = append(statements, "DROP TABLE IF EXISTS "+table)
+	} else {
+		var err error
+		if existing, err = s.dialect.existingColumns(ctx, s.db, target); err != nil {
+			return nil, fmt.Errorf("读取表 %s 结构失败: %w", target, err)
+		}
+	}
+
+	if len(existing) == 0 {
+		statements = append(statements, s.createTable(target, columns, opts.KeyFields))
+		for _, column := range columns {
+			s.addKnown(column.Name)
+		}
+	} else {
+		if opts.Mode == ModeUpsert {
+			if err := s.checkUniqueKey(ctx, target, opts.KeyFields); err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range existing {
+			s.addKnown(name)
+		}
+		for _, column := range columns {
+			if !s.present[column.Name] {
+				statements = append(statements, s.addColumn(target, column))
+				s.addKnown(column.Name)
+			}
+		}
+	}
+
+	if opts.DryRun {
+		return statements, nil
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("执行 %s 失败: %w", statement, err)
+		}
+	}
+	return statements, nil
+}
+
+// checkUniqueKey 检查已有表上有与keyFields完全一致的主键或唯一索引
+// 否则SQLite的ON CONFLICT会报错，MySQL的ON DUPLICATE KEY不会触发而写入重复行
+func (s *SQLSink) checkUniqueKey(ctx context.Context, target string, keyFields []string) error {
+	keys, err := s.dialect.uniqueKeys(ctx, s.db, target)
+	if err != nil {
+		return fmt.Errorf("读取表 %s 的索引失败: %w", target, err)
+	}
+	for _, key := range keys {
+		if sameColumns(key, keyFields) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 表 %s 的 %s", ErrNoUniqueKey, target, strings.Join(keyFields, ", "))
+}
+
+// createTable 生成建表语句，指定主键时加入PRIMARY KEY约束
+func (s *SQLSink) createTable(target string, columns []Column, keys []string) string {
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	definitions := make([]string, 0, len(columns)+1)
+	for _, column := range columns {
+		definitions = append(definitions, s.dialect.quote(column.Name)+" "+s.dialect.columnType(column.Type, isKey[column.Name]))
+	}
+	if len(keys) > 0 {
+		quotedKeys := make([]string, len(keys))
+		for i, key := range keys {
+			quotedKeys[i] = s.dialect.quote(key)
+		}
+		definitions = append(definitions, "PRIMARY KEY ("+strings.Join(quotedKeys, ", ")+")")
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", s.dialect.quote(target), strings.Join(definitions, ", "))
+}
+
+// addColumn 生成加列语句
+func (s *SQLSink) addColumn(target string, column Column) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+		s.dialect.quote(target), s.dialect.quote(column.Name), s.dialect.columnType(column.Type, false))
+}
+
+// addKnown 记录目标表中已有的列
+func (s *SQLSink) addKnown(name string) {
+	if !s.present[name] {
+		s.present[name] = true
+		s.columns = append(s.columns, name)
+	}
+}
+
+// Write 在一个事务中写入一批行，出现新列时先补充列
+func (s *SQLSink) Write(ctx context.Context, target string, rows []providers.Row, opts Options) (int64, error) {
+	flatRows := make([]providers.Row, len(rows))
+	for i, row := range rows {
+		flatRows[i] = Flatten(row)
+		for _, name := range sortedKeys(flatRows[i]) {
+			if s.present[name] {
+				continue
+			}
+			column := Column{Name: name, Type: typesystem.FromValue(flatRows[i][name])}
+			if column.Type == typesystem.Null || column.Type == typesystem.Unknown {
+				column.Type = typesystem.Str
+			}
+			if _, err := s.db.ExecContext(ctx, s.addColumn(target, column)); err != nil {
+				return 0, fmt.Errorf("添加列 %s 失败: %w", name, err)
+			}
+			s.addKnown(name)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.insertStatement(target, opts))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(s.columns))
+	for _, row := range flatRows {
+		for i, column := range s.columns {
+			value, err := sqlValue(row[column])
+			if err != nil {
+				return 0, err
+			}
+			args[i] = value
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
+
+// insertStatement 生成单行插入语句，upsert模式附加冲突处理子句
+func (s *SQLSink) insertStatement(target string, opts Options) string {
+	quoted := make([]string, len(s.columns))
+	placeholders := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		quoted[i] = s.dialect.quote(column)
+		placeholders[i] = "?"
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		s.dialect.quote(target), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	if opts.Mode == ModeUpsert {
+		statement += s.dialect.upsertClause(s.dialect.quote, s.columns, opts.KeyFields)
+	}
+	return statement
+}
+
+// Close 关闭数据库连接
+func (s *SQLSink) Close() error {
+	return s.closer.Close()
+}
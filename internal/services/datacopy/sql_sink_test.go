@@ -0,0 +1,58 @@
+package datacopy
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"minds_iolite_backend/internal/datasource/typesystem"
+)
+
+func TestSQLitePrepareUpsertRequiresUniqueKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  []string
+		keys    []string
+		wantErr bool
+	}{
+		{"没有键的已有表", []string{"CREATE TABLE t (id TEXT, name TEXT)"}, []string{"id"}, true},
+		{"主键", []string{"CREATE TABLE t (id TEXT PRIMARY KEY, name TEXT)"}, []string{"id"}, false},
+		{"INTEGER主键", []string{"CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)"}, []string{"id"}, false},
+		{"复合主键", []string{"CREATE TABLE t (a TEXT, b TEXT, name TEXT, PRIMARY KEY (a, b))"}, []string{"b", "a"}, false},
+		{"唯一索引", []string{"CREATE TABLE t (id TEXT, name TEXT)", "CREATE UNIQUE INDEX t_id ON t (id)"}, []string{"id"}, false},
+		{"普通索引", []string{"CREATE TABLE t (id TEXT, name TEXT)", "CREATE INDEX t_id ON t (id)"}, []string{"id"}, true},
+		{"部分唯一索引", []string{"CREATE TABLE t (id TEXT, name TEXT)", "CREATE UNIQUE INDEX t_id ON t (id) WHERE id IS NOT NULL"}, []string{"id"}, true},
+		{"唯一键只覆盖部分主键字段", []string{"CREATE TABLE t (a TEXT UNIQUE, b TEXT)"}, []string{"a", "b"}, true},
+		{"唯一键包含额外字段", []string{"CREATE TABLE t (a TEXT, b TEXT, UNIQUE (a, b))"}, []string{"a"}, true},
+		{"表不存在时建表", nil, []string{"id"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sink, err := NewSQLiteSink(filepath.Join(t.TempDir(), "target.db"))
+			if err != nil {
+				t.Fatalf("创建目标失败: %v", err)
+			}
+			defer sink.Close()
+			for _, statement := range tt.schema {
+				if _, err := sink.db.ExecContext(ctx, statement); err != nil {
+					t.Fatalf("建表失败: %v", err)
+				}
+			}
+
+			columns := []Column{{Name: "name", Type: typesystem.Str}}
+			for _, key := range tt.keys {
+				columns = append(columns, Column{Name: key, Type: typesystem.Str})
+			}
+			_, err = sink.Prepare(ctx, "t", columns, Options{Mode: ModeUpsert, KeyFields: tt.keys})
+			if gotErr := errors.Is(err, ErrNoUniqueKey); gotErr != tt.wantErr {
+				t.Fatalf("Prepare() 错误 = %v, 期望ErrNoUniqueKey: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Prepare() 错误 = %v", err)
+			}
+		})
+	}
+}
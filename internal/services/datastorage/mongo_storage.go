@@ -6,8 +6,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
-	"time"
 
+	"minds_iolite_backend/internal/datasource/typesystem"
 	"minds_iolite_backend/internal/models/datasource"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -131,7 +131,7 @@ func (s *MongoStorage) GenerateConnectionInfo() (*MongoDBConnectionInfo, error)
 	// 获取字段类型信息
 	fields := make(map[string]string)
 	for key, value := range sampleDoc {
-		fields[key] = getMongoType(value)
+		fields[key] = typesystem.FromValue(value).String()
 	}
 
 	// 将样本文档转为JSON字符串
@@ -160,31 +160,3 @@ func (s *MongoStorage) GenerateConnectionInfo() (*MongoDBConnectionInfo, error)
 
 	return connInfo, nil
 }
-
-// getMongoType 根据值确定MongoDB中的类型名称
-func getMongoType(value interface{}) string {
-	switch value.(type) {
-	case primitive.ObjectID:
-		return "ObjectId"
-	case string:
-		return "str"
-	case int, int32, int64:
-		return "int"
-	case float32, float64:
-		return "float"
-	case bool:
-		return "bool"
-	case time.Time:
-		return "date"
-	case primitive.DateTime:
-		return "date"
-	case bson.A:
-		return "array"
-	case bson.D, bson.M:
-		return "object"
-	case primitive.Binary:
-		return "binary"
-	default:
-		return "unknown"
-	}
-}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"minds_iolite_backend/internal/datasource/typesystem"
+
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -31,6 +33,7 @@ type MySQLConnectionInfo struct {
 type TableInformation struct {
 	Fields     map[string]string `json:"fields"`
 	SampleData string            `json:"sample_data"`
+	Error      string            `json:"error,omitempty"` // 批量推断时该表失败的原因，此时fields为空
 }
 
 // NewMySQLStorage 创建新的MySQL存储服务
@@ -117,7 +120,7 @@ func (s *MySQLStorage) GenerateConnectionInfo() (*MySQLConnectionInfo, error) {
 				columnsRows.Close()
 				return nil, fmt.Errorf("读取表结构失败: %w", err)
 			}
-			fields[field] = s.getMySQLType(fieldType)
+			fields[field] = typesystem.FromMySQL(fieldType).String()
 		}
 		columnsRows.Close()
 
@@ -202,7 +205,7 @@ func (s *MySQLStorage) GenerateConnectionInfoForTable(tableName string) (*MySQLC
 		if err := columnsRows.Scan(&field, &fieldType, &null, &key, &defaultValue, &extra); err != nil {
 			return nil, fmt.Errorf("读取表结构失败: %w", err)
 		}
-		fields[field] = s.getMySQLType(fieldType)
+		fields[field] = typesystem.FromMySQL(fieldType).String()
 	}
 
 	// 获取样本数据
@@ -268,41 +271,3 @@ func (s *MySQLStorage) GenerateConnectionInfoForTable(tableName string) (*MySQLC
 
 	return connInfo, nil
 }
-
-// getMySQLType 根据MySQL类型返回简化类型名称
-func (s *MySQLStorage) getMySQLType(mysqlType string) string {
-	if len(mysqlType) == 0 {
-		return "unknown"
-	}
-
-	// 提取MySQL类型前缀
-	typePrefix := mysqlType
-	// 去除括号和参数部分，如int(11)只保留int
-	for i, char := range typePrefix {
-		if char == '(' {
-			typePrefix = typePrefix[:i]
-			break
-		}
-	}
-
-	// 按类型返回统一的类型名称
-	switch typePrefix {
-	case "int", "smallint", "mediumint", "bigint":
-		return "int"
-	case "tinyint":
-		if mysqlType == "tinyint(1)" {
-			return "bool"
-		}
-		return "int"
-	case "varchar", "char", "text", "longtext":
-		return "str"
-	case "datetime", "date", "timestamp":
-		return "date"
-	case "decimal", "float", "double":
-		return "float"
-	case "blob", "longblob":
-		return "binary"
-	default:
-		return "unknown"
-	}
-}
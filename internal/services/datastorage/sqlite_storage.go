@@ -1,12 +1,15 @@
 package datastorage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"minds_iolite_backend/internal/datasource/providers/mongodb"
+	"minds_iolite_backend/internal/datasource/typesystem"
 	"minds_iolite_backend/internal/models/datasource"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -135,7 +138,9 @@ func (s *SQLiteStorage) ImportSQLiteToMongoDB(tableName, dbName, collName string
 	}
 
 	// 提取MongoDB连接信息
-	connInfo, err := connector.ExtractConnectionInfo(dbName)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	connInfo, err := connector.ExtractConnectionInfo(ctx, dbName, mongodb.ExtractOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("获取连接信息失败: %w", err)
 	}
@@ -163,7 +168,7 @@ func (s *SQLiteStorage) GenerateUnifiedModel(tableName string) (*datasource.Unif
 			return nil, fmt.Errorf("读取表结构失败: %w", err)
 		}
 
-		colType := getColumnType(dataType)
+		colType := typesystem.ToColumnType(typesystem.FromSQLite(dataType))
 		columns = append(columns, datasource.Column{
 			Name:        name,
 			DisplayName: name,
@@ -234,21 +239,3 @@ func (s *SQLiteStorage) GenerateUnifiedModel(tableName string) (*datasource.Unif
 
 	return model, nil
 }
-
-// 将SQLite类型映射到统一列类型
-func getColumnType(sqliteType string) datasource.ColumnType {
-	sqliteType = strings.ToLower(sqliteType)
-
-	switch sqliteType {
-	case "integer", "int":
-		return datasource.ColumnTypeInteger
-	case "real", "float", "double", "numeric", "decimal":
-		return datasource.ColumnTypeFloat
-	case "boolean":
-		return datasource.ColumnTypeBoolean
-	case "date", "datetime", "timestamp":
-		return datasource.ColumnTypeDate
-	default:
-		return datasource.ColumnTypeString
-	}
-}
@@ -3,14 +3,17 @@ package dynamic
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"minds_iolite_backend/internal/audit"
 	"minds_iolite_backend/internal/database"
 	"minds_iolite_backend/internal/models/metadata"
+	"minds_iolite_backend/internal/rbac"
 	metadataService "minds_iolite_backend/internal/services/metadata"
 
 	"github.com/gin-gonic/gin"
@@ -21,16 +24,22 @@ import (
 )
 
 // Generator 负责为模型动态生成API
+// 读取数据需要model:<模型名>:read权限，创建、更新和删除需要model:<模型名>:write权限
+// 每次成功的创建、更新和删除都会写入审计日志
 type Generator struct {
 	db              *database.MongoDB
 	metadataService *metadataService.Service
+	access          *rbac.Service
+	auditLog        audit.Log
 }
 
 // NewGenerator 创建一个新的动态API生成器
-func NewGenerator(db *database.MongoDB, metaService *metadataService.Service) *Generator {
+func NewGenerator(db *database.MongoDB, metaService *metadataService.Service, access *rbac.Service, auditLog audit.Log) *Generator {
 	return &Generator{
 		db:              db,
 		metadataService: metaService,
+		access:          access,
+		auditLog:        auditLog,
 	}
 }
 
@@ -74,6 +83,11 @@ func (g *Generator) RegisterAllModelRoutes(router *gin.RouterGroup) error {
 
 	// 为每个模型注册路由
 	for _, model := range models {
+		// 之前创建的模型可能使用了服务自身的集合，不为其生成API
+		if database.IsReservedCollection(model.Collection) {
+			log.Printf("模型 '%s' 使用的集合 '%s' 由系统使用，跳过注册路由", model.Name, model.Collection)
+			continue
+		}
 		if err := g.RegisterModelRoutes(router, model.Name); err != nil {
 			return fmt.Errorf("注册模型 '%s' 路由失败: %w", model.Name, err)
 		}
@@ -85,6 +99,11 @@ func (g *Generator) RegisterAllModelRoutes(router *gin.RouterGroup) error {
 // createHandler 处理创建实体的请求
 func (g *Generator) createHandler(model *metadata.ModelDefinition) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 检查当前用户是否有权写入模型数据
+		if !g.access.Check(c, rbac.ModelPermission(model.Name, rbac.ActionWrite)) {
+			return
+		}
+
 		// 解析请求数据
 		var data map[string]interface{}
 		if err := c.ShouldBindJSON(&data); err != nil {
@@ -118,6 +137,14 @@ func (g *Generator) createHandler(model *metadata.ModelDefinition) gin.HandlerFu
 			data["id"] = result.InsertedID
 		}
 
+		// 记录审计日志
+		audit.Record(c, g.auditLog, &audit.Entry{
+			Action:   audit.ActionCreate,
+			Model:    model.Name,
+			RecordID: fmt.Sprint(data["id"]),
+			After:    data,
+		})
+
 		c.JSON(http.StatusCreated, data)
 	}
 }
@@ -125,6 +152,11 @@ func (g *Generator) createHandler(model *metadata.ModelDefinition) gin.HandlerFu
 // getHandler 处理获取单个实体的请求
 func (g *Generator) getHandler(model *metadata.ModelDefinition) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 检查当前用户是否有权读取模型数据
+		if !g.access.Check(c, rbac.ModelPermission(model.Name, rbac.ActionRead)) {
+			return
+		}
+
 		id := c.Param("id")
 
 		// 将字符串ID转换为ObjectID
@@ -160,6 +192,11 @@ func (g *Generator) getHandler(model *metadata.ModelDefinition) gin.HandlerFunc
 // listHandler 处理获取实体列表的请求
 func (g *Generator) listHandler(model *metadata.ModelDefinition) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 检查当前用户是否有权读取模型数据
+		if !g.access.Check(c, rbac.ModelPermission(model.Name, rbac.ActionRead)) {
+			return
+		}
+
 		// 构建查询过滤器
 		filter := bson.M{}
 
@@ -277,6 +314,11 @@ func (g *Generator) listHandler(model *metadata.ModelDefinition) gin.HandlerFunc
 // updateHandler 处理更新实体的请求
 func (g *Generator) updateHandler(model *metadata.ModelDefinition) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 检查当前用户是否有权写入模型数据
+		if !g.access.Check(c, rbac.ModelPermission(model.Name, rbac.ActionWrite)) {
+			return
+		}
+
 		id := c.Param("id")
 
 		// 将字符串ID转换为ObjectID
@@ -312,22 +354,23 @@ func (g *Generator) updateHandler(model *metadata.ModelDefinition) gin.HandlerFu
 		// 更新数据库
 		collection := g.db.Collection(model.Collection)
 
-		// 执行更新操作
-		updateResult, err := collection.UpdateOne(
+		// 执行更新操作，同时取得更新前的实体用于审计
+		var original map[string]interface{}
+		err = collection.FindOneAndUpdate(
 			context.Background(),
 			bson.M{"_id": objectID},
 			bson.M{"$set": data},
-		)
+			options.FindOneAndUpdate().SetReturnDocument(options.Before),
+		).Decode(&original)
 		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "实体不存在"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新实体失败"})
 			return
 		}
 
-		if updateResult.MatchedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "实体不存在"})
-			return
-		}
-
 		// 获取更新后的完整实体
 		var updatedEntity map[string]interface{}
 		err = collection.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&updatedEntity)
@@ -341,6 +384,18 @@ func (g *Generator) updateHandler(model *metadata.ModelDefinition) gin.HandlerFu
 			updatedEntity["id"] = oid.Hex()
 			delete(updatedEntity, "_id")
 		}
+		delete(original, "_id")
+		original["id"] = id
+
+		// 记录审计日志
+		audit.Record(c, g.auditLog, &audit.Entry{
+			Action:   audit.ActionUpdate,
+			Model:    model.Name,
+			RecordID: id,
+			Before:   original,
+			After:    updatedEntity,
+			Changes:  audit.Diff(original, updatedEntity),
+		})
 
 		c.JSON(http.StatusOK, updatedEntity)
 	}
@@ -349,6 +404,11 @@ func (g *Generator) updateHandler(model *metadata.ModelDefinition) gin.HandlerFu
 // deleteHandler 处理删除实体的请求
 func (g *Generator) deleteHandler(model *metadata.ModelDefinition) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 检查当前用户是否有权写入模型数据
+		if !g.access.Check(c, rbac.ModelPermission(model.Name, rbac.ActionWrite)) {
+			return
+		}
+
 		id := c.Param("id")
 
 		// 将字符串ID转换为ObjectID
@@ -358,18 +418,28 @@ func (g *Generator) deleteHandler(model *metadata.ModelDefinition) gin.HandlerFu
 			return
 		}
 
-		// 删除数据库中的实体
+		// 删除数据库中的实体，同时取得删除前的实体用于审计
 		collection := g.db.Collection(model.Collection)
-		deleteResult, err := collection.DeleteOne(context.Background(), bson.M{"_id": objectID})
+		var original map[string]interface{}
+		err = collection.FindOneAndDelete(context.Background(), bson.M{"_id": objectID}).Decode(&original)
 		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "实体不存在"})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除实体失败"})
 			return
 		}
+		delete(original, "_id")
+		original["id"] = id
 
-		if deleteResult.DeletedCount == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "实体不存在"})
-			return
-		}
+		// 记录审计日志
+		audit.Record(c, g.auditLog, &audit.Entry{
+			Action:   audit.ActionDelete,
+			Model:    model.Name,
+			RecordID: id,
+			Before:   original,
+		})
 
 		c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 	}
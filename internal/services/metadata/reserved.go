@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"minds_iolite_backend/internal/database"
+	"minds_iolite_backend/internal/models/metadata"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrReservedCollection 模型使用了服务自身或系统模型的集合
+var ErrReservedCollection = errors.New("集合由系统使用，不能用于模型")
+
+// checkCollection 检查普通模型使用的集合：不能为服务自身的集合（见database.ReserveCollections），也不能是其他系统模型的集合
+// 系统模型的集合由使用它的服务指定，不检查
+func (s *Service) checkCollection(model *metadata.ModelDefinition) error {
+	if model.IsSystem {
+		return nil
+	}
+	if strings.ContainsAny(model.Collection, "$\x00") {
+		return fmt.Errorf("无效的集合名称: %s", model.Collection)
+	}
+	owned, err := s.ownedBySystemModel(model.Collection)
+	if err != nil {
+		return err
+	}
+	if owned || database.IsReservedCollection(model.Collection) {
+		return fmt.Errorf("%w: %s", ErrReservedCollection, model.Collection)
+	}
+	return nil
+}
+
+// ownedBySystemModel 集合是否属于某个系统模型
+func (s *Service) ownedBySystemModel(name string) (bool, error) {
+	collection := s.db.Collection(ModelCollectionName)
+	err := collection.FindOne(context.Background(), bson.M{"is_system": true, "collection": name}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("检查集合是否属于系统模型失败: %w", err)
+	}
+	return true, nil
+}
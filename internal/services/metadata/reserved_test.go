@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"testing"
+
+	"minds_iolite_backend/internal/database"
+
+	// 使用系统集合的包在init中登记集合
+	_ "minds_iolite_backend/internal/audit"
+	_ "minds_iolite_backend/internal/auth"
+	_ "minds_iolite_backend/internal/queries"
+	_ "minds_iolite_backend/internal/quota"
+	_ "minds_iolite_backend/internal/rbac"
+	_ "minds_iolite_backend/internal/secrets"
+	_ "minds_iolite_backend/internal/session"
+)
+
+func TestReservedCollections(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"users", true},
+		{"Users", true},
+		{"revoked_tokens", true},
+		{"api_keys", true},
+		{"roles", true},
+		{"role_assignments", true},
+		{"secrets", true},
+		{"audit_log", true},
+		{"usage_counters", true},
+		{"sessions", true},
+		{"session_audit", true},
+		{"query_history", true},
+		{"saved_queries", true},
+		{"models", true},
+		{"system.views", true},
+		{"customers", false},
+		{"users_archive", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := database.IsReservedCollection(tt.name); got != tt.want {
+				t.Errorf("IsReservedCollection(%q) = %v, 期望 %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"minds_iolite_backend/internal/database"
@@ -19,6 +20,10 @@ const (
 	ModelCollectionName = "models"
 )
 
+func init() {
+	database.ReserveCollections(ModelCollectionName)
+}
+
 // Service 提供元数据管理功能
 // 这个服务负责管理模型定义及其相关数据库结构
 // 它是低代码平台的核心服务之一，使平台能够动态创建和管理数据模型
@@ -93,13 +98,19 @@ func (s *Service) CreateModel(model *metadata.ModelDefinition) error {
 	if model.Collection == "" {
 		model.Collection = model.Name
 	}
+	if err := s.checkCollection(model); err != nil {
+		return err
+	}
 
 	// 保存到数据库的模型集合中
 	collection := s.db.Collection(ModelCollectionName)
-	_, err := collection.InsertOne(context.Background(), model)
+	result, err := collection.InsertOne(context.Background(), model)
 	if err != nil {
 		return fmt.Errorf("保存模型定义失败: %w", err)
 	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		model.ID = oid
+	}
 
 	// 如果不是系统模型，创建对应的集合用于存储实际数据
 	if !model.IsSystem {
@@ -111,6 +122,26 @@ func (s *Service) CreateModel(model *metadata.ModelDefinition) error {
 	return nil
 }
 
+// EnsureSystemModel 系统模型不存在时创建其定义，已存在时保持不变
+// 系统模型的集合和索引由使用它的服务自行维护
+func (s *Service) EnsureSystemModel(model *metadata.ModelDefinition) error {
+	model.IsSystem = true
+
+	collection := s.db.Collection(ModelCollectionName)
+	err := collection.FindOne(context.Background(), bson.M{"name": model.Name}).Err()
+	if err == nil {
+		return nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return fmt.Errorf("获取模型失败: %w", err)
+	}
+
+	if err := s.CreateModel(model); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
+
 // createModelCollection 为模型创建对应的集合和索引
 // 此方法根据模型定义在MongoDB中创建实际存储数据的集合和必要的索引
 // 这使得每个模型有自己专用的数据存储结构
@@ -234,6 +265,12 @@ func (s *Service) UpdateModel(id string, updates *metadata.ModelDefinition) erro
 	if err := updates.Validate(); err != nil {
 		return err
 	}
+	if updates.Collection == "" {
+		updates.Collection = updates.Name
+	}
+	if err := s.checkCollection(updates); err != nil {
+		return err
+	}
 
 	// 保留原始的创建时间和ID
 	updates.CreatedAt = original.CreatedAt
@@ -293,7 +330,15 @@ func (s *Service) DeleteModel(id string) error {
 		return fmt.Errorf("删除模型定义失败: %w", err)
 	}
 
-	// 删除对应的集合
+	// 删除对应的集合，服务自身和系统模型的集合保留（检查之前创建的模型可能使用了这些集合）
+	owned, err := s.ownedBySystemModel(model.Collection)
+	if err != nil {
+		return err
+	}
+	if owned || database.IsReservedCollection(model.Collection) {
+		log.Printf("模型 %s 使用的集合 %s 由系统使用，只删除模型定义", model.Name, model.Collection)
+		return nil
+	}
 	if err := s.db.DropCollection(model.Collection); err != nil {
 		return fmt.Errorf("删除模型集合失败: %w", err)
 	}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditCollectionName 在MongoDB中保存数据修改记录的集合名称
+const AuditCollectionName = "session_audit"
+
+// maxMemoryAuditEntries 进程内审计日志保留的最大记录数，超出后丢弃最早的记录
+const maxMemoryAuditEntries = 10000
+
+// AuditEntry 一次通过会话修改数据的记录，写入失败或因并发修改被拒绝的操作同样会被记录
+type AuditEntry struct {
+	ID           string                 `json:"id" bson:"_id"`
+	SessionID    string                 `json:"sessionId" bson:"sessionId"`
+	Time         time.Time              `json:"time" bson:"time"`
+	Type         string                 `json:"type" bson:"type"` // 数据源类型
+	Database     string                 `json:"database,omitempty" bson:"database,omitempty"`
+	FilePath     string                 `json:"filePath,omitempty" bson:"filePath,omitempty"`
+	Table        string                 `json:"table" bson:"table"`
+	Operation    string                 `json:"operation" bson:"operation"` // insert、update或delete
+	Key          map[string]interface{} `json:"key,omitempty" bson:"key,omitempty"`
+	Before       map[string]interface{} `json:"before,omitempty" bson:"before,omitempty"` // 修改前的整行
+	After        map[string]interface{} `json:"after,omitempty" bson:"after,omitempty"`   // 修改后的整行
+	RowsAffected int64                  `json:"rowsAffected" bson:"rowsAffected"`
+	ClientIP     string                 `json:"clientIp,omitempty" bson:"clientIp,omitempty"`
+	Error        string                 `json:"error,omitempty" bson:"error,omitempty"` // 失败原因，成功时为空
+}
+
+// AuditLog 保存通过会话修改数据的记录
+type AuditLog interface {
+	// Record 保存一条记录，未设置ID和时间时自动生成
+	Record(ctx context.Context, entry *AuditEntry) error
+	// List 按时间倒序返回会话最近的记录，sessionID为空时返回所有会话的记录
+	List(ctx context.Context, sessionID string, limit int) ([]*AuditEntry, error)
+}
+
+// prepareAuditEntry 填充记录的ID和时间
+func prepareAuditEntry(entry *AuditEntry) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+}
+
+// MemoryAuditLog 进程内的审计日志，用于未配置MongoDB时，服务重启后记录会丢失
+type MemoryAuditLog struct {
+	entries []*AuditEntry
+	mutex   sync.Mutex
+}
+
+// NewMemoryAuditLog 创建进程内审计日志
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+// Record 保存一条记录
+func (l *MemoryAuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	prepareAuditEntry(entry)
+	snapshot := *entry
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, &snapshot)
+	if len(l.entries) > maxMemoryAuditEntries {
+		l.entries = append([]*AuditEntry(nil), l.entries[len(l.entries)-maxMemoryAuditEntries:]...)
+	}
+	return nil
+}
+
+// List 按时间倒序返回最近的记录
+func (l *MemoryAuditLog) List(ctx context.Context, sessionID string, limit int) ([]*AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	result := []*AuditEntry{}
+	for i := len(l.entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if sessionID == "" || l.entries[i].SessionID == sessionID {
+			snapshot := *l.entries[i]
+			result = append(result, &snapshot)
+		}
+	}
+	return result, nil
+}
+
+// MongoAuditLog 基于MongoDB的审计日志，记录不会随会话过期而删除
+type MongoAuditLog struct {
+	coll *mongo.Collection
+}
+
+// NewMongoAuditLog 创建MongoDB审计日志并确保按会话和时间查询的索引存在
+func NewMongoAuditLog(ctx context.Context, coll *mongo.Collection) (*MongoAuditLog, error) {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "sessionId", Value: 1}, {Key: "time", Value: -1}},
+		Options: options.Index().SetName("sessionId_time"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建审计日志索引失败: %w", err)
+	}
+	return &MongoAuditLog{coll: coll}, nil
+}
+
+// Record 保存一条记录
+func (l *MongoAuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	prepareAuditEntry(entry)
+	_, err := l.coll.InsertOne(ctx, entry)
+	return err
+}
+
+// List 按时间倒序返回最近的记录
+func (l *MongoAuditLog) List(ctx context.Context, sessionID string, limit int) ([]*AuditEntry, error) {
+	filter := bson.M{}
+	if sessionID != "" {
+		filter["sessionId"] = sessionID
+	}
+	cursor, err := l.coll.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	result := []*AuditEntry{}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// encryptedPrefix 加密值的前缀，用于区分密文和未加密的旧数据
+const encryptedPrefix = "enc:v1:"
+
+// Cipher 使用AES-GCM加密会话中的数据库凭据
+// 旧版本将加密后的凭据直接保存在会话文档中，现在凭据保存在凭据库，Cipher只用于读取和迁移这些会话
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher 根据配置的密钥创建加密器，密钥经SHA-256派生为256位AES密钥
+func NewCipher(secret string) (*Cipher, error) {
+	if secret == "" {
+		return nil, errors.New("会话加密密钥不能为空")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt 加密字符串，空字符串原样返回
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密Encrypt的结果，空字符串原样返回
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return "", errors.New("凭据未加密或格式无效")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("凭据格式无效: %w", err)
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("凭据格式无效")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", errors.New("解密凭据失败，请检查会话加密密钥")
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,188 @@
+package session
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// 会话事件类型
+const (
+	EventExpiring = "expiring" // 会话即将过期
+	EventRenewed  = "renewed"  // 已发出过期预警的会话被续期
+	EventExpired  = "expired"  // 会话已过期
+	EventClosed   = "closed"   // 会话被关闭
+)
+
+// watchInterval 检查会话过期时间的间隔，过期事件最多延迟这么长时间
+const watchInterval = 5 * time.Second
+
+// subscriberBuffer 每个订阅者的事件缓冲区大小，订阅者处理不及时时丢弃新事件
+const subscriberBuffer = 64
+
+// Event 会话生命周期事件
+type Event struct {
+	Type             string    `json:"type"`
+	SessionID        string    `json:"sessionId"`
+	Time             time.Time `json:"time"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RemainingSeconds int64     `json:"remainingSeconds"` // 距离过期的秒数，已过期或已关闭时为0
+	OwnerID          string    `json:"-"`                // 会话的创建者，订阅者只应收到自己有权访问的会话的事件
+}
+
+// broker 将事件分发给所有订阅者
+type broker struct {
+	subscribers map[chan Event]struct{}
+	mutex       sync.Mutex
+}
+
+// subscribe 订阅事件，返回的函数用于取消订阅
+func (b *broker) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mutex.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, ch)
+			b.mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish 发送事件，不会阻塞
+func (b *broker) publish(event Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// watchedSession 监视中的会话
+type watchedSession struct {
+	ownerID   string
+	expiresAt time.Time
+	warned    bool // 是否已针对当前的过期时间发出预警
+}
+
+// Subscribe 订阅会话事件，调用方用完后必须调用返回的函数取消订阅
+// 事件由每个实例独立检测，订阅者能收到所有实例上的会话的事件
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	return m.events.subscribe()
+}
+
+// watch 定期检查会话存储，发出过期预警、续期和过期事件，并释放已过期会话在本实例的连接
+func (m *Manager) watch() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.checkExpiry()
+		}
+	}
+}
+
+// checkExpiry 比较存储中的会话与上次检查的结果
+func (m *Manager) checkExpiry() {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	sessions, err := m.store.List(ctx)
+	if err != nil {
+		log.Printf("检查会话过期时间失败: %v", err)
+		return
+	}
+
+	now := time.Now()
+	m.watchMutex.Lock()
+	var events []Event
+	for id, state := range sessions {
+		watched, exists := m.watched[id]
+		if !exists {
+			watched = &watchedSession{ownerID: state.OwnerID, expiresAt: state.ExpiresAt}
+			m.watched[id] = watched
+		}
+		if state.ExpiresAt.After(watched.expiresAt) {
+			if watched.warned {
+				events = append(events, newEvent(EventRenewed, id, watched.ownerID, state.ExpiresAt, now))
+			}
+			watched.warned = false
+		}
+		watched.expiresAt = state.ExpiresAt
+
+		if !watched.warned && state.ExpiresAt.Sub(now) <= m.warningFor(state) {
+			watched.warned = true
+			events = append(events, newEvent(EventExpiring, id, watched.ownerID, state.ExpiresAt, now))
+		}
+	}
+
+	// 存储中已不存在的会话：过了过期时间的视为过期，否则是被其他实例关闭
+	var gone []string
+	for id, watched := range m.watched {
+		if _, exists := sessions[id]; exists {
+			continue
+		}
+		eventType := EventClosed
+		if !now.Before(watched.expiresAt) {
+			eventType = EventExpired
+		}
+		events = append(events, newEvent(eventType, id, watched.ownerID, watched.expiresAt, now))
+		gone = append(gone, id)
+		delete(m.watched, id)
+	}
+	m.watchMutex.Unlock()
+
+	for _, id := range gone {
+		m.conns.CloseSession(id)
+	}
+	for _, event := range events {
+		m.events.publish(event)
+	}
+}
+
+// warningFor 返回会话的过期预警提前量，不超过会话有效期的一半
+func (m *Manager) warningFor(state *SessionState) time.Duration {
+	warning := m.options.ExpiryWarning
+	if half := m.ttlOf(state) / 2; warning > half {
+		warning = half
+	}
+	return warning
+}
+
+// forget 会话在本实例被关闭时停止监视并立即发出关闭事件
+func (m *Manager) forget(sessionID, ownerID string) {
+	m.watchMutex.Lock()
+	watched, exists := m.watched[sessionID]
+	delete(m.watched, sessionID)
+	m.watchMutex.Unlock()
+
+	event := newEvent(EventClosed, sessionID, ownerID, time.Time{}, time.Now())
+	if exists {
+		event.ExpiresAt = watched.expiresAt
+	}
+	m.events.publish(event)
+}
+
+// newEvent 创建事件并计算剩余时间
+func newEvent(eventType, sessionID, ownerID string, expiresAt, now time.Time) Event {
+	event := Event{Type: eventType, SessionID: sessionID, Time: now, ExpiresAt: expiresAt, OwnerID: ownerID}
+	if eventType == EventExpiring || eventType == EventRenewed {
+		if remaining := expiresAt.Sub(now); remaining > 0 {
+			event.RemainingSeconds = int64(remaining.Round(time.Second) / time.Second)
+		}
+	}
+	return event
+}
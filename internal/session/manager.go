@@ -1,19 +1,96 @@
 package session
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"sync"
 	"time"
 
+	"minds_iolite_backend/internal/services/connmanager"
+
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// ErrSessionNotFound 会话不存在或已过期
+var ErrSessionNotFound = errors.New("会话不存在或已过期")
+
+// ErrInvalidTTL 请求的会话有效期超出允许范围
+var ErrInvalidTTL = errors.New("无效的会话有效期")
+
+// storeTimeout 单次读写会话存储的超时时间
+const storeTimeout = 5 * time.Second
+
+// Options 会话有效期设置
+type Options struct {
+	DefaultTTL    time.Duration // 未指定有效期时使用的有效期
+	MinTTL        time.Duration // 客户端可以请求的最短有效期
+	MaxTTL        time.Duration // 客户端可以请求的最长有效期
+	ExpiryWarning time.Duration // 过期前多久发出预警事件
+}
+
+// DefaultOptions 返回默认的会话有效期设置
+func DefaultOptions() Options {
+	return Options{
+		DefaultTTL:    30 * time.Minute,
+		MinTTL:        time.Minute,
+		MaxTTL:        8 * time.Hour,
+		ExpiryWarning: time.Minute,
+	}
+}
+
+// normalize 用默认值补全未设置的项，并保证默认有效期在允许范围内
+func (o Options) normalize() Options {
+	defaults := DefaultOptions()
+	if o.DefaultTTL <= 0 {
+		o.DefaultTTL = defaults.DefaultTTL
+	}
+	if o.MinTTL <= 0 {
+		o.MinTTL = defaults.MinTTL
+	}
+	if o.MaxTTL <= 0 {
+		o.MaxTTL = defaults.MaxTTL
+	}
+	if o.MaxTTL < o.MinTTL {
+		o.MaxTTL = o.MinTTL
+	}
+	if o.DefaultTTL < o.MinTTL {
+		o.DefaultTTL = o.MinTTL
+	}
+	if o.DefaultTTL > o.MaxTTL {
+		o.DefaultTTL = o.MaxTTL
+	}
+	if o.ExpiryWarning <= 0 {
+		o.ExpiryWarning = defaults.ExpiryWarning
+	}
+	return o
+}
+
 // SessionState 表示会话的当前状态
 type SessionState struct {
+	OwnerID     string                 `json:"ownerId,omitempty"` // 创建会话的用户ID，只有该用户和管理员可以使用会话
 	Info        ConnectionInfo         `json:"info"`
 	Connected   bool                   `json:"connected"`
 	LastActive  time.Time              `json:"lastActive"`
+	ExpiresAt   time.Time              `json:"expiresAt"`
+	TTLSeconds  int64                  `json:"ttlSeconds"`      // 会话有效期，每次使用后顺延这么长时间
+	Error       string                 `json:"error,omitempty"` // 最近一次连接失败的原因
 	Collections map[string]interface{} `json:"collections,omitempty"`
 	Tables      map[string]interface{} `json:"tables,omitempty"`
+
+	// SchemaHistory 最近几次检查到的数据源结构指纹，按时间顺序，用于计算客户端上次获取后的变化
+	SchemaHistory []SchemaSnapshot `json:"-"`
+}
+
+// SchemaSnapshot 某一时刻数据源结构的指纹
+type SchemaSnapshot struct {
+	Fingerprint string            `bson:"fingerprint"` // 由全部实体的指纹计算得到
+	Entities    map[string]string `bson:"entities"`    // 实体名 -> 按表结构或集合元数据计算的指纹
+	Time        time.Time         `bson:"time"`
 }
 
 // ConnectionInfo 表示连接信息
@@ -22,146 +99,369 @@ type ConnectionInfo struct {
 	Host     string `json:"host,omitempty"`
 	Port     int    `json:"port,omitempty"`
 	Username string `json:"username,omitempty"`
-	Password string `json:"-"` // 不在JSON中暴露密码
+	Password string `json:"-" bson:"password,omitempty"` // 不在JSON中暴露密码，持久化时只保存凭据ID
 	Database string `json:"database,omitempty"`
-	URI      string `json:"-"` // 不在JSON中暴露完整URI
+	URI      string `json:"-" bson:"uri,omitempty"` // 不在JSON中暴露完整URI，持久化时只保存凭据ID
+	FilePath string `json:"filePath,omitempty"`
+	ReadOnly bool   `json:"readOnly"` // 只读会话拒绝一切写操作
+
+	PasswordSecretID string `json:"-" bson:"passwordSecretId,omitempty"` // 密码在凭据库中的ID
+	URISecretID      string `json:"-" bson:"uriSecretId,omitempty"`      // URI在凭据库中的ID
+}
+
+// SourceKey 返回不含凭据的数据源标识，连接同一数据源的不同会话返回相同的值
+func (info ConnectionInfo) SourceKey() string {
+	switch info.Type {
+	case "sqlite", "csv":
+		return info.Type + ":" + info.FilePath
+	case "mongodb":
+		if info.URI != "" {
+			return mongoSourceKey(info.URI, info.Database)
+		}
+		return fmt.Sprintf("mongodb://%s/%s", hostPort(info.Host, info.Port, 27017), info.Database)
+	default:
+		return fmt.Sprintf("%s://%s/%s", info.Type, hostPort(info.Host, info.Port, 3306), info.Database)
+	}
+}
+
+// hostPort 返回host:port，未设置时使用localhost和默认端口
+func hostPort(host string, port, defaultPort int) string {
+	if host == "" {
+		host = "localhost"
+	}
+	if port == 0 {
+		port = defaultPort
+	}
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+// mongoSourceKey 去掉MongoDB连接字符串中的凭据和选项，只保留主机列表和数据库名
+// database为空时使用连接字符串中的默认数据库
+func mongoSourceKey(uri, database string) string {
+	scheme, rest, found := strings.Cut(uri, "://")
+	if !found {
+		scheme, rest = "mongodb", uri
+	}
+	authority, path := rest, ""
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		authority = authority[at+1:]
+	}
+	if database == "" && strings.HasPrefix(path, "/") {
+		database, _, _ = strings.Cut(path[1:], "?")
+	}
+	return scheme + "://" + authority + "/" + database
+}
+
+// Connection 会话当前持有的数据库连接
+type Connection struct {
+	DB       *sql.DB       // MySQL/SQLite连接
+	Client   *mongo.Client // MongoDB连接
+	Database string        // MongoDB数据库名
+	ReadOnly bool          // 连接是否以只读方式建立
 }
 
 // Manager 管理所有会话
+// 会话状态保存在Store中，可以被多个服务实例共享；数据库连接由本实例的connmanager持有，
+// 在其他实例创建的会话第一次使用时按保存的连接信息重新建立
 type Manager struct {
-	sessions map[string]*SessionState
-	mutex    sync.RWMutex
-	// 每隔30分钟检查一次过期会话
-	cleanupInterval time.Duration
-	// 会话超时时间为30分钟
-	sessionTimeout time.Duration
+	store   Store
+	conns   *connmanager.SessionManager
+	options Options
+
+	events     *broker
+	watched    map[string]*watchedSession
+	watchMutex sync.Mutex
+	stop       chan struct{}
+	stopOnce   sync.Once
 }
 
-// NewManager 创建新的会话管理器
-func NewManager() *Manager {
-	manager := &Manager{
-		sessions:        make(map[string]*SessionState),
-		mutex:           sync.RWMutex{},
-		cleanupInterval: 30 * time.Minute,
-		sessionTimeout:  30 * time.Minute,
+// NewManager 创建新的会话管理器并开始监视会话过期，store为nil时使用进程内存储
+func NewManager(store Store, options Options) *Manager {
+	if store == nil {
+		store = NewMemoryStore()
 	}
+	m := &Manager{
+		store:   store,
+		conns:   connmanager.GetManager(),
+		options: options.normalize(),
+		events:  &broker{subscribers: make(map[chan Event]struct{})},
+		watched: make(map[string]*watchedSession),
+		stop:    make(chan struct{}),
+	}
+	// 会话过期后连接会被立即释放，空闲回收只作为兜底，不应早于会话本身过期
+	m.conns.SetMaxIdle(m.options.MaxTTL)
+	go m.watch()
+	return m
+}
 
-	// 启动定期清理过期会话的goroutine
-	go manager.cleanup()
+// Stop 停止监视会话过期
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
 
-	return manager
+// Options 返回会话有效期设置
+func (m *Manager) Options() Options {
+	return m.options
 }
 
-// CreateSession 创建新会话
-func (m *Manager) CreateSession(info ConnectionInfo, collections, tables map[string]interface{}) string {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// ResolveTTL 校验客户端请求的有效期（秒），0表示使用默认有效期
+func (m *Manager) ResolveTTL(seconds int) (time.Duration, error) {
+	if seconds == 0 {
+		return m.options.DefaultTTL, nil
+	}
+	ttl := time.Duration(seconds) * time.Second
+	if seconds < 0 || ttl < m.options.MinTTL || ttl > m.options.MaxTTL {
+		return 0, fmt.Errorf("%w: 必须在%d到%d秒之间", ErrInvalidTTL,
+			int64(m.options.MinTTL/time.Second), int64(m.options.MaxTTL/time.Second))
+	}
+	return ttl, nil
+}
 
+// ttlOf 返回会话的有效期，旧会话没有保存有效期时使用默认值
+func (m *Manager) ttlOf(state *SessionState) time.Duration {
+	if state.TTLSeconds > 0 {
+		return time.Duration(state.TTLSeconds) * time.Second
+	}
+	return m.options.DefaultTTL
+}
+
+// CreateSession 创建新会话，ownerID为创建会话的用户ID，ttl为会话有效期，应先经ResolveTTL校验
+// 会话创建前会建立并验证数据库连接，连接失败时返回错误
+func (m *Manager) CreateSession(ownerID string, info ConnectionInfo, ttl time.Duration, collections, tables map[string]interface{}) (string, error) {
 	// 生成唯一会话ID
 	sessionID := uuid.New().String()
 
+	// 建立连接
+	if _, err := m.conns.CreateSession(sessionID, toConnManagerInfo(info)); err != nil {
+		return "", err
+	}
+
 	// 创建会话状态
+	now := time.Now()
 	state := &SessionState{
+		OwnerID:     ownerID,
 		Info:        info,
 		Connected:   true,
-		LastActive:  time.Now(),
+		LastActive:  now,
+		ExpiresAt:   now.Add(ttl),
+		TTLSeconds:  int64(ttl / time.Second),
 		Collections: collections,
 		Tables:      tables,
 	}
 
 	// 保存会话
-	m.sessions[sessionID] = state
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := m.store.Create(ctx, sessionID, state); err != nil {
+		m.conns.CloseSession(sessionID)
+		return "", fmt.Errorf("保存会话失败: %w", err)
+	}
 
-	return sessionID
+	return sessionID, nil
+}
+
+// toConnManagerInfo 将会话连接信息转换为连接管理器使用的格式
+func toConnManagerInfo(info ConnectionInfo) connmanager.ConnectionInfo {
+	result := connmanager.ConnectionInfo{
+		Type:     connmanager.ConnectionType(info.Type),
+		Host:     info.Host,
+		Username: info.Username,
+		Password: info.Password,
+		Database: info.Database,
+		FilePath: info.FilePath,
+		URI:      info.URI,
+		ReadOnly: info.ReadOnly,
+	}
+	if info.Port > 0 {
+		result.Port = info.Port
+	}
+	return result
 }
 
 // GetSession 获取特定会话状态
 func (m *Manager) GetSession(sessionID string) (*SessionState, bool) {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
 
-	state, exists := m.sessions[sessionID]
-	if !exists {
+	state, err := m.store.Load(ctx, sessionID)
+	if err != nil {
+		if !errors.Is(err, ErrSessionNotFound) {
+			log.Printf("读取会话 %s 失败: %v", sessionID, err)
+		}
 		return nil, false
 	}
+	return state, true
+}
 
-	// 检查会话是否过期
-	if time.Since(state.LastActive) > m.sessionTimeout {
-		// 会话已过期，但我们在读锁中，所以不能删除
-		// 返回nil表示不存在
-		return nil, false
+// RefreshSession 检查会话连接是否可用，连接断开时尝试重新连接
+// 会话不存在或已过期时返回ErrSessionNotFound，重新连接失败时返回连接错误
+func (m *Manager) RefreshSession(sessionID string) (*SessionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	state, err := m.store.Load(ctx, sessionID)
+	if err != nil {
+		m.expire(sessionID, err)
+		return nil, err
 	}
 
-	return state, true
+	// 本实例还没有连接时建立连接，否则ping连接，失败时重新连接
+	created, err := m.conns.EnsureSession(sessionID, toConnManagerInfo(state.Info))
+	if err == nil && !created {
+		err = m.conns.RefreshSession(sessionID)
+	}
+
+	now := time.Now()
+	state.LastActive = now
+	state.ExpiresAt = now.Add(m.ttlOf(state))
+	state.Connected = err == nil
+	state.Error = ""
+	if err != nil {
+		state.Error = err.Error()
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if updateErr := m.store.Update(ctx, sessionID, state); updateErr != nil {
+		m.expire(sessionID, updateErr)
+		return nil, updateErr
+	}
+	return state, err
 }
 
-// RefreshSession 刷新会话活动时间
-func (m *Manager) RefreshSession(sessionID string) bool {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// Health 返回会话连接在本实例的健康状态和状态变化历史，不顺延会话的过期时间
+// 本实例还没有该会话的连接时先建立连接，历史从此时开始记录
+func (m *Manager) Health(sessionID string) (*connmanager.Health, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
 
-	state, exists := m.sessions[sessionID]
-	if !exists {
-		return false
+	state, err := m.store.Load(ctx, sessionID)
+	if err != nil {
+		m.expire(sessionID, err)
+		return nil, err
+	}
+	if _, err := m.conns.EnsureSession(sessionID, toConnManagerInfo(state.Info)); err != nil {
+		return nil, err
 	}
+	return m.conns.Health(sessionID)
+}
 
-	// 检查会话是否过期
-	if time.Since(state.LastActive) > m.sessionTimeout {
-		// 会话已过期，删除它
-		delete(m.sessions, sessionID)
-		return false
+// Heartbeat 顺延会话的过期时间，不检查连接；ttl大于0时同时修改会话的有效期，应先经ResolveTTL校验
+func (m *Manager) Heartbeat(sessionID string, ttl time.Duration) (*SessionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	state, err := m.store.Load(ctx, sessionID)
+	if err != nil {
+		m.expire(sessionID, err)
+		return nil, err
 	}
+	if ttl > 0 {
+		state.TTLSeconds = int64(ttl / time.Second)
+	}
+	now := time.Now()
+	state.LastActive = now
+	state.ExpiresAt = now.Add(m.ttlOf(state))
+	if err := m.store.Update(ctx, sessionID, state); err != nil {
+		m.expire(sessionID, err)
+		return nil, err
+	}
+	return state, nil
+}
 
-	// 更新最后活动时间
-	state.LastActive = time.Now()
-	return true
+// UpdateSession 读取会话，经fn修改后保存，用于更新表结构缓存等会话数据
+func (m *Manager) UpdateSession(sessionID string, fn func(state *SessionState)) (*SessionState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	state, err := m.store.Load(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	fn(state)
+	if err := m.store.Update(ctx, sessionID, state); err != nil {
+		return nil, err
+	}
+	return state, nil
 }
 
-// CloseSession 关闭并删除会话
-func (m *Manager) CloseSession(sessionID string) bool {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+// Connection 返回会话的数据库连接并刷新会话活动时间
+// 会话由其他实例创建或本实例的连接已被回收时，按保存的连接信息重新建立连接
+func (m *Manager) Connection(sessionID string) (*Connection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
 
-	_, exists := m.sessions[sessionID]
-	if !exists {
-		return false
+	state, err := m.store.Load(ctx, sessionID)
+	if err != nil {
+		m.expire(sessionID, err)
+		return nil, err
+	}
+
+	switch state.Info.Type {
+	case "mysql", "sqlite", "mongodb":
+	default:
+		return nil, fmt.Errorf("%s 会话不支持查询", state.Info.Type)
+	}
+
+	now := time.Now()
+	if err := m.store.Touch(ctx, sessionID, now, now.Add(m.ttlOf(state))); err != nil {
+		m.expire(sessionID, err)
+		return nil, err
 	}
 
-	// 删除会话
-	delete(m.sessions, sessionID)
-	return true
+	if _, err := m.conns.EnsureSession(sessionID, toConnManagerInfo(state.Info)); err != nil {
+		return nil, err
+	}
+	client, db, err := m.conns.Handles(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{DB: db, Client: client, Database: state.Info.Database, ReadOnly: state.Info.ReadOnly}, nil
 }
 
-// GetAllSessions 获取所有活动会话
-func (m *Manager) GetAllSessions() map[string]*SessionState {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
-
-	// 创建一个副本以避免并发访问问题
-	result := make(map[string]*SessionState)
-	for id, state := range m.sessions {
-		// 只返回未过期的会话
-		if time.Since(state.LastActive) <= m.sessionTimeout {
-			result[id] = state
-		}
+// expire 会话在存储中已不存在时释放本实例持有的连接
+func (m *Manager) expire(sessionID string, err error) {
+	if errors.Is(err, ErrSessionNotFound) {
+		go m.conns.CloseSession(sessionID)
 	}
+}
 
-	return result
+// CloseSession 关闭并删除会话
+func (m *Manager) CloseSession(sessionID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	// 关闭事件只发给会话的创建者，删除前读取创建者
+	ownerID := ""
+	if state, err := m.store.Load(ctx, sessionID); err == nil {
+		ownerID = state.OwnerID
+	}
+
+	// 删除会话并断开连接
+	existed, err := m.store.Delete(ctx, sessionID)
+	if err != nil {
+		log.Printf("删除会话 %s 失败: %v", sessionID, err)
+		return false
+	}
+	go m.conns.CloseSession(sessionID)
+	if existed {
+		m.forget(sessionID, ownerID)
+	}
+	return existed
 }
 
-// cleanup 定期清理过期会话
-func (m *Manager) cleanup() {
-	ticker := time.NewTicker(m.cleanupInterval)
-	defer ticker.Stop()
+// GetAllSessions 获取所有活动会话
+func (m *Manager) GetAllSessions() map[string]*SessionState {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
 
-	for range ticker.C {
-		m.mutex.Lock()
-		now := time.Now()
-		for id, state := range m.sessions {
-			if now.Sub(state.LastActive) > m.sessionTimeout {
-				delete(m.sessions, id)
-			}
-		}
-		m.mutex.Unlock()
+	sessions, err := m.store.List(ctx)
+	if err != nil {
+		log.Printf("读取会话列表失败: %v", err)
+		return map[string]*SessionState{}
 	}
+	return sessions
 }
@@ -0,0 +1,398 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"minds_iolite_backend/internal/database"
+	"minds_iolite_backend/internal/secrets"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName 在MongoDB中保存会话的集合名称
+const CollectionName = "sessions"
+
+func init() {
+	database.ReserveCollections(CollectionName, AuditCollectionName)
+}
+
+// Store 持久化会话状态
+// 过期的会话对Load/List不可见，由存储自身负责删除（MongoDB通过TTL索引）
+type Store interface {
+	// Create 保存新会话
+	Create(ctx context.Context, id string, state *SessionState) error
+	// Update 覆盖已有会话，会话不存在或已过期时返回ErrSessionNotFound
+	Update(ctx context.Context, id string, state *SessionState) error
+	// Touch 更新会话的活动时间和过期时间，会话不存在或已过期时返回ErrSessionNotFound
+	Touch(ctx context.Context, id string, lastActive, expiresAt time.Time) error
+	// Load 读取会话，会话不存在或已过期时返回ErrSessionNotFound
+	Load(ctx context.Context, id string) (*SessionState, error)
+	// Delete 删除会话，返回会话是否存在
+	Delete(ctx context.Context, id string) (bool, error)
+	// List 返回所有未过期的会话
+	List(ctx context.Context) (map[string]*SessionState, error)
+}
+
+// MemoryStore 进程内的会话存储，用于单实例部署和未配置MongoDB时
+type MemoryStore struct {
+	sessions map[string]*SessionState
+	mutex    sync.Mutex
+}
+
+// NewMemoryStore 创建进程内会话存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*SessionState)}
+}
+
+// Create 保存新会话
+func (s *MemoryStore) Create(ctx context.Context, id string, state *SessionState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := *state
+	s.sessions[id] = &snapshot
+	return nil
+}
+
+// Update 覆盖已有会话
+func (s *MemoryStore) Update(ctx context.Context, id string, state *SessionState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.live(id); !ok {
+		return ErrSessionNotFound
+	}
+	snapshot := *state
+	s.sessions[id] = &snapshot
+	return nil
+}
+
+// Touch 更新会话的活动时间和过期时间
+func (s *MemoryStore) Touch(ctx context.Context, id string, lastActive, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, ok := s.live(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	state.LastActive = lastActive
+	state.ExpiresAt = expiresAt
+	return nil
+}
+
+// Load 读取会话
+func (s *MemoryStore) Load(ctx context.Context, id string) (*SessionState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, ok := s.live(id)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	snapshot := *state
+	return &snapshot, nil
+}
+
+// Delete 删除会话
+func (s *MemoryStore) Delete(ctx context.Context, id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.live(id)
+	delete(s.sessions, id)
+	return ok, nil
+}
+
+// List 返回所有未过期的会话
+func (s *MemoryStore) List(ctx context.Context) (map[string]*SessionState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make(map[string]*SessionState)
+	for id := range s.sessions {
+		if state, ok := s.live(id); ok {
+			snapshot := *state
+			result[id] = &snapshot
+		}
+	}
+	return result, nil
+}
+
+// live 返回未过期的会话，顺带删除已过期的会话，调用方需持有写锁
+func (s *MemoryStore) live(id string) (*SessionState, bool) {
+	state, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(state.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	return state, true
+}
+
+// sessionDocument 会话在MongoDB中的文档结构，密码和URI保存在凭据库中，文档只保存凭据ID
+type sessionDocument struct {
+	ID          string                 `bson:"_id"`
+	OwnerID     string                 `bson:"ownerId,omitempty"`
+	Info        ConnectionInfo         `bson:"info"`
+	Connected   bool                   `bson:"connected"`
+	LastActive  time.Time              `bson:"lastActive"`
+	ExpiresAt   time.Time              `bson:"expiresAt"`
+	TTLSeconds  int64                  `bson:"ttlSeconds,omitempty"`
+	Error       string                 `bson:"error,omitempty"`
+	Collections map[string]interface{} `bson:"collections,omitempty"`
+	Tables      map[string]interface{} `bson:"tables,omitempty"`
+
+	SchemaHistory []SchemaSnapshot `bson:"schemaHistory,omitempty"`
+}
+
+// MongoStore 基于MongoDB的会话存储，多个服务实例可以共享同一集合
+// expiresAt上的TTL索引负责删除过期会话；TTL任务约每分钟运行一次，因此读取时仍会按expiresAt过滤
+// 会话的凭据与会话同时过期，会话顺延时凭据的过期时间一并顺延
+type MongoStore struct {
+	coll   *mongo.Collection
+	vault  *secrets.Vault
+	legacy *Cipher // 解密旧版本直接保存在会话文档中的凭据，为nil时无法读取这些会话
+}
+
+// NewMongoStore 创建MongoDB会话存储并确保TTL索引存在
+func NewMongoStore(ctx context.Context, coll *mongo.Collection, vault *secrets.Vault, legacy *Cipher) (*MongoStore, error) {
+	if vault == nil {
+		return nil, errors.New("MongoDB会话存储需要凭据库")
+	}
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建会话TTL索引失败: %w", err)
+	}
+	return &MongoStore{coll: coll, vault: vault, legacy: legacy}, nil
+}
+
+// Create 保存新会话
+func (s *MongoStore) Create(ctx context.Context, id string, state *SessionState) error {
+	doc, err := s.encode(ctx, id, state)
+	if err != nil {
+		return err
+	}
+	_, err = s.coll.InsertOne(ctx, doc)
+	return err
+}
+
+// Update 覆盖已有会话
+func (s *MongoStore) Update(ctx context.Context, id string, state *SessionState) error {
+	doc, err := s.encode(ctx, id, state)
+	if err != nil {
+		return err
+	}
+	result, err := s.coll.ReplaceOne(ctx, liveFilter(id), doc)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// credentialProjection 只读取会话的凭据ID
+var credentialProjection = bson.M{"info.passwordSecretId": 1, "info.uriSecretId": 1}
+
+// Touch 更新会话的活动时间和过期时间，并顺延会话凭据的过期时间
+func (s *MongoStore) Touch(ctx context.Context, id string, lastActive, expiresAt time.Time) error {
+	var doc sessionDocument
+	err := s.coll.FindOneAndUpdate(ctx, liveFilter(id), bson.M{
+		"$set": bson.M{"lastActive": lastActive, "expiresAt": expiresAt},
+	}, options.FindOneAndUpdate().SetProjection(credentialProjection)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.vault.Extend(ctx, []string{doc.Info.PasswordSecretID, doc.Info.URISecretID}, expiresAt)
+}
+
+// Load 读取会话
+func (s *MongoStore) Load(ctx context.Context, id string) (*SessionState, error) {
+	var doc sessionDocument
+	err := s.coll.FindOne(ctx, liveFilter(id)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(ctx, &doc)
+}
+
+// Delete 删除会话及其凭据
+func (s *MongoStore) Delete(ctx context.Context, id string) (bool, error) {
+	var doc sessionDocument
+	err := s.coll.FindOneAndDelete(ctx, liveFilter(id),
+		options.FindOneAndDelete().SetProjection(credentialProjection)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// 删除失败的凭据会在会话原定的过期时间被删除
+	if err := s.vault.Delete(ctx, doc.Info.PasswordSecretID, doc.Info.URISecretID); err != nil {
+		log.Printf("删除会话 %s 的凭据失败: %v", id, err)
+	}
+	return true, nil
+}
+
+// List 返回所有未过期的会话
+func (s *MongoStore) List(ctx context.Context) (map[string]*SessionState, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{"expiresAt": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// 单个会话无法读取时跳过并记录日志，不影响其他会话的列表和过期检查
+	result := make(map[string]*SessionState)
+	for cursor.Next(ctx) {
+		var doc sessionDocument
+		if err := cursor.Decode(&doc); err != nil {
+			log.Printf("跳过无法解析的会话文档 %v: %v", cursor.Current.Lookup("_id"), err)
+			continue
+		}
+		state, err := s.decode(ctx, &doc)
+		if err != nil {
+			log.Printf("跳过无法读取的会话 %s: %v", doc.ID, err)
+			continue
+		}
+		result[doc.ID] = state
+	}
+	return result, cursor.Err()
+}
+
+// MigrateCredentials 将旧版本直接加密保存在会话文档中的凭据移入凭据库，返回迁移的会话数
+func (s *MongoStore) MigrateCredentials(ctx context.Context) (int, error) {
+	cursor, err := s.coll.Find(ctx, bson.M{
+		"expiresAt": bson.M{"$gt": time.Now()},
+		"$or": bson.A{
+			bson.M{"info.password": bson.M{"$nin": bson.A{nil, ""}}},
+			bson.M{"info.uri": bson.M{"$nin": bson.A{nil, ""}}},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc sessionDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return migrated, err
+		}
+		state, err := s.decode(ctx, &doc)
+		if err != nil {
+			return migrated, fmt.Errorf("读取会话 %s 失败: %w", doc.ID, err)
+		}
+		if err := s.Update(ctx, doc.ID, state); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return migrated, fmt.Errorf("迁移会话 %s 的凭据失败: %w", doc.ID, err)
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
+
+// liveFilter 匹配指定ID且未过期的会话
+func liveFilter(id string) bson.M {
+	return bson.M{"_id": id, "expiresAt": bson.M{"$gt": time.Now()}}
+}
+
+// encode 将会话状态转换为文档，密码和URI保存到凭据库，文档中只保留凭据ID
+// 新保存的凭据ID同时写回state，之后的更新复用同一凭据
+func (s *MongoStore) encode(ctx context.Context, id string, state *SessionState) (*sessionDocument, error) {
+	var err error
+	if state.Info.PasswordSecretID, err = s.storeCredential(ctx, state.Info.Password, state.Info.PasswordSecretID, state.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("保存会话凭据失败: %w", err)
+	}
+	if state.Info.URISecretID, err = s.storeCredential(ctx, state.Info.URI, state.Info.URISecretID, state.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("保存会话凭据失败: %w", err)
+	}
+	info := state.Info
+	info.Password = ""
+	info.URI = ""
+	return &sessionDocument{
+		ID:          id,
+		OwnerID:     state.OwnerID,
+		Info:        info,
+		Connected:   state.Connected,
+		LastActive:  state.LastActive,
+		ExpiresAt:   state.ExpiresAt,
+		TTLSeconds:  state.TTLSeconds,
+		Error:       state.Error,
+		Collections: state.Collections,
+		Tables:      state.Tables,
+
+		SchemaHistory: state.SchemaHistory,
+	}, nil
+}
+
+// storeCredential 返回凭据ID：已保存的凭据顺延到会话的过期时间，否则保存为新凭据
+func (s *MongoStore) storeCredential(ctx context.Context, value, secretID string, expiresAt time.Time) (string, error) {
+	if secretID != "" {
+		return secretID, s.vault.Extend(ctx, []string{secretID}, expiresAt)
+	}
+	return s.vault.Put(ctx, value, expiresAt)
+}
+
+// decode 将文档转换为会话状态，并从凭据库读取密码和URI
+func (s *MongoStore) decode(ctx context.Context, doc *sessionDocument) (*SessionState, error) {
+	info := doc.Info
+	var err error
+	if info.Password, err = s.loadCredential(ctx, doc.ID, info.Password, info.PasswordSecretID); err != nil {
+		return nil, err
+	}
+	if info.URI, err = s.loadCredential(ctx, doc.ID, info.URI, info.URISecretID); err != nil {
+		return nil, err
+	}
+	return &SessionState{
+		OwnerID:     doc.OwnerID,
+		Info:        info,
+		Connected:   doc.Connected,
+		LastActive:  doc.LastActive,
+		ExpiresAt:   doc.ExpiresAt,
+		TTLSeconds:  doc.TTLSeconds,
+		Error:       doc.Error,
+		Collections: doc.Collections,
+		Tables:      doc.Tables,
+
+		SchemaHistory: doc.SchemaHistory,
+	}, nil
+}
+
+// loadCredential 读取凭据明文，没有凭据ID时按旧版本格式解密文档中的值
+func (s *MongoStore) loadCredential(ctx context.Context, sessionID, stored, secretID string) (string, error) {
+	if secretID != "" {
+		value, err := s.vault.Get(ctx, secretID)
+		if err != nil {
+			return "", fmt.Errorf("读取会话 %s 的凭据失败: %w", sessionID, err)
+		}
+		return value, nil
+	}
+	if stored == "" {
+		return "", nil
+	}
+	if s.legacy == nil {
+		return "", fmt.Errorf("会话 %s 的凭据使用旧版本格式保存，需要配置 session.secret_key 才能读取", sessionID)
+	}
+	return s.legacy.Decrypt(stored)
+}